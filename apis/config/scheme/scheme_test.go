@@ -94,6 +94,8 @@ profiles:
 							Name: coscheduling.Name,
 							Args: &config.CoschedulingArgs{
 								PermitWaitingTimeSeconds: 60,
+								QueueSortPolicy:          config.CreationTimeQueueSort,
+								QueueSortTenant:          config.NamespaceQueueSortTenant,
 							},
 						},
 						{
@@ -362,9 +364,12 @@ profiles:
 - pluginConfig:
   - args:
       apiVersion: kubescheduler.config.k8s.io/v1
+      enablePlacementSimulation: false
+      enablePreemption: false
       kind: CoschedulingArgs
       permitWaitingTimeSeconds: 10
       podGroupBackoffSeconds: 0
+      podGroupMaxBackoffSeconds: 0
     name: Coscheduling
   - args:
       apiVersion: kubescheduler.config.k8s.io/v1
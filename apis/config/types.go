@@ -31,9 +31,50 @@ type CoschedulingArgs struct {
 	// PermitWaitingTimeSeconds is the waiting timeout in seconds.
 	PermitWaitingTimeSeconds int64
 	// PodGroupBackoffSeconds is the backoff time in seconds before a pod group can be scheduled again.
+	// It's the initial backoff, doubled every consecutive time the pod group fails to be scheduled.
 	PodGroupBackoffSeconds int64
+	// PodGroupMaxBackoffSeconds is the maximum backoff time in seconds of a pod group.
+	// The backoff doesn't grow if it's not greater than PodGroupBackoffSeconds.
+	PodGroupMaxBackoffSeconds int64
+	// EnablePreemption enables PodGroup-aware preemption in PostFilter. Victims are only
+	// evicted if all the missing members of the PodGroup fit after preemption.
+	EnablePreemption bool
+	// EnablePlacementSimulation enables a bin-packing simulation in PreFilter that places the request
+	// of each pending member of a PodGroup onto the nodes before the PodGroup is admitted.
+	EnablePlacementSimulation bool
+	// QueueSortPolicy is the policy to order the PodGroups of the same priority in the scheduling queue.
+	// An empty policy stands for CreationTime.
+	QueueSortPolicy QueueSortPolicy
+	// QueueSortTenant is what the PodGroups are grouped by under a fair queue sort policy.
+	// An empty tenant stands for Namespace.
+	QueueSortTenant QueueSortTenant
+	// QueueSortWeights are the weights of the tenants under the WeightedFairShare queue sort policy.
+	// Tenants that are not listed have a weight of 1.
+	QueueSortWeights map[string]int64
 }
 
+// QueueSortPolicy is a "string" type.
+type QueueSortPolicy string
+
+const (
+	// CreationTimeQueueSort orders the PodGroups by their creation time.
+	CreationTimeQueueSort QueueSortPolicy = "CreationTime"
+	// RoundRobinQueueSort interleaves the PodGroups of different tenants one by one.
+	RoundRobinQueueSort QueueSortPolicy = "RoundRobin"
+	// WeightedFairShareQueueSort interleaves the PodGroups of different tenants in proportion to their weights.
+	WeightedFairShareQueueSort QueueSortPolicy = "WeightedFairShare"
+)
+
+// QueueSortTenant is a "string" type.
+type QueueSortTenant string
+
+const (
+	// NamespaceQueueSortTenant groups the PodGroups by their namespace.
+	NamespaceQueueSortTenant QueueSortTenant = "Namespace"
+	// QueueNameQueueSortTenant groups the PodGroups by the value of their queue name label.
+	QueueNameQueueSortTenant QueueSortTenant = "QueueName"
+)
+
 // ModeType is a "string" type.
 type ModeType string
 
@@ -265,3 +306,18 @@ type SySchedArgs struct {
 	// CR name of the default profile for all system calls
 	DefaultProfileName string
 }
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CapacitySchedulingArgs defines the parameters for CapacityScheduling plugin.
+type CapacitySchedulingArgs struct {
+	metav1.TypeMeta
+
+	// ReclaimGracePeriodSeconds is the time a pod of an ElasticQuota reclaiming its Min must have
+	// been unschedulable for before the pods borrowing resources from it are preempted.
+	ReclaimGracePeriodSeconds int64
+
+	// PreferRecentBorrowers, if true, makes the pods of the borrowing ElasticQuotas that started
+	// most recently, whatever their priority, preempted first when resources are reclaimed.
+	PreferRecentBorrowers bool
+}
@@ -27,8 +27,13 @@ import (
 )
 
 var (
-	defaultPermitWaitingTimeSeconds int64 = 60
-	defaultPodGroupBackoffSeconds   int64 = 0
+	defaultPermitWaitingTimeSeconds  int64 = 60
+	defaultPodGroupBackoffSeconds    int64 = 0
+	defaultPodGroupMaxBackoffSeconds int64 = 0
+	defaultEnablePreemption                = false
+	defaultEnablePlacementSimulation       = false
+	defaultQueueSortPolicy                 = CreationTimeQueueSort
+	defaultQueueSortTenant                 = NamespaceQueueSortTenant
 
 	defaultNodeResourcesAllocatableMode = Least
 
@@ -102,6 +107,12 @@ var (
 	DefaultSySchedProfileNamespace = "default"
 	// DefaultSySchedProfileName is the name of the default syscall profile CR for SySched plugin
 	DefaultSySchedProfileName = "all-syscalls"
+
+	// Defaults for CapacityScheduling
+	// DefaultReclaimGracePeriodSeconds is the default grace period before borrowed resources are reclaimed
+	DefaultReclaimGracePeriodSeconds int64 = 0
+	// DefaultPreferRecentBorrowers is the default preference for the pods that borrowed most recently as victims
+	DefaultPreferRecentBorrowers = false
 )
 
 // SetDefaults_CoschedulingArgs sets the default parameters for Coscheduling plugin.
@@ -112,6 +123,21 @@ func SetDefaults_CoschedulingArgs(obj *CoschedulingArgs) {
 	if obj.PodGroupBackoffSeconds == nil {
 		obj.PodGroupBackoffSeconds = &defaultPodGroupBackoffSeconds
 	}
+	if obj.PodGroupMaxBackoffSeconds == nil {
+		obj.PodGroupMaxBackoffSeconds = &defaultPodGroupMaxBackoffSeconds
+	}
+	if obj.EnablePreemption == nil {
+		obj.EnablePreemption = &defaultEnablePreemption
+	}
+	if obj.EnablePlacementSimulation == nil {
+		obj.EnablePlacementSimulation = &defaultEnablePlacementSimulation
+	}
+	if obj.QueueSortPolicy == "" {
+		obj.QueueSortPolicy = defaultQueueSortPolicy
+	}
+	if obj.QueueSortTenant == "" {
+		obj.QueueSortTenant = defaultQueueSortTenant
+	}
 }
 
 // SetDefaults_NodeResourcesAllocatableArgs sets the defaults parameters for NodeResourceAllocatable.
@@ -250,3 +276,14 @@ func SetDefaults_SySchedArgs(obj *SySchedArgs) {
 		obj.DefaultProfileName = &DefaultSySchedProfileName
 	}
 }
+
+// SetDefaults_CapacitySchedulingArgs sets the default parameters for CapacityScheduling plugin.
+func SetDefaults_CapacitySchedulingArgs(obj *CapacitySchedulingArgs) {
+	if obj.ReclaimGracePeriodSeconds == nil {
+		obj.ReclaimGracePeriodSeconds = &DefaultReclaimGracePeriodSeconds
+	}
+
+	if obj.PreferRecentBorrowers == nil {
+		obj.PreferRecentBorrowers = &DefaultPreferRecentBorrowers
+	}
+}
@@ -39,19 +39,36 @@ func TestSchedulingDefaults(t *testing.T) {
 			name:   "empty config CoschedulingArgs",
 			config: &CoschedulingArgs{},
 			expect: &CoschedulingArgs{
-				PermitWaitingTimeSeconds: pointer.Int64Ptr(60),
-				PodGroupBackoffSeconds:   pointer.Int64Ptr(0),
+				PermitWaitingTimeSeconds:  pointer.Int64Ptr(60),
+				PodGroupBackoffSeconds:    pointer.Int64Ptr(0),
+				PodGroupMaxBackoffSeconds: pointer.Int64Ptr(0),
+				EnablePreemption:          pointer.Bool(false),
+				EnablePlacementSimulation: pointer.Bool(false),
+				QueueSortPolicy:           CreationTimeQueueSort,
+				QueueSortTenant:           NamespaceQueueSortTenant,
 			},
 		},
 		{
 			name: "set non default CoschedulingArgs",
 			config: &CoschedulingArgs{
-				PermitWaitingTimeSeconds: pointer.Int64Ptr(60),
-				PodGroupBackoffSeconds:   pointer.Int64Ptr(20),
+				PermitWaitingTimeSeconds:  pointer.Int64Ptr(60),
+				PodGroupBackoffSeconds:    pointer.Int64Ptr(20),
+				PodGroupMaxBackoffSeconds: pointer.Int64Ptr(300),
+				EnablePreemption:          pointer.Bool(true),
+				EnablePlacementSimulation: pointer.Bool(true),
+				QueueSortPolicy:           WeightedFairShareQueueSort,
+				QueueSortTenant:           QueueNameQueueSortTenant,
+				QueueSortWeights:          map[string]int64{"prod": 3},
 			},
 			expect: &CoschedulingArgs{
-				PermitWaitingTimeSeconds: pointer.Int64Ptr(60),
-				PodGroupBackoffSeconds:   pointer.Int64Ptr(20),
+				PermitWaitingTimeSeconds:  pointer.Int64Ptr(60),
+				PodGroupBackoffSeconds:    pointer.Int64Ptr(20),
+				PodGroupMaxBackoffSeconds: pointer.Int64Ptr(300),
+				EnablePreemption:          pointer.Bool(true),
+				EnablePlacementSimulation: pointer.Bool(true),
+				QueueSortPolicy:           WeightedFairShareQueueSort,
+				QueueSortTenant:           QueueNameQueueSortTenant,
+				QueueSortWeights:          map[string]int64{"prod": 3},
 			},
 		},
 		{
@@ -274,6 +291,25 @@ func TestSchedulingDefaults(t *testing.T) {
 				DefaultProfileName:      pointer.StringPtr("all-syscalls"),
 			},
 		},
+		{
+			name:   "empty config CapacitySchedulingArgs",
+			config: &CapacitySchedulingArgs{},
+			expect: &CapacitySchedulingArgs{
+				ReclaimGracePeriodSeconds: pointer.Int64(0),
+				PreferRecentBorrowers:     pointer.Bool(false),
+			},
+		},
+		{
+			name: "set non default CapacitySchedulingArgs",
+			config: &CapacitySchedulingArgs{
+				ReclaimGracePeriodSeconds: pointer.Int64(300),
+				PreferRecentBorrowers:     pointer.Bool(true),
+			},
+			expect: &CapacitySchedulingArgs{
+				ReclaimGracePeriodSeconds: pointer.Int64(300),
+				PreferRecentBorrowers:     pointer.Bool(true),
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -31,9 +31,50 @@ type CoschedulingArgs struct {
 	// PermitWaitingTimeSeconds is the waiting timeout in seconds.
 	PermitWaitingTimeSeconds *int64 `json:"permitWaitingTimeSeconds,omitempty"`
 	// PodGroupBackoffSeconds is the backoff time in seconds before a pod group can be scheduled again.
+	// It's the initial backoff, doubled every consecutive time the pod group fails to be scheduled.
 	PodGroupBackoffSeconds *int64 `json:"podGroupBackoffSeconds,omitempty"`
+	// PodGroupMaxBackoffSeconds is the maximum backoff time in seconds of a pod group.
+	// The backoff doesn't grow if it's not greater than PodGroupBackoffSeconds.
+	PodGroupMaxBackoffSeconds *int64 `json:"podGroupMaxBackoffSeconds,omitempty"`
+	// EnablePreemption enables PodGroup-aware preemption in PostFilter. Victims are only
+	// evicted if all the missing members of the PodGroup fit after preemption.
+	EnablePreemption *bool `json:"enablePreemption,omitempty"`
+	// EnablePlacementSimulation enables a bin-packing simulation in PreFilter that places the request
+	// of each pending member of a PodGroup onto the nodes before the PodGroup is admitted.
+	EnablePlacementSimulation *bool `json:"enablePlacementSimulation,omitempty"`
+	// QueueSortPolicy is the policy to order the PodGroups of the same priority in the scheduling queue:
+	// CreationTime (default), RoundRobin or WeightedFairShare.
+	QueueSortPolicy QueueSortPolicy `json:"queueSortPolicy,omitempty"`
+	// QueueSortTenant is what the PodGroups are grouped by under a fair queue sort policy:
+	// Namespace (default) or QueueName.
+	QueueSortTenant QueueSortTenant `json:"queueSortTenant,omitempty"`
+	// QueueSortWeights are the weights of the tenants under the WeightedFairShare queue sort policy.
+	// Tenants that are not listed have a weight of 1.
+	QueueSortWeights map[string]int64 `json:"queueSortWeights,omitempty"`
 }
 
+// QueueSortPolicy is a type "string".
+type QueueSortPolicy string
+
+const (
+	// CreationTimeQueueSort orders the PodGroups by their creation time.
+	CreationTimeQueueSort QueueSortPolicy = "CreationTime"
+	// RoundRobinQueueSort interleaves the PodGroups of different tenants one by one.
+	RoundRobinQueueSort QueueSortPolicy = "RoundRobin"
+	// WeightedFairShareQueueSort interleaves the PodGroups of different tenants in proportion to their weights.
+	WeightedFairShareQueueSort QueueSortPolicy = "WeightedFairShare"
+)
+
+// QueueSortTenant is a type "string".
+type QueueSortTenant string
+
+const (
+	// NamespaceQueueSortTenant groups the PodGroups by their namespace.
+	NamespaceQueueSortTenant QueueSortTenant = "Namespace"
+	// QueueNameQueueSortTenant groups the PodGroups by the value of their queue name label.
+	QueueNameQueueSortTenant QueueSortTenant = "QueueName"
+)
+
 // ModeType is a type "string".
 type ModeType string
 
@@ -263,3 +304,20 @@ type SySchedArgs struct {
 	// CR name of the default profile for all system calls
 	DefaultProfileName *string `json:"defaultProfileName,omitempty"`
 }
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CapacitySchedulingArgs defines the parameters for CapacityScheduling plugin.
+type CapacitySchedulingArgs struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ReclaimGracePeriodSeconds is the time a pod of an ElasticQuota reclaiming its Min must have
+	// been unschedulable for before the pods borrowing resources from it are preempted.
+	// Defaults to 0, i.e. borrowed resources are reclaimed immediately.
+	ReclaimGracePeriodSeconds *int64 `json:"reclaimGracePeriodSeconds,omitempty"`
+
+	// PreferRecentBorrowers, if true, makes the pods of the borrowing ElasticQuotas that started
+	// most recently, whatever their priority, preempted first when resources are reclaimed.
+	// Defaults to false, i.e. the pods with the lowest priority are preempted first.
+	PreferRecentBorrowers *bool `json:"preferRecentBorrowers,omitempty"`
+}
@@ -27,6 +27,41 @@ import (
 	configv1 "k8s.io/kube-scheduler/config/v1"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacitySchedulingArgs) DeepCopyInto(out *CapacitySchedulingArgs) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	if in.ReclaimGracePeriodSeconds != nil {
+		in, out := &in.ReclaimGracePeriodSeconds, &out.ReclaimGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.PreferRecentBorrowers != nil {
+		in, out := &in.PreferRecentBorrowers, &out.PreferRecentBorrowers
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacitySchedulingArgs.
+func (in *CapacitySchedulingArgs) DeepCopy() *CapacitySchedulingArgs {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacitySchedulingArgs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CapacitySchedulingArgs) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CoschedulingArgs) DeepCopyInto(out *CoschedulingArgs) {
 	*out = *in
@@ -41,6 +76,28 @@ func (in *CoschedulingArgs) DeepCopyInto(out *CoschedulingArgs) {
 		*out = new(int64)
 		**out = **in
 	}
+	if in.PodGroupMaxBackoffSeconds != nil {
+		in, out := &in.PodGroupMaxBackoffSeconds, &out.PodGroupMaxBackoffSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.EnablePreemption != nil {
+		in, out := &in.EnablePreemption, &out.EnablePreemption
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnablePlacementSimulation != nil {
+		in, out := &in.EnablePlacementSimulation, &out.EnablePlacementSimulation
+		*out = new(bool)
+		**out = **in
+	}
+	if in.QueueSortWeights != nil {
+		in, out := &in.QueueSortWeights, &out.QueueSortWeights
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
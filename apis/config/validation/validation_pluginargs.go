@@ -30,6 +30,39 @@ var validScoringStrategy = sets.NewString(
 	string(config.LeastNUMANodes),
 )
 
+var validQueueSortPolicy = sets.NewString(
+	string(config.CreationTimeQueueSort),
+	string(config.RoundRobinQueueSort),
+	string(config.WeightedFairShareQueueSort),
+)
+
+var validQueueSortTenant = sets.NewString(
+	string(config.NamespaceQueueSortTenant),
+	string(config.QueueNameQueueSortTenant),
+)
+
+// ValidateCoschedulingArgs validates the args of the Coscheduling plugin. The args built in code are not
+// defaulted, so an empty QueueSortPolicy or QueueSortTenant is accepted, and stands for CreationTime or Namespace.
+func ValidateCoschedulingArgs(path *field.Path, args *config.CoschedulingArgs) error {
+	var allErrs field.ErrorList
+	if args.QueueSortPolicy != "" && !validQueueSortPolicy.Has(string(args.QueueSortPolicy)) {
+		allErrs = append(allErrs, field.NotSupported(path.Child("queueSortPolicy"), args.QueueSortPolicy, validQueueSortPolicy.List()))
+	}
+	if args.QueueSortTenant != "" && !validQueueSortTenant.Has(string(args.QueueSortTenant)) {
+		allErrs = append(allErrs, field.NotSupported(path.Child("queueSortTenant"), args.QueueSortTenant, validQueueSortTenant.List()))
+	}
+	if args.PodGroupMaxBackoffSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("podGroupMaxBackoffSeconds"), args.PodGroupMaxBackoffSeconds, "must be greater than or equal to 0"))
+	}
+	for tenant, weight := range args.QueueSortWeights {
+		if weight <= 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("queueSortWeights").Key(tenant), weight, "must be greater than 0"))
+		}
+	}
+
+	return allErrs.ToAggregate()
+}
+
 func ValidateNodeResourceTopologyMatchArgs(path *field.Path, args *config.NodeResourceTopologyMatchArgs) error {
 	var allErrs field.ErrorList
 	scoringStrategyTypePath := path.Child("scoringStrategy.type")
@@ -40,6 +73,15 @@ func ValidateNodeResourceTopologyMatchArgs(path *field.Path, args *config.NodeRe
 	return allErrs.ToAggregate()
 }
 
+func ValidateCapacitySchedulingArgs(path *field.Path, args *config.CapacitySchedulingArgs) error {
+	var allErrs field.ErrorList
+	if args.ReclaimGracePeriodSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("reclaimGracePeriodSeconds"), args.ReclaimGracePeriodSeconds, "must be greater than or equal to 0"))
+	}
+
+	return allErrs.ToAggregate()
+}
+
 func validateScoringStrategyType(scoringStrategy config.ScoringStrategyType, path *field.Path) *field.Error {
 	if !validScoringStrategy.Has(string(scoringStrategy)) {
 		return field.Invalid(path, scoringStrategy, "invalid ScoringStrategyType")
@@ -24,6 +24,81 @@ import (
 	"sigs.k8s.io/scheduler-plugins/apis/config"
 )
 
+func TestValidateCoschedulingArgs(t *testing.T) {
+	testCases := []struct {
+		args        *config.CoschedulingArgs
+		expectedErr error
+		description string
+	}{
+		{
+			description: "correct config",
+			args: &config.CoschedulingArgs{
+				QueueSortPolicy:  config.WeightedFairShareQueueSort,
+				QueueSortTenant:  config.QueueNameQueueSortTenant,
+				QueueSortWeights: map[string]int64{"batch": 1, "prod": 3},
+			},
+		},
+		{
+			description: "correct config, empty QueueSortPolicy and QueueSortTenant",
+			args: &config.CoschedulingArgs{
+				PermitWaitingTimeSeconds: 3,
+			},
+		},
+		{
+			description: "incorrect config, wrong QueueSortPolicy",
+			args: &config.CoschedulingArgs{
+				QueueSortPolicy: "not existent",
+				QueueSortTenant: config.NamespaceQueueSortTenant,
+			},
+			expectedErr: fmt.Errorf("queueSortPolicy: Unsupported value:"),
+		},
+		{
+			description: "incorrect config, wrong QueueSortTenant",
+			args: &config.CoschedulingArgs{
+				QueueSortPolicy: config.RoundRobinQueueSort,
+				QueueSortTenant: "not existent",
+			},
+			expectedErr: fmt.Errorf("queueSortTenant: Unsupported value:"),
+		},
+		{
+			description: "incorrect config, non-positive QueueSortWeights",
+			args: &config.CoschedulingArgs{
+				QueueSortPolicy:  config.WeightedFairShareQueueSort,
+				QueueSortTenant:  config.NamespaceQueueSortTenant,
+				QueueSortWeights: map[string]int64{"ns": 0},
+			},
+			expectedErr: fmt.Errorf("queueSortWeights[ns]: Invalid value:"),
+		},
+		{
+			description: "incorrect config, negative PodGroupMaxBackoffSeconds",
+			args: &config.CoschedulingArgs{
+				PodGroupMaxBackoffSeconds: -1,
+				QueueSortPolicy:           config.CreationTimeQueueSort,
+				QueueSortTenant:           config.NamespaceQueueSortTenant,
+			},
+			expectedErr: fmt.Errorf("podGroupMaxBackoffSeconds: Invalid value:"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			err := ValidateCoschedulingArgs(nil, testCase.args)
+			if testCase.expectedErr != nil {
+				if err == nil {
+					t.Fatalf("expected err to equal %v not nil", testCase.expectedErr)
+				}
+
+				if !strings.Contains(err.Error(), testCase.expectedErr.Error()) {
+					t.Errorf("expected err to contain %s in error message: %s", testCase.expectedErr.Error(), err.Error())
+				}
+			}
+			if testCase.expectedErr == nil && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
 func TestValidateNodeResourceTopologyMatchArgs(t *testing.T) {
 	testCases := []struct {
 		args        *config.NodeResourceTopologyMatchArgs
@@ -67,3 +142,44 @@ func TestValidateNodeResourceTopologyMatchArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateCapacitySchedulingArgs(t *testing.T) {
+	testCases := []struct {
+		args        *config.CapacitySchedulingArgs
+		expectedErr error
+		description string
+	}{
+		{
+			description: "correct config",
+			args: &config.CapacitySchedulingArgs{
+				ReclaimGracePeriodSeconds: 300,
+				PreferRecentBorrowers:     true,
+			},
+		},
+		{
+			description: "incorrect config, negative reclaimGracePeriodSeconds",
+			args: &config.CapacitySchedulingArgs{
+				ReclaimGracePeriodSeconds: -1,
+			},
+			expectedErr: fmt.Errorf("reclaimGracePeriodSeconds: Invalid value:"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			err := ValidateCapacitySchedulingArgs(nil, testCase.args)
+			if testCase.expectedErr != nil {
+				if err == nil {
+					t.Fatalf("expected err to equal %v not nil", testCase.expectedErr)
+				}
+
+				if !strings.Contains(err.Error(), testCase.expectedErr.Error()) {
+					t.Errorf("expected err to contain %s in error message: %s", testCase.expectedErr.Error(), err.Error())
+				}
+			}
+			if testCase.expectedErr == nil && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ package v1alpha1
 import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling"
 )
 
@@ -31,6 +32,11 @@ import (
 // +kubebuilder:metadata:annotations="api-approved.kubernetes.io=https://github.com/kubernetes-sigs/scheduler-plugins/pull/52"
 // +kubebuilder:printcolumn:name="Used",JSONPath=".status.used",type=string,description="Used is the current observed total usage of the resource in the namespace."
 // +kubebuilder:printcolumn:name="Max",JSONPath=".spec.max",type=string,description="Max is the set of desired max limits for each named resource."
+// +kubebuilder:printcolumn:name="Pods",JSONPath=".status.pods",type=integer,description="Pods is the number of pods contributing to Used."
+// +kubebuilder:printcolumn:name="Borrowed",JSONPath=".status.borrowed",type=string,description="Borrowed is the part of Used above Min."
+// +kubebuilder:printcolumn:name="Lent",JSONPath=".status.lent",type=string,description="Lent is the part of the unused Min borrowed by other ElasticQuotas."
+// +kubebuilder:printcolumn:name="Pending",JSONPath=".status.pending",type=string,description="Pending is the sum of the requests of the unschedulable pods."
+// +kubebuilder:printcolumn:name="Preempted",JSONPath=".status.preempted",type=integer,description="Preempted is the number of pods preempted by the scheduler."
 // +kubebuilder:printcolumn:name="Age",JSONPath=".metadata.creationTimestamp",type=date,description="Age is the time ElasticQuota was created."
 type ElasticQuota struct {
 	metav1.TypeMeta `json:",inline"`
@@ -58,13 +64,92 @@ type ElasticQuotaSpec struct {
 	// successfully scheduled pods.
 	// +optional
 	Max v1.ResourceList `json:"max,omitempty" protobuf:"bytes,2,rep,name=max, casttype=ResourceList,castkey=ResourceName"`
+
+	// Parent references the parent ElasticQuota of this ElasticQuota, if any. The usage of an
+	// ElasticQuota counts towards the usage of all its ancestors, so that it is bound by their Max too.
+	// +optional
+	Parent *ElasticQuotaReference `json:"parent,omitempty" protobuf:"bytes,3,opt,name=parent"`
+
+	// NamespaceSelector selects the namespaces the ElasticQuota applies to, so that a single
+	// ElasticQuota bounds the usage of several namespaces. If unset, the ElasticQuota applies to
+	// its own namespace only. The namespaces selected by different ElasticQuotas must not overlap.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty" protobuf:"bytes,4,opt,name=namespaceSelector"`
+
+	// Weight is the share of the ElasticQuota in the resources its siblings don't use below their Min
+	// or use above their Min. Quotas may borrow beyond their fair share when resources are idle, but
+	// the quotas furthest above their fair share are the first to be preempted. Defaults to 1.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Weight *int32 `json:"weight,omitempty" protobuf:"varint,5,opt,name=weight"`
+
+	// Scopes limit the usage of the pods of given PriorityClasses within the ElasticQuota, on top
+	// of its Max, e.g. so that best-effort pods can only use part of the extended resources of the
+	// ElasticQuota. There is at most one scope per PriorityClass.
+	// +listType=map
+	// +listMapKey=priorityClassName
+	// +optional
+	Scopes []ElasticQuotaScope `json:"scopes,omitempty" protobuf:"bytes,6,rep,name=scopes"`
+}
+
+// ElasticQuotaScope limits the usage of the pods of a PriorityClass within an ElasticQuota.
+type ElasticQuotaScope struct {
+	// PriorityClassName is the name of the PriorityClass of the pods the scope applies to.
+	PriorityClassName string `json:"priorityClassName" protobuf:"bytes,1,opt,name=priorityClassName"`
+
+	// Max is the set of max limits for each named resource of the pods of the PriorityClass,
+	// including the pods of the descendants of the ElasticQuota. The resources not listed are
+	// only limited by the Max of the ElasticQuota.
+	// +optional
+	Max v1.ResourceList `json:"max,omitempty" protobuf:"bytes,2,rep,name=max,casttype=ResourceList,castkey=ResourceName"`
+}
+
+// ElasticQuotaReference references an ElasticQuota.
+type ElasticQuotaReference struct {
+	// Namespace of the referenced ElasticQuota. Defaults to the namespace of the referencing object.
+	// +optional
+	Namespace string `json:"namespace,omitempty" protobuf:"bytes,1,opt,name=namespace"`
+
+	// Name of the referenced ElasticQuota.
+	Name string `json:"name" protobuf:"bytes,2,opt,name=name"`
 }
 
 // ElasticQuotaStatus defines the observed use.
 type ElasticQuotaStatus struct {
-	// Used is the current observed total usage of the resource in the namespace.
+	// Used is the current observed total usage of the resource in the namespaces of the
+	// ElasticQuota, including the usage of the descendants of the ElasticQuota.
 	// +optional
 	Used v1.ResourceList `json:"used,omitempty" protobuf:"bytes,1,rep,name=used,casttype=ResourceList,castkey=ResourceName"`
+
+	// Pods is the number of pods contributing to Used: the pods bound to a node, in a
+	// non-terminal phase and not being deleted, including the pods of the descendants.
+	// +optional
+	Pods int32 `json:"pods,omitempty" protobuf:"varint,2,opt,name=pods"`
+
+	// Borrowed is the part of Used above Min, borrowed from the unused Min of other ElasticQuotas.
+	// +optional
+	Borrowed v1.ResourceList `json:"borrowed,omitempty" protobuf:"bytes,3,rep,name=borrowed,casttype=ResourceList,castkey=ResourceName"`
+
+	// Lent is the part of the unused Min borrowed by the siblings of the ElasticQuota. The siblings
+	// above their Min borrow from the siblings below it in proportion to their unused Min.
+	// +optional
+	Lent v1.ResourceList `json:"lent,omitempty" protobuf:"bytes,4,rep,name=lent,casttype=ResourceList,castkey=ResourceName"`
+
+	// Pending is the sum of the requests of the unschedulable pods in the namespaces of the
+	// ElasticQuota, including the pending requests of the descendants of the ElasticQuota.
+	// +optional
+	Pending v1.ResourceList `json:"pending,omitempty" protobuf:"bytes,5,rep,name=pending,casttype=ResourceList,castkey=ResourceName"`
+
+	// Preempted is the number of pods of the namespaces of the ElasticQuota preempted by the
+	// scheduler, as observed by the controller.
+	// +optional
+	Preempted int32 `json:"preempted,omitempty" protobuf:"varint,6,opt,name=preempted"`
+
+	// PreemptedPods are the UIDs of the preempted pods counted in Preempted that still exist, so
+	// that each preempted pod is counted once while it terminates, whatever the restarts of the controller.
+	// +optional
+	PreemptedPods []types.UID `json:"preemptedPods,omitempty" protobuf:"bytes,7,rep,name=preemptedPods,casttype=k8s.io/apimachinery/pkg/types.UID"`
 }
 
 // +kubebuilder:object:root=true
@@ -110,6 +195,29 @@ const (
 
 	// PodGroupLabel is the default label of coscheduling
 	PodGroupLabel = scheduling.GroupName + "/pod-group"
+
+	// PodGroupQueueLabel is the label of a pod group naming the queue it belongs to,
+	// which the pod groups are grouped by under a fair queue sort policy of coscheduling.
+	PodGroupQueueLabel = scheduling.GroupName + "/queue-name"
+)
+
+// These are the valid condition types of podGroups.
+const (
+	// PodGroupConditionScheduled means the `spec.minMember` pods of the pod group have been scheduled.
+	// It is false with the last scheduling failure if the pod group got rejected by the scheduler.
+	PodGroupConditionScheduled = "Scheduled"
+
+	// PodGroupConditionQuorumReached means the pod group has at least `spec.minMember` pods,
+	// as well as the minMember of each of its roles.
+	PodGroupConditionQuorumReached = "QuorumReached"
+
+	// PodGroupConditionInsufficientResources means the scheduler found that the cluster cannot
+	// hold the pod group, e.g. its `spec.minResources`.
+	PodGroupConditionInsufficientResources = "InsufficientResources"
+
+	// PodGroupConditionBackedOff means the scheduler is backing off the pod group after
+	// it failed to be scheduled.
+	PodGroupConditionBackedOff = "BackedOff"
 )
 
 // PodGroup is a collection of Pod; used for batch workload.
@@ -148,6 +256,14 @@ type PodGroupSpec struct {
 	// will not start any.
 	MinMember int32 `json:"minMember,omitempty"`
 
+	// MaxMember defines the maximal number of members/tasks of an elastic pod group;
+	// once MinMember tasks have been scheduled, the scheduler will start the remaining
+	// tasks as resources become available, without waiting for each other, until
+	// MaxMember tasks have been scheduled. If not set, the pod group is not elastic.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxMember *int32 `json:"maxMember,omitempty"`
+
 	// MinResources defines the minimal resource of members/tasks to run the pod group;
 	// if there's not enough resources to start all tasks, the scheduler
 	// will not start any.
@@ -155,6 +271,60 @@ type PodGroupSpec struct {
 
 	// ScheduleTimeoutSeconds defines the maximal time of members/tasks to wait before run the pod group;
 	ScheduleTimeoutSeconds *int32 `json:"scheduleTimeoutSeconds,omitempty"`
+
+	// TopologyConstraint defines the topology domain the members/tasks of the pod group
+	// should be packed into, e.g. a single zone or rack.
+	// +optional
+	TopologyConstraint *TopologyConstraint `json:"topologyConstraint,omitempty"`
+
+	// Roles defines the roles of the members/tasks of the pod group, e.g. parameter servers
+	// and workers; the pod group is only scheduled if the minMember of every role is satisfied,
+	// in addition to the minMember of the pod group.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Roles []PodGroupRole `json:"roles,omitempty"`
+}
+
+// PodGroupRole defines a role of the members of a pod group.
+type PodGroupRole struct {
+	// Name is the name of the role, unique within the pod group.
+	Name string `json:"name"`
+
+	// Selector is a label query over the pods of the pod group that have this role.
+	Selector *metav1.LabelSelector `json:"selector"`
+
+	// MinMember defines the minimal number of members/tasks of this role to run the pod group.
+	MinMember int32 `json:"minMember,omitempty"`
+
+	// MinResources defines the minimal resource of members/tasks of this role to run the pod group.
+	// +optional
+	MinResources v1.ResourceList `json:"minResources,omitempty"`
+}
+
+// TopologyConstraintMode is the mode of a pod group topology constraint.
+type TopologyConstraintMode string
+
+const (
+	// TopologyConstraintRequired means all the members of the pod group must land in a single topology domain.
+	TopologyConstraintRequired TopologyConstraintMode = "Required"
+
+	// TopologyConstraintPreferred means the members of the pod group should land in a single topology domain
+	// if possible, but may spread across domains otherwise.
+	TopologyConstraintPreferred TopologyConstraintMode = "Preferred"
+)
+
+// TopologyConstraint defines the topology domain the members of a pod group are packed into.
+type TopologyConstraint struct {
+	// TopologyKey is the key of node labels. Nodes that have a label with this key
+	// and identical values are considered to be in the same topology domain.
+	TopologyKey string `json:"topologyKey"`
+
+	// Mode defines whether packing the pod group into a single topology domain is
+	// required or only preferred. Defaults to Required.
+	// +optional
+	// +kubebuilder:validation:Enum=Required;Preferred
+	Mode TopologyConstraintMode `json:"mode,omitempty"`
 }
 
 // PodGroupStatus represents the current state of a pod group.
@@ -180,6 +350,25 @@ type PodGroupStatus struct {
 
 	// ScheduleStartTime of the group
 	ScheduleStartTime metav1.Time `json:"scheduleStartTime,omitempty"`
+
+	// BackoffCount is the number of consecutive times the group has been backed off
+	// after failing to be scheduled. It is reset once the group is scheduled.
+	// +optional
+	BackoffCount int32 `json:"backoffCount,omitempty"`
+
+	// BackoffUntil is the time until which the group is backed off, i.e. the earliest
+	// time of the next scheduling attempt of the group.
+	// +optional
+	BackoffUntil *metav1.Time `json:"backoffUntil,omitempty"`
+
+	// Conditions represent the latest available observations of the pod group's state,
+	// including the reason of the last scheduling failure.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 // +kubebuilder:object:root=true
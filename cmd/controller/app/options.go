@@ -18,6 +18,8 @@ package app
 
 import (
 	"github.com/spf13/pflag"
+
+	"sigs.k8s.io/scheduler-plugins/pkg/webhooks"
 )
 
 type ServerRunOptions struct {
@@ -27,6 +29,15 @@ type ServerRunOptions struct {
 	ApiServerBurst       int
 	Workers              int
 	EnableLeaderElection bool
+	// EnableWorkloadPodGroups enables the controllers that create the PodGroups of Jobs and StatefulSets.
+	EnableWorkloadPodGroups bool
+	// EnableWebhooks enables the webhooks validating and defaulting ElasticQuotas and PodGroups.
+	EnableWebhooks bool
+	WebhookPort    int
+	WebhookCertDir string
+	// ElasticQuotaMinCapacityPolicy defines how an ElasticQuota whose Min exceeds, together with the Min
+	// of the other root ElasticQuotas, the allocatable resources of the cluster is handled: Ignore, Warn or Deny.
+	ElasticQuotaMinCapacityPolicy string
 }
 
 func NewServerRunOptions() *ServerRunOptions {
@@ -42,4 +53,9 @@ func (s *ServerRunOptions) addAllFlags() {
 	pflag.IntVar(&s.ApiServerBurst, "burst", 10, "burst of query apiserver.")
 	pflag.IntVar(&s.Workers, "workers", 1, "workers of scheduler-plugin-controllers.")
 	pflag.BoolVar(&s.EnableLeaderElection, "enableLeaderElection", s.EnableLeaderElection, "If EnableLeaderElection for controller.")
+	pflag.BoolVar(&s.EnableWorkloadPodGroups, "enableWorkloadPodGroups", s.EnableWorkloadPodGroups, "If create the PodGroups of the Jobs, StatefulSets and JobSets whose pod template is labeled with the pod group label.")
+	pflag.BoolVar(&s.EnableWebhooks, "enableWebhooks", s.EnableWebhooks, "If serve the webhooks validating and defaulting ElasticQuotas and PodGroups.")
+	pflag.IntVar(&s.WebhookPort, "webhookPort", 9443, "Port the webhook server listens on.")
+	pflag.StringVar(&s.WebhookCertDir, "webhookCertDir", "", "Directory containing the tls.crt and tls.key of the webhook server. Defaults to <temp-dir>/k8s-webhook-server/serving-certs.")
+	pflag.StringVar(&s.ElasticQuotaMinCapacityPolicy, "elasticQuotaMinCapacityPolicy", string(webhooks.MinCapacityWarn), "How an ElasticQuota whose min, summed with the min of the other root ElasticQuotas, exceeds the allocatable resources of the cluster is handled: Ignore, Warn or Deny.")
 }
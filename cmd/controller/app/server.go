@@ -17,6 +17,8 @@ limitations under the License.
 package app
 
 import (
+	"fmt"
+
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -24,9 +26,11 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	schedulingv1a1 "sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
 	"sigs.k8s.io/scheduler-plugins/pkg/controllers"
+	"sigs.k8s.io/scheduler-plugins/pkg/webhooks"
 )
 
 var (
@@ -45,6 +49,15 @@ func Run(s *ServerRunOptions) error {
 	config.QPS = float32(s.ApiServerQPS)
 	config.Burst = s.ApiServerBurst
 
+	minCapacityPolicy := webhooks.MinCapacityPolicy(s.ElasticQuotaMinCapacityPolicy)
+	switch minCapacityPolicy {
+	case webhooks.MinCapacityIgnore, webhooks.MinCapacityWarn, webhooks.MinCapacityDeny:
+	default:
+		err := fmt.Errorf("invalid elasticQuotaMinCapacityPolicy %q, must be one of Ignore, Warn or Deny", s.ElasticQuotaMinCapacityPolicy)
+		setupLog.Error(err, "invalid options")
+		return err
+	}
+
 	// Controller Runtime Controllers
 	ctrl.SetLogger(klogr.New())
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
@@ -56,6 +69,10 @@ func Run(s *ServerRunOptions) error {
 		LeaderElection:          s.EnableLeaderElection,
 		LeaderElectionID:        "sched-plugins-controllers",
 		LeaderElectionNamespace: "kube-system",
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Port:    s.WebhookPort,
+			CertDir: s.WebhookCertDir,
+		}),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -80,6 +97,50 @@ func Run(s *ServerRunOptions) error {
 		return err
 	}
 
+	if s.EnableWorkloadPodGroups {
+		if err = (&controllers.JobReconciler{
+			Client:  mgr.GetClient(),
+			Scheme:  mgr.GetScheme(),
+			Workers: s.Workers,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Job")
+			return err
+		}
+
+		if err = (&controllers.StatefulSetReconciler{
+			Client:  mgr.GetClient(),
+			Scheme:  mgr.GetScheme(),
+			Workers: s.Workers,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "StatefulSet")
+			return err
+		}
+
+		if err = (&controllers.JobSetReconciler{
+			Client:  mgr.GetClient(),
+			Scheme:  mgr.GetScheme(),
+			Workers: s.Workers,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "JobSet")
+			return err
+		}
+	}
+
+	if s.EnableWebhooks {
+		if err = (&webhooks.ElasticQuotaWebhook{
+			Reader:            mgr.GetClient(),
+			MinCapacityPolicy: minCapacityPolicy,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ElasticQuota")
+			return err
+		}
+
+		if err = (&webhooks.PodGroupWebhook{}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PodGroup")
+			return err
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		return err
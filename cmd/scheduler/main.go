@@ -17,11 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"net/http"
 	"os"
 
 	"k8s.io/component-base/cli"
 	_ "k8s.io/component-base/metrics/prometheus/clientgo" // for rest client metric registration
 	_ "k8s.io/component-base/metrics/prometheus/version"  // for version metric registration
+	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/cmd/kube-scheduler/app"
 
 	"sigs.k8s.io/scheduler-plugins/pkg/capacityscheduling"
@@ -37,6 +39,7 @@ import (
 	"sigs.k8s.io/scheduler-plugins/pkg/trimaran/loadvariationriskbalancing"
 	"sigs.k8s.io/scheduler-plugins/pkg/trimaran/lowriskovercommitment"
 	"sigs.k8s.io/scheduler-plugins/pkg/trimaran/targetloadpacking"
+	"sigs.k8s.io/scheduler-plugins/pkg/util"
 
 	// Ensure scheme package is initialized.
 	_ "sigs.k8s.io/scheduler-plugins/apis/config/scheme"
@@ -64,6 +67,29 @@ func main() {
 		app.WithPlugin(qos.Name, qos.New),
 	)
 
+	// The secure port of the scheduler only serves a fixed set of handlers, so the debug
+	// handlers of the plugins are served on an address of their own, if set.
+	var debugAddress string
+	command.Flags().StringVar(&debugAddress, "plugins-debug-address", "",
+		"The address to serve the debug handlers of the plugins on, without authentication, e.g. 127.0.0.1:10260. Disabled if empty.")
+	command.RunE = withDebugServer(command.RunE, &debugAddress)
+
 	code := cli.Run(command)
 	os.Exit(code)
 }
+
+// withDebugServer wraps the run function of the scheduler command, so that the debug handlers
+// of the plugins are served on <address> along with the scheduler, if set.
+func withDebugServer[C any](run func(C, []string) error, address *string) func(C, []string) error {
+	return func(cmd C, args []string) error {
+		if *address != "" {
+			go func() {
+				klog.InfoS("Serving the debug handlers of the plugins", "address", *address)
+				if err := http.ListenAndServe(*address, util.DebugHandler()); err != nil {
+					klog.ErrorS(err, "Cannot serve the debug handlers of the plugins", "address", *address)
+				}
+			}()
+		}
+		return run(cmd, args)
+	}
+}
@@ -21,13 +21,16 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	policy "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/informers"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	corelisters "k8s.io/client-go/listers/core/v1"
@@ -35,7 +38,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
 	"k8s.io/klog/v2"
-	extenderv1 "k8s.io/kube-scheduler/extender/v1"
+	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"k8s.io/kubernetes/pkg/scheduler/framework/preemption"
 	"k8s.io/kubernetes/pkg/scheduler/metrics"
@@ -43,9 +46,15 @@ import (
 	ctrlruntimecache "sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"sigs.k8s.io/scheduler-plugins/apis/config"
+	"sigs.k8s.io/scheduler-plugins/apis/config/validation"
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling"
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	pgclientset "sigs.k8s.io/scheduler-plugins/pkg/generated/clientset/versioned"
+	pgformers "sigs.k8s.io/scheduler-plugins/pkg/generated/informers/externalversions"
+	pglister "sigs.k8s.io/scheduler-plugins/pkg/generated/listers/scheduling/v1alpha1"
 	"sigs.k8s.io/scheduler-plugins/pkg/util"
+	"sigs.k8s.io/scheduler-plugins/pkg/util/usage"
 )
 
 var scheme = runtime.NewScheme()
@@ -58,11 +67,28 @@ func init() {
 // CapacityScheduling is a plugin that implements the mechanism of capacity scheduling.
 type CapacityScheduling struct {
 	sync.RWMutex
-	fh                framework.Handle
-	podLister         corelisters.PodLister
-	pdbLister         policylisters.PodDisruptionBudgetLister
+	fh        framework.Handle
+	podLister corelisters.PodLister
+	nsLister  corelisters.NamespaceLister
+	pdbLister policylisters.PodDisruptionBudgetLister
+	// pgLister looks up the PodGroups of the pods, so that gangs are admitted as a whole.
+	pgLister          pglister.PodGroupLister
 	client            client.Client
 	elasticQuotaInfos ElasticQuotaInfos
+	// namespaceIndex maps the namespaces selected by the namespace selectors to the elasticQuotaInfos.
+	namespaceIndex namespaceIndex
+	// elasticQuotas are the known ElasticQuotas, by namespace, including the ones skipped because
+	// they overlap with an older one, which are added again once the older one is deleted.
+	elasticQuotas map[string]*v1alpha1.ElasticQuota
+	// snapshot holds the copies of the elasticQuotaInfos shared by the snapshots taken since they
+	// last changed, by namespace, so that a snapshot only copies the ones that changed.
+	snapshotLock sync.Mutex
+	snapshot     map[string]*elasticQuotaSnapshotEntry
+	// reclaimGracePeriod is the time a pod must have been unschedulable for before it preempts
+	// the pods of the quotas borrowing resources.
+	reclaimGracePeriod time.Duration
+	// preferRecentBorrowers makes the borrowing pods that started most recently preempted first.
+	preferRecentBorrowers bool
 }
 
 // PreFilterState computed at PreFilter and used at PostFilter or Reserve.
@@ -73,6 +99,10 @@ type PreFilterState struct {
 	// which subject to the same quota(namespace) and is more important than the preemptor.
 	nominatedPodsReqInEQWithPodReq framework.Resource
 
+	// nominatedPodsReqInScopeWithPodReq is the part of nominatedPodsReqInEQWithPodReq of the pods of the
+	// preemptor's PriorityClass, which counts towards the scope of the PriorityClass in the quota.
+	nominatedPodsReqInScopeWithPodReq framework.Resource
+
 	// nominatedPodsReqWithPodReq is the sum of podReq and the requested resources of the Nominated Pods
 	// which subject to the all quota(namespace). Generated Nominated Pods consist of two kinds of pods:
 	// 1. the pods subject to the same quota(namespace) and is more important than the preemptor.
@@ -88,12 +118,23 @@ func (s *PreFilterState) Clone() framework.StateData {
 // ElasticQuotaSnapshotState stores the snapshot of elasticQuotas.
 type ElasticQuotaSnapshotState struct {
 	elasticQuotaInfos ElasticQuotaInfos
+	namespaceIndex    namespaceIndex
+}
+
+// elasticQuotaSnapshotEntry is the copy of an elasticQuotaInfo shared between snapshots, along with
+// the elasticQuotaInfo and the generation it was copied from.
+type elasticQuotaSnapshotEntry struct {
+	source     *ElasticQuotaInfo
+	generation int64
+	info       *ElasticQuotaInfo
 }
 
-// Clone the ElasticQuotaSnapshot state.
+// Clone the ElasticQuotaSnapshot state. The ElasticQuotaInfos shared between snapshots are
+// copied on write, so only the ones modified in this state are copied.
 func (s *ElasticQuotaSnapshotState) Clone() framework.StateData {
 	return &ElasticQuotaSnapshotState{
-		elasticQuotaInfos: s.elasticQuotaInfos.clone(),
+		elasticQuotaInfos: s.elasticQuotaInfos.shallowClone(),
+		namespaceIndex:    s.namespaceIndex,
 	}
 }
 
@@ -119,11 +160,25 @@ func (c *CapacityScheduling) Name() string {
 
 // New initializes a new plugin and returns it.
 func New(ctx context.Context, obj runtime.Object, handle framework.Handle) (framework.Plugin, error) {
+	args := &config.CapacitySchedulingArgs{}
+	if obj != nil {
+		var ok bool
+		if args, ok = obj.(*config.CapacitySchedulingArgs); !ok {
+			return nil, fmt.Errorf("want args to be of type CapacitySchedulingArgs, got %T", obj)
+		}
+		if err := validation.ValidateCapacitySchedulingArgs(nil, args); err != nil {
+			return nil, err
+		}
+	}
+
 	c := &CapacityScheduling{
-		fh:                handle,
-		elasticQuotaInfos: NewElasticQuotaInfos(),
-		podLister:         handle.SharedInformerFactory().Core().V1().Pods().Lister(),
-		pdbLister:         getPDBLister(handle.SharedInformerFactory()),
+		fh:                    handle,
+		elasticQuotaInfos:     NewElasticQuotaInfos(),
+		podLister:             handle.SharedInformerFactory().Core().V1().Pods().Lister(),
+		nsLister:              handle.SharedInformerFactory().Core().V1().Namespaces().Lister(),
+		pdbLister:             getPDBLister(handle.SharedInformerFactory()),
+		reclaimGracePeriod:    time.Duration(args.ReclaimGracePeriodSeconds) * time.Second,
+		preferRecentBorrowers: args.PreferRecentBorrowers,
 	}
 
 	client, err := client.New(handle.KubeConfig(), client.Options{Scheme: scheme})
@@ -187,6 +242,25 @@ func New(ctx context.Context, obj runtime.Object, handle framework.Handle) (fram
 			},
 		},
 	)
+	nsInformer := handle.SharedInformerFactory().Core().V1().Namespaces().Informer()
+	nsInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.addNamespace,
+		UpdateFunc: c.updateNamespace,
+	})
+
+	pgClient, err := pgclientset.NewForConfig(handle.KubeConfig())
+	if err != nil {
+		return nil, err
+	}
+	pgInformerFactory := pgformers.NewSharedInformerFactory(pgClient, 0)
+	pgInformer := pgInformerFactory.Scheduling().V1alpha1().PodGroups()
+	c.pgLister = pgInformer.Lister()
+	pgInformerFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), pgInformer.Informer().HasSynced) {
+		err := fmt.Errorf("WaitForCacheSync failed")
+		klog.ErrorS(err, "Cannot sync caches")
+		return nil, err
+	}
 	klog.InfoS("CapacityScheduling start")
 	return c, nil
 }
@@ -202,18 +276,21 @@ func (c *CapacityScheduling) EventsToRegister() []framework.ClusterEventWithHint
 }
 
 // PreFilter performs the following validations.
-// 1. Check if the (pod.request + eq.allocated) is less than eq.max.
-// 2. Check if the sum(eq's usage) > sum(eq's min).
+// 1. Check if the (pod.request + eq.allocated) is less than eq.max, for eq and all its ancestors.
+// 2. Check if the (pod.request + eq.allocated by the pod's PriorityClass) is less than the max of
+// the scope of the PriorityClass, for eq and all its ancestors.
+// 3. Check if the sum(root eq's usage) > sum(root eq's min).
+// The first member of a PodGroup is checked with the request of the whole gang, and the
+// following members with the part of their request not covered by the gang's reservation.
 func (c *CapacityScheduling) PreFilter(ctx context.Context, state *framework.CycleState, pod *v1.Pod) (*framework.PreFilterResult, *framework.Status) {
-	// TODO improve the efficiency of taking snapshot
-	// e.g. use a two-pointer data structure to only copy the updated EQs when necessary.
 	snapshotElasticQuota := c.snapshotElasticQuota()
 	podReq := computePodResourceRequest(pod)
 
 	state.Write(ElasticQuotaSnapshotKey, snapshotElasticQuota)
 
 	elasticQuotaInfos := snapshotElasticQuota.elasticQuotaInfos
-	eq := snapshotElasticQuota.elasticQuotaInfos[pod.Namespace]
+	namespaceIndex := snapshotElasticQuota.namespaceIndex
+	eq := snapshotElasticQuota.elasticQuotaInfos.getByNamespace(snapshotElasticQuota.namespaceIndex, pod.Namespace)
 	if eq == nil {
 		preFilterState := &PreFilterState{
 			podReq: *podReq,
@@ -225,6 +302,9 @@ func (c *CapacityScheduling) PreFilter(ctx context.Context, state *framework.Cyc
 	// nominatedPodsReqInEQWithPodReq is the sum of podReq and the requested resources of the Nominated Pods
 	// which subject to the same quota(namespace) and is more important than the preemptor.
 	nominatedPodsReqInEQWithPodReq := &framework.Resource{}
+	// nominatedPodsReqInScopeWithPodReq is the part of nominatedPodsReqInEQWithPodReq of the pods of the
+	// preemptor's PriorityClass.
+	nominatedPodsReqInScopeWithPodReq := &framework.Resource{}
 	// nominatedPodsReqWithPodReq is the sum of podReq and the requested resources of the Nominated Pods
 	// which subject to the all quota(namespace). Generated Nominated Pods consist of two kinds of pods:
 	// 1. the pods subject to the same quota(namespace) and is more important than the preemptor.
@@ -242,35 +322,57 @@ func (c *CapacityScheduling) PreFilter(ctx context.Context, state *framework.Cyc
 			if p.Pod.UID == pod.UID {
 				continue
 			}
-			ns := p.Pod.Namespace
-			info := c.elasticQuotaInfos[ns]
+			info := elasticQuotaInfos.getByNamespace(namespaceIndex, p.Pod.Namespace)
 			if info != nil {
 				pResourceRequest := util.ResourceList(computePodResourceRequest(p.Pod))
-				// If they are subject to the same quota(namespace) and p is more important than pod,
+				// If they are subject to the same quota and p is more important than pod,
 				// p will be added to the nominatedResource and totalNominatedResource.
-				// If they aren't subject to the same quota(namespace) and the usage of quota(p's namespace) does not exceed min,
+				// If they aren't subject to the same quota and the usage of quota(p's quota) does not exceed min,
 				// p will be added to the totalNominatedResource.
-				if ns == pod.Namespace && corev1helpers.PodPriority(p.Pod) >= corev1helpers.PodPriority(pod) {
+				sameQuota := info.Namespace == eq.Namespace
+				if sameQuota && corev1helpers.PodPriority(p.Pod) >= corev1helpers.PodPriority(pod) {
 					nominatedPodsReqInEQWithPodReq.Add(pResourceRequest)
 					nominatedPodsReqWithPodReq.Add(pResourceRequest)
-				} else if ns != pod.Namespace && !info.usedOverMin() {
+					if p.Pod.Spec.PriorityClassName == pod.Spec.PriorityClassName {
+						nominatedPodsReqInScopeWithPodReq.Add(pResourceRequest)
+					}
+				} else if !sameQuota && !info.usedOverMin() {
 					nominatedPodsReqWithPodReq.Add(pResourceRequest)
 				}
 			}
 		}
 	}
 
-	nominatedPodsReqInEQWithPodReq.Add(util.ResourceList(podReq))
-	nominatedPodsReqWithPodReq.Add(util.ResourceList(podReq))
+	// quotaReq is the request admitting the pod adds to the usage of its elasticQuota.
+	quotaReq := podReq
+	var gangReq *framework.Resource
+	if gang := eq.getGang(util.GetPodGroupFullName(pod)); gang != nil {
+		quotaReq = podReq.Clone()
+		subtractResource(quotaReq, *minResource(gang.request, podReq))
+	} else if gangReq, _ = c.computeGangRequest(eq, pod, podReq); gangReq != nil {
+		quotaReq = gangReq
+	}
+
+	nominatedPodsReqInEQWithPodReq.Add(util.ResourceList(quotaReq))
+	nominatedPodsReqInScopeWithPodReq.Add(util.ResourceList(quotaReq))
+	nominatedPodsReqWithPodReq.Add(util.ResourceList(quotaReq))
 	preFilterState := &PreFilterState{
-		podReq:                         *podReq,
-		nominatedPodsReqInEQWithPodReq: *nominatedPodsReqInEQWithPodReq,
-		nominatedPodsReqWithPodReq:     *nominatedPodsReqWithPodReq,
+		podReq:                            *podReq,
+		nominatedPodsReqInEQWithPodReq:    *nominatedPodsReqInEQWithPodReq,
+		nominatedPodsReqInScopeWithPodReq: *nominatedPodsReqInScopeWithPodReq,
+		nominatedPodsReqWithPodReq:        *nominatedPodsReqWithPodReq,
 	}
 	state.Write(preFilterStateKey, preFilterState)
 
-	if eq.usedOverMaxWith(nominatedPodsReqInEQWithPodReq) {
-		return nil, framework.NewStatus(framework.Unschedulable, fmt.Sprintf("Pod %v/%v is rejected in PreFilter because ElasticQuota %v is more than Max", pod.Namespace, pod.Name, eq.Namespace))
+	if elasticQuotaInfos.usedOverMaxWith(eq, nominatedPodsReqInEQWithPodReq) {
+		if gangReq != nil {
+			return nil, framework.NewStatus(framework.Unschedulable, fmt.Sprintf("Pod %v/%v is rejected in PreFilter because ElasticQuota %v or one of its ancestors is more than Max with PodGroup %v", pod.Namespace, pod.Name, eq.Namespace, util.GetPodGroupFullName(pod)))
+		}
+		return nil, framework.NewStatus(framework.Unschedulable, fmt.Sprintf("Pod %v/%v is rejected in PreFilter because ElasticQuota %v or one of its ancestors is more than Max", pod.Namespace, pod.Name, eq.Namespace))
+	}
+
+	if elasticQuotaInfos.usedOverScopedMaxWith(eq, pod.Spec.PriorityClassName, nominatedPodsReqInScopeWithPodReq) {
+		return nil, framework.NewStatus(framework.Unschedulable, fmt.Sprintf("Pod %v/%v is rejected in PreFilter because the pods of PriorityClass %v are more than their Max in ElasticQuota %v or one of its ancestors", pod.Namespace, pod.Name, pod.Spec.PriorityClassName, eq.Namespace))
 	}
 
 	if elasticQuotaInfos.aggregatedUsedOverMinWith(*nominatedPodsReqWithPodReq) {
@@ -293,12 +395,9 @@ func (c *CapacityScheduling) AddPod(ctx context.Context, cycleState *framework.C
 		return framework.NewStatus(framework.Error, err.Error())
 	}
 
-	elasticQuotaInfo := elasticQuotaSnapshotState.elasticQuotaInfos[podToAdd.Pod.Namespace]
-	if elasticQuotaInfo != nil {
-		err := elasticQuotaInfo.addPodIfNotPresent(podToAdd.Pod)
-		if err != nil {
-			klog.ErrorS(err, "Failed to add Pod to its associated elasticQuota", "pod", klog.KObj(podToAdd.Pod))
-		}
+	err = elasticQuotaSnapshotState.elasticQuotaInfos.addPodIfNotPresent(elasticQuotaSnapshotState.namespaceIndex, podToAdd.Pod)
+	if err != nil {
+		klog.ErrorS(err, "Failed to add Pod to its associated elasticQuota", "pod", klog.KObj(podToAdd.Pod))
 	}
 
 	return framework.NewStatus(framework.Success, "")
@@ -312,12 +411,9 @@ func (c *CapacityScheduling) RemovePod(ctx context.Context, cycleState *framewor
 		return framework.NewStatus(framework.Error, err.Error())
 	}
 
-	elasticQuotaInfo := elasticQuotaSnapshotState.elasticQuotaInfos[podToRemove.Pod.Namespace]
-	if elasticQuotaInfo != nil {
-		err = elasticQuotaInfo.deletePodIfPresent(podToRemove.Pod)
-		if err != nil {
-			klog.ErrorS(err, "Failed to delete Pod from its associated elasticQuota", "pod", klog.KObj(podToRemove.Pod))
-		}
+	err = elasticQuotaSnapshotState.elasticQuotaInfos.deletePodIfPresent(elasticQuotaSnapshotState.namespaceIndex, podToRemove.Pod)
+	if err != nil {
+		klog.ErrorS(err, "Failed to delete Pod from its associated elasticQuota", "pod", klog.KObj(podToRemove.Pod))
 	}
 
 	return framework.NewStatus(framework.Success, "")
@@ -328,68 +424,162 @@ func (c *CapacityScheduling) PostFilter(ctx context.Context, state *framework.Cy
 		metrics.PreemptionAttempts.Inc()
 	}()
 
+	p := &preemptor{
+		fh:                    c.fh,
+		state:                 state,
+		reclaimGracePeriod:    c.reclaimGracePeriod,
+		preferRecentBorrowers: c.preferRecentBorrowers,
+	}
 	pe := preemption.Evaluator{
 		PluginName: c.Name(),
 		Handler:    c.fh,
 		PodLister:  c.podLister,
 		PdbLister:  c.pdbLister,
 		State:      state,
-		Interface: &preemptor{
-			fh:    c.fh,
-			state: state,
-		},
+		Interface:  p,
 	}
 
-	return pe.Preempt(ctx, pod, m)
+	result, status := pe.Preempt(ctx, pod, m)
+	if status.IsSuccess() && result != nil && result.NominatingInfo != nil {
+		p.recordReclaims(pod, result.NominatingInfo.NominatedNodeName)
+	}
+	return result, status
 }
 
+// Reserve adds the pod to its elasticQuota. The first member of a PodGroup reserves the request of
+// the whole gang, provided it still fits, so that the members admitted in PreFilter can't be
+// starved by other pods while the gang is being scheduled.
 func (c *CapacityScheduling) Reserve(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) *framework.Status {
 	c.Lock()
 	defer c.Unlock()
 
-	elasticQuotaInfo := c.elasticQuotaInfos[pod.Namespace]
-	if elasticQuotaInfo != nil {
-		err := elasticQuotaInfo.addPodIfNotPresent(pod)
-		if err != nil {
-			klog.ErrorS(err, "Failed to add Pod to its associated elasticQuota", "pod", klog.KObj(pod))
-			return framework.NewStatus(framework.Error, err.Error())
+	if eq := c.elasticQuotaInfos.getByNamespace(c.namespaceIndex, pod.Namespace); eq != nil && eq.getGang(util.GetPodGroupFullName(pod)) == nil {
+		podReq := computePodResourceRequest(pod)
+		if gangReq, members := c.computeGangRequest(eq, pod, podReq); gangReq != nil {
+			if c.elasticQuotaInfos.usedOverMaxWith(eq, gangReq) || c.elasticQuotaInfos.usedOverScopedMaxWith(eq, pod.Spec.PriorityClassName, gangReq) {
+				return framework.NewStatus(framework.Unschedulable, fmt.Sprintf("Pod %v/%v is rejected in Reserve because ElasticQuota %v or one of its ancestors is more than Max with PodGroup %v", pod.Namespace, pod.Name, eq.Namespace, util.GetPodGroupFullName(pod)))
+			}
+			c.elasticQuotaInfos.reserveGang(eq, util.GetPodGroupFullName(pod), pod.Spec.PriorityClassName, gangReq, members)
 		}
 	}
+
+	err := c.elasticQuotaInfos.addPodIfNotPresent(c.namespaceIndex, pod)
+	if err != nil {
+		klog.ErrorS(err, "Failed to add Pod to its associated elasticQuota", "pod", klog.KObj(pod))
+		return framework.NewStatus(framework.Error, err.Error())
+	}
 	return framework.NewStatus(framework.Success, "")
 }
 
+// Unreserve deletes the pod from its elasticQuota. Since a gang is rejected as a whole, what remains
+// of the reservation of its PodGroup is released as well.
 func (c *CapacityScheduling) Unreserve(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) {
 	c.Lock()
 	defer c.Unlock()
 
-	elasticQuotaInfo := c.elasticQuotaInfos[pod.Namespace]
-	if elasticQuotaInfo != nil {
-		err := elasticQuotaInfo.deletePodIfPresent(pod)
-		if err != nil {
-			klog.ErrorS(err, "Failed to delete Pod from its associated elasticQuota", "pod", klog.KObj(pod))
+	err := c.elasticQuotaInfos.deletePodIfPresent(c.namespaceIndex, pod)
+	if err != nil {
+		klog.ErrorS(err, "Failed to delete Pod from its associated elasticQuota", "pod", klog.KObj(pod))
+	}
+	if eq := c.elasticQuotaInfos.getByNamespace(c.namespaceIndex, pod.Namespace); eq != nil {
+		c.elasticQuotaInfos.releaseGang(eq, util.GetPodGroupFullName(pod))
+	}
+}
+
+// computeGangRequest returns the request the PodGroup of the given pod has yet to reserve in the
+// given elasticQuotaInfo, along with its number of members yet to be scheduled. The request of the
+// gang is the minResources of its PodGroup, or the sum of the requests of its members if it has
+// none, minus the requests of the members already in the elasticQuotaInfo, and is at least the
+// request of the pod. It returns nil if the pod doesn't belong to a PodGroup.
+func (c *CapacityScheduling) computeGangRequest(eq *ElasticQuotaInfo, pod *v1.Pod, podReq *framework.Resource) (*framework.Resource, int32) {
+	pgName := util.GetPodGroupLabel(pod)
+	if pgName == "" || c.pgLister == nil || c.podLister == nil {
+		return nil, 0
+	}
+	pg, err := c.pgLister.PodGroups(pod.Namespace).Get(pgName)
+	if err != nil {
+		klog.V(5).InfoS("Failed to get PodGroup of pod", "pod", klog.KObj(pod), "podGroup", pgName, "err", err)
+		return nil, 0
+	}
+	members, err := c.podLister.Pods(pod.Namespace).List(labels.SelectorFromSet(labels.Set{v1alpha1.PodGroupLabel: pgName}))
+	if err != nil {
+		klog.ErrorS(err, "Failed to list members of PodGroup", "podGroup", klog.KObj(pg))
+		return nil, 0
+	}
+
+	gangReq := &framework.Resource{}
+	scheduledReq := &framework.Resource{}
+	var total, scheduled int32
+	for _, member := range members {
+		if member.DeletionTimestamp != nil || member.Status.Phase == v1.PodSucceeded || member.Status.Phase == v1.PodFailed {
+			continue
 		}
+		memberReq := computePodResourceRequest(member)
+		gangReq.Add(util.ResourceList(memberReq))
+		total++
+		if eq.pods.Has(string(member.UID)) {
+			scheduledReq.Add(util.ResourceList(memberReq))
+			scheduled++
+		}
+	}
+	if minResources := util.GetMinResources(pg); len(minResources) > 0 {
+		gangReq = framework.NewResource(minResources)
+		total = pg.Spec.MinMember
 	}
+
+	subtractResource(gangReq, *minResource(gangReq, scheduledReq))
+	gangReq.SetMaxResource(util.ResourceList(podReq))
+	return gangReq, max(total-scheduled, 1)
 }
 
 type preemptor struct {
+	util.PreemptorBase
 	fh    framework.Handle
 	state *framework.CycleState
+	// reclaimGracePeriod is the time the preemptor must have been unschedulable for before
+	// the pods of the quotas borrowing resources are selected as victims.
+	reclaimGracePeriod time.Duration
+	// preferRecentBorrowers makes the pods of the borrowing quotas that started most recently
+	// selected as victims first, whatever their priority.
+	preferRecentBorrowers bool
+
+	// reclaims holds, for each node, the victims of the quotas other than the preemptor's,
+	// along with their quota, so that they're told which quota reclaimed them once preempted.
+	reclaimsLock sync.Mutex
+	reclaims     map[string]map[*v1.Pod]string
 }
 
-func (p *preemptor) OrderedScoreFuncs(ctx context.Context, nodesToVictims map[string]*extenderv1.Victims) []func(node string) int64 {
-	return nil
+// reclaimGracePeriodElapsed checks whether the given pod has been unschedulable for at least
+// the reclaim grace period, i.e. whether it may preempt the pods of the borrowing quotas.
+func (p *preemptor) reclaimGracePeriodElapsed(pod *v1.Pod) bool {
+	if p.reclaimGracePeriod <= 0 {
+		return true
+	}
+	_, condition := podutil.GetPodCondition(&pod.Status, v1.PodScheduled)
+	if condition == nil || condition.Status != v1.ConditionFalse {
+		return false
+	}
+	return time.Since(condition.LastTransitionTime.Time) >= p.reclaimGracePeriod
 }
 
-func (p *preemptor) GetOffsetAndNumCandidates(n int32) (int32, int32) {
-	return 0, n
-}
+// recordReclaims emits an event on each victim the given pod reclaimed resources from on the given node.
+func (p *preemptor) recordReclaims(pod *v1.Pod, nodeName string) {
+	p.reclaimsLock.Lock()
+	defer p.reclaimsLock.Unlock()
 
-func (p *preemptor) CandidatesToVictimsMap(candidates []preemption.Candidate) map[string]*extenderv1.Victims {
-	m := make(map[string]*extenderv1.Victims)
-	for _, c := range candidates {
-		m[c.Name()] = c.Victims()
+	elasticQuotaSnapshotState, err := getElasticQuotaSnapshotState(p.state)
+	if err != nil {
+		return
+	}
+	preemptorElasticQuotaInfo := elasticQuotaSnapshotState.elasticQuotaInfos.getByNamespace(elasticQuotaSnapshotState.namespaceIndex, pod.Namespace)
+	if preemptorElasticQuotaInfo == nil {
+		return
+	}
+	for victim, quota := range p.reclaims[nodeName] {
+		p.fh.EventRecorder().Eventf(victim, pod, v1.EventTypeNormal, "Reclaimed", "Preempting",
+			"Preempted by pod %v/%v on node %v: ElasticQuota %v reclaimed the resources ElasticQuota %v borrowed",
+			pod.Namespace, pod.Name, nodeName, preemptorElasticQuotaInfo.Namespace, quota)
 	}
-	return m
 }
 
 // PodEligibleToPreemptOthers determines whether this pod should be considered
@@ -399,9 +589,9 @@ func (p *preemptor) CandidatesToVictimsMap(candidates []preemption.Candidate) ma
 // We look at the node that is nominated for this pod and as long as there are
 // terminating pods on the node, we don't consider this for preempting more pods.
 func (p *preemptor) PodEligibleToPreemptOthers(pod *v1.Pod, nominatedNodeStatus *framework.Status) (bool, string) {
-	if pod.Spec.PreemptionPolicy != nil && *pod.Spec.PreemptionPolicy == v1.PreemptNever {
+	if ok, msg := util.PreemptionPolicyAllows(pod); !ok {
 		klog.V(5).InfoS("Pod is not eligible for preemption because of its preemptionPolicy", "pod", klog.KObj(pod), "preemptionPolicy", v1.PreemptNever)
-		return false, "not eligible due to preemptionPolicy=Never."
+		return false, msg
 	}
 
 	preFilterState, err := getPreFilterState(p.state)
@@ -431,23 +621,24 @@ func (p *preemptor) PodEligibleToPreemptOthers(pod *v1.Pod, nominatedNodeStatus
 		}
 
 		podPriority := corev1helpers.PodPriority(pod)
-		preemptorEQInfo, preemptorWithEQ := elasticQuotaSnapshotState.elasticQuotaInfos[pod.Namespace]
-		if preemptorWithEQ {
+		preemptorEQInfo := elasticQuotaSnapshotState.elasticQuotaInfos.getByNamespace(elasticQuotaSnapshotState.namespaceIndex, pod.Namespace)
+		if preemptorEQInfo != nil {
 			moreThanMinWithPreemptor := preemptorEQInfo.usedOverMinWith(&preFilterState.nominatedPodsReqInEQWithPodReq)
 			for _, p := range nodeInfo.Pods {
 				// Checking terminating pods
 				if p.Pod.DeletionTimestamp != nil {
-					eqInfo, withEQ := elasticQuotaSnapshotState.elasticQuotaInfos[p.Pod.Namespace]
-					if !withEQ {
+					eqInfo := elasticQuotaSnapshotState.elasticQuotaInfos.getByNamespace(elasticQuotaSnapshotState.namespaceIndex, p.Pod.Namespace)
+					if eqInfo == nil {
 						continue
 					}
-					if p.Pod.Namespace == pod.Namespace && corev1helpers.PodPriority(p.Pod) < podPriority {
+					sameQuota := eqInfo.Namespace == preemptorEQInfo.Namespace
+					if sameQuota && corev1helpers.PodPriority(p.Pod) < podPriority {
 						// There is a terminating pod on the nominated node.
 						// If the terminating pod is in the same namespace with preemptor
 						// and it is less important than preemptor,
 						// return false to avoid preempting more pods.
 						return false, "not eligible due to a terminating pod on the nominated node."
-					} else if p.Pod.Namespace != pod.Namespace && !moreThanMinWithPreemptor && eqInfo.usedOverMin() {
+					} else if !sameQuota && !moreThanMinWithPreemptor && eqInfo.usedOverMin() {
 						// There is a terminating pod on the nominated node.
 						// The terminating pod isn't in the same namespace with preemptor.
 						// If moreThanMinWithPreemptor is false, it indicates that preemptor can preempt the pods in other EQs whose used is over min.
@@ -459,8 +650,7 @@ func (p *preemptor) PodEligibleToPreemptOthers(pod *v1.Pod, nominatedNodeStatus
 			}
 		} else {
 			for _, p := range nodeInfo.Pods {
-				_, withEQ := elasticQuotaSnapshotState.elasticQuotaInfos[p.Pod.Namespace]
-				if withEQ {
+				if elasticQuotaSnapshotState.elasticQuotaInfos.getByNamespace(elasticQuotaSnapshotState.namespaceIndex, p.Pod.Namespace) != nil {
 					continue
 				}
 				if p.Pod.DeletionTimestamp != nil && corev1helpers.PodPriority(p.Pod) < podPriority {
@@ -494,46 +684,50 @@ func (p *preemptor) SelectVictimsOnNode(
 	}
 
 	var nominatedPodsReqInEQWithPodReq framework.Resource
+	var nominatedPodsReqInScopeWithPodReq framework.Resource
 	var nominatedPodsReqWithPodReq framework.Resource
 	podReq := preFilterState.podReq
 
-	logger := klog.FromContext(ctx)
-	removePod := func(rpi *framework.PodInfo) error {
-		if err := nodeInfo.RemovePod(logger, rpi.Pod); err != nil {
-			return err
-		}
-		status := p.fh.RunPreFilterExtensionRemovePod(ctx, state, pod, rpi, nodeInfo)
-		if !status.IsSuccess() {
-			return status.AsError()
-		}
-		return nil
-	}
-	addPod := func(api *framework.PodInfo) error {
-		nodeInfo.AddPodInfo(api)
-		status := p.fh.RunPreFilterExtensionAddPod(ctx, state, pod, api, nodeInfo)
-		if !status.IsSuccess() {
-			return status.AsError()
-		}
-		return nil
-	}
+	sim := &util.VictimsSimulation{Handle: p.fh, State: state, Pod: pod, NodeInfo: nodeInfo}
 
 	elasticQuotaInfos := elasticQuotaSnapshotState.elasticQuotaInfos
+	namespaceIndex := elasticQuotaSnapshotState.namespaceIndex
 	podPriority := corev1helpers.PodPriority(pod)
-	preemptorElasticQuotaInfo, preemptorWithElasticQuota := elasticQuotaInfos[pod.Namespace]
+	preemptorElasticQuotaInfo := elasticQuotaInfos.getByNamespace(namespaceIndex, pod.Namespace)
+	preemptorWithElasticQuota := preemptorElasticQuotaInfo != nil
 
 	// sort the pods in node by the priority class
 	sort.Slice(nodeInfo.Pods, func(i, j int) bool { return !schedutil.MoreImportantPod(nodeInfo.Pods[i].Pod, nodeInfo.Pods[j].Pod) })
 
 	var potentialVictims []*framework.PodInfo
+	fairShareRatios := make(map[string]float64)
 	if preemptorWithElasticQuota {
+		// The pods of the other quotas are only selected as victims once the preemptor has
+		// been unschedulable for the reclaim grace period, so that the borrowers have time
+		// to give the resources back by themselves.
+		reclaimAllowed := p.reclaimGracePeriodElapsed(pod)
+		if !reclaimAllowed {
+			klog.V(5).InfoS("Reclaim grace period not elapsed, the pods of the other quotas are not selected as victims", "pod", klog.KObj(pod), "reclaimGracePeriod", p.reclaimGracePeriod)
+		}
 		nominatedPodsReqInEQWithPodReq = preFilterState.nominatedPodsReqInEQWithPodReq
+		nominatedPodsReqInScopeWithPodReq = preFilterState.nominatedPodsReqInScopeWithPodReq
 		nominatedPodsReqWithPodReq = preFilterState.nominatedPodsReqWithPodReq
 		moreThanMinWithPreemptor := preemptorElasticQuotaInfo.usedOverMinWith(&nominatedPodsReqInEQWithPodReq)
+		moreThanFairShareWithPreemptor := elasticQuotaInfos.usedOverFairShareWith(preemptorElasticQuotaInfo, &nominatedPodsReqInEQWithPodReq)
+		// The ratios of the usage of the quotas to their fair share are computed before any pod is removed.
+		for _, p := range nodeInfo.Pods {
+			if eqInfo := elasticQuotaInfos.getByNamespace(namespaceIndex, p.Pod.Namespace); eqInfo != nil {
+				if _, ok := fairShareRatios[eqInfo.Namespace]; !ok {
+					fairShareRatios[eqInfo.Namespace] = elasticQuotaInfos.fairShareRatio(eqInfo)
+				}
+			}
+		}
 		for _, p := range nodeInfo.Pods {
-			eqInfo, withEQ := elasticQuotaInfos[p.Pod.Namespace]
-			if !withEQ {
+			eqInfo := elasticQuotaInfos.getByNamespace(namespaceIndex, p.Pod.Namespace)
+			if eqInfo == nil {
 				continue
 			}
+			sameQuota := eqInfo.Namespace == preemptorElasticQuotaInfo.Namespace
 
 			if moreThanMinWithPreemptor {
 				// If Preemptor.Request + Quota.Used > Quota.Min:
@@ -541,9 +735,22 @@ func (p *preemptor) SelectVictimsOnNode(
 				// quotas. So that we will select the pods which subject to the
 				// same quota(namespace) with the lower priority than the
 				// preemptor's priority as potential victims in a node.
-				if p.Pod.Namespace == pod.Namespace && corev1helpers.PodPriority(p.Pod) < podPriority {
+				if sameQuota && corev1helpers.PodPriority(p.Pod) < podPriority {
+					potentialVictims = append(potentialVictims, p)
+					if err := sim.RemovePod(ctx, p); err != nil {
+						return nil, 0, framework.AsStatus(err)
+					}
+					continue
+				}
+
+				// If Preemptor.Request + Quota.Used is still within the fair share
+				// of the quota, the pods which subject to the quotas above their own
+				// fair share are selected as potential victims too, so that the
+				// unused min of the siblings is reclaimed from the quotas borrowing more
+				// than their share.
+				if !sameQuota && reclaimAllowed && !moreThanFairShareWithPreemptor && fairShareRatios[eqInfo.Namespace] > 1 {
 					potentialVictims = append(potentialVictims, p)
-					if err := removePod(p); err != nil {
+					if err := sim.RemovePod(ctx, p); err != nil {
 						return nil, 0, framework.AsStatus(err)
 					}
 				}
@@ -555,9 +762,9 @@ func (p *preemptor) SelectVictimsOnNode(
 				// will be chosen from Quotas that allocates more resources
 				// than its min, i.e., borrowing resources from other
 				// Quotas.
-				if p.Pod.Namespace != pod.Namespace && eqInfo.usedOverMin() {
+				if !sameQuota && reclaimAllowed && eqInfo.usedOverMin() {
 					potentialVictims = append(potentialVictims, p)
-					if err := removePod(p); err != nil {
+					if err := sim.RemovePod(ctx, p); err != nil {
 						return nil, 0, framework.AsStatus(err)
 					}
 				}
@@ -565,13 +772,12 @@ func (p *preemptor) SelectVictimsOnNode(
 		}
 	} else {
 		for _, p := range nodeInfo.Pods {
-			_, withEQ := elasticQuotaInfos[p.Pod.Namespace]
-			if withEQ {
+			if elasticQuotaInfos.getByNamespace(namespaceIndex, p.Pod.Namespace) != nil {
 				continue
 			}
 			if corev1helpers.PodPriority(p.Pod) < podPriority {
 				potentialVictims = append(potentialVictims, p)
-				if err := removePod(p); err != nil {
+				if err := sim.RemovePod(ctx, p); err != nil {
 					return nil, 0, framework.AsStatus(err)
 				}
 			}
@@ -590,117 +796,415 @@ func (p *preemptor) SelectVictimsOnNode(
 	// inter-pod affinity to one or more victims, but we have decided not to
 	// support this case for performance reasons. Having affinity to lower
 	// priority pods is not a recommended configuration anyway.
-	if s := p.fh.RunFilterPluginsWithNominatedPods(ctx, state, pod, nodeInfo); !s.IsSuccess() {
+	if s := sim.PodFits(ctx); !s.IsSuccess() {
 		return nil, 0, s
 	}
 
 	// If the quota.used + pod.request > quota.max or sum(quotas.used) + pod.request > sum(quotas.min)
 	// after removing all the lower priority pods,
 	// we are almost done and this node is not suitable for preemption.
+	// Since removing the pods copies the ElasticQuotaInfos shared with other snapshots, the
+	// preemptor's is looked up again to get its current usage.
 	if preemptorWithElasticQuota {
-		if preemptorElasticQuotaInfo.usedOverMaxWith(&podReq) ||
+		if elasticQuotaInfos.usedOverMaxWith(elasticQuotaInfos.getByNamespace(namespaceIndex, pod.Namespace), &podReq) ||
 			elasticQuotaInfos.aggregatedUsedOverMinWith(podReq) {
 			return nil, 0, framework.NewStatus(framework.Unschedulable, "global quota max exceeded")
 		}
+		// Likewise if the usage of the preemptor's PriorityClass + pod.request > the max of its scope.
+		if elasticQuotaInfos.usedOverScopedMaxWith(elasticQuotaInfos.getByNamespace(namespaceIndex, pod.Namespace), pod.Spec.PriorityClassName, &podReq) {
+			return nil, 0, framework.NewStatus(framework.Unschedulable, "scoped quota max exceeded")
+		}
 	}
 
-	var victims []*v1.Pod
-	numViolatingVictim := 0
-	sort.Slice(potentialVictims, func(i, j int) bool {
+	// The pods of the quotas farthest from the preemptor's quota in the quota tree come first,
+	// so that they are reprieved first: the preemptor reclaims resources from its siblings
+	// before crossing into the siblings of its parent, and so on. At the same distance, the
+	// pods of the quotas furthest above their fair share come last, so that they are
+	// preempted first. If preferRecentBorrowers is set, the pods of the other quotas that
+	// started most recently then come last, whatever their priority.
+	victimQuotas := make(map[*framework.PodInfo]string)
+	victimDistances := make(map[string]int)
+	if preemptorWithElasticQuota {
+		for _, pi := range potentialVictims {
+			eqInfo := elasticQuotaInfos.getByNamespace(namespaceIndex, pi.Pod.Namespace)
+			if eqInfo == nil {
+				continue
+			}
+			victimQuotas[pi] = eqInfo.Namespace
+			if _, ok := victimDistances[eqInfo.Namespace]; !ok {
+				victimDistances[eqInfo.Namespace] = elasticQuotaInfos.distance(preemptorElasticQuotaInfo, eqInfo)
+			}
+		}
+	}
+	sort.SliceStable(potentialVictims, func(i, j int) bool {
+		qi, qj := victimQuotas[potentialVictims[i]], victimQuotas[potentialVictims[j]]
+		if victimDistances[qi] != victimDistances[qj] {
+			return victimDistances[qi] > victimDistances[qj]
+		}
+		if fairShareRatios[qi] != fairShareRatios[qj] {
+			return fairShareRatios[qi] < fairShareRatios[qj]
+		}
+		if p.preferRecentBorrowers && preemptorWithElasticQuota && qi != preemptorElasticQuotaInfo.Namespace && qj != preemptorElasticQuotaInfo.Namespace {
+			if ti, tj := schedutil.GetPodStartTime(potentialVictims[i].Pod), schedutil.GetPodStartTime(potentialVictims[j].Pod); !ti.Equal(tj) {
+				return ti.Before(tj)
+			}
+		}
 		return schedutil.MoreImportantPod(potentialVictims[i].Pod, potentialVictims[j].Pod)
 	})
 	// Try to reprieve as many pods as possible. We first try to reprieve the PDB
 	// violating victims and then other non-violating ones. In both cases, we start
-	// from the highest priority victims.
-	violatingVictims, nonViolatingVictims := filterPodsWithPDBViolation(potentialVictims, pdbs)
-	reprievePod := func(pi *framework.PodInfo) (bool, error) {
-		if err := addPod(pi); err != nil {
-			return false, err
-		}
-		s := p.fh.RunFilterPluginsWithNominatedPods(ctx, state, pod, nodeInfo)
-		fits := s.IsSuccess()
-		if !fits {
-			if err := removePod(pi); err != nil {
-				return false, err
-			}
-			victims = append(victims, pi.Pod)
-			klog.V(5).InfoS("Found a potential preemption victim on node", "pod", klog.KObj(pi.Pod), "node", klog.KObj(nodeInfo.Node()))
-		}
-
-		if preemptorWithElasticQuota && (preemptorElasticQuotaInfo.usedOverMaxWith(&nominatedPodsReqInEQWithPodReq) || elasticQuotaInfos.aggregatedUsedOverMinWith(nominatedPodsReqWithPodReq)) {
-			if err := removePod(pi); err != nil {
-				return false, err
-			}
-			victims = append(victims, pi.Pod)
-			klog.V(5).InfoS("Found a potential preemption victim on node", "pod", klog.KObj(pi.Pod), " node", klog.KObj(nodeInfo.Node()))
+	// from the farthest and highest priority victims. A victim the preemptor fits with
+	// is still preempted while the preemptor's quota would exceed its max.
+	var mustPreempt func() bool
+	if preemptorWithElasticQuota {
+		mustPreempt = func() bool {
+			return elasticQuotaInfos.usedOverMaxWith(elasticQuotaInfos.getByNamespace(namespaceIndex, pod.Namespace), &nominatedPodsReqInEQWithPodReq) ||
+				elasticQuotaInfos.usedOverScopedMaxWith(elasticQuotaInfos.getByNamespace(namespaceIndex, pod.Namespace), pod.Spec.PriorityClassName, &nominatedPodsReqInScopeWithPodReq) ||
+				elasticQuotaInfos.aggregatedUsedOverMinWith(nominatedPodsReqWithPodReq)
 		}
-
-		return fits, nil
 	}
-	for _, pi := range violatingVictims {
-		if fits, err := reprievePod(pi); err != nil {
-			klog.ErrorS(err, "Failed to reprieve pod", "pod", klog.KObj(pi.Pod))
-			return nil, 0, framework.AsStatus(err)
-		} else if !fits {
-			numViolatingVictim++
-		}
+	victims, numViolatingVictim, err := sim.ReprieveVictims(ctx, potentialVictims, pdbs, mustPreempt)
+	if err != nil {
+		klog.ErrorS(err, "Failed to reprieve pods", "pod", klog.KObj(pod))
+		return nil, 0, framework.AsStatus(err)
 	}
-	// Now we try to reprieve non-violating victims.
-	for _, pi := range nonViolatingVictims {
-		if _, err := reprievePod(pi); err != nil {
-			klog.ErrorS(err, "Failed to reprieve pod", "pod", klog.KObj(pi.Pod))
-			return nil, 0, framework.AsStatus(err)
+	if preemptorWithElasticQuota {
+		reclaims := make(map[*v1.Pod]string)
+		for _, victim := range victims {
+			if eqInfo := elasticQuotaInfos.getByNamespace(namespaceIndex, victim.Namespace); eqInfo != nil && eqInfo.Namespace != preemptorElasticQuotaInfo.Namespace {
+				reclaims[victim] = eqInfo.Namespace
+			}
 		}
+		p.reclaimsLock.Lock()
+		if p.reclaims == nil {
+			p.reclaims = make(map[string]map[*v1.Pod]string)
+		}
+		p.reclaims[nodeInfo.Node().Name] = reclaims
+		p.reclaimsLock.Unlock()
 	}
 	return victims, numViolatingVictim, framework.NewStatus(framework.Success)
 }
 
 func (c *CapacityScheduling) addElasticQuota(obj interface{}) {
 	eq := obj.(*v1alpha1.ElasticQuota)
-	oldElasticQuotaInfo := c.elasticQuotaInfos[eq.Namespace]
-	if oldElasticQuotaInfo != nil {
-		return
-	}
-
-	elasticQuotaInfo := newElasticQuotaInfo(eq.Namespace, eq.Spec.Min, eq.Spec.Max, nil)
-
 	c.Lock()
 	defer c.Unlock()
-	c.elasticQuotaInfos[eq.Namespace] = elasticQuotaInfo
+	if c.elasticQuotas[eq.Namespace] != nil {
+		return
+	}
+	c.setElasticQuota(eq)
+	c.addElasticQuotaIfNotPresent(eq)
 }
 
 func (c *CapacityScheduling) updateElasticQuota(oldObj, newObj interface{}) {
 	oldEQ := oldObj.(*v1alpha1.ElasticQuota)
 	newEQ := newObj.(*v1alpha1.ElasticQuota)
-	newEQInfo := newElasticQuotaInfo(newEQ.Namespace, newEQ.Spec.Min, newEQ.Spec.Max, nil)
+	newEQInfo, err := c.newElasticQuotaInfoFor(newEQ)
+	if err != nil {
+		klog.ErrorS(err, "Failed to resolve the namespaces of elasticQuota", "elasticQuota", klog.KObj(newEQ))
+		return
+	}
 
 	c.Lock()
 	defer c.Unlock()
 
+	c.setElasticQuota(newEQ)
 	oldEQInfo := c.elasticQuotaInfos[oldEQ.Namespace]
-	if oldEQInfo != nil {
-		newEQInfo.pods = oldEQInfo.pods
-		newEQInfo.Used = oldEQInfo.Used
+	if oldEQInfo == nil {
+		c.addElasticQuotaInfo(newEQInfo)
+		return
 	}
+	if !c.resolveOverlaps(newEQInfo) {
+		c.removeElasticQuotaInfo(oldEQ.Namespace)
+		c.addSkippedElasticQuotas()
+		return
+	}
+
+	// The namespace selector may have changed, so the usage of the namespaces that are no longer
+	// or newly selected is moved first.
+	c.setNamespaces(oldEQInfo, newEQInfo.namespaces)
+	// The parent may have changed, so the ElasticQuota is moved in the tree along with its usage.
+	c.elasticQuotaInfos.unlink(oldEQ.Namespace)
+	newEQInfo.pods = oldEQInfo.pods
+	newEQInfo.gangs = oldEQInfo.gangs
+	newEQInfo.Used = oldEQInfo.Used
+	newEQInfo.ScopedUsed = oldEQInfo.ScopedUsed
 	c.elasticQuotaInfos[newEQ.Namespace] = newEQInfo
+	c.elasticQuotaInfos.link(newEQ.Namespace)
+	// The namespaces no longer selected may be selected by a skipped ElasticQuota.
+	c.addSkippedElasticQuotas()
 }
 
 func (c *CapacityScheduling) deleteElasticQuota(obj interface{}) {
-	elasticQuota := obj.(*v1alpha1.ElasticQuota)
+	var elasticQuota *v1alpha1.ElasticQuota
+	switch t := obj.(type) {
+	case *v1alpha1.ElasticQuota:
+		elasticQuota = t
+	case cache.DeletedFinalStateUnknown:
+		elasticQuota = t.Obj.(*v1alpha1.ElasticQuota)
+	default:
+		return
+	}
 	c.Lock()
 	defer c.Unlock()
-	delete(c.elasticQuotaInfos, elasticQuota.Namespace)
+	delete(c.elasticQuotas, elasticQuota.Namespace)
+	if c.elasticQuotaInfos[elasticQuota.Namespace] == nil {
+		return
+	}
+	c.removeElasticQuotaInfo(elasticQuota.Namespace)
+	// The ElasticQuotas skipped because they overlapped with the deleted one may apply now.
+	c.addSkippedElasticQuotas()
+}
+
+// setElasticQuota records the given ElasticQuota among the known ones. c must be locked.
+func (c *CapacityScheduling) setElasticQuota(eq *v1alpha1.ElasticQuota) {
+	if c.elasticQuotas == nil {
+		c.elasticQuotas = make(map[string]*v1alpha1.ElasticQuota)
+	}
+	c.elasticQuotas[eq.Namespace] = eq
+}
+
+// addElasticQuotaIfNotPresent adds the ElasticQuotaInfo of the given ElasticQuota, unless
+// already present. c must be locked.
+func (c *CapacityScheduling) addElasticQuotaIfNotPresent(eq *v1alpha1.ElasticQuota) {
+	if c.elasticQuotaInfos[eq.Namespace] != nil {
+		return
+	}
+	elasticQuotaInfo, err := c.newElasticQuotaInfoFor(eq)
+	if err != nil {
+		klog.ErrorS(err, "Failed to resolve the namespaces of elasticQuota", "elasticQuota", klog.KObj(eq))
+		return
+	}
+	c.addElasticQuotaInfo(elasticQuotaInfo)
+}
+
+// addSkippedElasticQuotas adds the ElasticQuotaInfos of the known ElasticQuotas skipped because
+// they overlapped with another one, oldest first. c must be locked.
+func (c *CapacityScheduling) addSkippedElasticQuotas() {
+	var skipped []*v1alpha1.ElasticQuota
+	for namespace, eq := range c.elasticQuotas {
+		if c.elasticQuotaInfos[namespace] == nil {
+			skipped = append(skipped, eq)
+		}
+	}
+	sort.Slice(skipped, func(i, j int) bool {
+		if !skipped[i].CreationTimestamp.Equal(&skipped[j].CreationTimestamp) {
+			return skipped[i].CreationTimestamp.Before(&skipped[j].CreationTimestamp)
+		}
+		return skipped[i].Namespace < skipped[j].Namespace
+	})
+	for _, eq := range skipped {
+		c.addElasticQuotaIfNotPresent(eq)
+	}
+}
+
+// newElasticQuotaInfoFor returns the ElasticQuotaInfo of the given ElasticQuota, resolving
+// the namespaces selected by its namespace selector, if any.
+func (c *CapacityScheduling) newElasticQuotaInfoFor(eq *v1alpha1.ElasticQuota) (*ElasticQuotaInfo, error) {
+	elasticQuotaInfo := newElasticQuotaInfo(eq.Namespace, eq.Spec.Min, eq.Spec.Max, nil)
+	elasticQuotaInfo.creationTimestamp = eq.CreationTimestamp
+	elasticQuotaInfo.Name = eq.Name
+	elasticQuotaInfo.Parent = getParentKey(eq)
+	if eq.Spec.Weight != nil {
+		elasticQuotaInfo.Weight = int64(*eq.Spec.Weight)
+	}
+	for _, scope := range eq.Spec.Scopes {
+		if elasticQuotaInfo.ScopedMax == nil {
+			elasticQuotaInfo.ScopedMax = make(map[string]*framework.Resource, len(eq.Spec.Scopes))
+		}
+		elasticQuotaInfo.ScopedMax[scope.PriorityClassName] = newScopedMax(scope.Max)
+	}
+	if eq.Spec.NamespaceSelector == nil {
+		return elasticQuotaInfo, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(eq.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := c.nsLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	elasticQuotaInfo.selector = selector
+	elasticQuotaInfo.namespaces = sets.NewString()
+	for _, ns := range namespaces {
+		elasticQuotaInfo.namespaces.Insert(ns.Name)
+	}
+	return elasticQuotaInfo, nil
+}
+
+// addElasticQuotaInfo adds the given ElasticQuotaInfo, unless it overlaps with an older one,
+// along with the pods already assigned in the namespaces it applies to. c must be locked.
+func (c *CapacityScheduling) addElasticQuotaInfo(elasticQuotaInfo *ElasticQuotaInfo) {
+	if !c.resolveOverlaps(elasticQuotaInfo) {
+		return
+	}
+	c.elasticQuotaInfos[elasticQuotaInfo.Namespace] = elasticQuotaInfo
+	c.namespaceIndex = c.namespaceIndex.with(elasticQuotaInfo.Namespace, elasticQuotaInfo.namespaces, nil)
+	c.elasticQuotaInfos.link(elasticQuotaInfo.Namespace)
+	// The pods of the namespaces may have been assigned before the ElasticQuota was added.
+	for _, namespace := range elasticQuotaInfo.getNamespaces().UnsortedList() {
+		c.addAssignedPods(namespace)
+	}
+}
+
+// resolveOverlaps checks whether the given ElasticQuotaInfo applies despite the ElasticQuotaInfos
+// it overlaps with. As in the controller, the oldest of overlapping ElasticQuotas applies: if they
+// are all newer, they are removed, and added again once the given one is deleted or no longer
+// overlaps with them. c must be locked.
+func (c *CapacityScheduling) resolveOverlaps(elasticQuotaInfo *ElasticQuotaInfo) bool {
+	overlapping := c.elasticQuotaInfos.overlapping(c.namespaceIndex, elasticQuotaInfo)
+	for _, other := range overlapping {
+		if other.createdBefore(elasticQuotaInfo) {
+			klog.ErrorS(nil, "Ignoring elasticQuota overlapping with an older elasticQuota", "elasticQuotaNamespace", elasticQuotaInfo.Namespace, "overlappingElasticQuotaNamespace", other.Namespace)
+			return false
+		}
+	}
+	for _, other := range overlapping {
+		klog.ErrorS(nil, "Ignoring elasticQuota overlapping with an older elasticQuota", "elasticQuotaNamespace", other.Namespace, "overlappingElasticQuotaNamespace", elasticQuotaInfo.Namespace)
+		c.removeElasticQuotaInfo(other.Namespace)
+	}
+	return true
+}
+
+// removeElasticQuotaInfo removes the ElasticQuotaInfo of the given namespace from the tree
+// and the index. c must be locked.
+func (c *CapacityScheduling) removeElasticQuotaInfo(namespace string) {
+	elasticQuotaInfo := c.elasticQuotaInfos[namespace]
+	c.elasticQuotaInfos.unlink(namespace)
+	delete(c.elasticQuotaInfos, namespace)
+	c.namespaceIndex = c.namespaceIndex.with(namespace, nil, elasticQuotaInfo.namespaces)
+}
+
+// setNamespaces sets the namespaces an ElasticQuotaInfo applies to, moving the usage of
+// the assigned pods of the namespaces it no longer or newly applies to. c must be locked.
+func (c *CapacityScheduling) setNamespaces(elasticQuotaInfo *ElasticQuotaInfo, namespaces sets.String) {
+	oldNamespaces := elasticQuotaInfo.getNamespaces()
+	newNamespaces := namespaces
+	if newNamespaces == nil {
+		newNamespaces = sets.NewString(elasticQuotaInfo.Namespace)
+	}
+	for _, namespace := range oldNamespaces.Difference(newNamespaces).UnsortedList() {
+		c.deleteAssignedPods(namespace)
+	}
+	c.namespaceIndex = c.namespaceIndex.with(elasticQuotaInfo.Namespace, namespaces, elasticQuotaInfo.namespaces)
+	elasticQuotaInfo.namespaces = namespaces
+	elasticQuotaInfo.generation = nextGeneration()
+	for _, namespace := range newNamespaces.Difference(oldNamespaces).UnsortedList() {
+		c.addAssignedPods(namespace)
+	}
+}
+
+// addAssignedPods adds the assigned pods of the given namespace to the ElasticQuotaInfo
+// that applies to it. c must be locked.
+func (c *CapacityScheduling) addAssignedPods(namespace string) {
+	for _, pod := range c.listAssignedPods(namespace) {
+		if err := c.elasticQuotaInfos.addPodIfNotPresent(c.namespaceIndex, pod); err != nil {
+			klog.ErrorS(err, "Failed to add Pod to its associated elasticQuota", "pod", klog.KObj(pod))
+		}
+	}
+}
+
+// deleteAssignedPods deletes the assigned pods of the given namespace from the
+// ElasticQuotaInfo that applies to it. c must be locked.
+func (c *CapacityScheduling) deleteAssignedPods(namespace string) {
+	for _, pod := range c.listAssignedPods(namespace) {
+		if err := c.elasticQuotaInfos.deletePodIfPresent(c.namespaceIndex, pod); err != nil {
+			klog.ErrorS(err, "Failed to delete Pod from its associated elasticQuota", "pod", klog.KObj(pod))
+		}
+	}
+}
+
+// listAssignedPods lists the pods of the given namespace that count towards the usage of an elasticQuota.
+func (c *CapacityScheduling) listAssignedPods(namespace string) []*v1.Pod {
+	pods, err := c.podLister.Pods(namespace).List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Failed to list pods", "namespace", namespace)
+		return nil
+	}
+	var assignedPods []*v1.Pod
+	for _, pod := range pods {
+		if usage.IsPodCounted(pod) {
+			assignedPods = append(assignedPods, pod)
+		}
+	}
+	return assignedPods
+}
+
+func (c *CapacityScheduling) addNamespace(obj interface{}) {
+	ns, ok := obj.(*v1.Namespace)
+	if !ok {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.syncNamespace(ns)
+}
+
+func (c *CapacityScheduling) updateNamespace(oldObj, newObj interface{}) {
+	oldNs, ok := oldObj.(*v1.Namespace)
+	if !ok {
+		return
+	}
+	newNs, ok := newObj.(*v1.Namespace)
+	if !ok || labels.Equals(oldNs.Labels, newNs.Labels) {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.syncNamespace(newNs)
+}
+
+// syncNamespace adds the given namespace to or deletes it from the ElasticQuotaInfos whose
+// namespace selector selects it or no longer selects it. A namespace that another ElasticQuota
+// already applies to is not added. c must be locked.
+func (c *CapacityScheduling) syncNamespace(ns *v1.Namespace) {
+	for _, elasticQuotaInfo := range c.elasticQuotaInfos {
+		if elasticQuotaInfo.selector == nil {
+			continue
+		}
+		selected := elasticQuotaInfo.selector.Matches(labels.Set(ns.Labels))
+		if selected == elasticQuotaInfo.namespaces.Has(ns.Name) {
+			continue
+		}
+		namespaces := sets.NewString(elasticQuotaInfo.namespaces.UnsortedList()...)
+		if selected {
+			if other := c.elasticQuotaInfos.getByNamespace(c.namespaceIndex, ns.Name); other != nil {
+				klog.ErrorS(nil, "Namespace selected by elasticQuota is already subject to another elasticQuota", "namespace", ns.Name, "elasticQuotaNamespace", elasticQuotaInfo.Namespace, "overlappingElasticQuotaNamespace", other.Namespace)
+				continue
+			}
+			namespaces.Insert(ns.Name)
+		} else {
+			namespaces.Delete(ns.Name)
+		}
+		c.setNamespaces(elasticQuotaInfo, namespaces)
+	}
+}
+
+// getParentKey returns the namespace and name of the parent of the given ElasticQuota, if any.
+func getParentKey(eq *v1alpha1.ElasticQuota) types.NamespacedName {
+	if eq.Spec.Parent == nil {
+		return types.NamespacedName{}
+	}
+	key := types.NamespacedName{Namespace: eq.Spec.Parent.Namespace, Name: eq.Spec.Parent.Name}
+	if key.Namespace == "" {
+		key.Namespace = eq.Namespace
+	}
+	return key
 }
 
 func (c *CapacityScheduling) addPod(obj interface{}) {
 	pod := obj.(*v1.Pod)
+	if !usage.IsPodCounted(pod) {
+		return
+	}
 
 	c.Lock()
 	defer c.Unlock()
 
-	elasticQuotaInfo := c.elasticQuotaInfos[pod.Namespace]
-	// If elasticQuotaInfo is nil, try to list ElasticQuotas through elasticQuotaLister
-	if elasticQuotaInfo == nil {
+	// If no elasticQuotaInfo applies to the namespace, try to list ElasticQuotas through elasticQuotaLister
+	if c.elasticQuotaInfos.getByNamespace(c.namespaceIndex, pod.Namespace) == nil && c.elasticQuotaInfos[pod.Namespace] == nil && c.elasticQuotas[pod.Namespace] == nil {
 		var eqList v1alpha1.ElasticQuotaList
 		if err := c.client.List(context.Background(), &eqList, client.InNamespace(pod.Namespace)); err != nil {
 			klog.ErrorS(err, "Failed to get elasticQuota", "elasticQuota", pod.Namespace)
@@ -715,13 +1219,13 @@ func (c *CapacityScheduling) addPod(obj interface{}) {
 
 		if len(eqs) > 0 {
 			// only one elasticquota is supported in each namespace
-			eq := eqs[0]
-			elasticQuotaInfo = newElasticQuotaInfo(eq.Namespace, eq.Spec.Min, eq.Spec.Max, nil)
-			c.elasticQuotaInfos[eq.Namespace] = elasticQuotaInfo
+			eq := &eqs[0]
+			c.setElasticQuota(eq)
+			c.addElasticQuotaIfNotPresent(eq)
 		}
 	}
 
-	err := elasticQuotaInfo.addPodIfNotPresent(pod)
+	err := c.elasticQuotaInfos.addPodIfNotPresent(c.namespaceIndex, pod)
 	if err != nil {
 		klog.ErrorS(err, "Failed to add Pod to its associated elasticQuota", "pod", klog.KObj(pod))
 	}
@@ -731,20 +1235,14 @@ func (c *CapacityScheduling) updatePod(oldObj, newObj interface{}) {
 	oldPod := oldObj.(*v1.Pod)
 	newPod := newObj.(*v1.Pod)
 
-	if oldPod.Status.Phase == v1.PodSucceeded || oldPod.Status.Phase == v1.PodFailed {
-		return
-	}
-
-	if newPod.Status.Phase != v1.PodRunning && newPod.Status.Phase != v1.PodPending {
+	// Pods stop counting towards their elasticQuota once they terminate or are being deleted.
+	if usage.IsPodCounted(oldPod) && !usage.IsPodCounted(newPod) {
 		c.Lock()
 		defer c.Unlock()
 
-		elasticQuotaInfo := c.elasticQuotaInfos[newPod.Namespace]
-		if elasticQuotaInfo != nil {
-			err := elasticQuotaInfo.deletePodIfPresent(newPod)
-			if err != nil {
-				klog.ErrorS(err, "Failed to delete Pod from its associated elasticQuota", "pod", klog.KObj(newPod))
-			}
+		err := c.elasticQuotaInfos.deletePodIfPresent(c.namespaceIndex, newPod)
+		if err != nil {
+			klog.ErrorS(err, "Failed to delete Pod from its associated elasticQuota", "pod", klog.KObj(newPod))
 		}
 	}
 }
@@ -754,23 +1252,43 @@ func (c *CapacityScheduling) deletePod(obj interface{}) {
 	c.Lock()
 	defer c.Unlock()
 
-	elasticQuotaInfo := c.elasticQuotaInfos[pod.Namespace]
-	if elasticQuotaInfo != nil {
-		err := elasticQuotaInfo.deletePodIfPresent(pod)
-		if err != nil {
-			klog.ErrorS(err, "Failed to delete Pod from its associated elasticQuota", "pod", klog.KObj(pod))
-		}
+	err := c.elasticQuotaInfos.deletePodIfPresent(c.namespaceIndex, pod)
+	if err != nil {
+		klog.ErrorS(err, "Failed to delete Pod from its associated elasticQuota", "pod", klog.KObj(pod))
 	}
 }
 
-// getElasticQuotasSnapshot will return the snapshot of elasticQuotas.
+// snapshotElasticQuota returns the snapshot of elasticQuotas. Only the elasticQuotaInfos that
+// changed since the previous snapshot are copied: the others are shared with it, and copied
+// on write should the snapshot be modified.
 func (c *CapacityScheduling) snapshotElasticQuota() *ElasticQuotaSnapshotState {
 	c.RLock()
 	defer c.RUnlock()
+	c.snapshotLock.Lock()
+	defer c.snapshotLock.Unlock()
 
-	elasticQuotaInfosDeepCopy := c.elasticQuotaInfos.clone()
+	if c.snapshot == nil {
+		c.snapshot = make(map[string]*elasticQuotaSnapshotEntry, len(c.elasticQuotaInfos))
+	}
+	elasticQuotaInfos := make(ElasticQuotaInfos, len(c.elasticQuotaInfos))
+	for key, elasticQuotaInfo := range c.elasticQuotaInfos {
+		entry := c.snapshot[key]
+		if entry == nil || entry.source != elasticQuotaInfo || entry.generation != elasticQuotaInfo.generation {
+			info := elasticQuotaInfo.clone()
+			info.shared = true
+			entry = &elasticQuotaSnapshotEntry{source: elasticQuotaInfo, generation: elasticQuotaInfo.generation, info: info}
+			c.snapshot[key] = entry
+		}
+		elasticQuotaInfos[key] = entry.info
+	}
+	for key := range c.snapshot {
+		if c.elasticQuotaInfos[key] == nil {
+			delete(c.snapshot, key)
+		}
+	}
 	return &ElasticQuotaSnapshotState{
-		elasticQuotaInfos: elasticQuotaInfosDeepCopy,
+		elasticQuotaInfos: elasticQuotaInfos,
+		namespaceIndex:    c.namespaceIndex,
 	}
 }
 
@@ -807,102 +1325,9 @@ func getPDBLister(informerFactory informers.SharedInformerFactory) policylisters
 }
 
 // computePodResourceRequest returns a framework.Resource that covers the largest
-// width in each resource dimension. Because init-containers run sequentially, we collect
-// the max in each dimension iteratively. In contrast, we sum the resource vectors for
-// regular containers since they run simultaneously.
-//
-// If Pod Overhead is specified and the feature gate is set, the resources defined for Overhead
-// are added to the calculated Resource request sum
-//
-// Example:
-//
-// Pod:
-//
-//	InitContainers
-//	  IC1:
-//	    CPU: 2
-//	    Memory: 1G
-//	  IC2:
-//	    CPU: 2
-//	    Memory: 3G
-//	Containers
-//	  C1:
-//	    CPU: 2
-//	    Memory: 1G
-//	  C2:
-//	    CPU: 1
-//	    Memory: 1G
-//
-// Result: CPU: 3, Memory: 3G
+// width in each resource dimension, as computed by usage.PodRequest.
 func computePodResourceRequest(pod *v1.Pod) *framework.Resource {
-	result := &framework.Resource{}
-	for _, container := range pod.Spec.Containers {
-		result.Add(container.Resources.Requests)
-	}
-
-	// take max_resource(sum_pod, any_init_container)
-	for _, container := range pod.Spec.InitContainers {
-		result.SetMaxResource(container.Resources.Requests)
-	}
-
-	// If Overhead is being utilized, add to the total requests for the pod
-	if pod.Spec.Overhead != nil {
-		result.Add(pod.Spec.Overhead)
-	}
-
-	return result
-}
-
-// filterPodsWithPDBViolation groups the given "pods" into two groups of "violatingPods"
-// and "nonViolatingPods" based on whether their PDBs will be violated if they are
-// preempted.
-// This function is stable and does not change the order of received pods. So, if it
-// receives a sorted list, grouping will preserve the order of the input list.
-func filterPodsWithPDBViolation(podInfos []*framework.PodInfo, pdbs []*policy.PodDisruptionBudget) (violatingPods, nonViolatingPods []*framework.PodInfo) {
-	pdbsAllowed := make([]int32, len(pdbs))
-	for i, pdb := range pdbs {
-		pdbsAllowed[i] = pdb.Status.DisruptionsAllowed
-	}
-
-	for _, podInfo := range podInfos {
-		pod := podInfo.Pod
-		pdbForPodIsViolated := false
-		// A pod with no labels will not match any PDB. So, no need to check.
-		if len(pod.Labels) != 0 {
-			for i, pdb := range pdbs {
-				if pdb.Namespace != pod.Namespace {
-					continue
-				}
-				selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
-				if err != nil {
-					continue
-				}
-				// A PDB with a nil or empty selector matches nothing.
-				if selector.Empty() || !selector.Matches(labels.Set(pod.Labels)) {
-					continue
-				}
-
-				// Existing in DisruptedPods means it has been processed in API server,
-				// we don't treat it as a violating case.
-				if _, exist := pdb.Status.DisruptedPods[pod.Name]; exist {
-					continue
-				}
-				// Only decrement the matched pdb when it's not in its <DisruptedPods>;
-				// otherwise we may over-decrement the budget number.
-				pdbsAllowed[i]--
-				// We have found a matching PDB.
-				if pdbsAllowed[i] < 0 {
-					pdbForPodIsViolated = true
-				}
-			}
-		}
-		if pdbForPodIsViolated {
-			violatingPods = append(violatingPods, podInfo)
-		} else {
-			nonViolatingPods = append(nonViolatingPods, podInfo)
-		}
-	}
-	return violatingPods, nonViolatingPods
+	return framework.NewResource(usage.PodRequest(pod))
 }
 
 // assignedPod selects pods that are assigned (scheduled and running).
@@ -18,9 +18,12 @@ package capacityscheduling
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 
@@ -28,8 +31,11 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	clientsetfake "k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/events"
 	extenderv1 "k8s.io/kube-scheduler/extender/v1"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
@@ -46,6 +52,7 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	pglister "sigs.k8s.io/scheduler-plugins/pkg/generated/listers/scheduling/v1alpha1"
 	testutil "sigs.k8s.io/scheduler-plugins/test/util"
 )
 
@@ -182,6 +189,83 @@ func TestPreFilter(t *testing.T) {
 	}
 }
 
+func TestPreFilterScopes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fwk, err := tf.NewFramework(
+		ctx, []tf.RegisterPluginFunc{
+			tf.RegisterQueueSortPlugin(queuesort.Name, queuesort.New),
+			tf.RegisterBindPlugin(defaultbinder.Name, defaultbinder.New),
+		}, "",
+		frameworkruntime.WithPodNominator(testutil.NewPodNominator(nil)),
+		frameworkruntime.WithSnapshotSharedLister(testutil.NewFakeSharedLister(make([]*v1.Pod, 0), make([]*v1.Node, 0))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The preemptible pods may only use 2 of the 4 GPUs of the quota.
+	resources := v1.ResourceList{
+		v1.ResourceCPU:    *resource.NewMilliQuantity(1000, resource.DecimalSI),
+		v1.ResourceMemory: *resource.NewQuantity(1000, resource.DecimalSI),
+		ResourceGPU:       *resource.NewQuantity(4, resource.DecimalSI),
+	}
+	eq := makeEQ("ns1", "eq1", resources, resources)
+	eq.Spec.Scopes = []v1alpha1.ElasticQuotaScope{
+		{PriorityClassName: "preemptible", Max: v1.ResourceList{ResourceGPU: *resource.NewQuantity(2, resource.DecimalSI)}},
+	}
+	cs := &CapacityScheduling{elasticQuotaInfos: NewElasticQuotaInfos(), fh: fwk, podLister: newPodLister()}
+	eqInfo, err := cs.newElasticQuotaInfoFor(eq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.addElasticQuotaInfo(eqInfo)
+
+	makeScopedPod := func(name, priorityClassName string, gpuReq int64) *v1.Pod {
+		pod := makePod(name, "ns1", 10, 10, gpuReq, midPriority, name, "node-a")
+		pod.Spec.PriorityClassName = priorityClassName
+		return pod
+	}
+	if err := cs.elasticQuotaInfos.addPodIfNotPresent(cs.namespaceIndex, makeScopedPod("ns1-p1", "preemptible", 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		pod      *v1.Pod
+		expected framework.Code
+	}{
+		{
+			name:     "preemptible pod over the scoped max",
+			pod:      makeScopedPod("ns1-p2", "preemptible", 1),
+			expected: framework.Unschedulable,
+		},
+		{
+			name:     "preemptible pod without GPU",
+			pod:      makeScopedPod("ns1-p3", "preemptible", 0),
+			expected: framework.Success,
+		},
+		{
+			name:     "production pod within the max",
+			pod:      makeScopedPod("ns1-p4", "production", 2),
+			expected: framework.Success,
+		},
+		{
+			name:     "production pod over the max",
+			pod:      makeScopedPod("ns1-p5", "production", 3),
+			expected: framework.Unschedulable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := cs.PreFilter(ctx, framework.NewCycleState(), tt.pod); got.Code() != tt.expected {
+				t.Errorf("expected %v, got %v : %v", tt.expected, got.Code(), got.Message())
+			}
+		})
+	}
+}
+
 func TestPostFilter(t *testing.T) {
 	res := map[v1.ResourceName]string{v1.ResourceMemory: "150"}
 	tests := []struct {
@@ -473,7 +557,7 @@ func TestReserve(t *testing.T) {
 				if got.Code() != tt.expectedCodes[i] {
 					t.Errorf("expected %v, got %v : %v", tt.expected[i], got.Code(), got.Message())
 				}
-				if !reflect.DeepEqual(cs.elasticQuotaInfos["ns1"], tt.expected[i]["ns1"]) {
+				if !equalElasticQuotaInfo(cs.elasticQuotaInfos["ns1"], tt.expected[i]["ns1"]) {
 					t.Errorf("expected %v, got %v", tt.expected[i]["ns1"], cs.elasticQuotaInfos["ns1"])
 				}
 			}
@@ -592,7 +676,7 @@ func TestUnreserve(t *testing.T) {
 			state := framework.NewCycleState()
 			for i, pod := range tt.pods {
 				cs.Unreserve(nil, state, pod, "node-a")
-				if !reflect.DeepEqual(cs.elasticQuotaInfos["ns1"], tt.expected[i]["ns1"]) {
+				if !equalElasticQuotaInfo(cs.elasticQuotaInfos["ns1"], tt.expected[i]["ns1"]) {
 					t.Errorf("expected %#v, got %#v", tt.expected[i]["ns1"].Used, cs.elasticQuotaInfos["ns1"].Used)
 				}
 			}
@@ -600,6 +684,188 @@ func TestUnreserve(t *testing.T) {
 	}
 }
 
+func TestGangAdmission(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var registerPlugins []tf.RegisterPluginFunc
+	registeredPlugins := append(
+		registerPlugins,
+		tf.RegisterQueueSortPlugin(queuesort.Name, queuesort.New),
+		tf.RegisterBindPlugin(defaultbinder.Name, defaultbinder.New),
+	)
+	fwk, err := tf.NewFramework(
+		ctx, registeredPlugins, "",
+		frameworkruntime.WithPodNominator(testutil.NewPodNominator(nil)),
+		frameworkruntime.WithSnapshotSharedLister(testutil.NewFakeSharedLister(make([]*v1.Pod, 0), make([]*v1.Node, 0))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	makeMember := func(name, pgName string) *v1.Pod {
+		pod := makePod(name, "ns1", 300, 0, 0, 0, name, "")
+		pod.Labels = map[string]string{v1alpha1.PodGroupLabel: pgName}
+		return pod
+	}
+	// pg1 has no minResources, so its request is the sum of the requests of its members.
+	pg1 := []*v1.Pod{makeMember("pg1-p1", "pg1"), makeMember("pg1-p2", "pg1"), makeMember("pg1-p3", "pg1")}
+	// pg2 needs 1200 of memory to run, which doesn't fit in the max of the elasticQuota.
+	pg2 := []*v1.Pod{makeMember("pg2-p1", "pg2")}
+	other := makePod("other", "ns1", 200, 0, 0, 0, "other", "")
+
+	informerFactory := informers.NewSharedInformerFactory(clientsetfake.NewSimpleClientset(), 0)
+	podStore := informerFactory.Core().V1().Pods().Informer().GetStore()
+	for _, pod := range append(append(pg1, pg2...), other) {
+		if err := podStore.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pgIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pg := range []*v1alpha1.PodGroup{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pg1"}, Spec: v1alpha1.PodGroupSpec{MinMember: 3}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pg2"}, Spec: v1alpha1.PodGroupSpec{
+			MinMember:    4,
+			MinResources: v1.ResourceList{v1.ResourceMemory: *resource.NewQuantity(1200, resource.DecimalSI)},
+		}},
+	} {
+		if err := pgIndexer.Add(pg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cs := &CapacityScheduling{
+		elasticQuotaInfos: ElasticQuotaInfos{
+			"ns1": newElasticQuotaInfo("ns1", makeResourceList(0, 1000), makeResourceList(0, 1000), nil),
+		},
+		fh:        fwk,
+		podLister: informerFactory.Core().V1().Pods().Lister(),
+		pgLister:  pglister.NewPodGroupLister(pgIndexer),
+	}
+	used := func() int64 {
+		return cs.elasticQuotaInfos["ns1"].Used.Memory
+	}
+
+	if _, got := cs.PreFilter(ctx, framework.NewCycleState(), pg2[0]); got.Code() != framework.Unschedulable {
+		t.Errorf("expected the first member of pg2 to be rejected, got %v", got.Code())
+	}
+	if _, got := cs.PreFilter(ctx, framework.NewCycleState(), pg1[0]); !got.IsSuccess() {
+		t.Fatalf("expected the first member of pg1 to be admitted, got %v", got.Message())
+	}
+	if got := cs.Reserve(ctx, framework.NewCycleState(), pg1[0], "node-a"); !got.IsSuccess() {
+		t.Fatalf("expected the first member of pg1 to be reserved, got %v", got.Message())
+	}
+	if used() != 900 {
+		t.Errorf("expected the whole gang to be reserved, got %v", used())
+	}
+	if _, got := cs.PreFilter(ctx, framework.NewCycleState(), other); got.Code() != framework.Unschedulable {
+		t.Errorf("expected a pod not fitting besides the reserved gang to be rejected, got %v", got.Code())
+	}
+
+	// Rejecting the gang releases its reservation.
+	cs.Unreserve(ctx, framework.NewCycleState(), pg1[0], "node-a")
+	if used() != 0 {
+		t.Errorf("expected the reservation of the gang to be released, got %v", used())
+	}
+
+	for _, pod := range pg1 {
+		if _, got := cs.PreFilter(ctx, framework.NewCycleState(), pod); !got.IsSuccess() {
+			t.Fatalf("expected %v to be admitted, got %v", pod.Name, got.Message())
+		}
+		if got := cs.Reserve(ctx, framework.NewCycleState(), pod, "node-a"); !got.IsSuccess() {
+			t.Fatalf("expected %v to be reserved, got %v", pod.Name, got.Message())
+		}
+		if used() != 900 {
+			t.Errorf("expected members to consume the reservation of the gang, got %v", used())
+		}
+	}
+	if gang := cs.elasticQuotaInfos["ns1"].getGang("ns1/pg1"); gang != nil {
+		t.Errorf("expected the reservation of the gang to be released once scheduled, got %v", gang)
+	}
+}
+
+func TestGangAdmissionScope(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fwk, err := tf.NewFramework(
+		ctx, []tf.RegisterPluginFunc{
+			tf.RegisterQueueSortPlugin(queuesort.Name, queuesort.New),
+			tf.RegisterBindPlugin(defaultbinder.Name, defaultbinder.New),
+		}, "",
+		frameworkruntime.WithPodNominator(testutil.NewPodNominator(nil)),
+		frameworkruntime.WithSnapshotSharedLister(testutil.NewFakeSharedLister(make([]*v1.Pod, 0), make([]*v1.Node, 0))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	makePreemptible := func(name, pgName string) *v1.Pod {
+		pod := makePod(name, "ns1", 300, 0, 0, 0, name, "")
+		pod.Spec.PriorityClassName = "preemptible"
+		if pgName != "" {
+			pod.Labels = map[string]string{v1alpha1.PodGroupLabel: pgName}
+		}
+		return pod
+	}
+	gang := []*v1.Pod{makePreemptible("pg1-p1", "pg1"), makePreemptible("pg1-p2", "pg1")}
+	other := makePreemptible("other", "")
+
+	informerFactory := informers.NewSharedInformerFactory(clientsetfake.NewSimpleClientset(), 0)
+	podStore := informerFactory.Core().V1().Pods().Informer().GetStore()
+	for _, pod := range append(gang, other) {
+		if err := podStore.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pgIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := pgIndexer.Add(&v1alpha1.PodGroup{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pg1"}, Spec: v1alpha1.PodGroupSpec{MinMember: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The preemptible pods may only use 800 of the 1000 of memory of the quota.
+	eqInfo := newElasticQuotaInfo("ns1", makeResourceList(0, 1000), makeResourceList(0, 1000), nil)
+	eqInfo.ScopedMax = map[string]*framework.Resource{"preemptible": newScopedMax(v1.ResourceList{v1.ResourceMemory: *resource.NewQuantity(800, resource.DecimalSI)})}
+	cs := &CapacityScheduling{
+		elasticQuotaInfos: ElasticQuotaInfos{"ns1": eqInfo},
+		fh:                fwk,
+		podLister:         informerFactory.Core().V1().Pods().Lister(),
+		pgLister:          pglister.NewPodGroupLister(pgIndexer),
+	}
+	scopedUsed := func() int64 {
+		if used := cs.elasticQuotaInfos["ns1"].ScopedUsed["preemptible"]; used != nil {
+			return used.Memory
+		}
+		return 0
+	}
+
+	if got := cs.Reserve(ctx, framework.NewCycleState(), gang[0], "node-a"); !got.IsSuccess() {
+		t.Fatalf("expected the first member of the gang to be reserved, got %v", got.Message())
+	}
+	if scopedUsed() != 600 {
+		t.Errorf("expected the whole gang to be reserved in its scope, got %v", scopedUsed())
+	}
+	if _, got := cs.PreFilter(ctx, framework.NewCycleState(), other); got.Code() != framework.Unschedulable {
+		t.Errorf("expected a pod of the scope not fitting besides the reserved gang to be rejected, got %v", got.Code())
+	}
+	cs.Unreserve(ctx, framework.NewCycleState(), gang[0], "node-a")
+	if scopedUsed() != 0 {
+		t.Errorf("expected the reservation of the gang to be released from its scope, got %v", scopedUsed())
+	}
+
+	for _, pod := range gang {
+		if got := cs.Reserve(ctx, framework.NewCycleState(), pod, "node-a"); !got.IsSuccess() {
+			t.Fatalf("expected %v to be reserved, got %v", pod.Name, got.Message())
+		}
+		if scopedUsed() != 600 {
+			t.Errorf("expected members to consume the reservation of the gang in its scope, got %v", scopedUsed())
+		}
+	}
+	if _, got := cs.PreFilter(ctx, framework.NewCycleState(), other); got.Code() != framework.Unschedulable {
+		t.Errorf("expected a pod of the scope not fitting besides the scheduled gang to be rejected, got %v", got.Code())
+	}
+}
+
 func TestDryRunPreemption(t *testing.T) {
 	res := map[v1.ResourceName]string{v1.ResourceMemory: "150"}
 	tests := []struct {
@@ -715,6 +981,118 @@ func TestDryRunPreemption(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "preemption of the quota furthest above its fair share",
+			pod:  makePod("t1-p", "ns1", 50, 0, 0, highPriority, "t1-p", ""),
+			pods: []*v1.Pod{
+				makePod("t1-p1", "ns1", 50, 0, 0, highPriority, "t1-p1", "node-a"),
+				makePod("t1-p2", "ns2", 50, 0, 0, midPriority, "t1-p2", "node-a"),
+				makePod("t1-p3", "ns2", 50, 0, 0, 10, "t1-p3", "node-a"),
+				makePod("t1-p4", "ns3", 75, 0, 0, midPriority, "t1-p4", "node-a"),
+			},
+			nodes: []*v1.Node{
+				st.MakeNode().Name("node-a").Capacity(map[v1.ResourceName]string{v1.ResourceMemory: "225"}).Obj(),
+			},
+			elasticQuotas: map[string]*ElasticQuotaInfo{
+				// The headroom is the unused min of ns4, 400, so the fair share of ns1 is 100,
+				// the fair share of ns2 and ns3 is 50 and the fair share of ns4 is 650.
+				"ns1": {
+					Namespace: "ns1",
+					Max:       &framework.Resource{Memory: 200},
+					Min:       &framework.Resource{Memory: 50},
+					Used:      &framework.Resource{Memory: 50},
+				},
+				"ns2": {
+					Namespace: "ns2",
+					Max:       &framework.Resource{Memory: 200},
+					Min:       &framework.Resource{Memory: 0},
+					Used:      &framework.Resource{Memory: 100},
+				},
+				"ns3": {
+					Namespace: "ns3",
+					Max:       &framework.Resource{Memory: 200},
+					Min:       &framework.Resource{Memory: 0},
+					Used:      &framework.Resource{Memory: 75},
+				},
+				"ns4": {
+					Namespace: "ns4",
+					Weight:    5,
+					Max:       &framework.Resource{Memory: 1000},
+					Min:       &framework.Resource{Memory: 400},
+					Used:      &framework.Resource{Memory: 0},
+				},
+			},
+			nodesStatuses: framework.NodeToStatusMap{
+				"node-a": framework.NewStatus(framework.Unschedulable),
+			},
+			want: []preemption.Candidate{
+				&candidate{
+					victims: &extenderv1.Victims{
+						Pods: []*v1.Pod{
+							makePod("t1-p3", "ns2", 50, 0, 0, 10, "t1-p3", "node-a"),
+						},
+						NumPDBViolations: 0,
+					},
+					name: "node-a",
+				},
+			},
+		},
+		{
+			name: "preemption of the heavy borrower first",
+			pod:  makePod("t1-p", "ns1", 50, 0, 0, highPriority, "t1-p", ""),
+			pods: []*v1.Pod{
+				makePod("t1-p1", "ns1", 50, 0, 0, highPriority, "t1-p1", "node-a"),
+				makePod("t2-p1", "ns2", 50, 0, 0, midPriority, "t2-p1", "node-a"),
+				makePod("t2-p2", "ns2", 50, 0, 0, 10, "t2-p2", "node-a"),
+				makePod("t3-p1", "ns3", 25, 0, 0, 10, "t3-p1", "node-a"),
+			},
+			nodes: []*v1.Node{
+				st.MakeNode().Name("node-a").Capacity(map[v1.ResourceName]string{v1.ResourceMemory: "175"}).Obj(),
+			},
+			elasticQuotas: map[string]*ElasticQuotaInfo{
+				// The headroom is the unused min of ns4, 300, so the fair share of ns1 is 125 and
+				// the fair share of ns2 and ns3 is 75: only ns2, the heavy borrower, is above its
+				// fair share, as its own borrowing doesn't raise its fair share.
+				"ns1": {
+					Namespace: "ns1",
+					Max:       &framework.Resource{Memory: 200},
+					Min:       &framework.Resource{Memory: 50},
+					Used:      &framework.Resource{Memory: 50},
+				},
+				"ns2": {
+					Namespace: "ns2",
+					Max:       &framework.Resource{Memory: 200},
+					Min:       &framework.Resource{Memory: 0},
+					Used:      &framework.Resource{Memory: 100},
+				},
+				"ns3": {
+					Namespace: "ns3",
+					Max:       &framework.Resource{Memory: 200},
+					Min:       &framework.Resource{Memory: 0},
+					Used:      &framework.Resource{Memory: 25},
+				},
+				"ns4": {
+					Namespace: "ns4",
+					Max:       &framework.Resource{Memory: 1000},
+					Min:       &framework.Resource{Memory: 300},
+					Used:      &framework.Resource{Memory: 0},
+				},
+			},
+			nodesStatuses: framework.NodeToStatusMap{
+				"node-a": framework.NewStatus(framework.Unschedulable),
+			},
+			want: []preemption.Candidate{
+				&candidate{
+					victims: &extenderv1.Victims{
+						Pods: []*v1.Pod{
+							makePod("t2-p2", "ns2", 50, 0, 0, 10, "t2-p2", "node-a"),
+						},
+						NumPDBViolations: 0,
+					},
+					name: "node-a",
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -791,10 +1169,10 @@ func TestDryRunPreemption(t *testing.T) {
 				t.Fatalf("Unexpected candidate length: want %v, but bot %v", len(tt.want), len(got))
 			}
 			for i, c := range got {
-				if diff := gocmp.Diff(c.Victims(), got[i].Victims()); diff != "" {
+				if diff := gocmp.Diff(tt.want[i].Victims(), c.Victims()); diff != "" {
 					t.Errorf("Unexpected victims at index %v (-want, +got): %s", i, diff)
 				}
-				if diff := gocmp.Diff(c.Name(), got[i].Name()); diff != "" {
+				if diff := gocmp.Diff(tt.want[i].Name(), c.Name()); diff != "" {
 					t.Errorf("Unexpected victims at index %v (-want, +got): %s", i, diff)
 				}
 			}
@@ -1013,6 +1391,158 @@ func TestPodEligibleToPreemptOthers(t *testing.T) {
 	}
 }
 
+func TestReclaimPolicy(t *testing.T) {
+	res := map[v1.ResourceName]string{v1.ResourceMemory: "150"}
+	startedAgo := func(pod *v1.Pod, d time.Duration) *v1.Pod {
+		pod.Status.StartTime = &metav1.Time{Time: time.Now().Add(-d)}
+		return pod
+	}
+	unschedulableFor := func(pod *v1.Pod, d time.Duration) *v1.Pod {
+		pod.Status.Conditions = []v1.PodCondition{{
+			Type:               v1.PodScheduled,
+			Status:             v1.ConditionFalse,
+			Reason:             v1.PodReasonUnschedulable,
+			LastTransitionTime: metav1.Time{Time: time.Now().Add(-d)},
+		}}
+		return pod
+	}
+	pods := []*v1.Pod{
+		startedAgo(makePod("t1-p1", "ns2", 50, 0, 0, midPriority, "t1-p1", "node-a"), time.Hour),
+		startedAgo(makePod("t1-p2", "ns2", 50, 0, 0, highPriority, "t1-p2", "node-a"), 30*time.Minute),
+		startedAgo(makePod("t1-p3", "ns2", 50, 0, 0, highPriority, "t1-p3", "node-a"), time.Minute),
+	}
+	tests := []struct {
+		name                  string
+		pod                   *v1.Pod
+		reclaimGracePeriod    time.Duration
+		preferRecentBorrowers bool
+		wantVictims           []string
+		wantEvent             bool
+	}{
+		{
+			name:        "lowest priority borrower is preempted",
+			pod:         makePod("t1-p", "ns1", 50, 0, 0, highPriority, "t1-p", ""),
+			wantVictims: []string{"t1-p1"},
+			wantEvent:   true,
+		},
+		{
+			name:                  "most recent borrower is preempted",
+			pod:                   makePod("t1-p", "ns1", 50, 0, 0, highPriority, "t1-p", ""),
+			preferRecentBorrowers: true,
+			wantVictims:           []string{"t1-p3"},
+			wantEvent:             true,
+		},
+		{
+			name:               "borrowers are not preempted before the reclaim grace period",
+			pod:                unschedulableFor(makePod("t1-p", "ns1", 50, 0, 0, highPriority, "t1-p", ""), time.Minute),
+			reclaimGracePeriod: 5 * time.Minute,
+		},
+		{
+			name:               "borrowers are preempted after the reclaim grace period",
+			pod:                unschedulableFor(makePod("t1-p", "ns1", 50, 0, 0, highPriority, "t1-p", ""), 10*time.Minute),
+			reclaimGracePeriod: 5 * time.Minute,
+			wantVictims:        []string{"t1-p1"},
+			wantEvent:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registeredPlugins := makeRegisteredPlugin()
+
+			cs := clientsetfake.NewSimpleClientset()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			recorder := &events.FakeRecorder{Events: make(chan string, 10)}
+			nodes := []*v1.Node{st.MakeNode().Name("node-a").Capacity(res).Obj()}
+			fwk, err := tf.NewFramework(
+				ctx,
+				registeredPlugins,
+				"default-scheduler",
+				frameworkruntime.WithClientSet(cs),
+				frameworkruntime.WithEventRecorder(recorder),
+				frameworkruntime.WithPodNominator(testutil.NewPodNominator(nil)),
+				frameworkruntime.WithSnapshotSharedLister(testutil.NewFakeSharedLister(pods, nodes)),
+				frameworkruntime.WithInformerFactory(informers.NewSharedInformerFactory(cs, 0)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state := framework.NewCycleState()
+			if _, status := fwk.RunPreFilterPlugins(ctx, state, tt.pod); !status.IsSuccess() {
+				t.Errorf("Unexpected preFilterStatus: %v", status)
+			}
+			podReq := computePodResourceRequest(tt.pod)
+			state.Write(preFilterStateKey, &PreFilterState{
+				podReq:                         *podReq,
+				nominatedPodsReqWithPodReq:     *podReq,
+				nominatedPodsReqInEQWithPodReq: *podReq,
+			})
+			state.Write(ElasticQuotaSnapshotKey, &ElasticQuotaSnapshotState{
+				elasticQuotaInfos: map[string]*ElasticQuotaInfo{
+					"ns1": {
+						Namespace: "ns1",
+						Max:       &framework.Resource{Memory: 200},
+						Min:       &framework.Resource{Memory: 150},
+						Used:      &framework.Resource{},
+					},
+					"ns2": {
+						Namespace: "ns2",
+						Max:       &framework.Resource{Memory: 200},
+						Min:       &framework.Resource{Memory: 50},
+						Used:      &framework.Resource{Memory: 150},
+					},
+				},
+			})
+
+			p := &preemptor{
+				fh:                    fwk,
+				state:                 state,
+				reclaimGracePeriod:    tt.reclaimGracePeriod,
+				preferRecentBorrowers: tt.preferRecentBorrowers,
+			}
+			pe := preemption.Evaluator{
+				PluginName: Name,
+				Handler:    fwk,
+				PodLister:  fwk.SharedInformerFactory().Core().V1().Pods().Lister(),
+				PdbLister:  getPDBLister(fwk.SharedInformerFactory()),
+				State:      state,
+				Interface:  p,
+			}
+
+			nodeInfos, _ := fwk.SnapshotSharedLister().NodeInfos().List()
+			got, _, err := pe.DryRunPreemption(ctx, tt.pod, nodeInfos, nil, 0, int32(len(nodeInfos)))
+			if err != nil {
+				t.Fatalf("unexpected error during DryRunPreemption(): %v", err)
+			}
+			var gotVictims []string
+			for _, c := range got {
+				for _, victim := range c.Victims().Pods {
+					gotVictims = append(gotVictims, victim.Name)
+				}
+			}
+			if diff := gocmp.Diff(tt.wantVictims, gotVictims); diff != "" {
+				t.Errorf("Unexpected victims (-want, +got): %s", diff)
+			}
+
+			p.recordReclaims(tt.pod, "node-a")
+			select {
+			case event := <-recorder.Events:
+				if !tt.wantEvent {
+					t.Errorf("Unexpected event: %v", event)
+				} else if !strings.Contains(event, "ElasticQuota ns1 reclaimed the resources ElasticQuota ns2 borrowed") {
+					t.Errorf("Unexpected event message: %v", event)
+				}
+			default:
+				if tt.wantEvent {
+					t.Errorf("Expected an event on the victim")
+				}
+			}
+		})
+	}
+}
+
 func TestAddElasticQuota(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -1029,6 +1559,7 @@ func TestAddElasticQuota(t *testing.T) {
 			expected: map[string]*ElasticQuotaInfo{
 				"ns1": {
 					Namespace: "ns1",
+					Name:      "t1-eq1",
 					pods:      sets.String{},
 					Max: &framework.Resource{
 						MilliCPU: 100,
@@ -1054,6 +1585,7 @@ func TestAddElasticQuota(t *testing.T) {
 			expected: map[string]*ElasticQuotaInfo{
 				"ns1": {
 					Namespace: "ns1",
+					Name:      "t1-eq1",
 					pods:      sets.String{},
 					Max: &framework.Resource{
 						MilliCPU:         UpperBoundOfMax,
@@ -1080,6 +1612,7 @@ func TestAddElasticQuota(t *testing.T) {
 			expected: map[string]*ElasticQuotaInfo{
 				"ns1": {
 					Namespace: "ns1",
+					Name:      "t1-eq1",
 					pods:      sets.String{},
 					Max: &framework.Resource{
 						MilliCPU: 100,
@@ -1106,6 +1639,7 @@ func TestAddElasticQuota(t *testing.T) {
 			expected: map[string]*ElasticQuotaInfo{
 				"ns1": {
 					Namespace: "ns1",
+					Name:      "t1-eq1",
 					pods:      sets.String{},
 					Max: &framework.Resource{
 						MilliCPU:         UpperBoundOfMax,
@@ -1150,6 +1684,7 @@ func TestAddElasticQuota(t *testing.T) {
 			cs := &CapacityScheduling{
 				elasticQuotaInfos: map[string]*ElasticQuotaInfo{},
 				fh:                fwk,
+				podLister:         newPodLister(),
 			}
 
 			for _, elasticQuota := range tt.elasticQuotas {
@@ -1157,7 +1692,7 @@ func TestAddElasticQuota(t *testing.T) {
 			}
 
 			for _, ns := range tt.ns {
-				if got := cs.elasticQuotaInfos[ns]; !reflect.DeepEqual(got, tt.expected[ns]) {
+				if got := cs.elasticQuotaInfos[ns]; !equalElasticQuotaInfo(got, tt.expected[ns]) {
 					t.Errorf("expected %v, got %v", tt.expected[ns], got)
 				}
 			}
@@ -1181,6 +1716,7 @@ func TestUpdateElasticQuota(t *testing.T) {
 			expected: map[string]*ElasticQuotaInfo{
 				"ns1": {
 					Namespace: "ns1",
+					Name:      "t1-eq1",
 					pods:      sets.String{},
 					Max: &framework.Resource{
 						MilliCPU: 300,
@@ -1223,12 +1759,13 @@ func TestUpdateElasticQuota(t *testing.T) {
 			cs := &CapacityScheduling{
 				elasticQuotaInfos: map[string]*ElasticQuotaInfo{},
 				fh:                fwk,
+				podLister:         newPodLister(),
 			}
 			cs.addElasticQuota(tt.oldElasticQuota)
 			cs.updateElasticQuota(tt.oldElasticQuota, tt.newElasticQuota)
 
 			for _, ns := range tt.ns {
-				if got := cs.elasticQuotaInfos[ns]; !reflect.DeepEqual(got, tt.expected[ns]) {
+				if got := cs.elasticQuotaInfos[ns]; !equalElasticQuotaInfo(got, tt.expected[ns]) {
 					t.Errorf("expected %v, got %v", tt.expected[ns], got)
 				}
 			}
@@ -1275,12 +1812,13 @@ func TestDeleteElasticQuota(t *testing.T) {
 			cs := &CapacityScheduling{
 				elasticQuotaInfos: map[string]*ElasticQuotaInfo{},
 				fh:                fwk,
+				podLister:         newPodLister(),
 			}
 			cs.addElasticQuota(tt.elasticQuota)
 			cs.deleteElasticQuota(tt.elasticQuota)
 
 			for _, ns := range tt.ns {
-				if got := cs.elasticQuotaInfos[ns]; !reflect.DeepEqual(got, tt.expected[ns]) {
+				if got := cs.elasticQuotaInfos[ns]; !equalElasticQuotaInfo(got, tt.expected[ns]) {
 					t.Errorf("expected %v, got %v", tt.expected[ns], got)
 				}
 			}
@@ -1308,6 +1846,7 @@ func TestAddPod(t *testing.T) {
 			expected: map[string]*ElasticQuotaInfo{
 				"ns1": {
 					Namespace: "ns1",
+					Name:      "t1-eq1",
 					pods:      sets.NewString("t1-p1", "t1-p2", "t1-p3"),
 					Max: &framework.Resource{
 						MilliCPU: 100,
@@ -1353,13 +1892,14 @@ func TestAddPod(t *testing.T) {
 			cs := &CapacityScheduling{
 				elasticQuotaInfos: map[string]*ElasticQuotaInfo{},
 				fh:                fwk,
+				podLister:         newPodLister(),
 			}
 			cs.addElasticQuota(tt.elasticQuota)
 			for _, pod := range tt.pods {
 				cs.addPod(pod)
 			}
 			for _, ns := range tt.ns {
-				if got := cs.elasticQuotaInfos[ns]; !reflect.DeepEqual(got, tt.expected[ns]) {
+				if got := cs.elasticQuotaInfos[ns]; !equalElasticQuotaInfo(got, tt.expected[ns]) {
 					t.Errorf("expected %v, got %v", tt.expected[ns], got)
 				}
 			}
@@ -1388,7 +1928,8 @@ func TestUpdatePod(t *testing.T) {
 			expected: map[string]*ElasticQuotaInfo{
 				"ns1": {
 					Namespace: "ns1",
-					pods:      sets.NewString("t1-p1"),
+					Name:      "t1-eq1",
+					pods:      sets.String{},
 					Max: &framework.Resource{
 						MilliCPU: 100,
 						Memory:   1000,
@@ -1398,8 +1939,38 @@ func TestUpdatePod(t *testing.T) {
 						Memory:   100,
 					},
 					Used: &framework.Resource{
-						MilliCPU: 30,
+						MilliCPU: 0,
+						Memory:   0,
+					},
+				},
+			},
+		},
+		{
+			name:         "Update Pod With Pod Status PodPending and PodFailed",
+			elasticQuota: makeEQ("ns1", "t1-eq1", makeResourceList(100, 1000), makeResourceList(10, 100)),
+			updatePods: [][2]*v1.Pod{
+				{
+					makePodWithStatus(makePod("t1-p2", "ns1", 100, 30, 0, midPriority, "t1-p2", "node-a"), v1.PodPending),
+					makePodWithStatus(makePod("t1-p2", "ns1", 100, 30, 0, highPriority, "t1-p2", "node-a"), v1.PodFailed),
+				},
+			},
+			ns: []string{"ns1"},
+			expected: map[string]*ElasticQuotaInfo{
+				"ns1": {
+					Namespace: "ns1",
+					Name:      "t1-eq1",
+					pods:      sets.String{},
+					Max: &framework.Resource{
+						MilliCPU: 100,
+						Memory:   1000,
+					},
+					Min: &framework.Resource{
+						MilliCPU: 10,
 						Memory:   100,
+					},
+					Used: &framework.Resource{
+						MilliCPU: 0,
+						Memory:   0,
 						ScalarResources: map[v1.ResourceName]int64{
 							ResourceGPU: 0,
 						},
@@ -1408,18 +1979,19 @@ func TestUpdatePod(t *testing.T) {
 			},
 		},
 		{
-			name:         "Update Pod With Pod Status PodPending and PodFailed",
+			name:         "Update Running Pod being deleted",
 			elasticQuota: makeEQ("ns1", "t1-eq1", makeResourceList(100, 1000), makeResourceList(10, 100)),
 			updatePods: [][2]*v1.Pod{
 				{
-					makePodWithStatus(makePod("t1-p2", "ns1", 100, 30, 0, midPriority, "t1-p2", "node-a"), v1.PodPending),
-					makePodWithStatus(makePod("t1-p2", "ns1", 100, 30, 0, highPriority, "t1-p2", "node-a"), v1.PodFailed),
+					makePodWithStatus(makePod("t1-p3", "ns1", 100, 30, 0, midPriority, "t1-p3", "node-a"), v1.PodRunning),
+					makeTerminatingPod(makePodWithStatus(makePod("t1-p3", "ns1", 100, 30, 0, midPriority, "t1-p3", "node-a"), v1.PodRunning)),
 				},
 			},
 			ns: []string{"ns1"},
 			expected: map[string]*ElasticQuotaInfo{
 				"ns1": {
 					Namespace: "ns1",
+					Name:      "t1-eq1",
 					pods:      sets.String{},
 					Max: &framework.Resource{
 						MilliCPU: 100,
@@ -1465,6 +2037,7 @@ func TestUpdatePod(t *testing.T) {
 			cs := &CapacityScheduling{
 				elasticQuotaInfos: map[string]*ElasticQuotaInfo{},
 				fh:                fwk,
+				podLister:         newPodLister(),
 			}
 			cs.addElasticQuota(tt.elasticQuota)
 			for _, pods := range tt.updatePods {
@@ -1472,7 +2045,7 @@ func TestUpdatePod(t *testing.T) {
 				cs.updatePod(pods[0], pods[1])
 			}
 			for _, ns := range tt.ns {
-				if got := cs.elasticQuotaInfos[ns]; !reflect.DeepEqual(got, tt.expected[ns]) {
+				if got := cs.elasticQuotaInfos[ns]; !equalElasticQuotaInfo(got, tt.expected[ns]) {
 					t.Errorf("expected %v, got %v", tt.expected[ns], got)
 				}
 			}
@@ -1504,6 +2077,7 @@ func TestDeletePod(t *testing.T) {
 			expected: map[string]*ElasticQuotaInfo{
 				"ns1": {
 					Namespace: "ns1",
+					Name:      "t1-eq1",
 					pods:      sets.NewString(),
 					Max: &framework.Resource{
 						MilliCPU: 100,
@@ -1537,6 +2111,7 @@ func TestDeletePod(t *testing.T) {
 			expected: map[string]*ElasticQuotaInfo{
 				"ns1": {
 					Namespace: "ns1",
+					Name:      "t1-eq1",
 					pods:      sets.NewString("t1-p2"),
 					Max: &framework.Resource{
 						MilliCPU: 100,
@@ -1582,6 +2157,7 @@ func TestDeletePod(t *testing.T) {
 			cs := &CapacityScheduling{
 				elasticQuotaInfos: map[string]*ElasticQuotaInfo{},
 				fh:                fwk,
+				podLister:         newPodLister(),
 			}
 			cs.addElasticQuota(tt.elasticQuota)
 			for _, existingpod := range tt.existingPods {
@@ -1591,7 +2167,7 @@ func TestDeletePod(t *testing.T) {
 				cs.deletePod(deletepod)
 			}
 			for _, ns := range tt.ns {
-				if got := cs.elasticQuotaInfos[ns]; !reflect.DeepEqual(got, tt.expected[ns]) {
+				if got := cs.elasticQuotaInfos[ns]; !equalElasticQuotaInfo(got, tt.expected[ns]) {
 					t.Errorf("expected %v, got %v", tt.expected[ns], got)
 				}
 			}
@@ -1599,6 +2175,259 @@ func TestDeletePod(t *testing.T) {
 	}
 }
 
+func TestSnapshotElasticQuota(t *testing.T) {
+	c := &CapacityScheduling{elasticQuotaInfos: NewElasticQuotaInfos()}
+	parent := newElasticQuotaInfo("ns1", makeResourceList(100, 1000), nil, nil)
+	child := newElasticQuotaInfo("ns2", makeResourceList(50, 500), nil, nil)
+	child.Parent = types.NamespacedName{Namespace: "ns1"}
+	other := newElasticQuotaInfo("ns3", makeResourceList(50, 500), nil, nil)
+	for _, eq := range []*ElasticQuotaInfo{parent, child, other} {
+		c.elasticQuotaInfos[eq.Namespace] = eq
+	}
+
+	first := c.snapshotElasticQuota().elasticQuotaInfos
+	second := c.snapshotElasticQuota().elasticQuotaInfos
+	for ns := range c.elasticQuotaInfos {
+		if first[ns] != second[ns] {
+			t.Errorf("expected unchanged ElasticQuotaInfo %v to be shared between snapshots", ns)
+		}
+	}
+
+	// Adding a pod to the child changes the child and its parent only.
+	if err := c.elasticQuotaInfos.addPodIfNotPresent(c.namespaceIndex, makePod("t1-p1", "ns2", 100, 10, 0, midPriority, "t1-p1", "node-a")); err != nil {
+		t.Fatal(err)
+	}
+	state := c.snapshotElasticQuota()
+	third := state.elasticQuotaInfos
+	if third["ns1"] == first["ns1"] || third["ns2"] == first["ns2"] {
+		t.Errorf("expected the changed ElasticQuotaInfos to be copied")
+	}
+	if third["ns3"] != first["ns3"] {
+		t.Errorf("expected unchanged ElasticQuotaInfo ns3 to be shared between snapshots")
+	}
+	if first["ns1"].Used.Memory != 0 || first["ns2"].Used.Memory != 0 {
+		t.Errorf("expected the previous snapshot to be unchanged, got %v and %v", first["ns1"].Used, first["ns2"].Used)
+	}
+	if third["ns1"].Used.Memory != 100 || third["ns2"].Used.Memory != 100 {
+		t.Errorf("expected the usage of the pod in the snapshot, got %v and %v", third["ns1"].Used, third["ns2"].Used)
+	}
+
+	// Modifying a snapshot, or a clone of it, copies the ElasticQuotaInfos it modifies.
+	clone := state.Clone().(*ElasticQuotaSnapshotState).elasticQuotaInfos
+	if err := clone.addPodIfNotPresent(nil, makePod("t1-p2", "ns2", 50, 10, 0, midPriority, "t1-p2", "node-a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := third.deletePodIfPresent(nil, makePod("t1-p1", "ns2", 100, 10, 0, midPriority, "t1-p1", "node-a")); err != nil {
+		t.Fatal(err)
+	}
+	fourth := c.snapshotElasticQuota().elasticQuotaInfos
+	for _, tc := range []struct {
+		name  string
+		infos ElasticQuotaInfos
+		want  int64
+	}{
+		{name: "cache", infos: c.elasticQuotaInfos, want: 100},
+		{name: "clone", infos: clone, want: 150},
+		{name: "modified snapshot", infos: third, want: 0},
+		{name: "next snapshot", infos: fourth, want: 100},
+	} {
+		if got := tc.infos["ns1"].Used.Memory; got != tc.want {
+			t.Errorf("expected the memory used by ns1 in the %v to be %v, got %v", tc.name, tc.want, got)
+		}
+		if got := tc.infos["ns2"].Used.Memory; got != tc.want {
+			t.Errorf("expected the memory used by ns2 in the %v to be %v, got %v", tc.name, tc.want, got)
+		}
+	}
+
+	// Deleted ElasticQuotaInfos are dropped from the snapshot.
+	delete(c.elasticQuotaInfos, "ns3")
+	if fifth := c.snapshotElasticQuota().elasticQuotaInfos; fifth["ns3"] != nil || c.snapshot["ns3"] != nil {
+		t.Errorf("expected deleted ElasticQuotaInfo ns3 to be dropped from the snapshot")
+	}
+}
+
+// newBenchmarkCapacityScheduling returns a CapacityScheduling with the given number of ElasticQuotas,
+// each with the given number of pods, along with one more pod per ElasticQuota.
+func newBenchmarkCapacityScheduling(b *testing.B, quotas, podsPerQuota int) (*CapacityScheduling, []*v1.Pod) {
+	c := &CapacityScheduling{elasticQuotaInfos: NewElasticQuotaInfos()}
+	pods := make([]*v1.Pod, 0, quotas)
+	for i := 0; i < quotas; i++ {
+		ns := fmt.Sprintf("ns%d", i)
+		c.elasticQuotaInfos[ns] = newElasticQuotaInfo(ns, makeResourceList(1000, 1000), nil, nil)
+		for j := 0; j < podsPerQuota; j++ {
+			name := fmt.Sprintf("%s-p%d", ns, j)
+			if err := c.elasticQuotaInfos.addPodIfNotPresent(c.namespaceIndex, makePod(name, ns, 1, 1, 0, midPriority, name, "node-a")); err != nil {
+				b.Fatal(err)
+			}
+		}
+		name := fmt.Sprintf("%s-p%d", ns, podsPerQuota)
+		pods = append(pods, makePod(name, ns, 1, 1, 0, midPriority, name, "node-a"))
+	}
+	return c, pods
+}
+
+// BenchmarkSnapshotElasticQuota takes a snapshot per scheduling cycle, with a pod of one of the
+// ElasticQuotas added or deleted between cycles.
+func BenchmarkSnapshotElasticQuota(b *testing.B) {
+	for _, quotas := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("%d quotas", quotas), func(b *testing.B) {
+			c, pods := newBenchmarkCapacityScheduling(b, quotas, 10)
+			c.snapshotElasticQuota()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pod := pods[i%quotas]
+				if (i/quotas)%2 == 0 {
+					_ = c.elasticQuotaInfos.addPodIfNotPresent(c.namespaceIndex, pod)
+				} else {
+					_ = c.elasticQuotaInfos.deletePodIfPresent(c.namespaceIndex, pod)
+				}
+				c.snapshotElasticQuota()
+			}
+		})
+	}
+}
+
+// BenchmarkCloneElasticQuotas copies all the ElasticQuotas, as the snapshot did before being
+// copied on write, for comparison.
+func BenchmarkCloneElasticQuotas(b *testing.B) {
+	for _, quotas := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("%d quotas", quotas), func(b *testing.B) {
+			c, _ := newBenchmarkCapacityScheduling(b, quotas, 10)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.elasticQuotaInfos.clone()
+			}
+		})
+	}
+}
+
+func TestElasticQuotaNamespaceSelector(t *testing.T) {
+	makeNamespace := func(name, team string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": team}}}
+	}
+	teamEQ := makeEQ("team1", "team1-eq", makeResourceList(100, 1000), makeResourceList(10, 100))
+	teamEQ.CreationTimestamp = metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	teamEQ.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "team1"}}
+
+	informerFactory := informers.NewSharedInformerFactory(clientsetfake.NewSimpleClientset(), 0)
+	nsStore := informerFactory.Core().V1().Namespaces().Informer().GetStore()
+	podStore := informerFactory.Core().V1().Pods().Informer().GetStore()
+	for _, ns := range []*v1.Namespace{makeNamespace("ns1", "team1"), makeNamespace("ns2", "team1"), makeNamespace("ns3", "team2")} {
+		if err := nsStore.Add(ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pod := range []*v1.Pod{
+		makePod("p1", "ns1", 50, 10, 0, midPriority, "p1", "node-a"),
+		makePod("p2", "ns2", 50, 10, 0, midPriority, "p2", "node-a"),
+		makePod("p3", "ns3", 50, 10, 0, midPriority, "p3", "node-a"),
+	} {
+		if err := podStore.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cs := &CapacityScheduling{
+		elasticQuotaInfos: map[string]*ElasticQuotaInfo{},
+		podLister:         informerFactory.Core().V1().Pods().Lister(),
+		nsLister:          informerFactory.Core().V1().Namespaces().Lister(),
+	}
+	cs.addElasticQuota(teamEQ)
+
+	info := cs.elasticQuotaInfos["team1"]
+	if info == nil {
+		t.Fatal("expected the ElasticQuota to be added")
+	}
+	if want := sets.NewString("ns1", "ns2"); !info.namespaces.Equal(want) {
+		t.Errorf("expected namespaces %v, got %v", want.List(), info.namespaces.List())
+	}
+	if got := cs.elasticQuotaInfos.getByNamespace(cs.namespaceIndex, "ns2"); got != info {
+		t.Errorf("expected ns2 to be subject to the ElasticQuota of team1, got %v", got)
+	}
+	if got := cs.elasticQuotaInfos.getByNamespace(cs.namespaceIndex, "team1"); got != nil {
+		t.Errorf("expected team1 not to be subject to any ElasticQuota, got %v", got)
+	}
+	if info.Used.MilliCPU != 20 || info.Used.Memory != 100 {
+		t.Errorf("expected the pods of ns1 and ns2 to be accounted, got %v", info.Used)
+	}
+
+	// ns3 joins the team, and its pods are accounted.
+	cs.updateNamespace(makeNamespace("ns3", "team2"), makeNamespace("ns3", "team1"))
+	if !info.namespaces.Has("ns3") || info.Used.MilliCPU != 30 {
+		t.Errorf("expected ns3 and its pods to be added, got namespaces %v and used %v", info.namespaces.List(), info.Used)
+	}
+	// ns1 leaves the team, and its pods are no longer accounted.
+	cs.updateNamespace(makeNamespace("ns1", "team1"), makeNamespace("ns1", "team2"))
+	if info.namespaces.Has("ns1") || info.Used.MilliCPU != 20 {
+		t.Errorf("expected ns1 and its pods to be deleted, got namespaces %v and used %v", info.namespaces.List(), info.Used)
+	}
+
+	// An ElasticQuota of a namespace already subject to an older ElasticQuota is ignored.
+	ns2EQ := makeEQ("ns2", "ns2-eq", makeResourceList(100, 1000), makeResourceList(10, 100))
+	ns2EQ.CreationTimestamp = metav1.NewTime(teamEQ.CreationTimestamp.Add(time.Minute))
+	cs.addElasticQuota(ns2EQ)
+	if cs.elasticQuotaInfos["ns2"] != nil {
+		t.Errorf("expected the overlapping ElasticQuota to be ignored")
+	}
+	// As is an ElasticQuota whose selector overlaps with the selector of an older ElasticQuota.
+	otherEQ := makeEQ("team2", "team2-eq", makeResourceList(100, 1000), makeResourceList(10, 100))
+	otherEQ.CreationTimestamp = metav1.NewTime(teamEQ.CreationTimestamp.Add(2 * time.Minute))
+	otherEQ.Spec.NamespaceSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "team", Operator: metav1.LabelSelectorOpExists},
+	}}
+	cs.addElasticQuota(otherEQ)
+	if cs.elasticQuotaInfos["team2"] != nil {
+		t.Errorf("expected the overlapping ElasticQuota to be ignored")
+	}
+
+	p2 := makePod("p2", "ns2", 50, 10, 0, midPriority, "p2", "node-a")
+	if err := podStore.Delete(p2); err != nil {
+		t.Fatal(err)
+	}
+	cs.deletePod(p2)
+	if info.Used.MilliCPU != 10 {
+		t.Errorf("expected the deleted pod to be unreserved, got %v", info.Used)
+	}
+
+	// Once the ElasticQuota of team1 is deleted, the oldest of the ElasticQuotas it overlapped with
+	// applies, and the other one is still ignored since it overlaps with the former.
+	cs.deleteElasticQuota(teamEQ)
+	if cs.elasticQuotaInfos["team1"] != nil {
+		t.Errorf("expected the ElasticQuota of team1 to be deleted")
+	}
+	if got := cs.elasticQuotaInfos.getByNamespace(cs.namespaceIndex, "ns1"); got != nil {
+		t.Errorf("expected ns1 not to be subject to any ElasticQuota, got %v", got)
+	}
+	if got := cs.elasticQuotaInfos["ns2"]; got == nil || got.Used.MilliCPU != 0 {
+		t.Errorf("expected the ElasticQuota of ns2 to be added, got %v", got)
+	}
+	if cs.elasticQuotaInfos["team2"] != nil {
+		t.Errorf("expected the ElasticQuota of team2 to be ignored")
+	}
+	cs.deleteElasticQuota(ns2EQ)
+	if got := cs.elasticQuotaInfos["team2"]; got == nil || got.Used.MilliCPU != 20 {
+		t.Errorf("expected the ElasticQuota of team2 to be added along with the pods of ns1 and ns3, got %v", got)
+	}
+
+	// An ElasticQuota older than the ElasticQuota it overlaps with applies in its stead, whatever
+	// the order they are added in.
+	olderEQ := makeEQ("team3", "team3-eq", makeResourceList(100, 1000), makeResourceList(10, 100))
+	olderEQ.CreationTimestamp = metav1.NewTime(teamEQ.CreationTimestamp.Add(-time.Minute))
+	olderEQ.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "team2"}}
+	cs.addElasticQuota(olderEQ)
+	if cs.elasticQuotaInfos["team2"] != nil {
+		t.Errorf("expected the newer overlapping ElasticQuota to be removed")
+	}
+	if got := cs.elasticQuotaInfos.getByNamespace(cs.namespaceIndex, "ns3"); got == nil || got.Namespace != "team3" || got.Used.MilliCPU != 10 {
+		t.Errorf("expected ns3 to be subject to the ElasticQuota of team3, got %v", got)
+	}
+	if got := cs.elasticQuotaInfos.getByNamespace(cs.namespaceIndex, "ns2"); got != nil {
+		t.Errorf("expected ns2 not to be subject to any ElasticQuota, got %v", got)
+	}
+}
+
 func makePod(podName string, namespace string, memReq int64, cpuReq int64, gpuReq int64, priority int32, uid string, nodeName string) *v1.Pod {
 	pause := imageutils.GetPauseImageName()
 	pod := st.MakePod().Namespace(namespace).Name(podName).Container(pause).
@@ -1618,6 +2447,12 @@ func makePodWithStatus(pod *v1.Pod, podPhase v1.PodPhase) *v1.Pod {
 	return pod
 }
 
+func makeTerminatingPod(pod *v1.Pod) *v1.Pod {
+	now := metav1.Now()
+	pod.DeletionTimestamp = &now
+	return pod
+}
+
 func makeEQ(namespace, name string, max, min v1.ResourceList) *v1alpha1.ElasticQuota {
 	eq := &v1alpha1.ElasticQuota{
 		TypeMeta: metav1.TypeMeta{Kind: "ElasticQuota", APIVersion: "scheduling.sigs.k8s.io/v1alpha1"},
@@ -1638,6 +2473,21 @@ func makeResourceList(cpu, mem int64) v1.ResourceList {
 	}
 }
 
+// newPodLister returns a lister of no pods.
+func newPodLister() corelisters.PodLister {
+	return informers.NewSharedInformerFactory(clientsetfake.NewSimpleClientset(), 0).Core().V1().Pods().Lister()
+}
+
+// equalElasticQuotaInfo compares the given ElasticQuotaInfos, ignoring their generation.
+func equalElasticQuotaInfo(x, y *ElasticQuotaInfo) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	xCopy, yCopy := *x, *y
+	xCopy.generation, yCopy.generation = 0, 0
+	return reflect.DeepEqual(&xCopy, &yCopy)
+}
+
 func makeRegisteredPlugin() []tf.RegisterPluginFunc {
 	registeredPlugins := []tf.RegisterPluginFunc{
 		tf.RegisterQueueSortPlugin(queuesort.Name, queuesort.New),
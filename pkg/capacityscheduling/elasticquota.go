@@ -18,9 +18,13 @@ package capacityscheduling
 
 import (
 	"math"
+	"sync/atomic"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"sigs.k8s.io/scheduler-plugins/pkg/util"
@@ -45,11 +49,45 @@ func (e ElasticQuotaInfos) clone() ElasticQuotaInfos {
 	return elasticQuotas
 }
 
+// shallowClone returns a copy of e sharing the ElasticQuotaInfos that are shared between snapshots,
+// and holding a copy of the others. Since the shared ones are copied on write, modifying the copy
+// doesn't affect e.
+func (e ElasticQuotaInfos) shallowClone() ElasticQuotaInfos {
+	elasticQuotas := make(ElasticQuotaInfos, len(e))
+	for key, elasticQuotaInfo := range e {
+		if elasticQuotaInfo.shared {
+			elasticQuotas[key] = elasticQuotaInfo
+		} else {
+			elasticQuotas[key] = elasticQuotaInfo.clone()
+		}
+	}
+	return elasticQuotas
+}
+
+// mutable returns the given ElasticQuotaInfo of e, first replaced in e by a private copy if it's
+// shared between snapshots, so that it can be modified, and bumps its generation. Since the
+// ElasticQuotaInfo may be replaced, pointers to it obtained before a modification of e must be
+// looked up again.
+func (e ElasticQuotaInfos) mutable(eq *ElasticQuotaInfo) *ElasticQuotaInfo {
+	if eq.shared {
+		eq = eq.clone()
+		e[eq.Namespace] = eq
+	}
+	eq.generation = nextGeneration()
+	return eq
+}
+
+// aggregatedUsedOverMinWith checks whether the total usage of the roots of the ElasticQuota trees
+// along with the given request is over their total min. Since the usage of an ElasticQuota includes
+// the usage of its descendants, only the roots are summed up.
 func (e ElasticQuotaInfos) aggregatedUsedOverMinWith(podRequest framework.Resource) bool {
 	used := framework.NewResource(nil)
 	min := framework.NewResource(nil)
 
 	for _, elasticQuotaInfo := range e {
+		if e.parent(elasticQuotaInfo) != nil {
+			continue
+		}
 		used.Add(util.ResourceList(elasticQuotaInfo.Used))
 		min.Add(util.ResourceList(elasticQuotaInfo.Min))
 	}
@@ -58,14 +96,414 @@ func (e ElasticQuotaInfos) aggregatedUsedOverMinWith(podRequest framework.Resour
 	return cmp(used, min, LowerBoundOfMin)
 }
 
+// getByNamespace returns the ElasticQuotaInfo that applies to the given namespace, if any:
+// the ElasticQuotaInfo of the namespace itself, or the one whose namespace selector selects it
+// according to the given index.
+func (e ElasticQuotaInfos) getByNamespace(index namespaceIndex, namespace string) *ElasticQuotaInfo {
+	if eq := e[namespace]; eq != nil && eq.appliesTo(namespace) {
+		return eq
+	}
+	if eq := e[index[namespace]]; eq != nil && eq.appliesTo(namespace) {
+		return eq
+	}
+	return nil
+}
+
+// overlapping returns the ElasticQuotaInfos, other than the given one, that apply to one of
+// the namespaces the given ElasticQuotaInfo applies to.
+func (e ElasticQuotaInfos) overlapping(index namespaceIndex, eq *ElasticQuotaInfo) []*ElasticQuotaInfo {
+	var overlapping []*ElasticQuotaInfo
+	seen := sets.NewString(eq.Namespace)
+	for _, namespace := range eq.getNamespaces().List() {
+		if other := e.getByNamespace(index, namespace); other != nil && !seen.Has(other.Namespace) {
+			seen.Insert(other.Namespace)
+			overlapping = append(overlapping, other)
+		}
+	}
+	return overlapping
+}
+
+// parent returns the parent of the given ElasticQuotaInfo, if it exists.
+func (e ElasticQuotaInfos) parent(eq *ElasticQuotaInfo) *ElasticQuotaInfo {
+	if parent := e[eq.Parent.Namespace]; parent != nil && parent.key() == eq.Parent {
+		return parent
+	}
+	return nil
+}
+
+// ancestors returns the ancestors of the given ElasticQuotaInfo, from its parent up to the root
+// of its tree. A cycle in the parent references ends the walk.
+func (e ElasticQuotaInfos) ancestors(eq *ElasticQuotaInfo) []*ElasticQuotaInfo {
+	var ancestors []*ElasticQuotaInfo
+	visited := sets.NewString(eq.Namespace)
+	for parent := e.parent(eq); parent != nil && !visited.Has(parent.Namespace); parent = e.parent(parent) {
+		visited.Insert(parent.Namespace)
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+// distance returns the number of levels to climb from eq to reach the closest common ancestor
+// of eq and other: 0 if eq is other or one of its ancestors, 1 if they are siblings, 2 if other
+// is a sibling of the parent of eq or one of its descendants and so on. If eq and other are not
+// in the same tree, the distance is the depth of eq plus one.
+func (e ElasticQuotaInfos) distance(eq, other *ElasticQuotaInfo) int {
+	otherAncestors := sets.NewString(other.Namespace)
+	for _, ancestor := range e.ancestors(other) {
+		otherAncestors.Insert(ancestor.Namespace)
+	}
+	if otherAncestors.Has(eq.Namespace) {
+		return 0
+	}
+	ancestors := e.ancestors(eq)
+	for i, ancestor := range ancestors {
+		if otherAncestors.Has(ancestor.Namespace) {
+			return i + 1
+		}
+	}
+	return len(ancestors) + 1
+}
+
+// usedOverMaxWith checks whether the given request fits in the Max of the given ElasticQuotaInfo
+// and of all its ancestors.
+func (e ElasticQuotaInfos) usedOverMaxWith(eq *ElasticQuotaInfo, podRequest *framework.Resource) bool {
+	if eq.usedOverMaxWith(podRequest) {
+		return true
+	}
+	for _, ancestor := range e.ancestors(eq) {
+		if ancestor.usedOverMaxWith(podRequest) {
+			return true
+		}
+	}
+	return false
+}
+
+// usedOverScopedMaxWith checks whether the given request of a pod of the given PriorityClass fits
+// in the Max of the scope of the PriorityClass in the given ElasticQuotaInfo and all its ancestors.
+func (e ElasticQuotaInfos) usedOverScopedMaxWith(eq *ElasticQuotaInfo, priorityClassName string, podRequest *framework.Resource) bool {
+	if eq.usedOverScopedMaxWith(priorityClassName, podRequest) {
+		return true
+	}
+	for _, ancestor := range e.ancestors(eq) {
+		if ancestor.usedOverScopedMaxWith(priorityClassName, podRequest) {
+			return true
+		}
+	}
+	return false
+}
+
+// addPodIfNotPresent adds the pod to the ElasticQuotaInfo that applies to its namespace, if any,
+// and reserves its request in that ElasticQuotaInfo and all its ancestors.
+func (e ElasticQuotaInfos) addPodIfNotPresent(index namespaceIndex, pod *v1.Pod) error {
+	eq := e.getByNamespace(index, pod.Namespace)
+	if eq == nil {
+		return nil
+	}
+	key, err := framework.GetPodKey(pod)
+	if err != nil {
+		return err
+	}
+	if eq.pods.Has(key) {
+		return nil
+	}
+
+	eq = e.mutable(eq)
+	eq.pods.Insert(key)
+	podRequest := computePodResourceRequest(pod)
+	eq.reserveResource(*podRequest)
+	eq.reserveScopedResource(pod.Spec.PriorityClassName, *podRequest)
+	for _, ancestor := range e.ancestors(eq) {
+		ancestor = e.mutable(ancestor)
+		ancestor.reserveResource(*podRequest)
+		ancestor.reserveScopedResource(pod.Spec.PriorityClassName, *podRequest)
+	}
+	e.consumeGangReservation(eq, util.GetPodGroupFullName(pod), podRequest)
+	return nil
+}
+
+// deletePodIfPresent deletes the pod from the ElasticQuotaInfo that applies to its namespace, if
+// any, and unreserves its request in that ElasticQuotaInfo and all its ancestors.
+func (e ElasticQuotaInfos) deletePodIfPresent(index namespaceIndex, pod *v1.Pod) error {
+	eq := e.getByNamespace(index, pod.Namespace)
+	if eq == nil {
+		return nil
+	}
+	key, err := framework.GetPodKey(pod)
+	if err != nil {
+		return err
+	}
+	if !eq.pods.Has(key) {
+		return nil
+	}
+
+	eq = e.mutable(eq)
+	eq.pods.Delete(key)
+	podRequest := computePodResourceRequest(pod)
+	eq.unreserveResource(*podRequest)
+	eq.unreserveScopedResource(pod.Spec.PriorityClassName, *podRequest)
+	for _, ancestor := range e.ancestors(eq) {
+		ancestor = e.mutable(ancestor)
+		ancestor.unreserveResource(*podRequest)
+		ancestor.unreserveScopedResource(pod.Spec.PriorityClassName, *podRequest)
+	}
+	return nil
+}
+
+// reserveGang reserves the given request in the given ElasticQuotaInfo and all its ancestors on
+// behalf of the given number of members of a PodGroup of the given PriorityClass yet to be scheduled,
+// unless already reserved. The reservation is consumed as the members are added, and released once
+// they all are.
+func (e ElasticQuotaInfos) reserveGang(eq *ElasticQuotaInfo, pgName, priorityClassName string, request *framework.Resource, members int32) {
+	if eq.gangs[pgName] != nil || members <= 0 {
+		return
+	}
+	eq = e.mutable(eq)
+	if eq.gangs == nil {
+		eq.gangs = make(map[string]*gangReservation)
+	}
+	eq.gangs[pgName] = &gangReservation{request: request.Clone(), priorityClassName: priorityClassName, members: members}
+	eq.reserveResource(*request)
+	eq.reserveScopedResource(priorityClassName, *request)
+	for _, ancestor := range e.ancestors(eq) {
+		ancestor = e.mutable(ancestor)
+		ancestor.reserveResource(*request)
+		ancestor.reserveScopedResource(priorityClassName, *request)
+	}
+}
+
+// releaseGang unreserves what remains of the reservation of the given PodGroup in the given
+// ElasticQuotaInfo and all its ancestors.
+func (e ElasticQuotaInfos) releaseGang(eq *ElasticQuotaInfo, pgName string) {
+	if eq.gangs[pgName] == nil {
+		return
+	}
+	eq = e.mutable(eq)
+	gang := eq.gangs[pgName]
+	delete(eq.gangs, pgName)
+	eq.unreserveResource(*gang.request)
+	eq.unreserveScopedResource(gang.priorityClassName, *gang.request)
+	for _, ancestor := range e.ancestors(eq) {
+		ancestor = e.mutable(ancestor)
+		ancestor.unreserveResource(*gang.request)
+		ancestor.unreserveScopedResource(gang.priorityClassName, *gang.request)
+	}
+}
+
+// consumeGangReservation deducts the request of a member added to the given ElasticQuotaInfo from
+// the reservation of its PodGroup, if any, so that the member isn't accounted twice.
+func (e ElasticQuotaInfos) consumeGangReservation(eq *ElasticQuotaInfo, pgName string, podRequest *framework.Resource) {
+	if eq.gangs[pgName] == nil {
+		return
+	}
+	eq = e.mutable(eq)
+	gang := eq.gangs[pgName]
+	consumed := minResource(gang.request, podRequest)
+	subtractResource(gang.request, *consumed)
+	eq.unreserveResource(*consumed)
+	eq.unreserveScopedResource(gang.priorityClassName, *consumed)
+	for _, ancestor := range e.ancestors(eq) {
+		ancestor = e.mutable(ancestor)
+		ancestor.unreserveResource(*consumed)
+		ancestor.unreserveScopedResource(gang.priorityClassName, *consumed)
+	}
+	gang.members--
+	if gang.members <= 0 {
+		e.releaseGang(eq, pgName)
+	}
+}
+
+// link adds the ElasticQuotaInfo of the given namespace to the tree: the usage of the
+// ElasticQuotaInfos whose parent it is counts towards its usage, and its usage counts towards
+// the usage of its ancestors.
+func (e ElasticQuotaInfos) link(namespace string) {
+	eq := e[namespace]
+	if eq == nil {
+		return
+	}
+	eq = e.mutable(eq)
+	for _, child := range e {
+		if child.Parent == eq.key() && child != eq {
+			eq.reserveResource(*child.Used)
+			eq.reserveScopedResources(child.ScopedUsed)
+		}
+	}
+	for _, ancestor := range e.ancestors(eq) {
+		ancestor = e.mutable(ancestor)
+		ancestor.reserveResource(*eq.Used)
+		ancestor.reserveScopedResources(eq.ScopedUsed)
+	}
+}
+
+// unlink removes the ElasticQuotaInfo of the given namespace from the tree, so that its usage
+// doesn't count towards the usage of its ancestors anymore, and the usage of its children
+// doesn't count towards its own.
+func (e ElasticQuotaInfos) unlink(namespace string) {
+	eq := e[namespace]
+	if eq == nil {
+		return
+	}
+	eq = e.mutable(eq)
+	for _, ancestor := range e.ancestors(eq) {
+		ancestor = e.mutable(ancestor)
+		ancestor.unreserveResource(*eq.Used)
+		ancestor.unreserveScopedResources(eq.ScopedUsed)
+	}
+	for _, child := range e {
+		if child.Parent == eq.key() && child != eq {
+			eq.unreserveResource(*child.Used)
+			eq.unreserveScopedResources(child.ScopedUsed)
+		}
+	}
+}
+
+// siblings returns the ElasticQuotaInfos sharing the parent of the given ElasticQuotaInfo,
+// including itself. The roots of the trees are siblings of each other.
+func (e ElasticQuotaInfos) siblings(eq *ElasticQuotaInfo) []*ElasticQuotaInfo {
+	parent := e.parent(eq)
+	var siblings []*ElasticQuotaInfo
+	for _, info := range e {
+		if e.parent(info) == parent {
+			siblings = append(siblings, info)
+		}
+	}
+	return siblings
+}
+
+// fairShare returns the fair share of the given ElasticQuotaInfo for each resource: its min
+// plus the part, proportional to its weight, of the headroom of its siblings, i.e. the resources
+// they don't use below their min. The resources borrowed above the min don't count towards the
+// headroom, so that a borrower doesn't raise its own fair share. The fair share is bounded by the max.
+func (e ElasticQuotaInfos) fairShare(eq *ElasticQuotaInfo) map[v1.ResourceName]int64 {
+	headroom := make(map[v1.ResourceName]int64)
+	var totalWeight int64
+	for _, sibling := range e.siblings(eq) {
+		totalWeight += sibling.getWeight()
+		min, used := resourceMap(sibling.Min), resourceMap(sibling.Used)
+		for name := range mergedResourceNames(min, used) {
+			headroom[name] += max(min[name]-used[name], 0)
+		}
+	}
+
+	min, max := resourceMap(eq.Min), resourceMap(eq.Max)
+	fairShare := make(map[v1.ResourceName]int64, len(headroom))
+	for name, value := range headroom {
+		share := min[name] + int64(float64(value)*float64(eq.getWeight())/float64(totalWeight))
+		if maxValue, ok := max[name]; ok && share > maxValue {
+			share = maxValue
+		}
+		fairShare[name] = share
+	}
+	return fairShare
+}
+
+// usedOverFairShareWith checks whether the usage of the given ElasticQuotaInfo along with
+// the given request is over its fair share.
+func (e ElasticQuotaInfos) usedOverFairShareWith(eq *ElasticQuotaInfo, podRequest *framework.Resource) bool {
+	fairShare := e.fairShare(eq)
+	used, request := resourceMap(eq.Used), resourceMap(podRequest)
+	for name, value := range request {
+		if value > 0 && used[name]+value > fairShare[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// fairShareRatio returns the dominant ratio of the usage of the given ElasticQuotaInfo to its
+// fair share, i.e. the highest ratio across resources. A ratio above 1 means the ElasticQuotaInfo
+// is above its fair share.
+func (e ElasticQuotaInfos) fairShareRatio(eq *ElasticQuotaInfo) float64 {
+	fairShare := e.fairShare(eq)
+	var ratio float64
+	for name, value := range resourceMap(eq.Used) {
+		if value <= 0 {
+			continue
+		}
+		if fairShare[name] <= 0 {
+			return math.Inf(1)
+		}
+		ratio = math.Max(ratio, float64(value)/float64(fairShare[name]))
+	}
+	return ratio
+}
+
+// namespaceIndex maps the namespaces selected by the namespace selector of an ElasticQuota to the
+// namespace of that ElasticQuota. It is replaced rather than modified, so that it can be shared
+// with the snapshots.
+type namespaceIndex map[string]string
+
+// with returns a copy of the index where the given added namespaces map to the given ElasticQuota
+// namespace, and the given deleted namespaces no longer map to it.
+func (i namespaceIndex) with(namespace string, added, deleted sets.String) namespaceIndex {
+	index := make(namespaceIndex, len(i)+added.Len())
+	for selected, eqNamespace := range i {
+		if eqNamespace != namespace || !deleted.Has(selected) {
+			index[selected] = eqNamespace
+		}
+	}
+	for _, selected := range added.UnsortedList() {
+		index[selected] = namespace
+	}
+	return index
+}
+
 // ElasticQuotaInfo is a wrapper to a ElasticQuota with information.
-// Each namespace can only have one ElasticQuota.
+// Each namespace can only have one ElasticQuota, which applies either to
+// the namespace itself or to the namespaces selected by its namespace selector.
 type ElasticQuotaInfo struct {
 	Namespace string
-	pods      sets.String
-	Min       *framework.Resource
-	Max       *framework.Resource
-	Used      *framework.Resource
+	// Name is the name of the ElasticQuota.
+	Name string
+	// creationTimestamp is the creation time of the ElasticQuota, which decides which one of
+	// two overlapping ElasticQuotas applies.
+	creationTimestamp metav1.Time
+	// Parent is the namespace and name of the parent ElasticQuota, if any. An ElasticQuota
+	// whose parent doesn't exist is a root.
+	Parent types.NamespacedName
+	// selector is the namespace selector of the ElasticQuota, if any.
+	selector labels.Selector
+	// namespaces are the namespaces selected by the namespace selector. It is
+	// nil if the ElasticQuota applies to its own namespace only. The set is
+	// replaced rather than modified, so that it can be shared by clones.
+	namespaces sets.String
+	// Weight is the weight of the ElasticQuota in the fair share of the unused min of its siblings.
+	Weight int64
+	pods   sets.String
+	// gangs are the reservations of the PodGroups admitted in the ElasticQuota whose members
+	// are not all scheduled yet, by PodGroup full name. Used includes them.
+	gangs map[string]*gangReservation
+	Min   *framework.Resource
+	Max   *framework.Resource
+	Used  *framework.Resource
+	// ScopedMax are the Max of the scopes of the ElasticQuota, by PriorityClass name.
+	ScopedMax map[string]*framework.Resource
+	// ScopedUsed is the part of Used of the pods and gangs of each PriorityClass, by PriorityClass name.
+	// It is tracked whether the ElasticQuota has a scope for the PriorityClass or not, so that
+	// it is carried over when the scopes change, and it includes the usage of the descendants.
+	ScopedUsed map[string]*framework.Resource
+	// generation is bumped on every modification of the ElasticQuotaInfo through ElasticQuotaInfos,
+	// so that snapshots only copy the ElasticQuotaInfos that changed since the previous one.
+	generation int64
+	// shared is set on the copies shared between snapshots, which must not be modified: the
+	// ElasticQuotaInfos methods modifying them first replace them with a private copy.
+	shared bool
+}
+
+// generation is the last generation of the ElasticQuotaInfos.
+var generation int64
+
+// nextGeneration returns the next generation of the ElasticQuotaInfos.
+func nextGeneration() int64 {
+	return atomic.AddInt64(&generation, 1)
+}
+
+// gangReservation is the part of the ElasticQuota reserved for the members of a PodGroup
+// that are yet to be scheduled, so that other pods can't starve an admitted gang.
+type gangReservation struct {
+	request *framework.Resource
+	// priorityClassName is the PriorityClass of the PodGroup, whose scope the request is reserved in.
+	priorityClassName string
+	members           int32
 }
 
 func newElasticQuotaInfo(namespace string, min, max, used v1.ResourceList) *ElasticQuotaInfo {
@@ -86,23 +524,91 @@ func newElasticQuotaInfo(namespace string, min, max, used v1.ResourceList) *Elas
 	return elasticQuotaInfo
 }
 
-func (e *ElasticQuotaInfo) reserveResource(request framework.Resource) {
-	e.Used.Memory += request.Memory
-	e.Used.MilliCPU += request.MilliCPU
-	e.Used.EphemeralStorage += request.EphemeralStorage
-	e.Used.AllowedPodNumber += request.AllowedPodNumber
-	for name, value := range request.ScalarResources {
-		e.Used.SetScalar(name, e.Used.ScalarResources[name]+value)
+// key returns the namespace and name of the ElasticQuota of the ElasticQuotaInfo.
+func (e *ElasticQuotaInfo) key() types.NamespacedName {
+	return types.NamespacedName{Namespace: e.Namespace, Name: e.Name}
+}
+
+// getWeight returns the weight of the ElasticQuotaInfo, which defaults to 1.
+func (e *ElasticQuotaInfo) getWeight() int64 {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// appliesTo checks whether the ElasticQuotaInfo applies to the given namespace.
+func (e *ElasticQuotaInfo) appliesTo(namespace string) bool {
+	if e.namespaces == nil {
+		return namespace == e.Namespace
+	}
+	return e.namespaces.Has(namespace)
+}
+
+// createdBefore checks whether the ElasticQuota of the ElasticQuotaInfo was created before the one
+// of the given ElasticQuotaInfo, breaking ties by namespace. Like the controller, the older of two
+// overlapping ElasticQuotas applies.
+func (e *ElasticQuotaInfo) createdBefore(other *ElasticQuotaInfo) bool {
+	if !e.creationTimestamp.Equal(&other.creationTimestamp) {
+		return e.creationTimestamp.Before(&other.creationTimestamp)
 	}
+	return e.Namespace < other.Namespace
+}
+
+// getNamespaces returns the namespaces the ElasticQuotaInfo applies to.
+func (e *ElasticQuotaInfo) getNamespaces() sets.String {
+	if e.namespaces == nil {
+		return sets.NewString(e.Namespace)
+	}
+	return e.namespaces
+}
+
+// getGang returns the reservation of the given PodGroup in the ElasticQuotaInfo, if any.
+func (e *ElasticQuotaInfo) getGang(pgName string) *gangReservation {
+	return e.gangs[pgName]
+}
+
+func (e *ElasticQuotaInfo) reserveResource(request framework.Resource) {
+	addResource(e.Used, request)
 }
 
 func (e *ElasticQuotaInfo) unreserveResource(request framework.Resource) {
-	e.Used.Memory -= request.Memory
-	e.Used.MilliCPU -= request.MilliCPU
-	e.Used.EphemeralStorage -= request.EphemeralStorage
-	e.Used.AllowedPodNumber -= request.AllowedPodNumber
-	for name, value := range request.ScalarResources {
-		e.Used.SetScalar(name, e.Used.ScalarResources[name]-value)
+	subtractResource(e.Used, request)
+}
+
+// reserveScopedResource reserves the given request of a pod of the given PriorityClass in its
+// scoped usage. The pods without a PriorityClass don't belong to any scope.
+func (e *ElasticQuotaInfo) reserveScopedResource(priorityClassName string, request framework.Resource) {
+	if priorityClassName == "" {
+		return
+	}
+	if e.ScopedUsed == nil {
+		e.ScopedUsed = make(map[string]*framework.Resource)
+	}
+	used := e.ScopedUsed[priorityClassName]
+	if used == nil {
+		used = &framework.Resource{}
+		e.ScopedUsed[priorityClassName] = used
+	}
+	addResource(used, request)
+}
+
+func (e *ElasticQuotaInfo) unreserveScopedResource(priorityClassName string, request framework.Resource) {
+	if used := e.ScopedUsed[priorityClassName]; used != nil {
+		subtractResource(used, request)
+	}
+}
+
+// reserveScopedResources reserves the given scoped usage, by PriorityClass name.
+func (e *ElasticQuotaInfo) reserveScopedResources(scopedUsed map[string]*framework.Resource) {
+	for priorityClassName, used := range scopedUsed {
+		e.reserveScopedResource(priorityClassName, *used)
+	}
+}
+
+func (e *ElasticQuotaInfo) unreserveScopedResources(scopedUsed map[string]*framework.Resource) {
+	for priorityClassName, used := range scopedUsed {
+		e.unreserveScopedResource(priorityClassName, *used)
 	}
 }
 
@@ -122,6 +628,20 @@ func (e *ElasticQuotaInfo) usedOverMaxWith(podRequest *framework.Resource) bool
 	return cmp2(podRequest, e.Used, e.Max, UpperBoundOfMax)
 }
 
+// usedOverScopedMaxWith checks whether the given request of a pod of the given PriorityClass
+// exceeds the Max of the scope of the PriorityClass, if the ElasticQuotaInfo has one.
+func (e *ElasticQuotaInfo) usedOverScopedMaxWith(priorityClassName string, podRequest *framework.Resource) bool {
+	max := e.ScopedMax[priorityClassName]
+	if max == nil {
+		return false
+	}
+	used := e.ScopedUsed[priorityClassName]
+	if used == nil {
+		used = &framework.Resource{}
+	}
+	return cmp2(podRequest, used, max, UpperBoundOfMax)
+}
+
 func (e *ElasticQuotaInfo) usedOverMin() bool {
 	// "ElasticQuotaInfo doesn't have Min" means used values exceeded min(0)
 	if e.Min == nil {
@@ -132,8 +652,15 @@ func (e *ElasticQuotaInfo) usedOverMin() bool {
 
 func (e *ElasticQuotaInfo) clone() *ElasticQuotaInfo {
 	newEQInfo := &ElasticQuotaInfo{
-		Namespace: e.Namespace,
-		pods:      sets.NewString(),
+		Namespace:         e.Namespace,
+		Name:              e.Name,
+		creationTimestamp: e.creationTimestamp,
+		Parent:            e.Parent,
+		selector:          e.selector,
+		namespaces:        e.namespaces,
+		Weight:            e.Weight,
+		pods:              make(sets.String, len(e.pods)),
+		generation:        e.generation,
 	}
 
 	if e.Min != nil {
@@ -145,48 +672,29 @@ func (e *ElasticQuotaInfo) clone() *ElasticQuotaInfo {
 	if e.Used != nil {
 		newEQInfo.Used = e.Used.Clone()
 	}
-	if len(e.pods) > 0 {
-		pods := e.pods.List()
-		for _, pod := range pods {
-			newEQInfo.pods.Insert(pod)
-		}
-	}
-
-	return newEQInfo
-}
-
-func (e *ElasticQuotaInfo) addPodIfNotPresent(pod *v1.Pod) error {
-	key, err := framework.GetPodKey(pod)
-	if err != nil {
-		return err
+	for pod := range e.pods {
+		newEQInfo.pods.Insert(pod)
 	}
-
-	if e.pods.Has(key) {
-		return nil
+	if e.ScopedMax != nil {
+		newEQInfo.ScopedMax = make(map[string]*framework.Resource, len(e.ScopedMax))
+		for priorityClassName, max := range e.ScopedMax {
+			newEQInfo.ScopedMax[priorityClassName] = max.Clone()
+		}
 	}
-
-	e.pods.Insert(key)
-	podRequest := computePodResourceRequest(pod)
-	e.reserveResource(*podRequest)
-
-	return nil
-}
-
-func (e *ElasticQuotaInfo) deletePodIfPresent(pod *v1.Pod) error {
-	key, err := framework.GetPodKey(pod)
-	if err != nil {
-		return err
+	if e.ScopedUsed != nil {
+		newEQInfo.ScopedUsed = make(map[string]*framework.Resource, len(e.ScopedUsed))
+		for priorityClassName, used := range e.ScopedUsed {
+			newEQInfo.ScopedUsed[priorityClassName] = used.Clone()
+		}
 	}
-
-	if !e.pods.Has(key) {
-		return nil
+	if e.gangs != nil {
+		newEQInfo.gangs = make(map[string]*gangReservation, len(e.gangs))
+		for pgName, gang := range e.gangs {
+			newEQInfo.gangs[pgName] = &gangReservation{request: gang.request.Clone(), priorityClassName: gang.priorityClassName, members: gang.members}
+		}
 	}
 
-	e.pods.Delete(key)
-	podRequest := computePodResourceRequest(pod)
-	e.unreserveResource(*podRequest)
-
-	return nil
+	return newEQInfo
 }
 
 func cmp(x, y *framework.Resource, bound int64) bool {
@@ -223,6 +731,91 @@ func cmp2(x1, x2, y *framework.Resource, bound int64) bool {
 	return false
 }
 
+// addResource adds y to x.
+func addResource(x *framework.Resource, y framework.Resource) {
+	x.Memory += y.Memory
+	x.MilliCPU += y.MilliCPU
+	x.EphemeralStorage += y.EphemeralStorage
+	x.AllowedPodNumber += y.AllowedPodNumber
+	for name, value := range y.ScalarResources {
+		x.SetScalar(name, x.ScalarResources[name]+value)
+	}
+}
+
+// subtractResource subtracts y from x.
+func subtractResource(x *framework.Resource, y framework.Resource) {
+	x.Memory -= y.Memory
+	x.MilliCPU -= y.MilliCPU
+	x.EphemeralStorage -= y.EphemeralStorage
+	x.AllowedPodNumber -= y.AllowedPodNumber
+	for name, value := range y.ScalarResources {
+		x.SetScalar(name, x.ScalarResources[name]-value)
+	}
+}
+
+// minResource returns the minimum of x and y in each resource dimension.
+func minResource(x, y *framework.Resource) *framework.Resource {
+	result := &framework.Resource{
+		MilliCPU:         min(x.MilliCPU, y.MilliCPU),
+		Memory:           min(x.Memory, y.Memory),
+		EphemeralStorage: min(x.EphemeralStorage, y.EphemeralStorage),
+		AllowedPodNumber: min(x.AllowedPodNumber, y.AllowedPodNumber),
+	}
+	for name, value := range x.ScalarResources {
+		if yValue, ok := y.ScalarResources[name]; ok {
+			result.SetScalar(name, min(value, yValue))
+		}
+	}
+	return result
+}
+
+// resourceMap returns the quantities of the given resource by name.
+func resourceMap(r *framework.Resource) map[v1.ResourceName]int64 {
+	if r == nil {
+		return nil
+	}
+	m := map[v1.ResourceName]int64{
+		v1.ResourceCPU:              r.MilliCPU,
+		v1.ResourceMemory:           r.Memory,
+		v1.ResourceEphemeralStorage: r.EphemeralStorage,
+	}
+	for name, value := range r.ScalarResources {
+		m[name] = value
+	}
+	return m
+}
+
+// mergedResourceNames returns the names of the resources of x and y.
+func mergedResourceNames(x, y map[v1.ResourceName]int64) sets.Set[v1.ResourceName] {
+	names := sets.New[v1.ResourceName]()
+	for name := range x {
+		names.Insert(name)
+	}
+	for name := range y {
+		names.Insert(name)
+	}
+	return names
+}
+
+// newScopedMax returns the Max of a scope of an ElasticQuota, in which the resources not listed
+// are unbounded, so that they are only limited by the Max of the ElasticQuota.
+func newScopedMax(max v1.ResourceList) *framework.Resource {
+	scopedMax := framework.NewResource(makeResourceListForBound(UpperBoundOfMax))
+	for name, quantity := range max {
+		switch name {
+		case v1.ResourceCPU:
+			scopedMax.MilliCPU = quantity.MilliValue()
+		case v1.ResourceMemory:
+			scopedMax.Memory = quantity.Value()
+		case v1.ResourceEphemeralStorage:
+			scopedMax.EphemeralStorage = quantity.Value()
+		default:
+			scopedMax.Add(v1.ResourceList{name: quantity})
+		}
+	}
+	return scopedMax
+}
+
 func makeResourceListForBound(bound int64) v1.ResourceList {
 	return v1.ResourceList{
 		v1.ResourceCPU:              *resource.NewMilliQuantity(bound, resource.DecimalSI),
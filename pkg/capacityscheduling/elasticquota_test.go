@@ -23,6 +23,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 )
 
@@ -630,3 +632,230 @@ func TestNewElasticQuotaInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestElasticQuotaInfosHierarchy(t *testing.T) {
+	// division -> team1 -> project
+	//          -> team2
+	// other
+	newInfos := func() ElasticQuotaInfos {
+		infos := ElasticQuotaInfos{
+			"division": newElasticQuotaInfo("division", makeResourceList(100, 1000), makeResourceList(200, 2000), nil),
+			"team1":    newElasticQuotaInfo("team1", makeResourceList(50, 500), makeResourceList(200, 2000), nil),
+			"team2":    newElasticQuotaInfo("team2", makeResourceList(50, 500), makeResourceList(200, 2000), nil),
+			"project":  newElasticQuotaInfo("project", makeResourceList(10, 100), makeResourceList(200, 2000), nil),
+			"other":    newElasticQuotaInfo("other", makeResourceList(100, 1000), makeResourceList(200, 2000), nil),
+		}
+		for ns, info := range infos {
+			info.Name = ns + "-eq"
+		}
+		infos["team1"].Parent = types.NamespacedName{Namespace: "division", Name: "division-eq"}
+		infos["team2"].Parent = types.NamespacedName{Namespace: "division", Name: "division-eq"}
+		infos["project"].Parent = types.NamespacedName{Namespace: "team1", Name: "team1-eq"}
+		return infos
+	}
+
+	t.Run("a parent reference to another ElasticQuota of the namespace is ignored", func(t *testing.T) {
+		infos := newInfos()
+		infos["project"].Parent = types.NamespacedName{Namespace: "team1", Name: "deleted-eq"}
+		if got := infos.ancestors(infos["project"]); len(got) != 0 {
+			t.Errorf("expected no ancestors, got %v", got)
+		}
+		if err := infos.addPodIfNotPresent(nil, makePod("p", "project", 100, 10, 0, midPriority, "p", "node-a")); err != nil {
+			t.Fatal(err)
+		}
+		if got := infos["team1"].Used; got.MilliCPU != 0 || got.Memory != 0 {
+			t.Errorf("expected no usage in team1, got %v", got)
+		}
+	})
+
+	t.Run("pods are accounted in the ancestors", func(t *testing.T) {
+		infos := newInfos()
+		pod := makePod("p", "project", 100, 10, 0, midPriority, "p", "node-a")
+		if err := infos.addPodIfNotPresent(nil, pod); err != nil {
+			t.Fatal(err)
+		}
+		// Adding the same pod twice doesn't count it twice.
+		if err := infos.addPodIfNotPresent(nil, pod); err != nil {
+			t.Fatal(err)
+		}
+		for _, ns := range []string{"project", "team1", "division"} {
+			if got := infos[ns].Used; got.MilliCPU != 10 || got.Memory != 100 {
+				t.Errorf("%v: expected used cpu 10 and memory 100, got %v", ns, got)
+			}
+		}
+		for _, ns := range []string{"team2", "other"} {
+			if got := infos[ns].Used; got.MilliCPU != 0 || got.Memory != 0 {
+				t.Errorf("%v: expected no usage, got %v", ns, got)
+			}
+		}
+
+		if err := infos.deletePodIfPresent(nil, pod); err != nil {
+			t.Fatal(err)
+		}
+		for _, ns := range []string{"project", "team1", "division"} {
+			if got := infos[ns].Used; got.MilliCPU != 0 || got.Memory != 0 {
+				t.Errorf("%v: expected no usage, got %v", ns, got)
+			}
+		}
+	})
+
+	t.Run("max of the ancestors applies", func(t *testing.T) {
+		infos := newInfos()
+		infos["division"].Max = &framework.Resource{MilliCPU: 20, Memory: 2000}
+		if !infos.usedOverMaxWith(infos["project"], &framework.Resource{MilliCPU: 30}) {
+			t.Errorf("expected the request to be over the max of the division")
+		}
+		if infos.usedOverMaxWith(infos["project"], &framework.Resource{MilliCPU: 10}) {
+			t.Errorf("expected the request to fit in the max of the division")
+		}
+	})
+
+	t.Run("scoped usage is accounted in the ancestors", func(t *testing.T) {
+		infos := newInfos()
+		infos["division"].ScopedMax = map[string]*framework.Resource{"preemptible": newScopedMax(v1.ResourceList{ResourceGPU: *resource.NewQuantity(2, resource.DecimalSI)})}
+		pod := makePod("p", "project", 100, 10, 2, midPriority, "p", "node-a")
+		pod.Spec.PriorityClassName = "preemptible"
+		if err := infos.addPodIfNotPresent(nil, pod); err != nil {
+			t.Fatal(err)
+		}
+		for _, ns := range []string{"project", "team1", "division"} {
+			if got := infos[ns].ScopedUsed["preemptible"]; got == nil || got.ScalarResources[ResourceGPU] != 2 {
+				t.Errorf("%v: expected scoped used gpu 2, got %v", ns, got)
+			}
+		}
+		if !infos.usedOverScopedMaxWith(infos["team1"], "preemptible", &framework.Resource{ScalarResources: map[v1.ResourceName]int64{ResourceGPU: 1}}) {
+			t.Errorf("expected the request to be over the scoped max of the division")
+		}
+		if infos.usedOverScopedMaxWith(infos["team1"], "production", &framework.Resource{ScalarResources: map[v1.ResourceName]int64{ResourceGPU: 1}}) {
+			t.Errorf("expected the request of another PriorityClass to fit")
+		}
+		// The resources not listed in the scoped max are not limited by it.
+		if infos.usedOverScopedMaxWith(infos["team1"], "preemptible", &framework.Resource{MilliCPU: 1000, Memory: 10000}) {
+			t.Errorf("expected the request of the resources not listed to fit in the scoped max")
+		}
+
+		// Moving the project under team2 moves its scoped usage.
+		infos.unlink("project")
+		infos["project"].Parent = types.NamespacedName{Namespace: "team2", Name: "team2-eq"}
+		infos.link("project")
+		if got := infos["team1"].ScopedUsed["preemptible"]; got.ScalarResources[ResourceGPU] != 0 {
+			t.Errorf("team1: expected no scoped usage, got %v", got)
+		}
+		for _, ns := range []string{"project", "team2", "division"} {
+			if got := infos[ns].ScopedUsed["preemptible"]; got == nil || got.ScalarResources[ResourceGPU] != 2 {
+				t.Errorf("%v: expected scoped used gpu 2, got %v", ns, got)
+			}
+		}
+
+		if err := infos.deletePodIfPresent(nil, pod); err != nil {
+			t.Fatal(err)
+		}
+		if infos.usedOverScopedMaxWith(infos["team2"], "preemptible", &framework.Resource{ScalarResources: map[v1.ResourceName]int64{ResourceGPU: 2}}) {
+			t.Errorf("expected the request to fit in the scoped max of the division")
+		}
+	})
+
+	t.Run("only roots are aggregated", func(t *testing.T) {
+		infos := newInfos()
+		if err := infos.addPodIfNotPresent(nil, makePod("p", "team2", 0, 150, 0, midPriority, "p", "node-a")); err != nil {
+			t.Fatal(err)
+		}
+		// The min of division and other add up to 200 cpu, the min of the children don't count.
+		if infos.aggregatedUsedOverMinWith(framework.Resource{MilliCPU: 50}) {
+			t.Errorf("expected the aggregated used to be within the aggregated min")
+		}
+		if !infos.aggregatedUsedOverMinWith(framework.Resource{MilliCPU: 51}) {
+			t.Errorf("expected the aggregated used to be over the aggregated min")
+		}
+	})
+
+	t.Run("distance", func(t *testing.T) {
+		infos := newInfos()
+		tests := []struct {
+			eq, other string
+			expected  int
+		}{
+			{eq: "project", other: "project", expected: 0},
+			{eq: "team1", other: "project", expected: 0},
+			{eq: "project", other: "team1", expected: 1},
+			{eq: "team1", other: "team2", expected: 1},
+			{eq: "project", other: "team2", expected: 2},
+			{eq: "project", other: "other", expected: 3},
+		}
+		for _, tt := range tests {
+			if got := infos.distance(infos[tt.eq], infos[tt.other]); got != tt.expected {
+				t.Errorf("distance(%v, %v): expected %v, got %v", tt.eq, tt.other, tt.expected, got)
+			}
+		}
+	})
+
+	t.Run("link and unlink", func(t *testing.T) {
+		infos := newInfos()
+		if err := infos.addPodIfNotPresent(nil, makePod("p", "project", 0, 10, 0, midPriority, "p", "node-a")); err != nil {
+			t.Fatal(err)
+		}
+		infos.unlink("team1")
+		delete(infos, "team1")
+		if got := infos["division"].Used.MilliCPU; got != 0 {
+			t.Errorf("expected division to have no usage after unlinking team1, got %v", got)
+		}
+		if got := infos["project"].Used.MilliCPU; got != 10 {
+			t.Errorf("expected project to keep its usage, got %v", got)
+		}
+
+		infos["team1"] = newElasticQuotaInfo("team1", nil, nil, nil)
+		infos["team1"].Name = "team1-eq"
+		infos["team1"].Parent = types.NamespacedName{Namespace: "division", Name: "division-eq"}
+		infos.link("team1")
+		if got := infos["team1"].Used.MilliCPU; got != 10 {
+			t.Errorf("expected team1 to count the usage of project, got %v", got)
+		}
+		if got := infos["division"].Used.MilliCPU; got != 10 {
+			t.Errorf("expected division to count the usage of project, got %v", got)
+		}
+	})
+
+	t.Run("parent cycle", func(t *testing.T) {
+		infos := newInfos()
+		infos["division"].Parent = types.NamespacedName{Namespace: "project", Name: "project-eq"}
+		if got := len(infos.ancestors(infos["project"])); got != 2 {
+			t.Errorf("expected 2 ancestors, got %v", got)
+		}
+	})
+}
+
+func TestFairShare(t *testing.T) {
+	infos := ElasticQuotaInfos{
+		"ns1": {Namespace: "ns1", Weight: 1, Min: &framework.Resource{MilliCPU: 100}, Max: &framework.Resource{MilliCPU: 1000}, Used: &framework.Resource{MilliCPU: 300}},
+		"ns2": {Namespace: "ns2", Weight: 3, Min: &framework.Resource{MilliCPU: 300}, Max: &framework.Resource{MilliCPU: 1000}, Used: &framework.Resource{MilliCPU: 0}},
+		"ns3": {Namespace: "ns3", Min: &framework.Resource{MilliCPU: 100}, Max: &framework.Resource{MilliCPU: 150}, Used: &framework.Resource{MilliCPU: 100}},
+	}
+	// The headroom is the unused min of ns2, 300, and the total weight is 5.
+	tests := []struct {
+		namespace string
+		expected  int64
+		ratio     float64
+	}{
+		{namespace: "ns1", expected: 160, ratio: float64(300) / 160},
+		{namespace: "ns2", expected: 480, ratio: 0},
+		// The fair share is bounded by the max.
+		{namespace: "ns3", expected: 150, ratio: float64(100) / 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			if got := infos.fairShare(infos[tt.namespace])[v1.ResourceCPU]; got != tt.expected {
+				t.Errorf("expected fair share %v, got %v", tt.expected, got)
+			}
+			if got := infos.fairShareRatio(infos[tt.namespace]); got != tt.ratio {
+				t.Errorf("expected fair share ratio %v, got %v", tt.ratio, got)
+			}
+		})
+	}
+
+	if !infos.usedOverFairShareWith(infos["ns3"], &framework.Resource{MilliCPU: 51}) {
+		t.Errorf("expected ns3 to be over its fair share")
+	}
+	if infos.usedOverFairShareWith(infos["ns2"], &framework.Resource{MilliCPU: 480}) {
+		t.Errorf("expected ns2 to be within its fair share")
+	}
+}
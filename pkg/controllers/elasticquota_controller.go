@@ -19,12 +19,17 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"math"
 
 	v1 "k8s.io/api/core/v1"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	quota "k8s.io/apiserver/pkg/quota/v1"
 	"k8s.io/client-go/tools/record"
 
@@ -33,7 +38,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	schedv1alpha1 "sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	"sigs.k8s.io/scheduler-plugins/pkg/util/usage"
 )
 
 type ElasticQuotaReconciler struct {
@@ -47,6 +54,7 @@ type ElasticQuotaReconciler struct {
 // +kubebuilder:rbac:groups=scheduling.x-k8s.io,resources=elasticquota,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=scheduling.x-k8s.io,resources=elasticquota/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=scheduling.x-k8s.io,resources=elasticquota/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 func (r *ElasticQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 	log.Info("reconciling")
@@ -60,27 +68,61 @@ func (r *ElasticQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, err
 	}
 
-	// TODO: When elastic quota supports multiple instances in a namespace, modify this
+	// Only one elastic quota is supported in each namespace, it may however apply to
+	// several namespaces through its namespace selector.
 	if len(eqList.Items) == 0 {
 		log.V(5).Info("no elasticquota found")
 		return ctrl.Result{}, nil
 	}
 
 	eq := &eqList.Items[0]
-	used, err := r.computeElasticQuotaUsed(ctx, req.Namespace, eq)
+	namespaces, err := r.getNamespaces(ctx, eq)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	overlapping, err := r.getOverlappingElasticQuota(ctx, eq, namespaces)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if overlapping != nil {
+		r.recorder.Event(eq, v1.EventTypeWarning, "Overlapping", fmt.Sprintf("Elastic Quota %s selects namespaces already subject to Elastic Quota %s", req.NamespacedName, client.ObjectKeyFromObject(overlapping)))
+		return ctrl.Result{}, nil
+	}
 
-	// Ignore this loop if the usage value has not changed
-	if apiequality.Semantic.DeepEqual(used, eq.Status.Used) {
+	status, preempted, err := r.computeElasticQuotaStatus(ctx, namespaces, eq)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	allEQs := &schedv1alpha1.ElasticQuotaList{}
+	if err := r.List(ctx, allEQs); err != nil {
+		return ctrl.Result{}, err
+	}
+	// The status of the children is not rolled up if the chain of parents loops back to the
+	// ElasticQuota, otherwise the ElasticQuotas of the loop would add up each other's usage forever.
+	if isInParentCycle(eq, allEQs.Items) {
+		r.recorder.Event(eq, v1.EventTypeWarning, "ParentCycle", fmt.Sprintf("Elastic Quota %s is its own ancestor, the usage of its children is ignored", req.NamespacedName))
+	} else {
+		childrenStatus := computeChildrenStatus(eq, allEQs.Items)
+		status.Used = quota.Add(status.Used, childrenStatus.Used)
+		status.Pods += childrenStatus.Pods
+		status.Pending = quota.Add(status.Pending, childrenStatus.Pending)
+	}
+	status.Borrowed = computeBorrowed(eq.Spec.Min, status.Used)
+	status.Lent = computeLent(eq, status.Used, getSiblings(eq, allEQs.Items))
+	// The preempted pods already counted are recorded in the status, so that they are not
+	// counted again by the next reconciliations, whether the controller restarted or not.
+	status.Preempted = eq.Status.Preempted + int32(preempted.Difference(sets.New(eq.Status.PreemptedPods...)).Len())
+	status.PreemptedPods = sets.List(preempted)
+
+	// Ignore this loop if the status has not changed
+	if apiequality.Semantic.DeepEqual(status, eq.Status) {
 		return ctrl.Result{}, nil
 	}
 
 	// create a usage object that is based on the elastic quota version that will handle updates
 	// by default, we set used to the current status
 	newEQ := eq.DeepCopy()
-	newEQ.Status.Used = used
+	newEQ.Status = status
 	if err = r.patchElasticQuota(ctx, eq, newEQ); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -93,65 +135,289 @@ func (r *ElasticQuotaReconciler) patchElasticQuota(ctx context.Context, old, new
 	return r.Status().Patch(ctx, new, patch)
 }
 
-func (r *ElasticQuotaReconciler) computeElasticQuotaUsed(ctx context.Context, namespace string, eq *schedv1alpha1.ElasticQuota) (v1.ResourceList, error) {
-	used := newZeroUsed(eq)
-	podList := &v1.PodList{}
-	if err := r.List(ctx, podList, client.InNamespace(namespace)); err != nil {
+// computeElasticQuotaStatus returns the usage of the pods of the given namespaces, as accounted by
+// the CapacityScheduling plugin, along with the number of pods contributing to it and the requests
+// of the unschedulable pods, and the UIDs of the pods preempted by the scheduler.
+func (r *ElasticQuotaReconciler) computeElasticQuotaStatus(ctx context.Context, namespaces []string, eq *schedv1alpha1.ElasticQuota) (schedv1alpha1.ElasticQuotaStatus, sets.Set[types.UID], error) {
+	status := schedv1alpha1.ElasticQuotaStatus{Used: newZeroUsed(eq)}
+	preempted := sets.New[types.UID]()
+	for _, namespace := range namespaces {
+		podList := &v1.PodList{}
+		if err := r.List(ctx, podList, client.InNamespace(namespace)); err != nil {
+			return status, nil, err
+		}
+
+		nsUsed, nsPods := usage.Compute(podList.Items)
+		status.Used = quota.Add(status.Used, nsUsed)
+		status.Pods += nsPods
+		for i := range podList.Items {
+			pod := &podList.Items[i]
+			if usage.IsPodUnschedulable(pod) {
+				status.Pending = quota.Add(status.Pending, usage.PodRequest(pod))
+			}
+			if usage.IsPodPreempted(pod) {
+				preempted.Insert(pod.UID)
+			}
+		}
+	}
+	return status, preempted, nil
+}
+
+// getNamespaces returns the namespaces the given ElasticQuota applies to: the namespaces
+// selected by its namespace selector, or its own namespace if it has none.
+func (r *ElasticQuotaReconciler) getNamespaces(ctx context.Context, eq *schedv1alpha1.ElasticQuota) ([]string, error) {
+	if eq.Spec.NamespaceSelector == nil {
+		return []string{eq.Namespace}, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(eq.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	nsList := &v1.NamespaceList{}
+	if err := r.List(ctx, nsList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
 		return nil, err
 	}
+	namespaces := make([]string, 0, len(nsList.Items))
+	for _, ns := range nsList.Items {
+		namespaces = append(namespaces, ns.Name)
+	}
+	return namespaces, nil
+}
 
-	for _, p := range podList.Items {
-		if p.Status.Phase == v1.PodRunning {
-			used = quota.Add(used, computePodResourceRequest(&p))
-		}
-	}
-	return used, nil
-}
-
-// computePodResourceRequest returns a v1.ResourceList that covers the largest
-// width in each resource dimension. Because init-containers run sequentially, we collect
-// the max in each dimension iteratively. In contrast, we sum the resource vectors for
-// regular containers since they run simultaneously.
-//
-// If Pod Overhead is specified and the feature gate is set, the resources defined for Overhead
-// are added to the calculated Resource request sum
-//
-// Example:
-//
-// Pod:
-//
-//	InitContainers
-//	  IC1:
-//	    CPU: 2
-//	    Memory: 1G
-//	  IC2:
-//	    CPU: 2
-//	    Memory: 3G
-//	Containers
-//	  C1:
-//	    CPU: 2
-//	    Memory: 1G
-//	  C2:
-//	    CPU: 1
-//	    Memory: 1G
-//
-// Result: CPU: 3, Memory: 3G
-func computePodResourceRequest(pod *v1.Pod) v1.ResourceList {
-	result := v1.ResourceList{}
-	for _, container := range pod.Spec.Containers {
-		result = quota.Add(result, container.Resources.Requests)
-	}
-	initRes := v1.ResourceList{}
-	// take max_resource for init_containers
-	for _, container := range pod.Spec.InitContainers {
-		initRes = quota.Max(initRes, container.Resources.Requests)
-	}
-	// If Overhead is being utilized, add to the total requests for the pod
-	if pod.Spec.Overhead != nil {
-		quota.Add(result, pod.Spec.Overhead)
-	}
-	// take max_resource for init_containers and containers
-	return quota.Max(result, initRes)
+// getOverlappingElasticQuota returns an ElasticQuota created before the given one that applies
+// to one of the given namespaces, if any. The older ElasticQuota wins, so that only the newer
+// one of two overlapping ElasticQuotas is rejected.
+func (r *ElasticQuotaReconciler) getOverlappingElasticQuota(ctx context.Context, eq *schedv1alpha1.ElasticQuota, namespaces []string) (*schedv1alpha1.ElasticQuota, error) {
+	eqList := &schedv1alpha1.ElasticQuotaList{}
+	if err := r.List(ctx, eqList); err != nil {
+		return nil, err
+	}
+	selected := sets.New(namespaces...)
+	for i := range eqList.Items {
+		other := &eqList.Items[i]
+		if other.Namespace == eq.Namespace || !isOlder(other, eq) {
+			continue
+		}
+		otherNamespaces, err := r.getNamespaces(ctx, other)
+		if err != nil {
+			return nil, err
+		}
+		if selected.HasAny(otherNamespaces...) {
+			return other, nil
+		}
+	}
+	return nil, nil
+}
+
+// isOlder checks whether the ElasticQuota a was created before the ElasticQuota b,
+// breaking ties by namespace.
+func isOlder(a, b *schedv1alpha1.ElasticQuota) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Namespace < b.Namespace
+}
+
+// computeChildrenStatus returns the sum of the usage, pods and pending requests of the ElasticQuotas
+// whose parent is the given ElasticQuota. Since the status of each child includes the status of its
+// own children, the status rolls up the whole tree.
+func computeChildrenStatus(eq *schedv1alpha1.ElasticQuota, eqs []schedv1alpha1.ElasticQuota) schedv1alpha1.ElasticQuotaStatus {
+	status := schedv1alpha1.ElasticQuotaStatus{Used: v1.ResourceList{}}
+	for i := range eqs {
+		child := &eqs[i]
+		if parent := getParentKey(child); parent != nil && *parent == client.ObjectKeyFromObject(eq) {
+			status.Used = quota.Add(status.Used, child.Status.Used)
+			status.Pods += child.Status.Pods
+			status.Pending = quota.Add(status.Pending, child.Status.Pending)
+		}
+	}
+	return status
+}
+
+// isInParentCycle checks whether the chain of parents of the given ElasticQuota loops back to it.
+func isInParentCycle(eq *schedv1alpha1.ElasticQuota, eqs []schedv1alpha1.ElasticQuota) bool {
+	byKey := make(map[types.NamespacedName]*schedv1alpha1.ElasticQuota, len(eqs))
+	for i := range eqs {
+		byKey[client.ObjectKeyFromObject(&eqs[i])] = &eqs[i]
+	}
+	key := client.ObjectKeyFromObject(eq)
+	visited := sets.New[types.NamespacedName]()
+	for current := eq; ; {
+		parent := getParentKey(current)
+		if parent == nil {
+			return false
+		}
+		if *parent == key {
+			return true
+		}
+		// the chain loops above the ElasticQuota
+		if visited.Has(*parent) {
+			return false
+		}
+		visited.Insert(*parent)
+		if current = byKey[*parent]; current == nil {
+			return false
+		}
+	}
+}
+
+// getSiblings returns the ElasticQuotas sharing the parent of the given ElasticQuota, excluding
+// itself. The roots of the trees are siblings of each other.
+func getSiblings(eq *schedv1alpha1.ElasticQuota, eqs []schedv1alpha1.ElasticQuota) []*schedv1alpha1.ElasticQuota {
+	parent := getParentKey(eq)
+	var siblings []*schedv1alpha1.ElasticQuota
+	for i := range eqs {
+		other := &eqs[i]
+		if client.ObjectKeyFromObject(other) == client.ObjectKeyFromObject(eq) {
+			continue
+		}
+		otherParent := getParentKey(other)
+		if (parent == nil && otherParent == nil) || (parent != nil && otherParent != nil && *parent == *otherParent) {
+			siblings = append(siblings, other)
+		}
+	}
+	return siblings
+}
+
+// computeBorrowed returns the part of the given usage above the given min.
+func computeBorrowed(min, used v1.ResourceList) v1.ResourceList {
+	borrowed := v1.ResourceList{}
+	for name, quantity := range used {
+		if minQuantity, ok := min[name]; ok {
+			quantity = quantity.DeepCopy()
+			quantity.Sub(minQuantity)
+		}
+		if quantity.Sign() > 0 {
+			borrowed[name] = quantity
+		}
+	}
+	return borrowed
+}
+
+// computeLent returns the part of the unused min of the given ElasticQuota borrowed by its siblings.
+// The siblings above their min borrow from the siblings below it in proportion to their unused min.
+func computeLent(eq *schedv1alpha1.ElasticQuota, used v1.ResourceList, siblings []*schedv1alpha1.ElasticQuota) v1.ResourceList {
+	lent := v1.ResourceList{}
+	for name, min := range eq.Spec.Min {
+		unused := float64(quantityValue(name, min) - quantityValue(name, used[name]))
+		if unused <= 0 {
+			continue
+		}
+		totalUnused, totalBorrowed := unused, 0.0
+		for _, sibling := range siblings {
+			diff := float64(quantityValue(name, sibling.Status.Used[name]) - quantityValue(name, sibling.Spec.Min[name]))
+			if diff > 0 {
+				totalBorrowed += diff
+			} else {
+				totalUnused -= diff
+			}
+		}
+		if value := int64(unused * math.Min(totalBorrowed, totalUnused) / totalUnused); value > 0 {
+			lent[name] = newQuantity(name, value, min.Format)
+		}
+	}
+	return lent
+}
+
+// quantityValue returns the value of the given quantity, in millicores for cpu.
+func quantityValue(name v1.ResourceName, quantity resource.Quantity) int64 {
+	if name == v1.ResourceCPU {
+		return quantity.MilliValue()
+	}
+	return quantity.Value()
+}
+
+// newQuantity returns the quantity of the given value, in millicores for cpu.
+func newQuantity(name v1.ResourceName, value int64, format resource.Format) resource.Quantity {
+	if name == v1.ResourceCPU {
+		return *resource.NewMilliQuantity(value, format)
+	}
+	return *resource.NewQuantity(value, format)
+}
+
+// getParentKey returns the key of the parent of the given ElasticQuota, if any.
+func getParentKey(eq *schedv1alpha1.ElasticQuota) *types.NamespacedName {
+	if eq.Spec.Parent == nil {
+		return nil
+	}
+	key := types.NamespacedName{Namespace: eq.Spec.Parent.Namespace, Name: eq.Spec.Parent.Name}
+	if key.Namespace == "" {
+		key.Namespace = eq.Namespace
+	}
+	return &key
+}
+
+// enqueueElasticQuotasOfPod maps a pod to the ElasticQuotas that apply to its namespace.
+func (r *ElasticQuotaReconciler) enqueueElasticQuotasOfPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	ns := &v1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: obj.GetNamespace()}, ns); err != nil {
+		ns.Name = obj.GetNamespace()
+	}
+	eqList := &schedv1alpha1.ElasticQuotaList{}
+	if err := r.List(ctx, eqList); err != nil {
+		log.FromContext(ctx).Error(err, "Unable to list elasticquotas")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range eqList.Items {
+		eq := &eqList.Items[i]
+		if appliesTo(eq, ns) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(eq)})
+		}
+	}
+	return requests
+}
+
+// enqueueElasticQuotasWithSelector maps a namespace to the ElasticQuotas with a namespace
+// selector, so that their usage is updated whenever the labels of a namespace change.
+func (r *ElasticQuotaReconciler) enqueueElasticQuotasWithSelector(ctx context.Context, _ client.Object) []reconcile.Request {
+	eqList := &schedv1alpha1.ElasticQuotaList{}
+	if err := r.List(ctx, eqList); err != nil {
+		log.FromContext(ctx).Error(err, "Unable to list elasticquotas")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range eqList.Items {
+		if eqList.Items[i].Spec.NamespaceSelector != nil {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&eqList.Items[i])})
+		}
+	}
+	return requests
+}
+
+// appliesTo checks whether the given ElasticQuota applies to the given namespace.
+func appliesTo(eq *schedv1alpha1.ElasticQuota, ns *v1.Namespace) bool {
+	if eq.Spec.NamespaceSelector == nil {
+		return eq.Namespace == ns.Name
+	}
+	selector, err := metav1.LabelSelectorAsSelector(eq.Spec.NamespaceSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(ns.Labels))
+}
+
+// enqueueParentAndSiblings maps an ElasticQuota to its parent, so that the usage of the parent is
+// updated whenever the usage of one of its children changes, and to its siblings, so that what they
+// lend is updated whenever what it borrows changes.
+func (r *ElasticQuotaReconciler) enqueueParentAndSiblings(ctx context.Context, obj client.Object) []reconcile.Request {
+	eq, ok := obj.(*schedv1alpha1.ElasticQuota)
+	if !ok {
+		return nil
+	}
+	var requests []reconcile.Request
+	if parent := getParentKey(eq); parent != nil {
+		requests = append(requests, reconcile.Request{NamespacedName: *parent})
+	}
+	eqList := &schedv1alpha1.ElasticQuotaList{}
+	if err := r.List(ctx, eqList); err != nil {
+		log.FromContext(ctx).Error(err, "Unable to list elasticquotas")
+		return requests
+	}
+	for _, sibling := range getSiblings(eq, eqList.Items) {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(sibling)})
+	}
+	return requests
 }
 
 // newZeroUsed will return the zero value of the union of min and max
@@ -171,8 +437,10 @@ func newZeroUsed(eq *schedv1alpha1.ElasticQuota) v1.ResourceList {
 func (r *ElasticQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.recorder = mgr.GetEventRecorderFor("ElasticQuotaController")
 	return ctrl.NewControllerManagedBy(mgr).
-		Watches(&v1.Pod{}, &handler.EnqueueRequestForObject{}).
+		Watches(&v1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.enqueueElasticQuotasOfPod)).
+		Watches(&v1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.enqueueElasticQuotasWithSelector)).
 		For(&schedv1alpha1.ElasticQuota{}).
+		Watches(&schedv1alpha1.ElasticQuota{}, handler.EnqueueRequestsFromMapFunc(r.enqueueParentAndSiblings)).
 		WithOptions(controller.Options{MaxConcurrentReconciles: r.Workers}).
 		Complete(r)
 }
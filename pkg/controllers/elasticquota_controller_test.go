@@ -20,14 +20,17 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	quota "k8s.io/apiserver/pkg/quota/v1"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -57,7 +60,7 @@ func TestElasticQuotaController_Run(t *testing.T) {
 					Max(testutil.MakeResourceList().CPU(5).Mem(15).GPU(1).Obj()).Obj(),
 			},
 			pods: []*v1.Pod{
-				testutil.MakePod("t1-ns1", "pod1").Phase(v1.PodRunning).Container(
+				testutil.MakePod("t1-ns1", "pod1").Phase(v1.PodRunning).Node("node-a").Container(
 					testutil.MakeResourceList().CPU(1).Mem(2).GPU(1).Obj()).Obj(),
 				testutil.MakePod("t1-ns1", "pod2").Phase(v1.PodPending).Container(
 					testutil.MakeResourceList().CPU(1).Mem(2).GPU(0).Obj()).Obj(),
@@ -77,13 +80,13 @@ func TestElasticQuotaController_Run(t *testing.T) {
 
 			pods: []*v1.Pod{
 				// CPU: 2, Mem: 4
-				testutil.MakePod("t2-ns1", "pod1").Phase(v1.PodRunning).
+				testutil.MakePod("t2-ns1", "pod1").Phase(v1.PodRunning).Node("node-a").
 					Container(
 						testutil.MakeResourceList().CPU(1).Mem(2).Obj()).
 					Container(
 						testutil.MakeResourceList().CPU(1).Mem(2).Obj()).Obj(),
 				// CPU: 3, Mem: 3
-				testutil.MakePod("t2-ns1", "pod2").Phase(v1.PodRunning).
+				testutil.MakePod("t2-ns1", "pod2").Phase(v1.PodRunning).Node("node-a").
 					InitContainerRequest(
 						testutil.MakeResourceList().CPU(2).Mem(1).Obj()).
 					InitContainerRequest(
@@ -110,17 +113,17 @@ func TestElasticQuotaController_Run(t *testing.T) {
 			},
 			pods: []*v1.Pod{
 				// CPU: 2, Mem: 4
-				testutil.MakePod("t3-ns1", "pod1").Phase(v1.PodRunning).
+				testutil.MakePod("t3-ns1", "pod1").Phase(v1.PodRunning).Node("node-a").
 					Container(testutil.MakeResourceList().CPU(1).Mem(2).GPU(1).Obj()).
 					Container(testutil.MakeResourceList().CPU(1).Mem(2).Obj()).Obj(),
 				// CPU: 3, Mem: 3
-				testutil.MakePod("t3-ns1", "pod1").Phase(v1.PodPending).
+				testutil.MakePod("t3-ns1", "pod1").Phase(v1.PodPending).Node("node-a").
 					InitContainerRequest(testutil.MakeResourceList().CPU(2).Mem(1).Obj()).
 					InitContainerRequest(testutil.MakeResourceList().CPU(2).Mem(3).Obj()).
 					Container(testutil.MakeResourceList().CPU(2).Mem(1).Obj()).
 					Container(testutil.MakeResourceList().CPU(1).Mem(1).Obj()).Obj(),
 				// CPU: 4, Mem: 3
-				testutil.MakePod("t3-ns2", "pod2").Phase(v1.PodRunning).
+				testutil.MakePod("t3-ns2", "pod2").Phase(v1.PodRunning).Node("node-a").
 					InitContainerRequest(testutil.MakeResourceList().CPU(2).Mem(1).Obj()).
 					InitContainerRequest(testutil.MakeResourceList().CPU(2).Mem(3).Obj()).
 					Container(testutil.MakeResourceList().CPU(3).Mem(1).Obj()).
@@ -170,7 +173,7 @@ func TestElasticQuotaController_Run(t *testing.T) {
 					Max(testutil.MakeResourceList().CPU(50).Mem(15).Obj()).Obj(),
 			},
 			pods: []*v1.Pod{
-				testutil.MakePod("t6-ns3", "pod1").Phase(v1.PodRunning).
+				testutil.MakePod("t6-ns3", "pod1").Phase(v1.PodRunning).Node("node-a").
 					Container(testutil.MakeResourceList().CPU(1).Mem(2).GPU(1).Obj()).
 					Container(testutil.MakeResourceList().CPU(1).Mem(2).Obj()).Obj(),
 			},
@@ -181,6 +184,36 @@ func TestElasticQuotaController_Run(t *testing.T) {
 					Used(testutil.MakeResourceList().CPU(0).Mem(0).GPU(0).Obj()).Obj(),
 			},
 		},
+		{
+			name: "usage of children rolls up to the parent",
+			elasticQuotas: []*v1alpha1.ElasticQuota{
+				testutil.MakeEQ("t7-ns2", "t7-eq2").Parent("t7-ns1", "t7-eq1").
+					Min(testutil.MakeResourceList().CPU(3).Mem(5).Obj()).
+					Max(testutil.MakeResourceList().CPU(5).Mem(15).Obj()).Obj(),
+				testutil.MakeEQ("t7-ns3", "t7-eq3").Parent("t7-ns1", "t7-eq1").
+					Min(testutil.MakeResourceList().CPU(3).Mem(5).Obj()).
+					Max(testutil.MakeResourceList().CPU(5).Mem(15).Obj()).Obj(),
+				testutil.MakeEQ("t7-ns1", "t7-eq1").
+					Min(testutil.MakeResourceList().CPU(6).Mem(10).Obj()).
+					Max(testutil.MakeResourceList().CPU(10).Mem(30).Obj()).Obj(),
+			},
+			pods: []*v1.Pod{
+				testutil.MakePod("t7-ns2", "pod1").Phase(v1.PodRunning).Node("node-a").
+					Container(testutil.MakeResourceList().CPU(1).Mem(2).Obj()).Obj(),
+				testutil.MakePod("t7-ns3", "pod2").Phase(v1.PodRunning).Node("node-a").
+					Container(testutil.MakeResourceList().CPU(2).Mem(1).Obj()).Obj(),
+				testutil.MakePod("t7-ns1", "pod3").Phase(v1.PodRunning).Node("node-a").
+					Container(testutil.MakeResourceList().CPU(1).Mem(1).Obj()).Obj(),
+			},
+			want: []*v1alpha1.ElasticQuota{
+				testutil.MakeEQ("t7-ns2", "t7-eq2").
+					Used(testutil.MakeResourceList().CPU(1).Mem(2).Obj()).Obj(),
+				testutil.MakeEQ("t7-ns3", "t7-eq3").
+					Used(testutil.MakeResourceList().CPU(2).Mem(1).Obj()).Obj(),
+				testutil.MakeEQ("t7-ns1", "t7-eq1").
+					Used(testutil.MakeResourceList().CPU(4).Mem(4).Obj()).Obj(),
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -229,6 +262,281 @@ func TestElasticQuotaController_Run(t *testing.T) {
 	}
 }
 
+func TestElasticQuotaControllerNamespaceSelector(t *testing.T) {
+	ctx := context.TODO()
+	older := metav1.NewTime(time.Now().Add(-time.Hour))
+	teamEQ := testutil.MakeEQ("team1", "team1-eq").NamespaceSelector(map[string]string{"team": "team1"}).
+		Min(testutil.MakeResourceList().CPU(3).Mem(5).Obj()).
+		Max(testutil.MakeResourceList().CPU(5).Mem(15).Obj()).Obj()
+	teamEQ.CreationTimestamp = older
+	overlappingEQ := testutil.MakeEQ("ns2", "ns2-eq").
+		Min(testutil.MakeResourceList().CPU(3).Mem(5).Obj()).
+		Max(testutil.MakeResourceList().CPU(5).Mem(15).Obj()).Obj()
+	overlappingEQ.CreationTimestamp = metav1.Now()
+	pods := []*v1.Pod{
+		testutil.MakePod("ns1", "pod1").Phase(v1.PodRunning).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(1).Mem(2).Obj()).Obj(),
+		testutil.MakePod("ns2", "pod2").Phase(v1.PodRunning).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(2).Mem(1).Obj()).Obj(),
+		testutil.MakePod("ns3", "pod3").Phase(v1.PodRunning).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(4).Mem(4).Obj()).Obj(),
+	}
+	controller, kClient := setUpEQ(ctx, t, []*v1alpha1.ElasticQuota{teamEQ, overlappingEQ}, pods)
+	for _, ns := range []*v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "ns1", Labels: map[string]string{"team": "team1"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "ns2", Labels: map[string]string{"team": "team1"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "ns3", Labels: map[string]string{"team": "team2"}}},
+	} {
+		if err := kClient.Create(ctx, ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := controller.enqueueElasticQuotasOfPod(ctx, pods[1]); len(got) != 2 {
+		t.Errorf("expected pod2 to map to both elasticquotas, got %v", got)
+	}
+	for _, eq := range []*v1alpha1.ElasticQuota{teamEQ, overlappingEQ} {
+		if _, err := controller.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(eq)}); err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+	}
+
+	eq := &v1alpha1.ElasticQuota{}
+	if err := kClient.Get(ctx, client.ObjectKeyFromObject(teamEQ), eq); err != nil {
+		t.Fatal(err)
+	}
+	if want := testutil.MakeResourceList().CPU(3).Mem(3).Obj(); !quota.Equals(eq.Status.Used, want) {
+		t.Errorf("want %v, got %v", want, eq.Status.Used)
+	}
+	if err := kClient.Get(ctx, client.ObjectKeyFromObject(overlappingEQ), eq); err != nil {
+		t.Fatal(err)
+	}
+	if eq.Status.Used != nil {
+		t.Errorf("want the overlapping elasticquota to be rejected, got %v", eq.Status.Used)
+	}
+}
+
+func TestElasticQuotaControllerPodAccounting(t *testing.T) {
+	ctx := context.TODO()
+	eq := testutil.MakeEQ("ns1", "eq1").
+		Min(testutil.MakeResourceList().CPU(3).Mem(5).Obj()).
+		Max(testutil.MakeResourceList().CPU(5).Mem(15).Obj()).Obj()
+	terminating := testutil.MakePod("ns1", "terminating").Phase(v1.PodRunning).Node("node-a").
+		Container(testutil.MakeResourceList().CPU(8).Mem(8).Obj()).Obj()
+	terminating.Finalizers = []string{"test/finalizer"}
+	pods := []*v1.Pod{
+		testutil.MakePod("ns1", "running").Phase(v1.PodRunning).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(1).Mem(2).Obj()).Obj(),
+		// Bound but not running yet, e.g. pulling its images.
+		testutil.MakePod("ns1", "pending").Phase(v1.PodPending).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(2).Mem(1).Obj()).Obj(),
+		testutil.MakePod("ns1", "unbound").Phase(v1.PodPending).
+			Container(testutil.MakeResourceList().CPU(8).Mem(8).Obj()).Obj(),
+		testutil.MakePod("ns1", "succeeded").Phase(v1.PodSucceeded).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(8).Mem(8).Obj()).Obj(),
+		terminating,
+	}
+	controller, kClient := setUpEQ(ctx, t, []*v1alpha1.ElasticQuota{eq}, pods)
+	// The finalizer keeps the pod around with a deletion timestamp.
+	if err := kClient.Delete(ctx, terminating); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := controller.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(eq)}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	got := &v1alpha1.ElasticQuota{}
+	if err := kClient.Get(ctx, client.ObjectKeyFromObject(eq), got); err != nil {
+		t.Fatal(err)
+	}
+	if want := testutil.MakeResourceList().CPU(3).Mem(3).Obj(); !quota.Equals(got.Status.Used, want) {
+		t.Errorf("want %v, got %v", want, got.Status.Used)
+	}
+	if got.Status.Pods != 2 {
+		t.Errorf("want 2 pods, got %v", got.Status.Pods)
+	}
+}
+
+func TestElasticQuotaControllerStatusBreakdown(t *testing.T) {
+	ctx := context.TODO()
+	eqs := []*v1alpha1.ElasticQuota{
+		testutil.MakeEQ("ns1", "eq1").
+			Min(testutil.MakeResourceList().CPU(4).Obj()).
+			Max(testutil.MakeResourceList().CPU(10).Obj()).Obj(),
+		testutil.MakeEQ("ns2", "eq2").
+			Min(testutil.MakeResourceList().CPU(4).Obj()).
+			Max(testutil.MakeResourceList().CPU(10).Obj()).Obj(),
+		testutil.MakeEQ("ns3", "eq3").
+			Min(testutil.MakeResourceList().CPU(4).Obj()).
+			Max(testutil.MakeResourceList().CPU(10).Obj()).Obj(),
+	}
+	preempted := testutil.MakePod("ns1", "preempted").Phase(v1.PodRunning).Node("node-a").
+		Container(testutil.MakeResourceList().CPU(1).Obj()).Obj()
+	preempted.Status.Conditions = []v1.PodCondition{{
+		Type:   v1.DisruptionTarget,
+		Status: v1.ConditionTrue,
+		Reason: v1.PodReasonPreemptionByScheduler,
+	}}
+	unschedulable := testutil.MakePod("ns2", "unschedulable").Phase(v1.PodPending).
+		Container(testutil.MakeResourceList().CPU(5).Obj()).Obj()
+	unschedulable.Status.Conditions = []v1.PodCondition{{
+		Type:   v1.PodScheduled,
+		Status: v1.ConditionFalse,
+		Reason: v1.PodReasonUnschedulable,
+	}}
+	pods := []*v1.Pod{
+		testutil.MakePod("ns1", "pod1").Phase(v1.PodRunning).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(5).Obj()).Obj(),
+		preempted,
+		testutil.MakePod("ns2", "pod2").Phase(v1.PodRunning).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(1).Obj()).Obj(),
+		unschedulable,
+		testutil.MakePod("ns3", "pod3").Phase(v1.PodRunning).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(2).Obj()).Obj(),
+	}
+	controller, kClient := setUpEQ(ctx, t, eqs, pods)
+	controller.recorder = record.NewFakeRecorder(10)
+	// The borrower is reconciled first, so that the lenders see what it borrows, and twice, so
+	// that the preempted pod is counted once. eq2 is reconciled again once eq3 has its usage,
+	// as the watch on its siblings would.
+	for _, eq := range []*v1alpha1.ElasticQuota{eqs[0], eqs[0], eqs[1], eqs[2], eqs[1]} {
+		if _, err := controller.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(eq)}); err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+	}
+
+	for _, want := range []struct {
+		name      string
+		borrowed  v1.ResourceList
+		lent      v1.ResourceList
+		pending   v1.ResourceList
+		preempted int32
+	}{
+		// eq1 borrows 2 cpus, lent by eq2 and eq3 in proportion to their unused min of 3 and 2 cpus.
+		{name: "ns1", borrowed: testutil.MakeResourceList().CPU(2).Obj(), preempted: 1},
+		{name: "ns2", lent: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1200m")}, pending: testutil.MakeResourceList().CPU(5).Obj()},
+		{name: "ns3", lent: v1.ResourceList{v1.ResourceCPU: resource.MustParse("800m")}},
+	} {
+		eq := &v1alpha1.ElasticQuota{}
+		if err := kClient.Get(ctx, types.NamespacedName{Namespace: want.name, Name: "eq" + want.name[2:]}, eq); err != nil {
+			t.Fatal(err)
+		}
+		if !quota.Equals(eq.Status.Borrowed, want.borrowed) {
+			t.Errorf("%v: want borrowed %v, got %v", want.name, want.borrowed, eq.Status.Borrowed)
+		}
+		if !quota.Equals(eq.Status.Lent, want.lent) {
+			t.Errorf("%v: want lent %v, got %v", want.name, want.lent, eq.Status.Lent)
+		}
+		if !quota.Equals(eq.Status.Pending, want.pending) {
+			t.Errorf("%v: want pending %v, got %v", want.name, want.pending, eq.Status.Pending)
+		}
+		if eq.Status.Preempted != want.preempted {
+			t.Errorf("%v: want %v preempted pods, got %v", want.name, want.preempted, eq.Status.Preempted)
+		}
+	}
+}
+
+func TestElasticQuotaControllerPreemptedAcrossRestarts(t *testing.T) {
+	ctx := context.TODO()
+	eq := testutil.MakeEQ("ns1", "eq1").
+		Min(testutil.MakeResourceList().CPU(4).Obj()).
+		Max(testutil.MakeResourceList().CPU(10).Obj()).Obj()
+	makePreempted := func(name string) *v1.Pod {
+		pod := testutil.MakePod("ns1", name).Phase(v1.PodRunning).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(1).Obj()).Obj()
+		pod.UID = types.UID(name)
+		pod.Status.Conditions = []v1.PodCondition{{
+			Type:   v1.DisruptionTarget,
+			Status: v1.ConditionTrue,
+			Reason: v1.PodReasonPreemptionByScheduler,
+		}}
+		return pod
+	}
+	controller, kClient := setUpEQ(ctx, t, []*v1alpha1.ElasticQuota{eq}, []*v1.Pod{makePreempted("p1")})
+	// Each reconciliation is done by a new reconciler, as if the controller restarted in between.
+	reconcile := func(wantPreempted int32, wantPods ...types.UID) {
+		t.Helper()
+		restarted := &ElasticQuotaReconciler{Client: kClient, Scheme: controller.Scheme, recorder: record.NewFakeRecorder(3)}
+		if _, err := restarted.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(eq)}); err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+		got := &v1alpha1.ElasticQuota{}
+		if err := kClient.Get(ctx, client.ObjectKeyFromObject(eq), got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Status.Preempted != wantPreempted {
+			t.Errorf("want %v preempted pods, got %v", wantPreempted, got.Status.Preempted)
+		}
+		if !sets.New(got.Status.PreemptedPods...).Equal(sets.New(wantPods...)) {
+			t.Errorf("want preempted pods %v, got %v", wantPods, got.Status.PreemptedPods)
+		}
+	}
+
+	reconcile(1, "p1")
+	reconcile(1, "p1")
+	// The preempted pods that are gone are forgotten but stay counted.
+	if err := kClient.Delete(ctx, makePreempted("p1")); err != nil {
+		t.Fatal(err)
+	}
+	reconcile(1)
+	if err := kClient.Create(ctx, makePreempted("p2")); err != nil {
+		t.Fatal(err)
+	}
+	reconcile(2, "p2")
+	reconcile(2, "p2")
+}
+
+func TestElasticQuotaControllerParentCycle(t *testing.T) {
+	ctx := context.TODO()
+	eqs := []*v1alpha1.ElasticQuota{
+		testutil.MakeEQ("ns1", "eq1").Parent("ns2", "eq2").
+			Min(testutil.MakeResourceList().CPU(4).Obj()).
+			Max(testutil.MakeResourceList().CPU(10).Obj()).Obj(),
+		testutil.MakeEQ("ns2", "eq2").Parent("ns1", "eq1").
+			Min(testutil.MakeResourceList().CPU(4).Obj()).
+			Max(testutil.MakeResourceList().CPU(10).Obj()).Obj(),
+	}
+	pods := []*v1.Pod{
+		testutil.MakePod("ns1", "pod1").Phase(v1.PodRunning).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(1).Obj()).Obj(),
+		testutil.MakePod("ns2", "pod2").Phase(v1.PodRunning).Node("node-a").
+			Container(testutil.MakeResourceList().CPU(2).Obj()).Obj(),
+	}
+	controller, kClient := setUpEQ(ctx, t, eqs, pods)
+	recorder := record.NewFakeRecorder(20)
+	controller.recorder = recorder
+	// Each reconcile would re-queue the other ElasticQuota through the watch on the children.
+	for i := 0; i < 3; i++ {
+		for _, eq := range eqs {
+			if _, err := controller.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(eq)}); err != nil {
+				t.Fatalf("reconcile: (%v)", err)
+			}
+		}
+	}
+
+	for i, want := range []v1.ResourceList{
+		testutil.MakeResourceList().CPU(1).Obj(),
+		testutil.MakeResourceList().CPU(2).Obj(),
+	} {
+		eq := &v1alpha1.ElasticQuota{}
+		if err := kClient.Get(ctx, client.ObjectKeyFromObject(eqs[i]), eq); err != nil {
+			t.Fatal(err)
+		}
+		if !quota.Equals(eq.Status.Used, want) {
+			t.Errorf("%v: want used %v, got %v", eq.Name, want, eq.Status.Used)
+		}
+	}
+	close(recorder.Events)
+	var events []string
+	for event := range recorder.Events {
+		if strings.Contains(event, "ParentCycle") {
+			return
+		}
+		events = append(events, event)
+	}
+	t.Errorf("want a ParentCycle event, got %v", events)
+}
+
 func setUpEQ(ctx context.Context,
 	t *testing.T,
 	eqs []*v1alpha1.ElasticQuota,
@@ -19,13 +19,17 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -101,34 +105,40 @@ func (r *PodGroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	pods := podList.Items
 
 	pgCopy := pg.DeepCopy()
+	setQuorumReachedCondition(pgCopy, pods)
 	switch pgCopy.Status.Phase {
 	case "":
 		pgCopy.Status.Phase = schedv1alpha1.PodGroupPending
 	case schedv1alpha1.PodGroupPending:
-		if len(pods) >= int(pg.Spec.MinMember) {
+		if len(pods) >= int(pg.Spec.MinMember) && rolesSatisfied(pg, pods) {
 			pgCopy.Status.Phase = schedv1alpha1.PodGroupScheduling
 			fillOccupiedObj(pgCopy, &pods[0])
 		}
 	default:
 		pgCopy.Status.Running, pgCopy.Status.Succeeded, pgCopy.Status.Failed = getCurrentPodStats(pods)
-		if len(pods) < int(pg.Spec.MinMember) {
+		if len(pods) < int(pg.Spec.MinMember) || !rolesSatisfied(pg, pods) {
 			pgCopy.Status.Phase = schedv1alpha1.PodGroupPending
 			break
 		}
 
-		if pgCopy.Status.Succeeded+pgCopy.Status.Running < pg.Spec.MinMember {
+		running := rolesSatisfied(pg, pods, v1.PodRunning, v1.PodSucceeded)
+		if pgCopy.Status.Succeeded+pgCopy.Status.Running < pg.Spec.MinMember || !running {
 			pgCopy.Status.Phase = schedv1alpha1.PodGroupScheduling
 		}
 
-		if pgCopy.Status.Succeeded+pgCopy.Status.Running >= pg.Spec.MinMember {
+		if pgCopy.Status.Succeeded+pgCopy.Status.Running >= pg.Spec.MinMember && running {
 			pgCopy.Status.Phase = schedv1alpha1.PodGroupRunning
 		}
 		// Final state of pod group
+		if util.GetMaxMember(pg) != 0 {
+			setElasticFinalPhase(pgCopy, pods)
+			break
+		}
 		if pgCopy.Status.Failed != 0 &&
 			pgCopy.Status.Failed+pgCopy.Status.Running+pgCopy.Status.Succeeded >= pg.Spec.MinMember {
 			pgCopy.Status.Phase = schedv1alpha1.PodGroupFailed
 		}
-		if pgCopy.Status.Succeeded >= pg.Spec.MinMember {
+		if pgCopy.Status.Succeeded >= pg.Spec.MinMember && rolesSatisfied(pg, pods, v1.PodSucceeded) {
 			pgCopy.Status.Phase = schedv1alpha1.PodGroupFinished
 		}
 	}
@@ -136,13 +146,23 @@ func (r *PodGroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	return r.patchPodGroup(ctx, pg, pgCopy)
 }
 
+// patchPodGroup patches the status of the pod group, the only part of it the reconciler changes.
+// The status is shared with the scheduler, which sets the conditions of the pod group: the patch
+// is guarded by the resourceVersion of the old pod group, so that a concurrent update is not
+// overwritten, and the pod group is reconciled again on conflict.
 func (r *PodGroupReconciler) patchPodGroup(ctx context.Context, old, new *schedv1alpha1.PodGroup) (ctrl.Result, error) {
-	patch := client.MergeFrom(old)
+	if equality.Semantic.DeepEqual(old.Status, new.Status) {
+		return ctrl.Result{}, nil
+	}
+	patch := client.MergeFromWithOptions(old, client.MergeFromWithOptimisticLock{})
 	if err := r.Status().Patch(ctx, new, patch); err != nil {
+		if apierrs.IsConflict(err) {
+			log.FromContext(ctx).V(4).Info("Pod group has been updated concurrently, requeuing")
+			return ctrl.Result{Requeue: true}, nil
+		}
 		return ctrl.Result{}, err
 	}
-	err := r.Patch(ctx, new, patch)
-	return ctrl.Result{}, err
+	return ctrl.Result{}, nil
 }
 
 func getCurrentPodStats(pods []v1.Pod) (int32, int32, int32) {
@@ -168,6 +188,57 @@ func getCurrentPodStats(pods []v1.Pod) (int32, int32, int32) {
 	return running, succeeded, failed
 }
 
+// rolesSatisfied returns true if the minMember of every role of the pod group is satisfied
+// by the given pods in one of the given phases, or by all the given pods if no phase is given.
+func rolesSatisfied(pg *schedv1alpha1.PodGroup, pods []v1.Pod, phases ...v1.PodPhase) bool {
+	if len(pg.Spec.Roles) == 0 {
+		return true
+	}
+	var selected []*v1.Pod
+	for i := range pods {
+		if len(phases) == 0 || slices.Contains(phases, pods[i].Status.Phase) {
+			selected = append(selected, &pods[i])
+		}
+	}
+	return len(util.GetUnsatisfiedRoles(pg, selected)) == 0
+}
+
+// setElasticFinalPhase sets the final phase of an elastic pod group, whose members beyond
+// minMember may come and go: the pod group only fails once its failed pods keep the others
+// from reaching minMember, and only finishes once none of its pods is running anymore.
+func setElasticFinalPhase(pg *schedv1alpha1.PodGroup, pods []v1.Pod) {
+	if pg.Status.Failed != 0 && pg.Status.Running+pg.Status.Succeeded < pg.Spec.MinMember &&
+		pg.Status.Failed+pg.Status.Running+pg.Status.Succeeded >= pg.Spec.MinMember {
+		pg.Status.Phase = schedv1alpha1.PodGroupFailed
+	}
+	if pg.Status.Succeeded >= pg.Spec.MinMember && pg.Status.Running == 0 && rolesSatisfied(pg, pods, v1.PodSucceeded) {
+		pg.Status.Phase = schedv1alpha1.PodGroupFinished
+	}
+}
+
+// setQuorumReachedCondition sets the QuorumReached condition of the pod group
+// according to whether the given pods satisfy its minMember and roles. The message does not
+// depend on the number of pods, so that the condition only changes with its status: the pod
+// counts are reported by the status counters.
+func setQuorumReachedCondition(pg *schedv1alpha1.PodGroup, pods []v1.Pod) {
+	condition := metav1.Condition{
+		Type:               schedv1alpha1.PodGroupConditionQuorumReached,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: pg.Generation,
+		Reason:             "EnoughPods",
+		Message:            "the minMember pods of the pod group have been created",
+	}
+	if len(pods) < int(pg.Spec.MinMember) || !rolesSatisfied(pg, pods) {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "NotEnoughPods"
+		condition.Message = "less pods than the minMember of the pod group have been created"
+		if len(pods) >= int(pg.Spec.MinMember) {
+			condition.Message = "the minMember of some roles of the pod group is not satisfied"
+		}
+	}
+	meta.SetStatusCondition(&pg.Status.Conditions, condition)
+}
+
 func fillOccupiedObj(pg *schedv1alpha1.PodGroup, pod *v1.Pod) {
 	if len(pod.OwnerReferences) == 0 {
 		return
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -195,6 +196,274 @@ func Test_Run(t *testing.T) {
 	}
 }
 
+func TestReconcileWithRoles(t *testing.T) {
+	ctx := context.TODO()
+	makeRolePod := func(name, role string, phase v1.PodPhase) *v1.Pod {
+		pod := st.MakePod().Namespace("default").Name(name).Label(v1alpha1.PodGroupLabel, "pg").Label("role", role).Obj()
+		pod.Status.Phase = phase
+		return pod
+	}
+	cases := []struct {
+		name              string
+		pods              []*v1.Pod
+		previousPhase     v1alpha1.PodGroupPhase
+		desiredGroupPhase v1alpha1.PodGroupPhase
+		quorumReached     metav1.ConditionStatus
+	}{
+		{
+			name: "Group status keeps pending if a role is missing",
+			pods: []*v1.Pod{
+				makeRolePod("worker-0", "worker", v1.PodPending),
+				makeRolePod("worker-1", "worker", v1.PodPending),
+				makeRolePod("worker-2", "worker", v1.PodPending),
+			},
+			previousPhase:     v1alpha1.PodGroupPending,
+			desiredGroupPhase: v1alpha1.PodGroupPending,
+			quorumReached:     metav1.ConditionFalse,
+		},
+		{
+			name: "Group status convert from pending to scheduling if all roles are satisfied",
+			pods: []*v1.Pod{
+				makeRolePod("ps-0", "ps", v1.PodPending),
+				makeRolePod("worker-0", "worker", v1.PodPending),
+				makeRolePod("worker-1", "worker", v1.PodPending),
+			},
+			previousPhase:     v1alpha1.PodGroupPending,
+			desiredGroupPhase: v1alpha1.PodGroupScheduling,
+			quorumReached:     metav1.ConditionTrue,
+		},
+		{
+			name: "Group status keeps scheduling if a role is not running",
+			pods: []*v1.Pod{
+				makeRolePod("ps-0", "ps", v1.PodPending),
+				makeRolePod("worker-0", "worker", v1.PodRunning),
+				makeRolePod("worker-1", "worker", v1.PodRunning),
+				makeRolePod("worker-2", "worker", v1.PodRunning),
+			},
+			previousPhase:     v1alpha1.PodGroupScheduling,
+			desiredGroupPhase: v1alpha1.PodGroupScheduling,
+			quorumReached:     metav1.ConditionTrue,
+		},
+		{
+			name: "Group running if all roles are running",
+			pods: []*v1.Pod{
+				makeRolePod("ps-0", "ps", v1.PodRunning),
+				makeRolePod("worker-0", "worker", v1.PodRunning),
+				makeRolePod("worker-1", "worker", v1.PodRunning),
+			},
+			previousPhase:     v1alpha1.PodGroupScheduling,
+			desiredGroupPhase: v1alpha1.PodGroupRunning,
+			quorumReached:     metav1.ConditionTrue,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := scheme.Scheme
+			pg := makePG("pg", 3, c.previousPhase, nil)
+			pg.Spec.Roles = []v1alpha1.PodGroupRole{
+				{Name: "ps", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "ps"}}, MinMember: 1},
+				{Name: "worker", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}}, MinMember: 2},
+			}
+			s.AddKnownTypes(v1alpha1.SchemeGroupVersion, pg)
+			objs := []runtime.Object{pg}
+			for _, p := range c.pods {
+				objs = append(objs, p)
+			}
+			kClient := fake.NewClientBuilder().
+				WithScheme(s).
+				WithStatusSubresource(&v1alpha1.PodGroup{}).
+				WithRuntimeObjects(objs...).
+				Build()
+			controller := &PodGroupReconciler{
+				Client:   kClient,
+				Scheme:   s,
+				recorder: record.NewFakeRecorder(3),
+
+				log: klogr.New().WithName("podGroupTest"),
+			}
+
+			if _, err := controller.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pg)}); err != nil {
+				t.Fatalf("reconcile: (%v)", err)
+			}
+			if err := kClient.Get(ctx, client.ObjectKeyFromObject(pg), pg); err != nil {
+				t.Fatal(err)
+			}
+			if pg.Status.Phase != c.desiredGroupPhase {
+				t.Fatalf("want %v, got %v", c.desiredGroupPhase, pg.Status.Phase)
+			}
+			if !meta.IsStatusConditionPresentAndEqual(pg.Status.Conditions, v1alpha1.PodGroupConditionQuorumReached, c.quorumReached) {
+				t.Fatalf("want condition %v to be %v, got %v", v1alpha1.PodGroupConditionQuorumReached, c.quorumReached, pg.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestPatchPodGroupConflict(t *testing.T) {
+	ctx := context.TODO()
+	s := scheme.Scheme
+	pg := makePG("pg", 1, v1alpha1.PodGroupPending, nil)
+	s.AddKnownTypes(v1alpha1.SchemeGroupVersion, pg)
+	kClient := fake.NewClientBuilder().
+		WithScheme(s).
+		WithStatusSubresource(&v1alpha1.PodGroup{}).
+		WithRuntimeObjects(pg).
+		Build()
+	controller := &PodGroupReconciler{
+		Client:   kClient,
+		Scheme:   s,
+		recorder: record.NewFakeRecorder(3),
+
+		log: klogr.New().WithName("podGroupTest"),
+	}
+
+	old := &v1alpha1.PodGroup{}
+	if err := kClient.Get(ctx, client.ObjectKeyFromObject(pg), old); err != nil {
+		t.Fatal(err)
+	}
+	// The scheduler sets a condition of the pod group while it is being reconciled.
+	scheduled := old.DeepCopy()
+	meta.SetStatusCondition(&scheduled.Status.Conditions, metav1.Condition{
+		Type:   v1alpha1.PodGroupConditionScheduled,
+		Status: metav1.ConditionFalse,
+		Reason: "Unschedulable",
+	})
+	if err := kClient.Status().Update(ctx, scheduled); err != nil {
+		t.Fatal(err)
+	}
+
+	pgCopy := old.DeepCopy()
+	pgCopy.Status.Phase = v1alpha1.PodGroupScheduling
+	result, err := controller.patchPodGroup(ctx, old, pgCopy)
+	if err != nil {
+		t.Fatalf("patch: (%v)", err)
+	}
+	if !result.Requeue {
+		t.Errorf("want the pod group to be requeued on conflict, got %+v", result)
+	}
+
+	got := &v1alpha1.PodGroup{}
+	if err := kClient.Get(ctx, client.ObjectKeyFromObject(pg), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.Phase != v1alpha1.PodGroupPending {
+		t.Errorf("want the stale status not to be written, got phase %v", got.Status.Phase)
+	}
+	if !meta.IsStatusConditionFalse(got.Status.Conditions, v1alpha1.PodGroupConditionScheduled) {
+		t.Errorf("want condition %v of the scheduler to be kept, got %v", v1alpha1.PodGroupConditionScheduled, got.Status.Conditions)
+	}
+}
+
+func TestReconcileElastic(t *testing.T) {
+	ctx := context.TODO()
+	makeElasticPod := func(name string, phase v1.PodPhase) *v1.Pod {
+		pod := st.MakePod().Namespace("default").Name(name).Label(v1alpha1.PodGroupLabel, "pg").Obj()
+		pod.Status.Phase = phase
+		return pod
+	}
+	cases := []struct {
+		name              string
+		pods              []*v1.Pod
+		elastic           bool
+		desiredGroupPhase v1alpha1.PodGroupPhase
+	}{
+		{
+			name: "Group failed if a pod beyond min member failed",
+			pods: []*v1.Pod{
+				makeElasticPod("pod1", v1.PodRunning),
+				makeElasticPod("pod2", v1.PodRunning),
+				makeElasticPod("pod3", v1.PodFailed),
+			},
+			desiredGroupPhase: v1alpha1.PodGroupFailed,
+		},
+		{
+			name: "Elastic group keeps running if a pod beyond min member failed",
+			pods: []*v1.Pod{
+				makeElasticPod("pod1", v1.PodRunning),
+				makeElasticPod("pod2", v1.PodRunning),
+				makeElasticPod("pod3", v1.PodFailed),
+			},
+			elastic:           true,
+			desiredGroupPhase: v1alpha1.PodGroupRunning,
+		},
+		{
+			name: "Elastic group failed if failed pods keep it from min member",
+			pods: []*v1.Pod{
+				makeElasticPod("pod1", v1.PodRunning),
+				makeElasticPod("pod2", v1.PodFailed),
+				makeElasticPod("pod3", v1.PodFailed),
+			},
+			elastic:           true,
+			desiredGroupPhase: v1alpha1.PodGroupFailed,
+		},
+		{
+			name: "Group finished while pods beyond min member are running",
+			pods: []*v1.Pod{
+				makeElasticPod("pod1", v1.PodSucceeded),
+				makeElasticPod("pod2", v1.PodSucceeded),
+				makeElasticPod("pod3", v1.PodRunning),
+			},
+			desiredGroupPhase: v1alpha1.PodGroupFinished,
+		},
+		{
+			name: "Elastic group keeps running while pods beyond min member are running",
+			pods: []*v1.Pod{
+				makeElasticPod("pod1", v1.PodSucceeded),
+				makeElasticPod("pod2", v1.PodSucceeded),
+				makeElasticPod("pod3", v1.PodRunning),
+			},
+			elastic:           true,
+			desiredGroupPhase: v1alpha1.PodGroupRunning,
+		},
+		{
+			name: "Elastic group finished once all pods succeeded",
+			pods: []*v1.Pod{
+				makeElasticPod("pod1", v1.PodSucceeded),
+				makeElasticPod("pod2", v1.PodSucceeded),
+				makeElasticPod("pod3", v1.PodSucceeded),
+			},
+			elastic:           true,
+			desiredGroupPhase: v1alpha1.PodGroupFinished,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := scheme.Scheme
+			pg := makePG("pg", 2, v1alpha1.PodGroupRunning, nil)
+			if c.elastic {
+				maxMember := int32(4)
+				pg.Spec.MaxMember = &maxMember
+			}
+			s.AddKnownTypes(v1alpha1.SchemeGroupVersion, pg)
+			objs := []runtime.Object{pg}
+			for _, p := range c.pods {
+				objs = append(objs, p)
+			}
+			kClient := fake.NewClientBuilder().
+				WithScheme(s).
+				WithStatusSubresource(&v1alpha1.PodGroup{}).
+				WithRuntimeObjects(objs...).
+				Build()
+			controller := &PodGroupReconciler{
+				Client:   kClient,
+				Scheme:   s,
+				recorder: record.NewFakeRecorder(3),
+
+				log: klogr.New().WithName("podGroupTest"),
+			}
+
+			if _, err := controller.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pg)}); err != nil {
+				t.Fatalf("reconcile: (%v)", err)
+			}
+			if err := kClient.Get(ctx, client.ObjectKeyFromObject(pg), pg); err != nil {
+				t.Fatal(err)
+			}
+			if pg.Status.Phase != c.desiredGroupPhase {
+				t.Fatalf("want %v, got %v", c.desiredGroupPhase, pg.Status.Phase)
+			}
+		})
+	}
+}
+
 func TestFillGroupStatusOccupied(t *testing.T) {
 	ctx := context.TODO()
 	cases := []struct {
@@ -0,0 +1,283 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	resourcehelper "k8s.io/kubernetes/pkg/api/v1/resource"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	schedv1alpha1 "sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+)
+
+// JobReconciler creates the PodGroup of a Job whose pod template is labeled with
+// the pod group label, and keeps its minMember and minResources in sync with the Job.
+type JobReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	Workers int
+}
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+
+// Reconcile creates or updates the PodGroup of a Job. The PodGroup is owned by
+// the Job, so that it's garbage-collected with it.
+func (r *JobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, req.NamespacedName, job); err != nil {
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	minMember := getJobMinMember(job)
+	return reconcileWorkloadPodGroup(ctx, r.Client, r.Scheme, job, job.Spec.Template.Labels[schedv1alpha1.PodGroupLabel],
+		minMember, getMinResources(&job.Spec.Template, minMember))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *JobReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&batchv1.Job{}).
+		Owns(&schedv1alpha1.PodGroup{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.Workers}).
+		Complete(r)
+}
+
+// getJobMinMember returns the number of pods of a Job that run at the same time:
+// its parallelism, or its completions if lower.
+func getJobMinMember(job *batchv1.Job) int32 {
+	minMember := int32(1)
+	if job.Spec.Parallelism != nil {
+		minMember = *job.Spec.Parallelism
+	}
+	if job.Spec.Completions != nil && *job.Spec.Completions < minMember {
+		minMember = *job.Spec.Completions
+	}
+	return minMember
+}
+
+// StatefulSetReconciler creates the PodGroup of a StatefulSet whose pod template is labeled
+// with the pod group label, and keeps its minMember and minResources in sync with the StatefulSet.
+type StatefulSetReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	Workers int
+}
+
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
+
+// Reconcile creates or updates the PodGroup of a StatefulSet. The PodGroup is owned by
+// the StatefulSet, so that it's garbage-collected with it.
+func (r *StatefulSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	sts := &appsv1.StatefulSet{}
+	if err := r.Get(ctx, req.NamespacedName, sts); err != nil {
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	minMember := int32(1)
+	if sts.Spec.Replicas != nil {
+		minMember = *sts.Spec.Replicas
+	}
+	return reconcileWorkloadPodGroup(ctx, r.Client, r.Scheme, sts, sts.Spec.Template.Labels[schedv1alpha1.PodGroupLabel],
+		minMember, getMinResources(&sts.Spec.Template, minMember))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *StatefulSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&appsv1.StatefulSet{}).
+		Owns(&schedv1alpha1.PodGroup{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.Workers}).
+		Complete(r)
+}
+
+// reconcileWorkloadPodGroup creates the PodGroup named by the pod group label of the pod template
+// of a workload, with the given minMember and minResources, or updates it if the workload has changed,
+// including when it's scaled to zero. Workloads whose pod template is not labeled, and PodGroups
+// that are not controlled by the workload, e.g. created by hand, are left alone.
+func reconcileWorkloadPodGroup(ctx context.Context, c client.Client, scheme *runtime.Scheme,
+	owner client.Object, pgName string, minMember int32, minResources v1.ResourceList) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if pgName == "" {
+		return ctrl.Result{}, nil
+	}
+	spec := schedv1alpha1.PodGroupSpec{
+		MinMember:    minMember,
+		MinResources: minResources,
+	}
+
+	pg := &schedv1alpha1.PodGroup{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: owner.GetNamespace(), Name: pgName}, pg); err != nil {
+		if !apierrs.IsNotFound(err) || minMember == 0 {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		pg = &schedv1alpha1.PodGroup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: owner.GetNamespace(), Name: pgName},
+			Spec:       spec,
+		}
+		if err := controllerutil.SetControllerReference(owner, pg, scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+		log.V(3).Info("Creating pod group of workload", "podGroup", pgName, "minMember", minMember)
+		return ctrl.Result{}, c.Create(ctx, pg)
+	}
+
+	if !metav1.IsControlledBy(pg, owner) {
+		log.V(5).Info("Pod group is not controlled by the workload", "podGroup", pgName)
+		return ctrl.Result{}, nil
+	}
+	if pg.Spec.MinMember == spec.MinMember && apiequality.Semantic.DeepEqual(pg.Spec.MinResources, spec.MinResources) {
+		return ctrl.Result{}, nil
+	}
+	pgCopy := pg.DeepCopy()
+	pgCopy.Spec.MinMember = spec.MinMember
+	pgCopy.Spec.MinResources = spec.MinResources
+	log.V(3).Info("Updating pod group of workload", "podGroup", pgName, "minMember", minMember)
+	return ctrl.Result{}, c.Patch(ctx, pgCopy, client.MergeFrom(pg))
+}
+
+// JobSetReconciler creates the PodGroup of a JobSet whose pod templates are labeled with the pod group
+// label, and keeps its minMember and minResources in sync with the JobSet. JobSets are handled as
+// unstructured objects, so that the controller doesn't depend on the JobSet API, and the controller
+// is only set up if the JobSet API is served.
+type JobSetReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	Workers int
+}
+
+var jobSetGVK = schema.GroupVersionKind{Group: "jobset.x-k8s.io", Version: "v1alpha2", Kind: "JobSet"}
+
+// jobSetSpec is the part of the spec of a JobSet the controller needs.
+type jobSetSpec struct {
+	ReplicatedJobs []jobSetReplicatedJob `json:"replicatedJobs"`
+}
+
+type jobSetReplicatedJob struct {
+	Name     string                  `json:"name"`
+	Replicas *int32                  `json:"replicas,omitempty"`
+	Template batchv1.JobTemplateSpec `json:"template"`
+}
+
+// +kubebuilder:rbac:groups=jobset.x-k8s.io,resources=jobsets,verbs=get;list;watch
+
+// Reconcile creates or updates the PodGroup of a JobSet. The PodGroup is owned by
+// the JobSet, so that it's garbage-collected with it.
+func (r *JobSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	js := &unstructured.Unstructured{}
+	js.SetGroupVersionKind(jobSetGVK)
+	if err := r.Get(ctx, req.NamespacedName, js); err != nil {
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	rawSpec, _, err := unstructured.NestedMap(js.Object, "spec")
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	spec := &jobSetSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSpec, spec); err != nil {
+		return ctrl.Result{}, err
+	}
+	pgName, minMember, minResources := getJobSetPodGroup(spec)
+	return reconcileWorkloadPodGroup(ctx, r.Client, r.Scheme, js, pgName, minMember, minResources)
+}
+
+// SetupWithManager sets up the controller with the Manager, unless the JobSet API is not served.
+func (r *JobSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if _, err := mgr.GetRESTMapper().RESTMapping(jobSetGVK.GroupKind(), jobSetGVK.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			mgr.GetLogger().Info("JobSet API is not served, the PodGroups of JobSets are not created", "gvk", jobSetGVK)
+			return nil
+		}
+		return err
+	}
+	js := &unstructured.Unstructured{}
+	js.SetGroupVersionKind(jobSetGVK)
+	return ctrl.NewControllerManagedBy(mgr).
+		For(js).
+		Owns(&schedv1alpha1.PodGroup{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.Workers}).
+		Complete(r)
+}
+
+// getJobSetPodGroup returns the name of the PodGroup of a JobSet, the number of pods of its replicated
+// jobs labeled with it that run at the same time, and their requests. The PodGroup is named by the pod
+// group label of the first labeled replicated job; the replicated jobs not labeled with it aren't part of it.
+func getJobSetPodGroup(spec *jobSetSpec) (string, int32, v1.ResourceList) {
+	pgName := ""
+	minMember := int32(0)
+	var minResources v1.ResourceList
+	for i := range spec.ReplicatedJobs {
+		rj := &spec.ReplicatedJobs[i]
+		name := rj.Template.Spec.Template.Labels[schedv1alpha1.PodGroupLabel]
+		if name == "" || (pgName != "" && name != pgName) {
+			continue
+		}
+		pgName = name
+		replicas := int32(1)
+		if rj.Replicas != nil {
+			replicas = *rj.Replicas
+		}
+		members := replicas * getJobMinMember(&batchv1.Job{Spec: rj.Template.Spec})
+		minMember += members
+		for name, quantity := range getMinResources(&rj.Template.Spec.Template, members) {
+			if minResources == nil {
+				minResources = make(v1.ResourceList)
+			}
+			total := minResources[name]
+			total.Add(quantity)
+			minResources[name] = total
+		}
+	}
+	return pgName, minMember, minResources
+}
+
+// getMinResources returns the requests of <minMember> pods of the given template.
+func getMinResources(template *v1.PodTemplateSpec, minMember int32) v1.ResourceList {
+	requests := resourcehelper.PodRequests(&v1.Pod{Spec: template.Spec}, resourcehelper.PodResourcesOptions{})
+	if len(requests) == 0 {
+		return nil
+	}
+	minResources := make(v1.ResourceList, len(requests))
+	for name, quantity := range requests {
+		// Quantity.Mul keeps the fractional requests exact, e.g. 0.5 of a resource counted in units.
+		quantity.Mul(int64(minMember))
+		minResources[name] = quantity
+	}
+	return minResources
+}
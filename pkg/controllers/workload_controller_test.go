@@ -0,0 +1,329 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+)
+
+func TestJobReconcile(t *testing.T) {
+	ctx := context.TODO()
+	cases := []struct {
+		name             string
+		job              *batchv1.Job
+		pg               *v1alpha1.PodGroup
+		wantMinMember    int32
+		wantMinResources v1.ResourceList
+		wantPodGroup     bool
+	}{
+		{
+			name:         "job without pod group label",
+			job:          makeJob("job", "", pointer.Int32(3), nil),
+			wantPodGroup: false,
+		},
+		{
+			name:          "pod group is created with the parallelism of the job",
+			job:           makeJob("job", "pg", pointer.Int32(3), nil),
+			wantPodGroup:  true,
+			wantMinMember: 3,
+			wantMinResources: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("1500m"),
+				v1.ResourceMemory: resource.MustParse("3Gi"),
+			},
+		},
+		{
+			name:          "pod group is created with the completions of the job",
+			job:           makeJob("job", "pg", pointer.Int32(3), pointer.Int32(2)),
+			wantPodGroup:  true,
+			wantMinMember: 2,
+			wantMinResources: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("1"),
+				v1.ResourceMemory: resource.MustParse("2Gi"),
+			},
+		},
+		{
+			name:          "pod group is updated with the parallelism of the job",
+			job:           makeJob("job", "pg", pointer.Int32(4), nil),
+			pg:            makeWorkloadPG("pg", 3, "job"),
+			wantPodGroup:  true,
+			wantMinMember: 4,
+			wantMinResources: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("2"),
+				v1.ResourceMemory: resource.MustParse("4Gi"),
+			},
+		},
+		{
+			name:          "pod group is updated when the job is scaled to zero",
+			job:           makeJob("job", "pg", pointer.Int32(0), nil),
+			pg:            makeWorkloadPG("pg", 3, "job"),
+			wantPodGroup:  true,
+			wantMinMember: 0,
+			wantMinResources: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("0"),
+				v1.ResourceMemory: resource.MustParse("0"),
+			},
+		},
+		{
+			name:         "pod group is not created for a job scaled to zero",
+			job:          makeJob("job", "pg", pointer.Int32(0), nil),
+			wantPodGroup: false,
+		},
+		{
+			name:          "pod group not controlled by the job is left alone",
+			job:           makeJob("job", "pg", pointer.Int32(4), nil),
+			pg:            makeWorkloadPG("pg", 3, ""),
+			wantPodGroup:  true,
+			wantMinMember: 3,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := scheme.Scheme
+			s.AddKnownTypes(v1alpha1.SchemeGroupVersion, &v1alpha1.PodGroup{}, &v1alpha1.PodGroupList{})
+			objs := []runtime.Object{c.job}
+			if c.pg != nil {
+				objs = append(objs, c.pg)
+			}
+			kClient := fake.NewClientBuilder().
+				WithScheme(s).
+				WithRuntimeObjects(objs...).
+				Build()
+			controller := &JobReconciler{
+				Client: kClient,
+				Scheme: s,
+			}
+
+			if _, err := controller.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(c.job)}); err != nil {
+				t.Fatalf("reconcile: (%v)", err)
+			}
+			pg := &v1alpha1.PodGroup{}
+			err := kClient.Get(ctx, types.NamespacedName{Namespace: c.job.Namespace, Name: "pg"}, pg)
+			if !c.wantPodGroup {
+				if !apierrs.IsNotFound(err) {
+					t.Fatalf("want no pod group, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pg.Spec.MinMember != c.wantMinMember {
+				t.Errorf("want minMember %v, got %v", c.wantMinMember, pg.Spec.MinMember)
+			}
+			if c.wantMinResources != nil && !apiequality.Semantic.DeepEqual(pg.Spec.MinResources, c.wantMinResources) {
+				t.Errorf("want minResources %v, got %v", c.wantMinResources, pg.Spec.MinResources)
+			}
+			if c.pg == nil && !metav1.IsControlledBy(pg, c.job) {
+				t.Errorf("want pod group to be controlled by the job, got %v", pg.OwnerReferences)
+			}
+		})
+	}
+}
+
+func TestStatefulSetReconcile(t *testing.T) {
+	ctx := context.TODO()
+	s := scheme.Scheme
+	s.AddKnownTypes(v1alpha1.SchemeGroupVersion, &v1alpha1.PodGroup{}, &v1alpha1.PodGroupList{})
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "sts", Namespace: "default", UID: "sts"},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: pointer.Int32(2),
+			Template: makePodTemplate("pg"),
+		},
+	}
+	kClient := fake.NewClientBuilder().
+		WithScheme(s).
+		WithRuntimeObjects(sts).
+		Build()
+	controller := &StatefulSetReconciler{
+		Client: kClient,
+		Scheme: s,
+	}
+
+	if _, err := controller.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(sts)}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	pg := &v1alpha1.PodGroup{}
+	if err := kClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pg"}, pg); err != nil {
+		t.Fatal(err)
+	}
+	if pg.Spec.MinMember != 2 {
+		t.Errorf("want minMember 2, got %v", pg.Spec.MinMember)
+	}
+	if !metav1.IsControlledBy(pg, sts) {
+		t.Errorf("want pod group to be controlled by the statefulset, got %v", pg.OwnerReferences)
+	}
+}
+
+func TestJobSetReconcile(t *testing.T) {
+	ctx := context.TODO()
+	s := scheme.Scheme
+	s.AddKnownTypes(v1alpha1.SchemeGroupVersion, &v1alpha1.PodGroup{}, &v1alpha1.PodGroupList{})
+
+	replicatedJob := func(name, pgName string, replicas, parallelism int32) map[string]interface{} {
+		template := batchv1.JobTemplateSpec{
+			Spec: batchv1.JobSpec{
+				Parallelism: pointer.Int32(parallelism),
+				Template:    makePodTemplate(pgName),
+			},
+		}
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[string]interface{}{"name": name, "replicas": int64(replicas), "template": obj}
+	}
+	js := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicatedJobs": []interface{}{
+				replicatedJob("driver", "pg", 1, 1),
+				replicatedJob("workers", "pg", 2, 3),
+				replicatedJob("other", "", 1, 1),
+			},
+		},
+	}}
+	js.SetGroupVersionKind(jobSetGVK)
+	js.SetNamespace("default")
+	js.SetName("js")
+	js.SetUID("js")
+
+	kClient := fake.NewClientBuilder().
+		WithScheme(s).
+		WithObjects(js).
+		Build()
+	controller := &JobSetReconciler{
+		Client: kClient,
+		Scheme: s,
+	}
+
+	if _, err := controller.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(js)}); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	pg := &v1alpha1.PodGroup{}
+	if err := kClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pg"}, pg); err != nil {
+		t.Fatal(err)
+	}
+	if pg.Spec.MinMember != 7 {
+		t.Errorf("want minMember 7, got %v", pg.Spec.MinMember)
+	}
+	wantMinResources := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("3500m"),
+		v1.ResourceMemory: resource.MustParse("7Gi"),
+	}
+	if !apiequality.Semantic.DeepEqual(pg.Spec.MinResources, wantMinResources) {
+		t.Errorf("want minResources %v, got %v", wantMinResources, pg.Spec.MinResources)
+	}
+	if !metav1.IsControlledBy(pg, js) {
+		t.Errorf("want pod group to be controlled by the jobset, got %v", pg.OwnerReferences)
+	}
+}
+
+func TestGetMinResources(t *testing.T) {
+	template := v1.PodTemplateSpec{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "c",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU:              resource.MustParse("250m"),
+						v1.ResourceMemory:           resource.MustParse("1.5Gi"),
+						v1.ResourceEphemeralStorage: resource.MustParse("2.5"),
+					},
+				},
+			}},
+		},
+	}
+	want := v1.ResourceList{
+		v1.ResourceCPU:              resource.MustParse("750m"),
+		v1.ResourceMemory:           resource.MustParse("4.5Gi"),
+		v1.ResourceEphemeralStorage: resource.MustParse("7.5"),
+	}
+	if got := getMinResources(&template, 3); !apiequality.Semantic.DeepEqual(got, want) {
+		t.Errorf("want minResources %v, got %v", want, got)
+	}
+	if got := getMinResources(&v1.PodTemplateSpec{}, 3); got != nil {
+		t.Errorf("want no minResources, got %v", got)
+	}
+}
+
+func makePodTemplate(pgName string) v1.PodTemplateSpec {
+	template := v1.PodTemplateSpec{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "c",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse("500m"),
+						v1.ResourceMemory: resource.MustParse("1Gi"),
+					},
+				},
+			}},
+		},
+	}
+	if pgName != "" {
+		template.Labels = map[string]string{v1alpha1.PodGroupLabel: pgName}
+	}
+	return template
+}
+
+func makeJob(name, pgName string, parallelism, completions *int32) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+		Spec: batchv1.JobSpec{
+			Parallelism: parallelism,
+			Completions: completions,
+			Template:    makePodTemplate(pgName),
+		},
+	}
+}
+
+func makeWorkloadPG(pgName string, minMember int32, jobName string) *v1alpha1.PodGroup {
+	pg := &v1alpha1.PodGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: pgName, Namespace: "default"},
+		Spec:       v1alpha1.PodGroupSpec{MinMember: minMember},
+	}
+	if jobName != "" {
+		pg.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: batchv1.SchemeGroupVersion.String(),
+			Kind:       "Job",
+			Name:       jobName,
+			UID:        types.UID(jobName),
+			Controller: pointer.Bool(true),
+		}}
+	}
+	return pg
+}
@@ -19,22 +19,28 @@ package core
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 	"time"
 
 	gocache "github.com/patrickmn/go-cache"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	informerv1 "k8s.io/client-go/informers/core/v1"
 	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	pginformer "sigs.k8s.io/scheduler-plugins/pkg/generated/informers/externalversions/scheduling/v1alpha1"
+	pglister "sigs.k8s.io/scheduler-plugins/pkg/generated/listers/scheduling/v1alpha1"
 	"sigs.k8s.io/scheduler-plugins/pkg/util"
 )
 
@@ -48,10 +54,32 @@ const (
 	PodGroupNotFound Status = "PodGroup not found"
 	Success          Status = "Success"
 	Wait             Status = "Wait"
+	// PodGroupFull denotes the elastic PodGroup in the Pod spec already has
+	// maxMember pods that have been assigned nodes.
+	PodGroupFull Status = "PodGroup is full"
 
 	permitStateKey = "PermitCoscheduling"
 )
 
+// TopologyDomain is the topology domain the members of a PodGroup are packed into.
+type TopologyDomain struct {
+	// Key is the topology key of the node label.
+	Key string
+	// Value is the value of the node label shared by all the nodes in the domain.
+	Value string
+	// Required indicates the members must not be placed outside of the domain.
+	Required bool
+}
+
+// Contains returns true if the given node belongs to the topology domain.
+func (d *TopologyDomain) Contains(node *corev1.Node) bool {
+	if node == nil {
+		return false
+	}
+	v, ok := node.Labels[d.Key]
+	return ok && v == d.Value
+}
+
 type PermitState struct {
 	Activate bool
 }
@@ -66,16 +94,24 @@ type Manager interface {
 	Permit(context.Context, *framework.CycleState, *corev1.Pod) Status
 	GetPodGroup(context.Context, *corev1.Pod) (string, *v1alpha1.PodGroup)
 	GetCreationTimestamp(*corev1.Pod, time.Time) time.Time
+	GetQueueSortKey(*corev1.Pod, time.Time) QueueSortKey
 	DeletePermittedPodGroup(string)
 	CalculateAssignedPods(string, string) int
+	CalculateUnsatisfiedRoles(*v1alpha1.PodGroup) map[string]int32
 	ActivateSiblings(pod *corev1.Pod, state *framework.CycleState)
-	BackoffPodGroup(string, time.Duration)
+	BackoffPodGroup(context.Context, *v1alpha1.PodGroup, time.Duration, time.Duration) time.Duration
+	GetTopologyDomain(string) *TopologyDomain
+	SetPodGroupCondition(context.Context, *v1alpha1.PodGroup, metav1.Condition)
 }
 
 // PodGroupManager defines the scheduling operation called
 type PodGroupManager struct {
-	// client is a generic controller-runtime client to manipulate both core resources and PodGroups.
+	// client is a generic controller-runtime client to update the status of PodGroups.
 	client client.Client
+	// statusWriter writes the conditions and the backoff of PodGroups off the scheduling cycle.
+	statusWriter *podGroupStatusWriter
+	// pgLister is podgroup lister
+	pgLister pglister.PodGroupLister
 	// snapshotSharedLister is pod shared list
 	snapshotSharedLister framework.SharedLister
 	// scheduleTimeout is the default timeout for podgroup scheduling.
@@ -85,29 +121,147 @@ type PodGroupManager struct {
 	permittedPG *gocache.Cache
 	// backedOffPG stores the podgorup name which failed scheudling recently.
 	backedOffPG *gocache.Cache
+	// topologyDomains stores the topology domain chosen for a podgroup with a topology constraint.
+	topologyDomains *gocache.Cache
 	// podLister is pod lister
 	podLister listerv1.PodLister
+	// enablePlacementSimulation enables bin-packing the pending members of a podgroup onto the nodes in PreFilter.
+	enablePlacementSimulation bool
+	// queueSortKeys stores the key each pod is sorted by in the scheduling queue.
+	queueSortKeys queueSortKeys
+	// fairQueue orders the podgroups of different tenants fairly in the scheduling queue, if not nil.
+	fairQueue *FairQueue
+	sync.RWMutex
+}
+
+// QueueSortKey is the key a pod is sorted by in the scheduling queue, next to its priority.
+type QueueSortKey struct {
+	// VirtualFinishTime is the virtual finish time of the podGroup of the pod under a fair
+	// queue sort policy, see FairQueue; it's 0 otherwise.
+	VirtualFinishTime float64
+	// CreationTimestamp is the creation time of the podGroup of the pod, or the time the pod
+	// was first added to the scheduling queue if it doesn't belong to any podGroup.
+	CreationTimestamp time.Time
+	// PodGroup is the full name of the podGroup of the pod.
+	PodGroup string
+	// Name is the namespaced name of the pod.
+	Name string
+}
+
+// Less returns true if the pod of <key> should be sorted before the pod of <other>. The pods of
+// the same podGroup only differ in their name, so that they are kept together in the queue.
+func (key *QueueSortKey) Less(other *QueueSortKey) bool {
+	if key.VirtualFinishTime != other.VirtualFinishTime {
+		return key.VirtualFinishTime < other.VirtualFinishTime
+	}
+	if !key.CreationTimestamp.Equal(other.CreationTimestamp) {
+		return key.CreationTimestamp.Before(other.CreationTimestamp)
+	}
+	if key.PodGroup != other.PodGroup {
+		return key.PodGroup < other.PodGroup
+	}
+	return key.Name < other.Name
+}
+
+type queueSortKeys struct {
 	sync.RWMutex
+	keys map[types.NamespacedName]*QueueSortKey
 }
 
 // NewPodGroupManager creates a new operation object.
-func NewPodGroupManager(client client.Client, snapshotSharedLister framework.SharedLister, scheduleTimeout *time.Duration, podInformer informerv1.PodInformer) *PodGroupManager {
+func NewPodGroupManager(client client.Client, snapshotSharedLister framework.SharedLister, scheduleTimeout *time.Duration,
+	pgInformer pginformer.PodGroupInformer, podInformer informerv1.PodInformer, enablePlacementSimulation bool, fairQueue *FairQueue) *PodGroupManager {
 	pgMgr := &PodGroupManager{
-		client:               client,
-		snapshotSharedLister: snapshotSharedLister,
-		scheduleTimeout:      scheduleTimeout,
-		podLister:            podInformer.Lister(),
-		permittedPG:          gocache.New(3*time.Second, 3*time.Second),
-		backedOffPG:          gocache.New(10*time.Second, 10*time.Second),
+		client:                    client,
+		statusWriter:              newPodGroupStatusWriter(client),
+		pgLister:                  pgInformer.Lister(),
+		snapshotSharedLister:      snapshotSharedLister,
+		scheduleTimeout:           scheduleTimeout,
+		podLister:                 podInformer.Lister(),
+		permittedPG:               gocache.New(3*time.Second, 3*time.Second),
+		backedOffPG:               gocache.New(10*time.Second, 10*time.Second),
+		topologyDomains:           gocache.New(3*time.Second, 3*time.Second),
+		enablePlacementSimulation: enablePlacementSimulation,
+		fairQueue:                 fairQueue,
 	}
+	// The queue sort keys are dropped along with the pods, or the podgroups they are derived from.
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok1 := oldObj.(*corev1.Pod)
+			newPod, ok2 := newObj.(*corev1.Pod)
+			if ok1 && ok2 && util.GetPodGroupLabel(oldPod) != util.GetPodGroupLabel(newPod) {
+				pgMgr.deleteQueueSortKey(oldPod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				pgMgr.deleteQueueSortKey(pod)
+			}
+		},
+	})
+	pgInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pg, ok := obj.(*v1alpha1.PodGroup); ok {
+				pgMgr.deleteQueueSortKeys(GetNamespacedName(pg))
+			}
+		},
+	})
 	return pgMgr
 }
 
-func (pgMgr *PodGroupManager) BackoffPodGroup(pgName string, backoff time.Duration) {
+// Run writes the status updates of the podGroups until <ctx> is done.
+func (pgMgr *PodGroupManager) Run(ctx context.Context) {
+	pgMgr.statusWriter.run(ctx)
+}
+
+// BackoffPodGroup backs off a podGroup for <backoff>, doubled every consecutive time the podGroup
+// has been backed off, up to <maxBackoff>. The backoff is recorded in the status of the podGroup,
+// so that it's honoured after a scheduler restart, once written. It returns the duration of the backoff.
+func (pgMgr *PodGroupManager) BackoffPodGroup(ctx context.Context, pg *v1alpha1.PodGroup, backoff, maxBackoff time.Duration) time.Duration {
 	if backoff == time.Duration(0) {
+		return 0
+	}
+	backoff = getBackoffDuration(pg.Status.BackoffCount, backoff, maxBackoff)
+	pgMgr.backedOffPG.Add(GetNamespacedName(pg), nil, backoff)
+
+	backoffUntil := metav1.NewTime(time.Now().Add(backoff))
+	pgMgr.statusWriter.setBackoff(pg, podGroupBackoff{count: pg.Status.BackoffCount + 1, until: &backoffUntil})
+	return backoff
+}
+
+// resetPodGroupBackoff clears the backoff of a podGroup once it's scheduled.
+func (pgMgr *PodGroupManager) resetPodGroupBackoff(ctx context.Context, pg *v1alpha1.PodGroup) {
+	if pg.Status.BackoffCount == 0 && pg.Status.BackoffUntil == nil {
 		return
 	}
-	pgMgr.backedOffPG.Add(pgName, nil, backoff)
+	pgMgr.backedOffPG.Delete(GetNamespacedName(pg))
+	pgMgr.statusWriter.setBackoff(pg, podGroupBackoff{})
+}
+
+// getBackoffDuration returns <backoff> doubled <count> times, up to <maxBackoff>.
+// The backoff doesn't grow if <maxBackoff> is not greater than <backoff>.
+func getBackoffDuration(count int32, backoff, maxBackoff time.Duration) time.Duration {
+	if maxBackoff <= backoff {
+		return backoff
+	}
+	for i := int32(0); i < count && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+// isBackedOff returns true if the backoff recorded in the status of a podGroup hasn't expired yet.
+func isBackedOff(pg *v1alpha1.PodGroup) bool {
+	return pg.Status.BackoffUntil != nil && time.Now().Before(pg.Status.BackoffUntil.Time)
 }
 
 // ActivateSiblings stashes the pods belonging to the same PodGroup of the given pod
@@ -156,18 +310,45 @@ func (pgMgr *PodGroupManager) ActivateSiblings(pod *corev1.Pod, state *framework
 
 // PreFilter filters out a pod if
 // 1. it belongs to a podgroup that was recently denied or
-// 2. the total number of pods in the podgroup is less than the minimum number of pods
-// that is required to be scheduled.
+// 2. it belongs to an elastic podgroup that already has maxMember pods assigned or
+// 3. the total number of pods in the podgroup (or in one of its roles) is less than the
+// minimum number of pods that is required to be scheduled or
+// 4. the podgroup has a required topology constraint and no topology domain can hold it.
+// Once the quorum of an elastic podgroup has been assigned, its other pods are not gated anymore.
 func (pgMgr *PodGroupManager) PreFilter(ctx context.Context, pod *corev1.Pod) error {
 	klog.V(5).InfoS("Pre-filter", "pod", klog.KObj(pod))
+	if pgMgr.fairQueue != nil {
+		pgMgr.fairQueue.dequeue(getQueueSortGroup(pod))
+	}
 	pgFullName, pg := pgMgr.GetPodGroup(ctx, pod)
 	if pg == nil {
 		return nil
 	}
 
-	if _, exist := pgMgr.backedOffPG.Get(pgFullName); exist {
+	if _, exist := pgMgr.backedOffPG.Get(pgFullName); exist || isBackedOff(pg) {
 		return fmt.Errorf("podGroup %v failed recently", pgFullName)
 	}
+	pgMgr.resetPodGroupCondition(ctx, pg, metav1.Condition{
+		Type:    v1alpha1.PodGroupConditionBackedOff,
+		Status:  metav1.ConditionFalse,
+		Reason:  "BackoffExpired",
+		Message: "podGroup is not backed off",
+	})
+
+	if maxMember := util.GetMaxMember(pg); maxMember != 0 {
+		assignedPods := pgMgr.getAssignedPods(pg.Name, pg.Namespace)
+		if len(assignedPods) >= int(maxMember) {
+			return fmt.Errorf("podGroup %v already has %v pods assigned, maxMember of group: %v", pgFullName, len(assignedPods), maxMember)
+		}
+		if satisfied(pg, assignedPods) {
+			klog.V(4).InfoS("Quorum of elastic PodGroup has been assigned", "podGroup", klog.KObj(pg), "assigned", len(assignedPods))
+			if pg.Spec.TopologyConstraint != nil {
+				// The domain is pinned by the assigned pods.
+				return pgMgr.preFilterTopology(ctx, pgFullName, pg, nil)
+			}
+			return nil
+		}
+	}
 
 	pods, err := pgMgr.podLister.Pods(pod.Namespace).List(
 		labels.SelectorFromSet(labels.Set{v1alpha1.PodGroupLabel: util.GetPodGroupLabel(pod)}),
@@ -177,17 +358,34 @@ func (pgMgr *PodGroupManager) PreFilter(ctx context.Context, pod *corev1.Pod) er
 	}
 
 	if len(pods) < int(pg.Spec.MinMember) {
+		pgMgr.SetPodGroupCondition(ctx, pg, newQuorumNotReachedCondition("less pods than the minMember of the pod group have been created"))
 		return fmt.Errorf("pre-filter pod %v cannot find enough sibling pods, "+
 			"current pods number: %v, minMember of group: %v", pod.Name, len(pods), pg.Spec.MinMember)
 	}
 
-	if pg.Spec.MinResources == nil {
+	if roles := util.GetUnsatisfiedRoles(pg, pods); len(roles) != 0 {
+		pgMgr.SetPodGroupCondition(ctx, pg, newQuorumNotReachedCondition("the minMember of some roles of the pod group is not satisfied"))
+		return fmt.Errorf("pre-filter pod %v cannot find enough sibling pods for roles %v of group", pod.Name, roles)
+	}
+	pgMgr.resetPodGroupCondition(ctx, pg, metav1.Condition{
+		Type:    v1alpha1.PodGroupConditionQuorumReached,
+		Status:  metav1.ConditionTrue,
+		Reason:  "EnoughPods",
+		Message: "podGroup has enough pods to reach the quorum",
+	})
+
+	if pg.Spec.TopologyConstraint != nil {
+		return pgMgr.preFilterTopology(ctx, pgFullName, pg, pods)
+	}
+
+	if util.GetMinResources(pg) == nil && !pgMgr.enablePlacementSimulation {
 		return nil
 	}
 
 	// TODO(cwdsuzhou): This resource check may not always pre-catch unschedulable pod group.
 	// It only tries to PreFilter resource constraints so even if a PodGroup passed here,
-	// it may not necessarily pass Filter due to other constraints such as affinity/taints.
+	// it may not necessarily pass Filter due to other constraints such as inter-pod affinity.
+	// Node selectors and taints are only honoured if the placement simulation is enabled.
 	if _, ok := pgMgr.permittedPG.Get(pgFullName); ok {
 		return nil
 	}
@@ -197,19 +395,129 @@ func (pgMgr *PodGroupManager) PreFilter(ctx context.Context, pod *corev1.Pod) er
 		return err
 	}
 
-	minResources := pg.Spec.MinResources.DeepCopy()
-	podQuantity := resource.NewQuantity(int64(pg.Spec.MinMember), resource.DecimalSI)
-	minResources[corev1.ResourcePods] = *podQuantity
-	err = CheckClusterResource(ctx, nodes, minResources, pgFullName)
+	err = pgMgr.checkResources(ctx, nodes, pgFullName, pg, pods)
 	if err != nil {
 		klog.ErrorS(err, "Failed to PreFilter", "podGroup", klog.KObj(pg))
+		pgMgr.SetPodGroupCondition(ctx, pg, newNotEnoughResourcesCondition())
 		return err
 	}
+	pgMgr.resetPodGroupCondition(ctx, pg, newSufficientResourcesCondition())
+	pgMgr.permittedPG.Add(pgFullName, pgFullName, *pgMgr.scheduleTimeout)
+	return nil
+}
+
+// preFilterTopology picks the topology domain a podgroup is packed into. Members that have been
+// assigned already pin the domain; otherwise the first domain (in the order of label values) whose
+// nodes can hold the podgroup is chosen. If no domain fits, a required
+// constraint fails, while a preferred one falls back to the cluster-wide resource check.
+// <pods> is nil for the pods of an elastic podgroup whose quorum has been assigned,
+// which only follow the domain of the assigned pods and are not checked for resources.
+func (pgMgr *PodGroupManager) preFilterTopology(ctx context.Context, pgFullName string, pg *v1alpha1.PodGroup, pods []*corev1.Pod) error {
+	if _, ok := pgMgr.permittedPG.Get(pgFullName); ok {
+		return nil
+	}
+
+	nodes, err := pgMgr.snapshotSharedLister.NodeInfos().List()
+	if err != nil {
+		return err
+	}
+
+	key := pg.Spec.TopologyConstraint.TopologyKey
+	required := pg.Spec.TopologyConstraint.Mode != v1alpha1.TopologyConstraintPreferred
+	domains := make(map[string][]*framework.NodeInfo)
+	assigned := make(map[string]int)
+	for _, info := range nodes {
+		if info == nil || info.Node() == nil {
+			continue
+		}
+		value, ok := info.Node().Labels[key]
+		if !ok {
+			continue
+		}
+		domains[value] = append(domains[value], info)
+		for _, podInfo := range info.Pods {
+			if util.GetPodGroupFullName(podInfo.Pod) == pgFullName && podInfo.Pod.Spec.NodeName != "" {
+				assigned[value]++
+			}
+		}
+	}
+
+	values := make([]string, 0, len(domains))
+	for value := range domains {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	chosen, found := "", false
+	for _, value := range values {
+		if assigned[value] > 0 && (!found || assigned[value] > assigned[chosen]) {
+			chosen, found = value, true
+		}
+	}
+	if !found && pods != nil {
+		for _, value := range values {
+			if err := pgMgr.checkResources(ctx, domains[value], pgFullName, pg, pods); err != nil {
+				klog.V(4).InfoS("Topology domain cannot hold the PodGroup", "podGroup", klog.KObj(pg), "topologyKey", key, "domain", value, "err", err)
+				continue
+			}
+			chosen, found = value, true
+			break
+		}
+	}
+
+	if !found {
+		if required {
+			err := fmt.Errorf("no topology domain of %q can satisfy podGroup %v", key, pgFullName)
+			klog.ErrorS(err, "Failed to PreFilter", "podGroup", klog.KObj(pg))
+			pgMgr.SetPodGroupCondition(ctx, pg, newInsufficientResourcesCondition("NoTopologyDomain",
+				fmt.Sprintf("no topology domain of %q can satisfy the podGroup", key)))
+			return err
+		}
+		if pods != nil && (util.GetMinResources(pg) != nil || pgMgr.enablePlacementSimulation) {
+			if err := pgMgr.checkResources(ctx, nodes, pgFullName, pg, pods); err != nil {
+				klog.ErrorS(err, "Failed to PreFilter", "podGroup", klog.KObj(pg))
+				pgMgr.SetPodGroupCondition(ctx, pg, newNotEnoughResourcesCondition())
+				return err
+			}
+		}
+	} else {
+		klog.V(4).InfoS("Topology domain chosen", "podGroup", klog.KObj(pg), "topologyKey", key, "domain", chosen)
+		pgMgr.topologyDomains.Add(pgFullName, &TopologyDomain{Key: key, Value: chosen, Required: required}, *pgMgr.scheduleTimeout)
+	}
+	pgMgr.resetPodGroupCondition(ctx, pg, newSufficientResourcesCondition())
 	pgMgr.permittedPG.Add(pgFullName, pgFullName, *pgMgr.scheduleTimeout)
 	return nil
 }
 
+// checkResources checks if <nodes> can satisfy the minResources of a podGroup and, if the
+// placement simulation is enabled, if the pending members of the podGroup can be placed onto them.
+func (pgMgr *PodGroupManager) checkResources(ctx context.Context, nodes []*framework.NodeInfo, pgFullName string, pg *v1alpha1.PodGroup, pods []*corev1.Pod) error {
+	minResources := util.GetMinResources(pg)
+	if minResources == nil {
+		minResources = corev1.ResourceList{}
+	}
+	podQuantity := resource.NewQuantity(int64(pg.Spec.MinMember), resource.DecimalSI)
+	minResources[corev1.ResourcePods] = *podQuantity
+	if err := CheckClusterResource(ctx, nodes, minResources, pgFullName); err != nil {
+		return err
+	}
+	if pgMgr.enablePlacementSimulation {
+		return SimulatePlacement(nodes, pg, pods)
+	}
+	return nil
+}
+
+// GetTopologyDomain returns the topology domain chosen for a podGroup, or nil if there is none.
+func (pgMgr *PodGroupManager) GetTopologyDomain(pgFullName string) *TopologyDomain {
+	if d, ok := pgMgr.topologyDomains.Get(pgFullName); ok {
+		return d.(*TopologyDomain)
+	}
+	return nil
+}
+
 // Permit permits a pod to run, if the minMember match, it would send a signal to chan.
+// Once the minMember of an elastic podgroup match, its other pods are permitted right away
+// until maxMember pods have been assigned.
 func (pgMgr *PodGroupManager) Permit(ctx context.Context, state *framework.CycleState, pod *corev1.Pod) Status {
 	pgFullName, pg := pgMgr.GetPodGroup(ctx, pod)
 	if pgFullName == "" {
@@ -220,10 +528,15 @@ func (pgMgr *PodGroupManager) Permit(ctx context.Context, state *framework.Cycle
 		return PodGroupNotFound
 	}
 
-	assigned := pgMgr.CalculateAssignedPods(pg.Name, pg.Namespace)
+	assignedPods := pgMgr.getAssignedPods(pg.Name, pg.Namespace)
+	assigned := len(assignedPods)
+	if maxMember := util.GetMaxMember(pg); maxMember != 0 && int32(assigned) >= maxMember {
+		return PodGroupFull
+	}
 	// The number of pods that have been assigned nodes is calculated from the snapshot.
 	// The current pod in not included in the snapshot during the current scheduling cycle.
-	if int32(assigned)+1 >= pg.Spec.MinMember {
+	if int32(assigned)+1 >= pg.Spec.MinMember && len(util.GetUnsatisfiedRoles(pg, append(assignedPods, pod))) == 0 {
+		pgMgr.resetPodGroupBackoff(ctx, pg)
 		return Success
 	}
 
@@ -248,49 +561,184 @@ func (pgMgr *PodGroupManager) GetCreationTimestamp(pod *corev1.Pod, ts time.Time
 	if len(pgName) == 0 {
 		return ts
 	}
-	var pg v1alpha1.PodGroup
-	if err := pgMgr.client.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pgName}, &pg); err != nil {
+	pg, err := pgMgr.pgLister.PodGroups(pod.Namespace).Get(pgName)
+	if err != nil {
 		return ts
 	}
 	return pg.CreationTimestamp.Time
 }
 
-// DeletePermittedPodGroup deletes a podGroup that passes Pre-Filter but reaches PostFilter.
+// GetQueueSortKey returns the key a pod is sorted by in the scheduling queue, with <ts> as the
+// creation time if the pod doesn't belong to any podGroup. The key is computed once per pod,
+// as it's needed for every comparison of the pod in the scheduling queue.
+func (pgMgr *PodGroupManager) GetQueueSortKey(pod *corev1.Pod, ts time.Time) QueueSortKey {
+	podName := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	pgMgr.queueSortKeys.RLock()
+	key, ok := pgMgr.queueSortKeys.keys[podName]
+	pgMgr.queueSortKeys.RUnlock()
+	if !ok {
+		key = &QueueSortKey{PodGroup: util.GetPodGroupFullName(pod), Name: podName.String()}
+		var pg *v1alpha1.PodGroup
+		if key.PodGroup != "" {
+			var err error
+			pg, err = pgMgr.pgLister.PodGroups(pod.Namespace).Get(util.GetPodGroupLabel(pod))
+			if err != nil {
+				// The key is computed again once the podGroup is created.
+				key.CreationTimestamp = ts
+				if pgMgr.fairQueue != nil {
+					key.VirtualFinishTime = math.Inf(1)
+				}
+				return *key
+			}
+			key.CreationTimestamp = pg.CreationTimestamp.Time
+		}
+		if pgMgr.fairQueue != nil {
+			key.VirtualFinishTime = pgMgr.fairQueue.finishTime(getQueueSortGroup(pod), pgMgr.fairQueue.getTenant(pod, pg))
+		}
+		pgMgr.queueSortKeys.Lock()
+		if pgMgr.queueSortKeys.keys == nil {
+			pgMgr.queueSortKeys.keys = make(map[types.NamespacedName]*QueueSortKey)
+		}
+		pgMgr.queueSortKeys.keys[podName] = key
+		pgMgr.queueSortKeys.Unlock()
+	}
+	if key.PodGroup == "" {
+		k := *key
+		k.CreationTimestamp = ts
+		return k
+	}
+	return *key
+}
+
+func (pgMgr *PodGroupManager) deleteQueueSortKey(pod *corev1.Pod) {
+	pgMgr.queueSortKeys.Lock()
+	defer pgMgr.queueSortKeys.Unlock()
+	delete(pgMgr.queueSortKeys.keys, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})
+	if pgMgr.fairQueue != nil && util.GetPodGroupLabel(pod) == "" {
+		pgMgr.fairQueue.delete(getQueueSortGroup(pod))
+	}
+}
+
+func (pgMgr *PodGroupManager) deleteQueueSortKeys(pgFullName string) {
+	pgMgr.queueSortKeys.Lock()
+	defer pgMgr.queueSortKeys.Unlock()
+	for podName, key := range pgMgr.queueSortKeys.keys {
+		if key.PodGroup == pgFullName {
+			delete(pgMgr.queueSortKeys.keys, podName)
+		}
+	}
+	if pgMgr.fairQueue != nil {
+		pgMgr.fairQueue.delete(pgFullName)
+	}
+}
+
+// DeletePermittedPodGroup deletes a podGroup that passes Pre-Filter but reaches PostFilter,
+// along with the topology domain chosen for it.
 func (pgMgr *PodGroupManager) DeletePermittedPodGroup(pgFullName string) {
 	pgMgr.permittedPG.Delete(pgFullName)
+	pgMgr.topologyDomains.Delete(pgFullName)
+}
+
+// SetPodGroupCondition sets the given condition on the status of a podGroup, unless a condition
+// of the same type, status, reason and message is already set. The condition is written
+// asynchronously, and the given podGroup is updated in place. Failures are only logged,
+// as conditions are informational.
+func (pgMgr *PodGroupManager) SetPodGroupCondition(ctx context.Context, pg *v1alpha1.PodGroup, condition metav1.Condition) {
+	pgMgr.statusWriter.setCondition(pg, condition)
+}
+
+// resetPodGroupCondition sets the given condition on the status of a podGroup only if a condition
+// of the same type but a different status is already set, to avoid updating a podGroup on every
+// scheduling cycle.
+func (pgMgr *PodGroupManager) resetPodGroupCondition(ctx context.Context, pg *v1alpha1.PodGroup, condition metav1.Condition) {
+	if c := meta.FindStatusCondition(pg.Status.Conditions, condition.Type); c == nil || c.Status == condition.Status {
+		return
+	}
+	pgMgr.SetPodGroupCondition(ctx, pg, condition)
+}
+
+// newQuorumNotReachedCondition returns the QuorumReached condition of a podGroup not having enough pods.
+// The message is the same as the one set by the controller, and it must not depend on the pod being
+// scheduled or on the number of pods, so that the condition is not patched again by every member
+// of the podGroup.
+func newQuorumNotReachedCondition(message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    v1alpha1.PodGroupConditionQuorumReached,
+		Status:  metav1.ConditionFalse,
+		Reason:  "NotEnoughPods",
+		Message: message,
+	}
 }
 
-// GetPodGroup returns the PodGroup that a Pod belongs to in cache.
+// newInsufficientResourcesCondition returns the InsufficientResources condition of a podGroup rejected
+// in PreFilter. The details of the rejection, such as the resource gap, are left to the error returned
+// by PreFilter, so that the condition is not patched again as the free resources change.
+func newInsufficientResourcesCondition(reason, message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    v1alpha1.PodGroupConditionInsufficientResources,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+func newNotEnoughResourcesCondition() metav1.Condition {
+	return newInsufficientResourcesCondition("NotEnoughResources", "the nodes cannot hold the pods of the podGroup")
+}
+
+func newSufficientResourcesCondition() metav1.Condition {
+	return metav1.Condition{
+		Type:    v1alpha1.PodGroupConditionInsufficientResources,
+		Status:  metav1.ConditionFalse,
+		Reason:  "EnoughResources",
+		Message: "podGroup fits the cluster",
+	}
+}
+
+// GetPodGroup returns a copy of the PodGroup that a Pod belongs to in cache.
 func (pgMgr *PodGroupManager) GetPodGroup(ctx context.Context, pod *corev1.Pod) (string, *v1alpha1.PodGroup) {
 	pgName := util.GetPodGroupLabel(pod)
 	if len(pgName) == 0 {
 		return "", nil
 	}
-	var pg v1alpha1.PodGroup
-	if err := pgMgr.client.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pgName}, &pg); err != nil {
+	pg, err := pgMgr.pgLister.PodGroups(pod.Namespace).Get(pgName)
+	if err != nil {
 		return fmt.Sprintf("%v/%v", pod.Namespace, pgName), nil
 	}
-	return fmt.Sprintf("%v/%v", pod.Namespace, pgName), &pg
+	return fmt.Sprintf("%v/%v", pod.Namespace, pgName), pg.DeepCopy()
 }
 
 // CalculateAssignedPods returns the number of pods that has been assigned nodes: assumed or bound.
 func (pgMgr *PodGroupManager) CalculateAssignedPods(podGroupName, namespace string) int {
+	return len(pgMgr.getAssignedPods(podGroupName, namespace))
+}
+
+// CalculateUnsatisfiedRoles returns, for each role of a podGroup whose minMember is not satisfied
+// by the pods that has been assigned nodes (assumed or bound), how many members it is missing.
+func (pgMgr *PodGroupManager) CalculateUnsatisfiedRoles(pg *v1alpha1.PodGroup) map[string]int32 {
+	if len(pg.Spec.Roles) == 0 {
+		return nil
+	}
+	return util.GetMissingRoleMembers(pg, pgMgr.getAssignedPods(pg.Name, pg.Namespace))
+}
+
+func (pgMgr *PodGroupManager) getAssignedPods(podGroupName, namespace string) []*corev1.Pod {
 	nodeInfos, err := pgMgr.snapshotSharedLister.NodeInfos().List()
 	if err != nil {
 		klog.ErrorS(err, "Cannot get nodeInfos from frameworkHandle")
-		return 0
+		return nil
 	}
-	var count int
+	var pods []*corev1.Pod
 	for _, nodeInfo := range nodeInfos {
 		for _, podInfo := range nodeInfo.Pods {
 			pod := podInfo.Pod
 			if util.GetPodGroupLabel(pod) == podGroupName && pod.Namespace == namespace && pod.Spec.NodeName != "" {
-				count++
+				pods = append(pods, pod)
 			}
 		}
 	}
 
-	return count
+	return pods
 }
 
 // CheckClusterResource checks if resource capacity of the cluster can satisfy <resourceRequest>.
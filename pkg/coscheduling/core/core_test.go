@@ -18,19 +18,27 @@ package core
 
 import (
 	"context"
+	"reflect"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	gocache "github.com/patrickmn/go-cache"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	clientsetfake "k8s.io/client-go/kubernetes/fake"
 	clicache "k8s.io/client-go/tools/cache"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	st "k8s.io/kubernetes/pkg/scheduler/testing"
+	"sigs.k8s.io/scheduler-plugins/apis/config"
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	"sigs.k8s.io/scheduler-plugins/pkg/util"
 	tu "sigs.k8s.io/scheduler-plugins/test/util"
 )
 
@@ -45,11 +53,13 @@ func TestPreFilter(t *testing.T) {
 	}
 
 	tests := []struct {
-		name            string
-		pod             *corev1.Pod
-		pendingPods     []*corev1.Pod
-		pgs             []*v1alpha1.PodGroup
-		expectedSuccess bool
+		name                      string
+		pod                       *corev1.Pod
+		pendingPods               []*corev1.Pod
+		pgs                       []*v1alpha1.PodGroup
+		enablePlacementSimulation bool
+		expectedSuccess           bool
+		expectedConditions        map[string]metav1.ConditionStatus
 	}{
 		{
 			name: "pod does not belong to any pg",
@@ -83,6 +93,9 @@ func TestPreFilter(t *testing.T) {
 				tu.MakePodGroup().Name("pg2").Namespace("ns").MinMember(2).Obj(),
 			},
 			expectedSuccess: false,
+			expectedConditions: map[string]metav1.ConditionStatus{
+				v1alpha1.PodGroupConditionQuorumReached: metav1.ConditionFalse,
+			},
 		},
 		{
 			name: "pod count equal minMember",
@@ -110,6 +123,30 @@ func TestPreFilter(t *testing.T) {
 			},
 			expectedSuccess: true,
 		},
+		{
+			name: "pod belongs to a pg that is backed off",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			pendingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+				st.MakePod().Name("p1c").Namespace("ns").UID("p1c").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Backoff(1, time.Now().Add(time.Minute)).Obj(),
+			},
+			expectedSuccess: false,
+		},
+		{
+			name: "pod belongs to a pg whose backoff expired",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			pendingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+				st.MakePod().Name("p1c").Namespace("ns").UID("p1c").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Backoff(1, time.Now().Add(-time.Minute)).Obj(),
+			},
+			expectedSuccess: true,
+		},
 		{
 			// Previously we defined 2 nodes, each with 4 cpus. Now the PodGroup's minResources req is 6 cpus.
 			name: "cluster's resource satisfies minResource", // Although it'd fail in Filter()
@@ -137,6 +174,119 @@ func TestPreFilter(t *testing.T) {
 					MinResources(map[corev1.ResourceName]string{corev1.ResourceCPU: "10"}).Obj(),
 			},
 			expectedSuccess: false,
+			expectedConditions: map[string]metav1.ConditionStatus{
+				v1alpha1.PodGroupConditionInsufficientResources: metav1.ConditionTrue,
+			},
+		},
+		{
+			// Previously we defined 2 nodes, each with 4 cpus. Now the PodGroup's minResources req is 6 cpus,
+			// but its 2 members request 3 cpus each.
+			name: "pods of the pg can be placed onto the nodes",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			pendingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").
+					Req(map[corev1.ResourceName]string{corev1.ResourceCPU: "3"}).Obj(),
+				st.MakePod().Name("p1c").Namespace("ns").UID("p1c").Label(v1alpha1.PodGroupLabel, "pg1").
+					Req(map[corev1.ResourceName]string{corev1.ResourceCPU: "3"}).Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+					MinResources(map[corev1.ResourceName]string{corev1.ResourceCPU: "6"}).Obj(),
+			},
+			enablePlacementSimulation: true,
+			expectedSuccess:           true,
+		},
+		{
+			// Previously we defined 2 nodes, each with 4 cpus. Now the PodGroup's minResources req is 6 cpus,
+			// but its 2 members request 5 cpus each, which doesn't fit any node.
+			name: "pods of the pg cannot be placed onto the nodes",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			pendingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").
+					Req(map[corev1.ResourceName]string{corev1.ResourceCPU: "5"}).Obj(),
+				st.MakePod().Name("p1c").Namespace("ns").UID("p1c").Label(v1alpha1.PodGroupLabel, "pg1").
+					Req(map[corev1.ResourceName]string{corev1.ResourceCPU: "5"}).Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+					MinResources(map[corev1.ResourceName]string{corev1.ResourceCPU: "6"}).Obj(),
+			},
+			enablePlacementSimulation: true,
+			expectedSuccess:           false,
+			expectedConditions: map[string]metav1.ConditionStatus{
+				v1alpha1.PodGroupConditionInsufficientResources: metav1.ConditionTrue,
+			},
+		},
+		{
+			name: "pod count of a role less than its minMember",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Obj(),
+			pendingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+					Role("ps", map[string]string{"role": "ps"}, 1, nil).
+					Role("worker", map[string]string{"role": "worker"}, 1, nil).Obj(),
+			},
+			expectedSuccess: false,
+			expectedConditions: map[string]metav1.ConditionStatus{
+				v1alpha1.PodGroupConditionQuorumReached: metav1.ConditionFalse,
+			},
+		},
+		{
+			name: "pod count of every role equal its minMember",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Obj(),
+			pendingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "ps").Obj(),
+				st.MakePod().Name("p1c").Namespace("ns").UID("p1c").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+					Role("ps", map[string]string{"role": "ps"}, 1, nil).
+					Role("worker", map[string]string{"role": "worker"}, 1, nil).Obj(),
+			},
+			expectedSuccess: true,
+		},
+		{
+			// Previously we defined 2 nodes, each with 4 cpus. Now the roles' minResources req adds up to 10 cpus.
+			name: "cluster's resource cannot satisfy minResource of roles",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Obj(),
+			pendingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "ps").Obj(),
+				st.MakePod().Name("p1c").Namespace("ns").UID("p1c").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+					Role("ps", map[string]string{"role": "ps"}, 1, map[corev1.ResourceName]string{corev1.ResourceCPU: "2"}).
+					Role("worker", map[string]string{"role": "worker"}, 1, map[corev1.ResourceName]string{corev1.ResourceCPU: "8"}).Obj(),
+			},
+			expectedSuccess: false,
+		},
+		{
+			// Previously we defined 2 nodes, each with 4 cpus. The quorum of the elastic pg has been assigned,
+			// so its minResources req of 10 cpus isn't checked anymore.
+			name: "pods of an elastic pg beyond minMember are not gated",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			pendingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Node("node-a").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(1).MaxMember(3).
+					MinResources(map[corev1.ResourceName]string{corev1.ResourceCPU: "10"}).Obj(),
+			},
+			expectedSuccess: true,
+		},
+		{
+			name: "elastic pg has maxMember pods assigned",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			pendingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Node("node-a").Obj(),
+				st.MakePod().Name("p1c").Namespace("ns").UID("p1c").Label(v1alpha1.PodGroupLabel, "pg1").Node("node-b").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(1).MaxMember(2).Obj(),
+			},
+			expectedSuccess: false,
 		},
 	}
 
@@ -163,27 +313,213 @@ func TestPreFilter(t *testing.T) {
 			informerFactory := informers.NewSharedInformerFactory(cs, 0)
 			podInformer := informerFactory.Core().V1().Pods()
 
+			pgMgr := &PodGroupManager{
+				client:                    client,
+				statusWriter:              newPodGroupStatusWriter(client),
+				pgLister:                  tu.NewFakePodGroupInformer(tt.pgs...).Lister(),
+				snapshotSharedLister:      tu.NewFakeSharedLister(tt.pendingPods, nodes),
+				podLister:                 podInformer.Lister(),
+				scheduleTimeout:           &scheduleTimeout,
+				permittedPG:               newCache(),
+				backedOffPG:               newCache(),
+				topologyDomains:           newCache(),
+				enablePlacementSimulation: tt.enablePlacementSimulation,
+			}
+
+			informerFactory.Start(ctx.Done())
+			if !clicache.WaitForCacheSync(ctx.Done(), podInformer.Informer().HasSynced) {
+				t.Fatal("WaitForCacheSync failed")
+			}
+			for _, p := range tt.pendingPods {
+				podInformer.Informer().GetStore().Add(p)
+			}
+
+			err = pgMgr.PreFilter(ctx, tt.pod)
+			if (err == nil) != tt.expectedSuccess {
+				t.Errorf("Want %v, but got %v", tt.expectedSuccess, err == nil)
+			}
+			if len(tt.expectedConditions) != 0 {
+				writePodGroupStatus(ctx, pgMgr.statusWriter)
+				pg := &v1alpha1.PodGroup{}
+				if err := client.Get(ctx, types.NamespacedName{Namespace: tt.pod.Namespace, Name: util.GetPodGroupLabel(tt.pod)}, pg); err != nil {
+					t.Fatal(err)
+				}
+				for conditionType, status := range tt.expectedConditions {
+					if !meta.IsStatusConditionPresentAndEqual(pg.Status.Conditions, conditionType, status) {
+						t.Errorf("Want condition %v to be %v, but got %v", conditionType, status, pg.Status.Conditions)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPreFilterConditionNotPatchedPerPod(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scheduleTimeout := 10 * time.Second
+
+	pods := []*corev1.Pod{
+		st.MakePod().Name("p1").Namespace("ns").UID("p1").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+		st.MakePod().Name("p2").Namespace("ns").UID("p2").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+	}
+	client, err := tu.NewFakeClient(pods[0], pods[1], tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(3).Obj())
+	if err != nil {
+		t.Fatal(err)
+	}
+	podInformer := informers.NewSharedInformerFactory(clientsetfake.NewSimpleClientset(), 0).Core().V1().Pods()
+	for _, p := range pods {
+		podInformer.Informer().GetStore().Add(p)
+	}
+
+	resourceVersion := ""
+	for i, pod := range pods {
+		pg := &v1alpha1.PodGroup{}
+		if err := client.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "pg1"}, pg); err != nil {
+			t.Fatal(err)
+		}
+		pgMgr := &PodGroupManager{
+			client:          client,
+			statusWriter:    newPodGroupStatusWriter(client),
+			pgLister:        tu.NewFakePodGroupInformer(pg).Lister(),
+			podLister:       podInformer.Lister(),
+			scheduleTimeout: &scheduleTimeout,
+			permittedPG:     newCache(),
+			backedOffPG:     newCache(),
+			topologyDomains: newCache(),
+		}
+		if err := pgMgr.PreFilter(ctx, pod); err == nil {
+			t.Fatalf("Want PreFilter of pod %v to fail", pod.Name)
+		}
+		writePodGroupStatus(ctx, pgMgr.statusWriter)
+
+		if err := client.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "pg1"}, pg); err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && pg.ResourceVersion != resourceVersion {
+			t.Errorf("PodGroup status patched again by pod %v: %v", pod.Name, pg.Status.Conditions)
+		}
+		resourceVersion = pg.ResourceVersion
+	}
+}
+
+func TestPreFilterTopology(t *testing.T) {
+	scheduleTimeout := 10 * time.Second
+	capacity := map[corev1.ResourceName]string{
+		corev1.ResourceCPU: "4",
+	}
+	// Zone "a" has 4 cpus, zone "b" has 8 cpus and node-c doesn't belong to any zone.
+	nodes := []*corev1.Node{
+		st.MakeNode().Name("node-a").Label("zone", "a").Capacity(capacity).Obj(),
+		st.MakeNode().Name("node-b1").Label("zone", "b").Capacity(capacity).Obj(),
+		st.MakeNode().Name("node-b2").Label("zone", "b").Capacity(capacity).Obj(),
+		st.MakeNode().Name("node-c").Capacity(capacity).Obj(),
+	}
+
+	tests := []struct {
+		name            string
+		pg              *v1alpha1.PodGroup
+		assignedPods    []*corev1.Pod
+		expectedSuccess bool
+		expectedDomain  *TopologyDomain
+	}{
+		{
+			name: "required constraint picks the first domain that fits",
+			pg: tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+				MinResources(map[corev1.ResourceName]string{corev1.ResourceCPU: "3"}).
+				TopologyConstraint("zone", v1alpha1.TopologyConstraintRequired).Obj(),
+			expectedSuccess: true,
+			expectedDomain:  &TopologyDomain{Key: "zone", Value: "a", Required: true},
+		},
+		{
+			name: "required constraint skips the domains that cannot fit",
+			pg: tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+				MinResources(map[corev1.ResourceName]string{corev1.ResourceCPU: "6"}).
+				TopologyConstraint("zone", "").Obj(),
+			expectedSuccess: true,
+			expectedDomain:  &TopologyDomain{Key: "zone", Value: "b", Required: true},
+		},
+		{
+			name: "required constraint fails if no domain fits",
+			pg: tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+				MinResources(map[corev1.ResourceName]string{corev1.ResourceCPU: "10"}).
+				TopologyConstraint("zone", v1alpha1.TopologyConstraintRequired).Obj(),
+			expectedSuccess: false,
+		},
+		{
+			name: "preferred constraint falls back to the cluster if no domain fits",
+			pg: tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+				MinResources(map[corev1.ResourceName]string{corev1.ResourceCPU: "10"}).
+				TopologyConstraint("zone", v1alpha1.TopologyConstraintPreferred).Obj(),
+			expectedSuccess: true,
+		},
+		{
+			name: "preferred constraint fails if the cluster cannot fit",
+			pg: tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+				MinResources(map[corev1.ResourceName]string{corev1.ResourceCPU: "20"}).
+				TopologyConstraint("zone", v1alpha1.TopologyConstraintPreferred).Obj(),
+			expectedSuccess: false,
+		},
+		{
+			name: "assigned members pin the domain",
+			pg: tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+				MinResources(map[corev1.ResourceName]string{corev1.ResourceCPU: "3"}).
+				TopologyConstraint("zone", v1alpha1.TopologyConstraintRequired).Obj(),
+			assignedPods: []*corev1.Pod{
+				st.MakePod().Name("p0").Namespace("ns").UID("p0").Label(v1alpha1.PodGroupLabel, "pg1").Node("node-b2").Obj(),
+			},
+			expectedSuccess: true,
+			expectedDomain:  &TopologyDomain{Key: "zone", Value: "b", Required: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			pod := st.MakePod().Name("p1").Namespace("ns").UID("p1").Label(v1alpha1.PodGroupLabel, "pg1").Obj()
+			pendingPods := []*corev1.Pod{
+				pod,
+				st.MakePod().Name("p2").Namespace("ns").UID("p2").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			}
+
+			client, err := tu.NewFakeClient(tt.pg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cs := clientsetfake.NewSimpleClientset()
+			informerFactory := informers.NewSharedInformerFactory(cs, 0)
+			podInformer := informerFactory.Core().V1().Pods()
+
 			pgMgr := &PodGroupManager{
 				client:               client,
-				snapshotSharedLister: tu.NewFakeSharedLister(tt.pendingPods, nodes),
+				statusWriter:         newPodGroupStatusWriter(client),
+				pgLister:             tu.NewFakePodGroupInformer(tt.pg).Lister(),
+				snapshotSharedLister: tu.NewFakeSharedLister(tt.assignedPods, nodes),
 				podLister:            podInformer.Lister(),
 				scheduleTimeout:      &scheduleTimeout,
 				permittedPG:          newCache(),
 				backedOffPG:          newCache(),
+				topologyDomains:      newCache(),
 			}
 
 			informerFactory.Start(ctx.Done())
 			if !clicache.WaitForCacheSync(ctx.Done(), podInformer.Informer().HasSynced) {
 				t.Fatal("WaitForCacheSync failed")
 			}
-			for _, p := range tt.pendingPods {
+			for _, p := range append(pendingPods, tt.assignedPods...) {
 				podInformer.Informer().GetStore().Add(p)
 			}
 
-			err = pgMgr.PreFilter(ctx, tt.pod)
+			err = pgMgr.PreFilter(ctx, pod)
 			if (err == nil) != tt.expectedSuccess {
 				t.Errorf("Want %v, but got %v", tt.expectedSuccess, err == nil)
 			}
+			if got := pgMgr.GetTopologyDomain("ns/pg1"); !reflect.DeepEqual(got, tt.expectedDomain) {
+				t.Errorf("Want topology domain %v, but got %v", tt.expectedDomain, got)
+			}
 		})
 	}
 }
@@ -239,6 +575,55 @@ func TestPermit(t *testing.T) {
 			},
 			want: Success,
 		},
+		{
+			name: "pod belongs to a pg that have quorum satisfied, but not the quorum of a role",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Obj(),
+			existingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Node("node").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+					Role("ps", map[string]string{"role": "ps"}, 1, nil).
+					Role("worker", map[string]string{"role": "worker"}, 1, nil).Obj(),
+			},
+			want: Wait,
+		},
+		{
+			name: "pod belongs to a pg that have the quorum of every role satisfied",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "ps").Obj(),
+			existingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Node("node").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+					Role("ps", map[string]string{"role": "ps"}, 1, nil).
+					Role("worker", map[string]string{"role": "worker"}, 1, nil).Obj(),
+			},
+			want: Success,
+		},
+		{
+			name: "pod belongs to an elastic pg that have quorum satisfied",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			existingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Node("node").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(1).MaxMember(2).Obj(),
+			},
+			want: Success,
+		},
+		{
+			name: "pod belongs to an elastic pg that have maxMember pods assigned",
+			pod:  st.MakePod().Name("p1a").Namespace("ns").UID("p1a").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			existingPods: []*corev1.Pod{
+				st.MakePod().Name("p1b").Namespace("ns").UID("p1b").Label(v1alpha1.PodGroupLabel, "pg1").Node("node").Obj(),
+				st.MakePod().Name("p1c").Namespace("ns").UID("p1c").Label(v1alpha1.PodGroupLabel, "pg1").Node("node").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(1).MaxMember(2).Obj(),
+			},
+			want: PodGroupFull,
+		},
 	}
 
 	for _, tt := range tests {
@@ -266,6 +651,8 @@ func TestPermit(t *testing.T) {
 
 			pgMgr := &PodGroupManager{
 				client:               client,
+				statusWriter:         newPodGroupStatusWriter(client),
+				pgLister:             tu.NewFakePodGroupInformer(tt.pgs...).Lister(),
 				snapshotSharedLister: tu.NewFakeSharedLister(tt.existingPods, nodes),
 				podLister:            podInformer.Lister(),
 				scheduleTimeout:      &scheduleTimeout,
@@ -286,6 +673,101 @@ func TestPermit(t *testing.T) {
 	}
 }
 
+func TestBackoffPodGroup(t *testing.T) {
+	ctx := context.Background()
+	pg := tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(1).Obj()
+	client, err := tu.NewFakeClient(pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgMgr := &PodGroupManager{client: client, statusWriter: newPodGroupStatusWriter(client), backedOffPG: newCache()}
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		if got := pgMgr.BackoffPodGroup(ctx, pg, time.Second, 5*time.Second); got != want {
+			t.Errorf("Want backoff %v, but got %v", want, got)
+		}
+		pgMgr.backedOffPG.Delete("ns/pg1")
+		writePodGroupStatus(ctx, pgMgr.statusWriter)
+
+		got := &v1alpha1.PodGroup{}
+		if err := client.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "pg1"}, got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Status.BackoffCount != int32(i+1) || !isBackedOff(got) {
+			t.Errorf("Want the podGroup to be backed off %v times, but got %v until %v", i+1, got.Status.BackoffCount, got.Status.BackoffUntil)
+		}
+	}
+
+	pgMgr.resetPodGroupBackoff(ctx, pg)
+	writePodGroupStatus(ctx, pgMgr.statusWriter)
+	got := &v1alpha1.PodGroup{}
+	if err := client.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "pg1"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.BackoffCount != 0 || got.Status.BackoffUntil != nil {
+		t.Errorf("Want the backoff of the podGroup to be reset, but got %v until %v", got.Status.BackoffCount, got.Status.BackoffUntil)
+	}
+}
+
+func TestGetBackoffDuration(t *testing.T) {
+	tests := []struct {
+		name       string
+		count      int32
+		backoff    time.Duration
+		maxBackoff time.Duration
+		want       time.Duration
+	}{
+		{
+			name:       "first backoff",
+			backoff:    10 * time.Second,
+			maxBackoff: time.Minute,
+			want:       10 * time.Second,
+		},
+		{
+			name:       "backoff doubles",
+			count:      2,
+			backoff:    10 * time.Second,
+			maxBackoff: time.Minute,
+			want:       40 * time.Second,
+		},
+		{
+			name:       "backoff is capped",
+			count:      3,
+			backoff:    10 * time.Second,
+			maxBackoff: time.Minute,
+			want:       time.Minute,
+		},
+		{
+			name:       "backoff is capped after many attempts",
+			count:      1000,
+			backoff:    10 * time.Second,
+			maxBackoff: time.Minute,
+			want:       time.Minute,
+		},
+		{
+			name:    "backoff doesn't grow without maxBackoff",
+			count:   3,
+			backoff: 10 * time.Second,
+			want:    10 * time.Second,
+		},
+		{
+			name:       "backoff doesn't grow with a smaller maxBackoff",
+			count:      3,
+			backoff:    10 * time.Second,
+			maxBackoff: time.Second,
+			want:       10 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getBackoffDuration(tt.count, tt.backoff, tt.maxBackoff); got != tt.want {
+				t.Errorf("Want %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestCheckClusterResource(t *testing.T) {
 	capacity := map[corev1.ResourceName]string{
 		corev1.ResourceCPU: "3",
@@ -360,3 +842,155 @@ func TestCheckClusterResource(t *testing.T) {
 func newCache() *gocache.Cache {
 	return gocache.New(10*time.Second, 10*time.Second)
 }
+
+func TestGetQueueSortKey(t *testing.T) {
+	now := time.Now()
+	ts := now.Add(time.Hour)
+	pgInformer := tu.NewFakePodGroupInformer(tu.MakePodGroup().Name("pg1").Namespace("ns").Time(now).Obj())
+	pgMgr := &PodGroupManager{pgLister: pgInformer.Lister()}
+
+	pod := st.MakePod().Name("p").Namespace("ns").Obj()
+	if got := pgMgr.GetQueueSortKey(pod, ts); !got.CreationTimestamp.Equal(ts) || got.Name != "ns/p" {
+		t.Errorf("Want %v and %v, but got %v and %v", ts, "ns/p", got.CreationTimestamp, got.Name)
+	}
+
+	pod = st.MakePod().Name("p1").Namespace("ns").Label(v1alpha1.PodGroupLabel, "pg1").Obj()
+	if got := pgMgr.GetQueueSortKey(pod, ts); !got.CreationTimestamp.Equal(now) || got.Name != "ns/p1" || got.PodGroup != "ns/pg1" {
+		t.Errorf("Want %v, %v and %v, but got %v, %v and %v", now, "ns/p1", "ns/pg1", got.CreationTimestamp, got.Name, got.PodGroup)
+	}
+
+	// The key of a pod whose podGroup doesn't exist yet is computed again once the podGroup is created.
+	pod = st.MakePod().Name("p2").Namespace("ns").Label(v1alpha1.PodGroupLabel, "pg2").Obj()
+	if got := pgMgr.GetQueueSortKey(pod, ts); !got.CreationTimestamp.Equal(ts) {
+		t.Errorf("Want %v, but got %v", ts, got.CreationTimestamp)
+	}
+	pg2 := tu.MakePodGroup().Name("pg2").Namespace("ns").Time(now.Add(time.Second)).Obj()
+	pgInformer.Informer().GetStore().Add(pg2)
+	if got := pgMgr.GetQueueSortKey(pod, ts); !got.CreationTimestamp.Equal(pg2.CreationTimestamp.Time) {
+		t.Errorf("Want %v, but got %v", pg2.CreationTimestamp.Time, got.CreationTimestamp)
+	}
+
+	// The key of a pod is computed again once its podGroup is recreated.
+	pgInformer.Informer().GetStore().Update(tu.MakePodGroup().Name("pg2").Namespace("ns").Time(now.Add(2 * time.Second)).Obj())
+	if got := pgMgr.GetQueueSortKey(pod, ts); !got.CreationTimestamp.Equal(pg2.CreationTimestamp.Time) {
+		t.Errorf("Want the cached %v, but got %v", pg2.CreationTimestamp.Time, got.CreationTimestamp)
+	}
+	pgMgr.deleteQueueSortKeys("ns/pg2")
+	if got := pgMgr.GetQueueSortKey(pod, ts); !got.CreationTimestamp.Equal(now.Add(2 * time.Second)) {
+		t.Errorf("Want %v, but got %v", now.Add(2*time.Second), got.CreationTimestamp)
+	}
+}
+
+func TestFairQueue(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		tenant  config.QueueSortTenant
+		weights map[string]int64
+		pgs     []*v1alpha1.PodGroup
+		// pods are added to the queue in this order, and sorted afterwards.
+		pods []*corev1.Pod
+		want []string
+	}{
+		{
+			name:   "podGroups of different namespaces are interleaved",
+			tenant: config.NamespaceQueueSortTenant,
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns1").Time(now).Obj(),
+				tu.MakePodGroup().Name("pg2").Namespace("ns1").Time(now.Add(time.Second)).Obj(),
+				tu.MakePodGroup().Name("pg3").Namespace("ns1").Time(now.Add(2 * time.Second)).Obj(),
+				tu.MakePodGroup().Name("pg4").Namespace("ns2").Time(now.Add(3 * time.Second)).Obj(),
+			},
+			pods: []*corev1.Pod{
+				st.MakePod().Name("p1").Namespace("ns1").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+				st.MakePod().Name("p2").Namespace("ns1").Label(v1alpha1.PodGroupLabel, "pg2").Obj(),
+				st.MakePod().Name("p3").Namespace("ns1").Label(v1alpha1.PodGroupLabel, "pg3").Obj(),
+				st.MakePod().Name("p4").Namespace("ns1").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+				st.MakePod().Name("p5").Namespace("ns2").Label(v1alpha1.PodGroupLabel, "pg4").Obj(),
+				st.MakePod().Name("p6").Namespace("ns2").Label(v1alpha1.PodGroupLabel, "pg4").Obj(),
+			},
+			want: []string{"ns1/p1", "ns1/p4", "ns2/p5", "ns2/p6", "ns1/p2", "ns1/p3"},
+		},
+		{
+			name:    "podGroups of a heavier namespace are sorted first",
+			tenant:  config.NamespaceQueueSortTenant,
+			weights: map[string]int64{"ns2": 2},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns1").Time(now).Obj(),
+				tu.MakePodGroup().Name("pg2").Namespace("ns1").Time(now.Add(time.Second)).Obj(),
+				tu.MakePodGroup().Name("pg3").Namespace("ns2").Time(now.Add(2 * time.Second)).Obj(),
+				tu.MakePodGroup().Name("pg4").Namespace("ns2").Time(now.Add(3 * time.Second)).Obj(),
+				tu.MakePodGroup().Name("pg5").Namespace("ns2").Time(now.Add(4 * time.Second)).Obj(),
+			},
+			pods: []*corev1.Pod{
+				st.MakePod().Name("p1").Namespace("ns1").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+				st.MakePod().Name("p2").Namespace("ns1").Label(v1alpha1.PodGroupLabel, "pg2").Obj(),
+				st.MakePod().Name("p3").Namespace("ns2").Label(v1alpha1.PodGroupLabel, "pg3").Obj(),
+				st.MakePod().Name("p4").Namespace("ns2").Label(v1alpha1.PodGroupLabel, "pg4").Obj(),
+				st.MakePod().Name("p5").Namespace("ns2").Label(v1alpha1.PodGroupLabel, "pg5").Obj(),
+			},
+			want: []string{"ns2/p3", "ns1/p1", "ns2/p4", "ns2/p5", "ns1/p2"},
+		},
+		{
+			name:   "podGroups of different queues are interleaved",
+			tenant: config.QueueNameQueueSortTenant,
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").Time(now).Label(v1alpha1.PodGroupQueueLabel, "q1").Obj(),
+				tu.MakePodGroup().Name("pg2").Namespace("ns").Time(now.Add(time.Second)).Label(v1alpha1.PodGroupQueueLabel, "q1").Obj(),
+				tu.MakePodGroup().Name("pg3").Namespace("ns").Time(now.Add(2*time.Second)).Label(v1alpha1.PodGroupQueueLabel, "q2").Obj(),
+			},
+			pods: []*corev1.Pod{
+				st.MakePod().Name("p1").Namespace("ns").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+				st.MakePod().Name("p2").Namespace("ns").Label(v1alpha1.PodGroupLabel, "pg2").Obj(),
+				st.MakePod().Name("p3").Namespace("ns").Label(v1alpha1.PodGroupLabel, "pg3").Obj(),
+			},
+			want: []string{"ns/p1", "ns/p3", "ns/p2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pgMgr := &PodGroupManager{
+				pgLister:  tu.NewFakePodGroupInformer(tt.pgs...).Lister(),
+				fairQueue: NewFairQueue(tt.tenant, tt.weights),
+			}
+			keys := make([]QueueSortKey, 0, len(tt.pods))
+			for _, pod := range tt.pods {
+				keys = append(keys, pgMgr.GetQueueSortKey(pod, now))
+			}
+			sort.Slice(keys, func(i, j int) bool { return keys[i].Less(&keys[j]) })
+			var got []string
+			for _, key := range keys {
+				got = append(got, key.Name)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Unexpected order (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFairQueueVirtualTime(t *testing.T) {
+	q := NewFairQueue(config.NamespaceQueueSortTenant, nil)
+	if got := q.finishTime("ns1/pg1", "ns1"); got != 1 {
+		t.Errorf("Want 1, but got %v", got)
+	}
+	if got := q.finishTime("ns1/pg2", "ns1"); got != 2 {
+		t.Errorf("Want 2, but got %v", got)
+	}
+	// A podGroup keeps its stamp across scheduling attempts.
+	if got := q.finishTime("ns1/pg1", "ns1"); got != 1 {
+		t.Errorf("Want 1, but got %v", got)
+	}
+	// A tenant that shows up late starts from the current virtual time, rather than
+	// catching up with the tenants that have been waiting.
+	q.dequeue("ns1/pg1")
+	q.dequeue("ns1/pg2")
+	if got := q.finishTime("ns2/pg3", "ns2"); got != 2 {
+		t.Errorf("Want 2, but got %v", got)
+	}
+	q.delete("ns1/pg1")
+	if got := q.finishTime("ns1/pg1", "ns1"); got != 3 {
+		t.Errorf("Want 3, but got %v", got)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"math"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/scheduler-plugins/apis/config"
+	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	"sigs.k8s.io/scheduler-plugins/pkg/util"
+)
+
+// FairQueue orders the podGroups of different tenants, i.e. namespaces or queues, fairly in the
+// scheduling queue, following start-time fair queuing. Each podGroup is stamped with a virtual
+// start time, the later of the current virtual time and the virtual finish time of the previous
+// podGroup of its tenant, and with a virtual finish time, its virtual start time plus the inverse
+// of the weight of its tenant. PodGroups are sorted by their virtual finish time, and the virtual
+// time advances to the virtual start time of the podGroups that leave the queue. A podGroup is
+// only stamped once, so that it keeps its place in the queue across scheduling attempts.
+// Pods that don't belong to any podGroup are stamped as podGroups of their own.
+type FairQueue struct {
+	tenant  config.QueueSortTenant
+	weights map[string]int64
+
+	sync.Mutex
+	// virtualTime is the latest virtual start time of the podGroups that left the queue.
+	virtualTime float64
+	// finishTimes stores the virtual finish time of the last podGroup of each tenant.
+	finishTimes map[string]float64
+	// stamps stores the virtual start and finish times of each podGroup.
+	stamps map[string]virtualStamp
+}
+
+type virtualStamp struct {
+	start  float64
+	finish float64
+}
+
+// NewFairQueue creates a FairQueue whose tenants are given by <tenant>. Tenants that are not
+// in <weights> have a weight of 1; all the tenants have a weight of 1 if <weights> is nil.
+func NewFairQueue(tenant config.QueueSortTenant, weights map[string]int64) *FairQueue {
+	return &FairQueue{
+		tenant:      tenant,
+		weights:     weights,
+		finishTimes: make(map[string]float64),
+		stamps:      make(map[string]virtualStamp),
+	}
+}
+
+// getTenant returns the tenant of a pod, given the podGroup it belongs to, if any.
+func (q *FairQueue) getTenant(pod *corev1.Pod, pg *v1alpha1.PodGroup) string {
+	if q.tenant == config.QueueNameQueueSortTenant {
+		if pg == nil {
+			return ""
+		}
+		return pg.Labels[v1alpha1.PodGroupQueueLabel]
+	}
+	return pod.Namespace
+}
+
+// finishTime returns the virtual finish time of a podGroup of the given tenant, stamping it first if needed.
+func (q *FairQueue) finishTime(name, tenant string) float64 {
+	q.Lock()
+	defer q.Unlock()
+	if stamp, ok := q.stamps[name]; ok {
+		return stamp.finish
+	}
+	weight := int64(1)
+	if w, ok := q.weights[tenant]; ok {
+		weight = w
+	}
+	start := math.Max(q.virtualTime, q.finishTimes[tenant])
+	stamp := virtualStamp{start: start, finish: start + 1/float64(weight)}
+	q.stamps[name] = stamp
+	q.finishTimes[tenant] = stamp.finish
+	return stamp.finish
+}
+
+// dequeue advances the virtual time to the virtual start time of a podGroup that leaves the queue.
+func (q *FairQueue) dequeue(name string) {
+	q.Lock()
+	defer q.Unlock()
+	if stamp, ok := q.stamps[name]; ok && stamp.start > q.virtualTime {
+		q.virtualTime = stamp.start
+	}
+}
+
+// delete drops the stamps of a podGroup.
+func (q *FairQueue) delete(name string) {
+	q.Lock()
+	defer q.Unlock()
+	delete(q.stamps, name)
+}
+
+// getQueueSortGroup returns the name a pod is stamped with in the FairQueue:
+// the full name of its podGroup, or its own namespaced name.
+func getQueueSortGroup(pod *corev1.Pod) string {
+	if pgFullName := util.GetPodGroupFullName(pod); pgFullName != "" {
+		return pgFullName
+	}
+	return GetNamespacedName(pod)
+}
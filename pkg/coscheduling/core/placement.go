@@ -0,0 +1,154 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/api/v1/resource"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/noderesources"
+
+	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	"sigs.k8s.io/scheduler-plugins/pkg/util"
+)
+
+// SimulatePlacement checks if the pending members of a PodGroup can be bin-packed onto <nodeList>,
+// so that together with the members that have been assigned nodes, the minMember of the PodGroup
+// and of each of its roles is satisfied. Each pending member is placed with its actual request
+// onto a copy of the first node that fits it, honouring its node selector, required node affinity
+// and tolerations; the members with the largest dominant share of the resources of the nodes are
+// placed first. Pods that are not members of the PodGroup and constraints such as inter-pod
+// affinity are not taken into account.
+// It returns an error if the PodGroup cannot be placed; otherwise returns nil.
+func SimulatePlacement(nodeList []*framework.NodeInfo, pg *v1alpha1.PodGroup, siblings []*corev1.Pod) error {
+	// Members that have been assigned nodes (assumed or bound) are taken from the snapshot, as
+	// the assumed ones are not visible as such to the pod lister.
+	pgFullName := GetNamespacedName(pg)
+	var assigned, pending []*corev1.Pod
+	assignedUIDs := sets.New[types.UID]()
+	for _, info := range nodeList {
+		if info == nil {
+			continue
+		}
+		for _, podInfo := range info.Pods {
+			if util.GetPodGroupFullName(podInfo.Pod) == pgFullName && podInfo.Pod.Spec.NodeName != "" {
+				assigned = append(assigned, podInfo.Pod)
+				assignedUIDs.Insert(podInfo.Pod.UID)
+			}
+		}
+	}
+	if satisfied(pg, assigned) {
+		return nil
+	}
+	for _, pod := range siblings {
+		if pod.DeletionTimestamp == nil && pod.Spec.NodeName == "" && !assignedUIDs.Has(pod.UID) {
+			pending = append(pending, pod)
+		}
+	}
+
+	nodeInfos := make([]*framework.NodeInfo, 0, len(nodeList))
+	total := &framework.Resource{}
+	for _, info := range nodeList {
+		if info == nil || info.Node() == nil || info.Node().Spec.Unschedulable {
+			continue
+		}
+		nodeInfos = append(nodeInfos, info.Snapshot())
+		total.Add(info.Node().Status.Allocatable)
+	}
+
+	shares := make(map[*corev1.Pod]float64, len(pending))
+	for _, pod := range pending {
+		shares[pod] = dominantShare(framework.NewResource(resource.PodRequests(pod, resource.PodResourcesOptions{})), total)
+	}
+	// Place the larger members first, which tends to pack better than the queue order. Members
+	// are compared by their dominant share, so that the scarce resources such as GPUs are taken
+	// into account along with CPU and memory.
+	sort.SliceStable(pending, func(i, j int) bool {
+		return shares[pending[i]] > shares[pending[j]]
+	})
+
+	placed := assigned
+	for _, pod := range pending {
+		nodeInfo := findFitNode(pod, nodeInfos)
+		if nodeInfo == nil {
+			klog.V(5).InfoS("Member of PodGroup does not fit any node in the placement simulation", "podGroup", klog.KObj(pg), "pod", klog.KObj(pod))
+			continue
+		}
+		assumed := pod.DeepCopy()
+		assumed.Spec.NodeName = nodeInfo.Node().Name
+		nodeInfo.AddPod(assumed)
+		klog.V(5).InfoS("Member of PodGroup placed in the placement simulation", "podGroup", klog.KObj(pg), "pod", klog.KObj(pod), "node", klog.KObj(nodeInfo.Node()))
+		placed = append(placed, assumed)
+		if satisfied(pg, placed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("placement simulation failed: only %v of the %v members of the group fit the nodes, unsatisfied roles: %v",
+		len(placed), pg.Spec.MinMember, util.GetUnsatisfiedRoles(pg, placed))
+}
+
+// satisfied returns true if the given pods satisfy the minMember of the PodGroup and of each of its roles.
+func satisfied(pg *v1alpha1.PodGroup, pods []*corev1.Pod) bool {
+	return len(pods) >= int(pg.Spec.MinMember) && len(util.GetUnsatisfiedRoles(pg, pods)) == 0
+}
+
+// dominantShare returns the largest share of the allocatable resources of the nodes that the
+// request takes, among the resources it requests.
+func dominantShare(req, total *framework.Resource) float64 {
+	var share float64
+	addShare := func(requested, allocatable int64) {
+		if requested > 0 && allocatable > 0 {
+			share = max(share, float64(requested)/float64(allocatable))
+		}
+	}
+	addShare(req.MilliCPU, total.MilliCPU)
+	addShare(req.Memory, total.Memory)
+	addShare(req.EphemeralStorage, total.EphemeralStorage)
+	for name, quantity := range req.ScalarResources {
+		addShare(quantity, total.ScalarResources[name])
+	}
+	return share
+}
+
+// findFitNode returns the first node that the pod fits on, or nil if there is none.
+func findFitNode(pod *corev1.Pod, nodeInfos []*framework.NodeInfo) *framework.NodeInfo {
+	affinity := nodeaffinity.GetRequiredNodeAffinity(pod)
+	for _, nodeInfo := range nodeInfos {
+		node := nodeInfo.Node()
+		if match, _ := affinity.Match(node); !match {
+			continue
+		}
+		if _, untolerated := corev1helpers.FindMatchingUntoleratedTaint(node.Spec.Taints, pod.Spec.Tolerations, func(t *corev1.Taint) bool {
+			return t.Effect == corev1.TaintEffectNoSchedule || t.Effect == corev1.TaintEffectNoExecute
+		}); untolerated {
+			continue
+		}
+		if len(noderesources.Fits(pod, nodeInfo)) != 0 {
+			continue
+		}
+		return nodeInfo
+	}
+	return nil
+}
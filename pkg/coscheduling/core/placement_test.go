@@ -0,0 +1,186 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	st "k8s.io/kubernetes/pkg/scheduler/testing"
+
+	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	tu "sigs.k8s.io/scheduler-plugins/test/util"
+)
+
+func TestSimulatePlacement(t *testing.T) {
+	capacity := map[corev1.ResourceName]string{
+		corev1.ResourceCPU: "4",
+	}
+	nodes := []*corev1.Node{
+		st.MakeNode().Name("node-a").Label("disk", "ssd").Capacity(capacity).Obj(),
+		st.MakeNode().Name("node-b").Capacity(capacity).Obj(),
+		st.MakeNode().Name("node-c").Capacity(capacity).Taints([]corev1.Taint{
+			{Key: "dedicated", Value: "batch", Effect: corev1.TaintEffectNoSchedule},
+		}).Obj(),
+	}
+	makeMember := func(name, cpu string) *st.PodWrapper {
+		return st.MakePod().Name(name).Namespace("ns").UID(name).Label(v1alpha1.PodGroupLabel, "pg1").
+			Req(map[corev1.ResourceName]string{corev1.ResourceCPU: cpu})
+	}
+	toleration := corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "batch", Effect: corev1.TaintEffectNoSchedule}
+
+	tests := []struct {
+		name         string
+		pg           *v1alpha1.PodGroup
+		siblings     []*corev1.Pod
+		existingPods []*corev1.Pod
+		want         bool
+	}{
+		{
+			name: "members fit one per node",
+			pg:   tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(3).Obj(),
+			siblings: []*corev1.Pod{
+				makeMember("p1", "3").Obj(),
+				makeMember("p2", "3").Obj(),
+				makeMember("p3", "3").Toleration("dedicated").Obj(),
+			},
+			want: true,
+		},
+		{
+			name: "members do not fit any node although the cluster-wide sum does",
+			pg:   tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Obj(),
+			siblings: []*corev1.Pod{
+				makeMember("p1", "5").Obj(),
+				makeMember("p2", "5").Obj(),
+			},
+			want: false,
+		},
+		{
+			name: "members do not tolerate the taint",
+			pg:   tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(3).Obj(),
+			siblings: []*corev1.Pod{
+				makeMember("p1", "3").Obj(),
+				makeMember("p2", "3").Obj(),
+				makeMember("p3", "3").Obj(),
+			},
+			want: false,
+		},
+		{
+			name: "members tolerate the taint",
+			pg:   tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(3).Obj(),
+			siblings: func() []*corev1.Pod {
+				var pods []*corev1.Pod
+				for _, name := range []string{"p1", "p2", "p3"} {
+					pod := makeMember(name, "3").Obj()
+					pod.Spec.Tolerations = []corev1.Toleration{toleration}
+					pods = append(pods, pod)
+				}
+				return pods
+			}(),
+			want: true,
+		},
+		{
+			name: "members are restricted by their node selector",
+			pg:   tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(3).Obj(),
+			siblings: []*corev1.Pod{
+				makeMember("p1", "2").NodeSelector(map[string]string{"disk": "ssd"}).Obj(),
+				makeMember("p2", "2").NodeSelector(map[string]string{"disk": "ssd"}).Obj(),
+				makeMember("p3", "2").NodeSelector(map[string]string{"disk": "ssd"}).Obj(),
+			},
+			want: false,
+		},
+		{
+			name: "members beyond minMember are not required to fit",
+			pg:   tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Obj(),
+			siblings: []*corev1.Pod{
+				makeMember("p1", "3").Obj(),
+				makeMember("p2", "3").Obj(),
+				makeMember("p3", "3").Obj(),
+			},
+			want: true,
+		},
+		{
+			// p1 is assumed on node-a but not bound yet, so it is pending in the pod lister.
+			name: "members that have been assumed are not placed twice",
+			pg:   tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(3).Obj(),
+			siblings: []*corev1.Pod{
+				makeMember("p1", "3").Toleration("dedicated").Obj(),
+				makeMember("p2", "3").Toleration("dedicated").Obj(),
+			},
+			existingPods: []*corev1.Pod{
+				makeMember("p1", "3").Toleration("dedicated").Node("node-a").Obj(),
+			},
+			want: false,
+		},
+		{
+			name: "members that have been assumed count towards minMember",
+			pg:   tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Obj(),
+			siblings: []*corev1.Pod{
+				makeMember("p1", "3").Obj(),
+				makeMember("p2", "3").Obj(),
+			},
+			existingPods: []*corev1.Pod{
+				makeMember("p1", "3").Node("node-a").Obj(),
+			},
+			want: true,
+		},
+		{
+			name: "members of every role need to fit",
+			pg: tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+				Role("ps", map[string]string{"role": "ps"}, 1, nil).Obj(),
+			siblings: []*corev1.Pod{
+				makeMember("p1", "3").Obj(),
+				makeMember("p2", "3").Obj(),
+				makeMember("p3", "5").Label("role", "ps").Obj(),
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshotSharedLister := tu.NewFakeSharedLister(tt.existingPods, nodes)
+			nodeInfoList, _ := snapshotSharedLister.NodeInfos().List()
+			err := SimulatePlacement(nodeInfoList, tt.pg, tt.siblings)
+			if (err == nil) != tt.want {
+				t.Errorf("Expect the PodGroup to be placed: %v, but got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSimulatePlacementOrder(t *testing.T) {
+	// node-g is listed first, so a member placed before the GPU member takes it if it can.
+	nodes := []*corev1.Node{
+		st.MakeNode().Name("node-g").Capacity(map[corev1.ResourceName]string{corev1.ResourceCPU: "4", "nvidia.com/gpu": "1"}).Obj(),
+		st.MakeNode().Name("node-c").Capacity(map[corev1.ResourceName]string{corev1.ResourceCPU: "4"}).Obj(),
+	}
+	pg := tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Obj()
+	siblings := []*corev1.Pod{
+		st.MakePod().Name("p1").Namespace("ns").UID("p1").Label(v1alpha1.PodGroupLabel, "pg1").
+			Req(map[corev1.ResourceName]string{corev1.ResourceCPU: "3"}).Obj(),
+		// p2 requests less CPU than p1, but all the GPUs of the cluster.
+		st.MakePod().Name("p2").Namespace("ns").UID("p2").Label(v1alpha1.PodGroupLabel, "pg1").
+			Req(map[corev1.ResourceName]string{corev1.ResourceCPU: "2", "nvidia.com/gpu": "1"}).Obj(),
+	}
+
+	snapshotSharedLister := tu.NewFakeSharedLister(nil, nodes)
+	nodeInfoList, _ := snapshotSharedLister.NodeInfos().List()
+	if err := SimulatePlacement(nodeInfoList, pg, siblings); err != nil {
+		t.Errorf("Expect the PodGroup to be placed, but got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+)
+
+// maxStatusWriteRetries is the number of times the status update of a podGroup is retried
+// before it's dropped. The updates are informational, and the next one supersedes it anyway.
+const maxStatusWriteRetries = 5
+
+// podGroupStatusWriter writes the conditions and the backoff of podGroups to the API server
+// asynchronously, so that the scheduling cycle doesn't wait for them. The updates of a podGroup
+// are merged while they wait to be written, so that the podGroup is patched once for all of them,
+// and not at all if its status is unchanged.
+type podGroupStatusWriter struct {
+	// client is a generic controller-runtime client to update the status of PodGroups.
+	client client.Client
+	// queue holds the podGroups with pending updates.
+	queue workqueue.RateLimitingInterface
+	// pending stores the update waiting to be written of each podGroup in the queue.
+	pending map[types.NamespacedName]*podGroupStatusUpdate
+	sync.Mutex
+}
+
+// podGroupStatusUpdate is an update of the status of a podGroup.
+type podGroupStatusUpdate struct {
+	// conditions are the conditions to set, by type.
+	conditions map[string]metav1.Condition
+	// backoff is the backoff to record, if not nil.
+	backoff *podGroupBackoff
+}
+
+// podGroupBackoff is the backoff of a podGroup, as recorded in its status.
+type podGroupBackoff struct {
+	count int32
+	until *metav1.Time
+}
+
+func newPodGroupStatusWriter(client client.Client) *podGroupStatusWriter {
+	return &podGroupStatusWriter{
+		client:  client,
+		queue:   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		pending: make(map[types.NamespacedName]*podGroupStatusUpdate),
+	}
+}
+
+// run writes the pending updates until <ctx> is done.
+func (w *podGroupStatusWriter) run(ctx context.Context) {
+	defer w.queue.ShutDown()
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		for w.processNextItem(ctx) {
+		}
+	}, time.Second)
+	<-ctx.Done()
+}
+
+// setCondition queues the given condition to be set on the status of a podGroup, unless a
+// condition of the same type, status, reason and message is already set and no other condition
+// of this type is waiting to be written. The given podGroup is updated in place, so that the
+// rest of the scheduling cycle sees the condition.
+func (w *podGroupStatusWriter) setCondition(pg *v1alpha1.PodGroup, condition metav1.Condition) {
+	key := types.NamespacedName{Namespace: pg.Namespace, Name: pg.Name}
+	w.Lock()
+	defer w.Unlock()
+	if !w.pending[key].hasCondition(condition.Type) {
+		if c := meta.FindStatusCondition(pg.Status.Conditions, condition.Type); c != nil && c.Status == condition.Status &&
+			c.Reason == condition.Reason && c.Message == condition.Message {
+			return
+		}
+	}
+	update := w.getOrCreate(key)
+	if update.conditions == nil {
+		update.conditions = make(map[string]metav1.Condition)
+	}
+	update.conditions[condition.Type] = condition
+
+	condition.ObservedGeneration = pg.Generation
+	meta.SetStatusCondition(&pg.Status.Conditions, condition)
+	w.queue.Add(key)
+}
+
+// setBackoff queues the given backoff to be recorded in the status of a podGroup. The given
+// podGroup is updated in place.
+func (w *podGroupStatusWriter) setBackoff(pg *v1alpha1.PodGroup, backoff podGroupBackoff) {
+	key := types.NamespacedName{Namespace: pg.Namespace, Name: pg.Name}
+	w.Lock()
+	defer w.Unlock()
+	w.getOrCreate(key).backoff = &backoff
+
+	pg.Status.BackoffCount, pg.Status.BackoffUntil = backoff.count, backoff.until
+	w.queue.Add(key)
+}
+
+// getOrCreate returns the pending update of a podGroup, creating it if needed.
+// It must be called with the lock held.
+func (w *podGroupStatusWriter) getOrCreate(key types.NamespacedName) *podGroupStatusUpdate {
+	update, ok := w.pending[key]
+	if !ok {
+		update = &podGroupStatusUpdate{}
+		w.pending[key] = update
+	}
+	return update
+}
+
+// processNextItem writes the pending update of the next podGroup in the queue.
+// It returns false once the queue is shut down.
+func (w *podGroupStatusWriter) processNextItem(ctx context.Context) bool {
+	item, shutdown := w.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer w.queue.Done(item)
+	key := item.(types.NamespacedName)
+
+	w.Lock()
+	update := w.pending[key]
+	delete(w.pending, key)
+	w.Unlock()
+	if update == nil {
+		w.queue.Forget(key)
+		return true
+	}
+
+	if err := w.write(ctx, key, update); err != nil {
+		if w.queue.NumRequeues(key) < maxStatusWriteRetries {
+			klog.V(4).InfoS("Failed to update PodGroup status, retrying", "podGroup", key, "err", err)
+			w.requeue(key, update)
+			return true
+		}
+		klog.ErrorS(err, "Failed to update PodGroup status", "podGroup", key)
+	}
+	w.queue.Forget(key)
+	return true
+}
+
+// requeue merges back an update that failed to be written, unless it has been superseded
+// in the meantime, and queues the podGroup again with a delay.
+func (w *podGroupStatusWriter) requeue(key types.NamespacedName, update *podGroupStatusUpdate) {
+	w.Lock()
+	defer w.Unlock()
+	pending := w.getOrCreate(key)
+	for t, c := range update.conditions {
+		if !pending.hasCondition(t) {
+			if pending.conditions == nil {
+				pending.conditions = make(map[string]metav1.Condition)
+			}
+			pending.conditions[t] = c
+		}
+	}
+	if pending.backoff == nil {
+		pending.backoff = update.backoff
+	}
+	w.queue.AddRateLimited(key)
+}
+
+// write applies an update to the latest version of a podGroup, and patches the podGroup if its
+// status changed. The patch is guarded by the resourceVersion of the podGroup, so that the status
+// set concurrently by the controller is not overwritten.
+func (w *podGroupStatusWriter) write(ctx context.Context, key types.NamespacedName, update *podGroupStatusUpdate) error {
+	pg := &v1alpha1.PodGroup{}
+	if err := w.client.Get(ctx, key, pg); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	pgCopy := pg.DeepCopy()
+	for _, condition := range update.conditions {
+		condition.ObservedGeneration = pg.Generation
+		meta.SetStatusCondition(&pgCopy.Status.Conditions, condition)
+	}
+	if update.backoff != nil {
+		pgCopy.Status.BackoffCount, pgCopy.Status.BackoffUntil = update.backoff.count, update.backoff.until
+	}
+	if equality.Semantic.DeepEqual(pg.Status, pgCopy.Status) {
+		return nil
+	}
+	return w.client.Status().Patch(ctx, pgCopy, client.MergeFromWithOptions(pg, client.MergeFromWithOptimisticLock{}))
+}
+
+// hasCondition returns true if the update sets a condition of the given type.
+func (u *podGroupStatusUpdate) hasCondition(conditionType string) bool {
+	if u == nil {
+		return false
+	}
+	_, ok := u.conditions[conditionType]
+	return ok
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	tu "sigs.k8s.io/scheduler-plugins/test/util"
+)
+
+// writePodGroupStatus writes the pending status updates of the podGroups synchronously.
+func writePodGroupStatus(ctx context.Context, w *podGroupStatusWriter) {
+	for w.queue.Len() > 0 {
+		w.processNextItem(ctx)
+	}
+}
+
+func TestPodGroupStatusWriter(t *testing.T) {
+	ctx := context.Background()
+	client, err := tu.NewFakeClient(tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Obj())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: "ns", Name: "pg1"}
+	get := func() *v1alpha1.PodGroup {
+		pg := &v1alpha1.PodGroup{}
+		if err := client.Get(ctx, key, pg); err != nil {
+			t.Fatal(err)
+		}
+		return pg
+	}
+	unschedulable := metav1.Condition{
+		Type:    v1alpha1.PodGroupConditionScheduled,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Unschedulable",
+		Message: "the minMember pods of the PodGroup cannot be assigned",
+	}
+	scheduled := metav1.Condition{
+		Type:    v1alpha1.PodGroupConditionScheduled,
+		Status:  metav1.ConditionTrue,
+		Reason:  "QuorumScheduled",
+		Message: "the minMember pods of the PodGroup have been scheduled",
+	}
+	w := newPodGroupStatusWriter(client)
+
+	// The updates made before the podGroup is written are merged, the last one winning.
+	pg := get()
+	w.setCondition(pg, unschedulable)
+	w.setBackoff(pg, podGroupBackoff{count: 1, until: &metav1.Time{}})
+	w.setCondition(pg, scheduled)
+	if !meta.IsStatusConditionTrue(pg.Status.Conditions, v1alpha1.PodGroupConditionScheduled) || pg.Status.BackoffCount != 1 {
+		t.Errorf("Want the podGroup to be updated in place, got %v, backoff count %v", pg.Status.Conditions, pg.Status.BackoffCount)
+	}
+	if got := w.queue.Len(); got != 1 {
+		t.Errorf("Want the podGroup to be queued once, got %v", got)
+	}
+	writePodGroupStatus(ctx, w)
+	pg = get()
+	if !meta.IsStatusConditionTrue(pg.Status.Conditions, v1alpha1.PodGroupConditionScheduled) || pg.Status.BackoffCount != 1 {
+		t.Errorf("Want the merged update to be written, got %v, backoff count %v", pg.Status.Conditions, pg.Status.BackoffCount)
+	}
+
+	// A condition that is already set is not written again.
+	resourceVersion := pg.ResourceVersion
+	w.setCondition(pg, scheduled)
+	if got := w.queue.Len(); got != 0 {
+		t.Errorf("Want an unchanged condition not to be queued, got %v", got)
+	}
+
+	// A condition set on a stale copy of the podGroup is written over its latest version, and
+	// the podGroup is not patched if its status is unchanged.
+	stale := pg.DeepCopy()
+	stale.Status.Conditions = nil
+	w.setCondition(stale, scheduled)
+	writePodGroupStatus(ctx, w)
+	if pg = get(); pg.ResourceVersion != resourceVersion {
+		t.Errorf("Want an unchanged status not to be patched, got %v", pg.Status.Conditions)
+	}
+
+	// Setting a condition back while the previous one is pending overrides it.
+	w.setCondition(pg, unschedulable)
+	w.setCondition(pg, scheduled)
+	writePodGroupStatus(ctx, w)
+	if pg = get(); !meta.IsStatusConditionTrue(pg.Status.Conditions, v1alpha1.PodGroupConditionScheduled) {
+		t.Errorf("Want the last condition to be written, got %v", pg.Status.Conditions)
+	}
+}
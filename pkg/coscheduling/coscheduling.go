@@ -22,9 +22,12 @@ import (
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientscheme "k8s.io/client-go/kubernetes/scheme"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	policylisters "k8s.io/client-go/listers/policy/v1"
 	"k8s.io/client-go/tools/cache"
 	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
 	"k8s.io/klog/v2"
@@ -32,9 +35,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"sigs.k8s.io/scheduler-plugins/apis/config"
+	"sigs.k8s.io/scheduler-plugins/apis/config/validation"
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling"
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
 	"sigs.k8s.io/scheduler-plugins/pkg/coscheduling/core"
+	pgclientset "sigs.k8s.io/scheduler-plugins/pkg/generated/clientset/versioned"
+	pgformers "sigs.k8s.io/scheduler-plugins/pkg/generated/informers/externalversions"
 	"sigs.k8s.io/scheduler-plugins/pkg/util"
 )
 
@@ -44,10 +50,17 @@ type Coscheduling struct {
 	pgMgr            core.Manager
 	scheduleTimeout  *time.Duration
 	pgBackoff        *time.Duration
+	pgMaxBackoff     time.Duration
+	// enablePreemption enables PodGroup-aware preemption in PostFilter.
+	enablePreemption bool
+	podLister        corelisters.PodLister
+	pdbLister        policylisters.PodDisruptionBudgetLister
 }
 
 var _ framework.QueueSortPlugin = &Coscheduling{}
 var _ framework.PreFilterPlugin = &Coscheduling{}
+var _ framework.FilterPlugin = &Coscheduling{}
+var _ framework.ScorePlugin = &Coscheduling{}
 var _ framework.PostFilterPlugin = &Coscheduling{}
 var _ framework.PermitPlugin = &Coscheduling{}
 var _ framework.ReservePlugin = &Coscheduling{}
@@ -57,14 +70,28 @@ var _ framework.EnqueueExtensions = &Coscheduling{}
 const (
 	// Name is the name of the plugin used in Registry and configurations.
 	Name = "Coscheduling"
+
+	topologyStateKey = Name + "/topology"
 )
 
+// topologyState is the topology domain of the PodGroup computed at PreFilter.
+type topologyState struct {
+	domain *core.TopologyDomain
+}
+
+func (s *topologyState) Clone() framework.StateData {
+	return s
+}
+
 // New initializes and returns a new Coscheduling plugin.
-func New(_ context.Context, obj runtime.Object, handle framework.Handle) (framework.Plugin, error) {
+func New(ctx context.Context, obj runtime.Object, handle framework.Handle) (framework.Plugin, error) {
 	args, ok := obj.(*config.CoschedulingArgs)
 	if !ok {
 		return nil, fmt.Errorf("want args to be of type CoschedulingArgs, got %T", obj)
 	}
+	if err := validation.ValidateCoschedulingArgs(nil, args); err != nil {
+		return nil, err
+	}
 
 	scheme := runtime.NewScheme()
 	_ = clientscheme.AddToScheme(scheme)
@@ -75,21 +102,54 @@ func New(_ context.Context, obj runtime.Object, handle framework.Handle) (framew
 		return nil, err
 	}
 
+	// PodGroups are read from the informer cache, as they are looked up in the hot path.
+	pgClient, err := pgclientset.NewForConfig(handle.KubeConfig())
+	if err != nil {
+		return nil, err
+	}
+	pgInformerFactory := pgformers.NewSharedInformerFactory(pgClient, 0)
+	pgInformer := pgInformerFactory.Scheduling().V1alpha1().PodGroups()
+
 	// Performance improvement when retrieving list of objects by namespace or we'll log 'index not exist' warning.
 	handle.SharedInformerFactory().Core().V1().Pods().Informer().AddIndexers(cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
+	// The args built in code are not defaulted: an empty policy orders the PodGroups by creation time,
+	// like CreationTime, and an empty tenant groups them by namespace.
+	var fairQueue *core.FairQueue
+	switch args.QueueSortPolicy {
+	case config.RoundRobinQueueSort:
+		fairQueue = core.NewFairQueue(args.QueueSortTenant, nil)
+	case config.WeightedFairShareQueueSort:
+		fairQueue = core.NewFairQueue(args.QueueSortTenant, args.QueueSortWeights)
+	}
+
 	scheduleTimeDuration := time.Duration(args.PermitWaitingTimeSeconds) * time.Second
 	pgMgr := core.NewPodGroupManager(
 		client,
 		handle.SnapshotSharedLister(),
 		&scheduleTimeDuration,
+		pgInformer,
 		// Keep the podInformer (from frameworkHandle) as the single source of Pods.
 		handle.SharedInformerFactory().Core().V1().Pods(),
+		args.EnablePlacementSimulation,
+		fairQueue,
 	)
+	pgInformerFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), pgInformer.Informer().HasSynced) {
+		err := fmt.Errorf("WaitForCacheSync failed")
+		klog.ErrorS(err, "Cannot sync caches")
+		return nil, err
+	}
+	go pgMgr.Run(ctx)
 	plugin := &Coscheduling{
 		frameworkHandler: handle,
 		pgMgr:            pgMgr,
 		scheduleTimeout:  &scheduleTimeDuration,
+		enablePreemption: args.EnablePreemption,
+		podLister:        handle.SharedInformerFactory().Core().V1().Pods().Lister(),
+	}
+	if args.EnablePreemption {
+		plugin.pdbLister = handle.SharedInformerFactory().Policy().V1().PodDisruptionBudgets().Lister()
 	}
 	if args.PodGroupBackoffSeconds < 0 {
 		err := fmt.Errorf("parse arguments failed")
@@ -98,6 +158,7 @@ func New(_ context.Context, obj runtime.Object, handle framework.Handle) (framew
 	} else if args.PodGroupBackoffSeconds > 0 {
 		pgBackoff := time.Duration(args.PodGroupBackoffSeconds) * time.Second
 		plugin.pgBackoff = &pgBackoff
+		plugin.pgMaxBackoff = time.Duration(args.PodGroupMaxBackoffSeconds) * time.Second
 	}
 	return plugin, nil
 }
@@ -109,6 +170,7 @@ func (cs *Coscheduling) EventsToRegister() []framework.ClusterEventWithHint {
 	return []framework.ClusterEventWithHint{
 		{Event: framework.ClusterEvent{Resource: framework.Pod, ActionType: framework.Add}},
 		{Event: framework.ClusterEvent{Resource: framework.GVK(pgGVK), ActionType: framework.Add | framework.Update}},
+		{Event: framework.ClusterEvent{Resource: framework.Node, ActionType: framework.Add | framework.UpdateNodeLabel}},
 	}
 }
 
@@ -127,28 +189,82 @@ func (cs *Coscheduling) Less(podInfo1, podInfo2 *framework.QueuedPodInfo) bool {
 	if prio1 != prio2 {
 		return prio1 > prio2
 	}
-	creationTime1 := cs.pgMgr.GetCreationTimestamp(podInfo1.Pod, *podInfo1.InitialAttemptTimestamp)
-	creationTime2 := cs.pgMgr.GetCreationTimestamp(podInfo2.Pod, *podInfo2.InitialAttemptTimestamp)
-	if creationTime1.Equal(creationTime2) {
-		return core.GetNamespacedName(podInfo1.Pod) < core.GetNamespacedName(podInfo2.Pod)
-	}
-	return creationTime1.Before(creationTime2)
+	key1 := cs.pgMgr.GetQueueSortKey(podInfo1.Pod, *podInfo1.InitialAttemptTimestamp)
+	key2 := cs.pgMgr.GetQueueSortKey(podInfo2.Pod, *podInfo2.InitialAttemptTimestamp)
+	return key1.Less(&key2)
 }
 
 // PreFilter performs the following validations.
 // 1. Whether the PodGroup that the Pod belongs to is on the deny list.
 // 2. Whether the total number of pods in a PodGroup is less than its `minMember`.
+// 3. Whether a topology domain can hold the PodGroup if it has a required topology constraint.
 func (cs *Coscheduling) PreFilter(ctx context.Context, state *framework.CycleState, pod *v1.Pod) (*framework.PreFilterResult, *framework.Status) {
 	// If PreFilter fails, return framework.UnschedulableAndUnresolvable to avoid
-	// any preemption attempts.
-	if err := cs.pgMgr.PreFilter(ctx, pod); err != nil {
-		klog.ErrorS(err, "PreFilter failed", "pod", klog.KObj(pod))
-		return nil, framework.NewStatus(framework.UnschedulableAndUnresolvable, err.Error())
+	// any preemption attempts. The PodGroup checks already passed for the preemptor
+	// when its siblings are simulated during the preemption.
+	if !isPreemptionSimulation(state) {
+		if err := cs.pgMgr.PreFilter(ctx, pod); err != nil {
+			klog.ErrorS(err, "PreFilter failed", "pod", klog.KObj(pod))
+			return nil, framework.NewStatus(framework.UnschedulableAndUnresolvable, err.Error())
+		}
+	}
+	if domain := cs.pgMgr.GetTopologyDomain(util.GetPodGroupFullName(pod)); domain != nil {
+		state.Write(topologyStateKey, &topologyState{domain: domain})
 	}
 	return nil, framework.NewStatus(framework.Success, "")
 }
 
+// Filter rejects the nodes outside of the topology domain chosen for the PodGroup
+// if its topology constraint is required.
+func (cs *Coscheduling) Filter(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
+	domain := getTopologyDomain(state)
+	if domain == nil || !domain.Required {
+		return nil
+	}
+	if !domain.Contains(nodeInfo.Node()) {
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable,
+			fmt.Sprintf("node is not in the topology domain %v=%v of the PodGroup", domain.Key, domain.Value))
+	}
+	return nil
+}
+
+// Score favors the nodes inside of the topology domain chosen for the PodGroup
+// if its topology constraint is preferred.
+func (cs *Coscheduling) Score(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (int64, *framework.Status) {
+	domain := getTopologyDomain(state)
+	if domain == nil || domain.Required {
+		return 0, nil
+	}
+	nodeInfo, err := cs.frameworkHandler.SnapshotSharedLister().NodeInfos().Get(nodeName)
+	if err != nil {
+		return 0, framework.AsStatus(fmt.Errorf("getting node %q from Snapshot: %w", nodeName, err))
+	}
+	if domain.Contains(nodeInfo.Node()) {
+		return framework.MaxNodeScore, nil
+	}
+	return 0, nil
+}
+
+// ScoreExtensions of the Score plugin.
+func (cs *Coscheduling) ScoreExtensions() framework.ScoreExtensions {
+	return nil
+}
+
+func getTopologyDomain(state *framework.CycleState) *core.TopologyDomain {
+	c, err := state.Read(topologyStateKey)
+	if err != nil {
+		return nil
+	}
+	s, ok := c.(*topologyState)
+	if !ok {
+		return nil
+	}
+	return s.domain
+}
+
 // PostFilter is used to reject a group of pods if a pod does not pass PreFilter or Filter.
+// If preemption is enabled, it first tries to make room for all the missing members of the
+// PodGroup at once, and only evicts victims if the whole group fits afterwards.
 func (cs *Coscheduling) PostFilter(ctx context.Context, state *framework.CycleState, pod *v1.Pod,
 	filteredNodeStatusMap framework.NodeToStatusMap) (*framework.PostFilterResult, *framework.Status) {
 	pgName, pg := cs.pgMgr.GetPodGroup(ctx, pod)
@@ -158,13 +274,22 @@ func (cs *Coscheduling) PostFilter(ctx context.Context, state *framework.CycleSt
 	}
 
 	// This indicates there are already enough Pods satisfying the PodGroup,
-	// so don't bother to reject the whole PodGroup.
+	// so don't bother to reject the whole PodGroup. This is also the case for the
+	// members of an elastic PodGroup beyond its minMember.
 	assigned := cs.pgMgr.CalculateAssignedPods(pg.Name, pod.Namespace)
-	if assigned >= int(pg.Spec.MinMember) {
+	if assigned >= int(pg.Spec.MinMember) && len(cs.pgMgr.CalculateUnsatisfiedRoles(pg)) == 0 {
 		klog.V(4).InfoS("Assigned pods", "podGroup", klog.KObj(pg), "assigned", assigned)
 		return &framework.PostFilterResult{}, framework.NewStatus(framework.Unschedulable)
 	}
 
+	if cs.enablePreemption {
+		nominatedNode, status := cs.preemptPodGroup(ctx, state, pod, pg, assigned, cs.pgMgr.CalculateUnsatisfiedRoles(pg), filteredNodeStatusMap)
+		if status.IsSuccess() {
+			return framework.NewPostFilterResultWithNominatedNode(nominatedNode), status
+		}
+		klog.V(4).InfoS("PodGroup preemption failed", "podGroup", klog.KObj(pg), "pod", klog.KObj(pod), "reason", status.Message())
+	}
+
 	// If the gap is less than/equal 10%, we may want to try subsequent Pods
 	// to see they can satisfy the PodGroup
 	notAssignedPercentage := float32(int(pg.Spec.MinMember)-assigned) / float32(pg.Spec.MinMember)
@@ -187,11 +312,24 @@ func (cs *Coscheduling) PostFilter(ctx context.Context, state *framework.CycleSt
 			labels.SelectorFromSet(labels.Set{v1alpha1.PodGroupLabel: util.GetPodGroupLabel(pod)}),
 		)
 		if err == nil && len(pods) >= int(pg.Spec.MinMember) {
-			cs.pgMgr.BackoffPodGroup(pgName, *cs.pgBackoff)
+			backoff := cs.pgMgr.BackoffPodGroup(ctx, pg, *cs.pgBackoff, cs.pgMaxBackoff)
+			klog.V(3).InfoS("PodGroup is backed off", "podGroup", klog.KObj(pg), "backoff", backoff)
+			cs.pgMgr.SetPodGroupCondition(ctx, pg, metav1.Condition{
+				Type:    v1alpha1.PodGroupConditionBackedOff,
+				Status:  metav1.ConditionTrue,
+				Reason:  "FailedScheduling",
+				Message: "PodGroup is backed off until status.backoffUntil",
+			})
 		}
 	}
 
 	cs.pgMgr.DeletePermittedPodGroup(pgName)
+	cs.pgMgr.SetPodGroupCondition(ctx, pg, metav1.Condition{
+		Type:    v1alpha1.PodGroupConditionScheduled,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Unschedulable",
+		Message: "the minMember pods of the PodGroup cannot be assigned",
+	})
 	return &framework.PostFilterResult{}, framework.NewStatus(framework.Unschedulable,
 		fmt.Sprintf("PodGroup %v gets rejected due to Pod %v is unschedulable even after PostFilter", pgName, pod.Name))
 }
@@ -211,6 +349,8 @@ func (cs *Coscheduling) Permit(ctx context.Context, state *framework.CycleState,
 		return framework.NewStatus(framework.Success, ""), 0
 	case core.PodGroupNotFound:
 		return framework.NewStatus(framework.Unschedulable, "PodGroup not found"), 0
+	case core.PodGroupFull:
+		return framework.NewStatus(framework.Unschedulable, "PodGroup has reached its maxMember"), 0
 	case core.Wait:
 		klog.InfoS("Pod is waiting to be scheduled to node", "pod", klog.KObj(pod), "nodeName", nodeName)
 		_, pg := cs.pgMgr.GetPodGroup(ctx, pod)
@@ -221,6 +361,14 @@ func (cs *Coscheduling) Permit(ctx context.Context, state *framework.CycleState,
 		// We will also request to move the sibling pods back to activeQ.
 		cs.pgMgr.ActivateSiblings(pod, state)
 	case core.Success:
+		if _, pg := cs.pgMgr.GetPodGroup(ctx, pod); pg != nil {
+			cs.pgMgr.SetPodGroupCondition(ctx, pg, metav1.Condition{
+				Type:    v1alpha1.PodGroupConditionScheduled,
+				Status:  metav1.ConditionTrue,
+				Reason:  "QuorumScheduled",
+				Message: "the minMember pods of the PodGroup have been scheduled",
+			})
+		}
 		pgFullName := util.GetPodGroupFullName(pod)
 		cs.frameworkHandler.IterateOverWaitingPods(func(waitingPod framework.WaitingPod) {
 			if util.GetPodGroupFullName(waitingPod.GetPod()) == pgFullName {
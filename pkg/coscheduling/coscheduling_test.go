@@ -19,6 +19,7 @@ package coscheduling
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"reflect"
 	"sort"
 	"testing"
@@ -29,9 +30,13 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 	clientsetfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
 	clicache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/defaultbinder"
+	plfeature "k8s.io/kubernetes/pkg/scheduler/framework/plugins/feature"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/noderesources"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/queuesort"
 	fwkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
 	st "k8s.io/kubernetes/pkg/scheduler/testing"
@@ -112,7 +117,10 @@ func TestPodGroupBackoffTime(t *testing.T) {
 				tu.NewFakeSharedLister(tt.pods, nodes),
 				// In this UT, 5 seconds should suffice to test the PreFilter's return code.
 				pointer.Duration(5*time.Second),
+				tu.NewFakePodGroupInformer(tt.pgs...),
 				podInformer,
+				false,
+				nil,
 			)
 			pl := &Coscheduling{
 				frameworkHandler: f,
@@ -415,7 +423,7 @@ func TestLess(t *testing.T) {
 			informerFactory := informers.NewSharedInformerFactory(cs, 0)
 			podInformer := informerFactory.Core().V1().Pods()
 
-			pl := &Coscheduling{pgMgr: core.NewPodGroupManager(client, nil, nil, podInformer)}
+			pl := &Coscheduling{pgMgr: core.NewPodGroupManager(client, nil, nil, tu.NewFakePodGroupInformer(tt.pgs...), podInformer, false, nil)}
 
 			informerFactory.Start(ctx.Done())
 			if !clicache.WaitForCacheSync(ctx.Done(), podInformer.Informer().HasSynced) {
@@ -429,6 +437,70 @@ func TestLess(t *testing.T) {
 	}
 }
 
+func BenchmarkLess(b *testing.B) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		podNum   int
+		pgMember int
+	}{
+		{name: "100 pods in groups of 10", podNum: 100, pgMember: 10},
+		{name: "1000 pods in groups of 10", podNum: 1000, pgMember: 10},
+		{name: "5000 pods in groups of 50", podNum: 5000, pgMember: 50},
+	}
+
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var objs []runtime.Object
+			var pgs []*v1alpha1.PodGroup
+			podInfos := make([]*framework.QueuedPodInfo, 0, tt.podNum)
+			for i := 0; i < tt.podNum; i++ {
+				pgName := fmt.Sprintf("pg%d", i/tt.pgMember)
+				if i%tt.pgMember == 0 {
+					pg := tu.MakePodGroup().Name(pgName).Namespace("ns").MinMember(int32(tt.pgMember)).
+						Time(now.Add(time.Duration(i) * time.Millisecond)).Obj()
+					pgs = append(pgs, pg)
+					objs = append(objs, pg)
+				}
+				pod := st.MakePod().Name(fmt.Sprintf("p%d", i)).Namespace("ns").Label(v1alpha1.PodGroupLabel, pgName).Obj()
+				podInfos = append(podInfos, &framework.QueuedPodInfo{
+					PodInfo:                 tu.MustNewPodInfo(b, pod),
+					InitialAttemptTimestamp: ptrTime(now),
+				})
+			}
+
+			client, err := tu.NewFakeClient(objs...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			cs := clientsetfake.NewSimpleClientset()
+			informerFactory := informers.NewSharedInformerFactory(cs, 0)
+			podInformer := informerFactory.Core().V1().Pods()
+			pl := &Coscheduling{pgMgr: core.NewPodGroupManager(client, nil, nil, tu.NewFakePodGroupInformer(pgs...), podInformer, false, nil)}
+			informerFactory.Start(ctx.Done())
+			if !clicache.WaitForCacheSync(ctx.Done(), podInformer.Informer().HasSynced) {
+				b.Fatal("WaitForCacheSync failed")
+			}
+
+			r := rand.New(rand.NewSource(1))
+			r.Shuffle(len(podInfos), func(i, j int) {
+				podInfos[i], podInfos[j] = podInfos[j], podInfos[i]
+			})
+			queue := make([]*framework.QueuedPodInfo, len(podInfos))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				copy(queue, podInfos)
+				sort.Slice(queue, func(i, j int) bool {
+					return pl.Less(queue[i], queue[j])
+				})
+			}
+		})
+	}
+}
+
 func TestPermit(t *testing.T) {
 	scheduleTimeout := 10 * time.Second
 	capacity := map[v1.ResourceName]string{
@@ -505,7 +577,7 @@ func TestPermit(t *testing.T) {
 
 			pl := &Coscheduling{
 				frameworkHandler: f,
-				pgMgr:            core.NewPodGroupManager(client, tu.NewFakeSharedLister(nil, nodes), nil, podInformer),
+				pgMgr:            core.NewPodGroupManager(client, tu.NewFakeSharedLister(nil, nodes), nil, tu.NewFakePodGroupInformer(tt.pgs...), podInformer, false, nil),
 				scheduleTimeout:  &scheduleTimeout,
 			}
 
@@ -522,6 +594,131 @@ func TestPermit(t *testing.T) {
 	}
 }
 
+func TestTopologyConstraint(t *testing.T) {
+	scheduleTimeout := 10 * time.Second
+	capacity := map[v1.ResourceName]string{
+		v1.ResourceCPU: "4",
+	}
+	nodes := []*v1.Node{
+		st.MakeNode().Name("node-a").Label("zone", "a").Capacity(capacity).Obj(),
+		st.MakeNode().Name("node-b1").Label("zone", "b").Capacity(capacity).Obj(),
+		st.MakeNode().Name("node-b2").Label("zone", "b").Capacity(capacity).Obj(),
+		st.MakeNode().Name("node-c").Capacity(capacity).Obj(),
+	}
+
+	tests := []struct {
+		name       string
+		pg         *v1alpha1.PodGroup
+		wantFilter map[string]framework.Code
+		wantScore  map[string]int64
+	}{
+		{
+			name: "required constraint filters out the nodes outside of the domain",
+			pg: tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+				MinResources(map[v1.ResourceName]string{v1.ResourceCPU: "6"}).
+				TopologyConstraint("zone", v1alpha1.TopologyConstraintRequired).Obj(),
+			wantFilter: map[string]framework.Code{
+				"node-a":  framework.UnschedulableAndUnresolvable,
+				"node-b1": framework.Success,
+				"node-b2": framework.Success,
+				"node-c":  framework.UnschedulableAndUnresolvable,
+			},
+			wantScore: map[string]int64{"node-a": 0, "node-b1": 0, "node-b2": 0, "node-c": 0},
+		},
+		{
+			name: "preferred constraint favors the nodes inside of the domain",
+			pg: tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+				MinResources(map[v1.ResourceName]string{v1.ResourceCPU: "6"}).
+				TopologyConstraint("zone", v1alpha1.TopologyConstraintPreferred).Obj(),
+			wantFilter: map[string]framework.Code{
+				"node-a":  framework.Success,
+				"node-b1": framework.Success,
+				"node-b2": framework.Success,
+				"node-c":  framework.Success,
+			},
+			wantScore: map[string]int64{"node-a": 0, "node-b1": framework.MaxNodeScore, "node-b2": framework.MaxNodeScore, "node-c": 0},
+		},
+		{
+			name: "no constraint",
+			pg:   tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Obj(),
+			wantFilter: map[string]framework.Code{
+				"node-a":  framework.Success,
+				"node-b1": framework.Success,
+				"node-b2": framework.Success,
+				"node-c":  framework.Success,
+			},
+			wantScore: map[string]int64{"node-a": 0, "node-b1": 0, "node-b2": 0, "node-c": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			pod := st.MakePod().Name("p1").Namespace("ns").UID("p1").Label(v1alpha1.PodGroupLabel, "pg1").Obj()
+			pods := []*v1.Pod{
+				pod,
+				st.MakePod().Name("p2").Namespace("ns").UID("p2").Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			}
+
+			client, err := tu.NewFakeClient(tt.pg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Compose a fake framework handle.
+			snapshot := tu.NewFakeSharedLister(nil, nodes)
+			registeredPlugins := []tf.RegisterPluginFunc{
+				tf.RegisterQueueSortPlugin(queuesort.Name, queuesort.New),
+				tf.RegisterBindPlugin(defaultbinder.Name, defaultbinder.New),
+			}
+			f, err := tf.NewFramework(ctx, registeredPlugins, "default-scheduler", fwkruntime.WithSnapshotSharedLister(snapshot))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs := clientsetfake.NewSimpleClientset()
+			informerFactory := informers.NewSharedInformerFactory(cs, 0)
+			podInformer := informerFactory.Core().V1().Pods()
+
+			pl := &Coscheduling{
+				frameworkHandler: f,
+				pgMgr:            core.NewPodGroupManager(client, snapshot, &scheduleTimeout, tu.NewFakePodGroupInformer(tt.pg), podInformer, false, nil),
+				scheduleTimeout:  &scheduleTimeout,
+			}
+
+			informerFactory.Start(ctx.Done())
+			if !clicache.WaitForCacheSync(ctx.Done(), podInformer.Informer().HasSynced) {
+				t.Fatal("WaitForCacheSync failed")
+			}
+			for _, p := range pods {
+				podInformer.Informer().GetStore().Add(p)
+			}
+
+			state := framework.NewCycleState()
+			if _, status := pl.PreFilter(ctx, state, pod); !status.IsSuccess() {
+				t.Fatalf("Unexpected PreFilter status: %v", status)
+			}
+			for _, node := range nodes {
+				nodeInfo, err := snapshot.NodeInfos().Get(node.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := pl.Filter(ctx, state, pod, nodeInfo).Code(); got != tt.wantFilter[node.Name] {
+					t.Errorf("Want Filter code %v on node %v, but got %v", tt.wantFilter[node.Name], node.Name, got)
+				}
+				score, status := pl.Score(ctx, state, pod, node.Name)
+				if !status.IsSuccess() {
+					t.Fatalf("Unexpected Score status: %v", status)
+				}
+				if score != tt.wantScore[node.Name] {
+					t.Errorf("Want score %v on node %v, but got %v", tt.wantScore[node.Name], node.Name, score)
+				}
+			}
+		})
+	}
+}
+
 func TestPostFilter(t *testing.T) {
 	scheduleTimeout := 10 * time.Second
 	capacity := map[v1.ResourceName]string{
@@ -613,7 +810,10 @@ func TestPostFilter(t *testing.T) {
 					client,
 					tu.NewFakeSharedLister(tt.existingPods, nodes),
 					&scheduleTimeout,
+					tu.NewFakePodGroupInformer(tt.pgs...),
 					podInformer,
+					false,
+					nil,
 				),
 				scheduleTimeout: &scheduleTimeout,
 			}
@@ -633,3 +833,215 @@ func TestPostFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestPostFilterWithPreemption(t *testing.T) {
+	scheduleTimeout := 10 * time.Second
+	lowPriority, midPriority, highPriority := int32(10), int32(100), int32(1000)
+	capacity := map[v1.ResourceName]string{
+		v1.ResourceCPU:  "4",
+		v1.ResourcePods: "10",
+	}
+	request := map[v1.ResourceName]string{
+		v1.ResourceCPU: "4",
+	}
+	nodeStatusMap := framework.NodeToStatusMap{
+		"node-a": framework.NewStatus(framework.Unschedulable),
+		"node-b": framework.NewStatus(framework.Unschedulable),
+	}
+
+	tests := []struct {
+		name         string
+		pods         []*v1.Pod
+		existingPods []*v1.Pod
+		pgs          []*v1alpha1.PodGroup
+		wantResult   *framework.PostFilterResult
+		wantCode     framework.Code
+		wantDeleted  []string
+	}{
+		{
+			name: "whole gang fits after preemption",
+			pods: []*v1.Pod{
+				st.MakePod().Name("p1").Namespace("ns").UID("p1").Priority(highPriority).Req(request).Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+				st.MakePod().Name("p2").Namespace("ns").UID("p2").Priority(highPriority).Req(request).Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			},
+			existingPods: []*v1.Pod{
+				st.MakePod().Name("v1").Namespace("ns").UID("v1").Priority(lowPriority).Req(request).Node("node-a").Obj(),
+				st.MakePod().Name("v2").Namespace("ns").UID("v2").Priority(midPriority).Req(request).Node("node-b").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Obj(),
+			},
+			wantResult:  framework.NewPostFilterResultWithNominatedNode("node-a"),
+			wantCode:    framework.Success,
+			wantDeleted: []string{"v1", "v2"},
+		},
+		{
+			name: "only part of the gang fits after preemption, no victims",
+			pods: []*v1.Pod{
+				st.MakePod().Name("p1").Namespace("ns").UID("p1").Priority(highPriority).Req(request).Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+				st.MakePod().Name("p2").Namespace("ns").UID("p2").Priority(highPriority).Req(request).Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			},
+			existingPods: []*v1.Pod{
+				st.MakePod().Name("v1").Namespace("ns").UID("v1").Priority(lowPriority).Req(request).Node("node-a").Obj(),
+				st.MakePod().Name("v2").Namespace("ns").UID("v2").Priority(highPriority).Req(request).Node("node-b").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Obj(),
+			},
+			wantResult: &framework.PostFilterResult{},
+			wantCode:   framework.Unschedulable,
+		},
+		{
+			name: "members of different sizes are simulated with their own requests",
+			pods: []*v1.Pod{
+				st.MakePod().Name("p1").Namespace("ns").UID("p1").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+				st.MakePod().Name("p2").Namespace("ns").UID("p2").Priority(highPriority).Req(request).Label(v1alpha1.PodGroupLabel, "pg1").Obj(),
+			},
+			existingPods: []*v1.Pod{
+				st.MakePod().Name("v1").Namespace("ns").UID("v1").Priority(lowPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "3"}).Node("node-a").Obj(),
+				st.MakePod().Name("v2").Namespace("ns").UID("v2").Priority(lowPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "2"}).Node("node-b").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).Obj(),
+			},
+			wantResult:  framework.NewPostFilterResultWithNominatedNode("node-a"),
+			wantCode:    framework.Success,
+			wantDeleted: []string{"v2"},
+		},
+		{
+			name: "quorum exceeded but a role is unsatisfied, preempting for the role",
+			pods: []*v1.Pod{
+				st.MakePod().Name("ps-0").Namespace("ns").UID("ps-0").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "2"}).Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "ps").Obj(),
+				st.MakePod().Name("worker-3").Namespace("ns").UID("worker-3").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Obj(),
+			},
+			existingPods: []*v1.Pod{
+				st.MakePod().Name("worker-0").Namespace("ns").UID("worker-0").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Node("node-a").Obj(),
+				st.MakePod().Name("worker-1").Namespace("ns").UID("worker-1").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Node("node-a").Obj(),
+				st.MakePod().Name("worker-2").Namespace("ns").UID("worker-2").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Node("node-b").Obj(),
+				st.MakePod().Name("v1").Namespace("ns").UID("v1").Priority(lowPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "2"}).Node("node-a").Obj(),
+				st.MakePod().Name("v2").Namespace("ns").UID("v2").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "3"}).Node("node-b").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+					Role("ps", map[string]string{"role": "ps"}, 1, nil).
+					Role("worker", map[string]string{"role": "worker"}, 1, nil).Obj(),
+			},
+			wantResult:  framework.NewPostFilterResultWithNominatedNode("node-a"),
+			wantCode:    framework.Success,
+			wantDeleted: []string{"v1"},
+		},
+		{
+			name: "quorum exceeded but a role is unsatisfied, the preemptor is not in the role",
+			pods: []*v1.Pod{
+				st.MakePod().Name("worker-3").Namespace("ns").UID("worker-3").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Obj(),
+				st.MakePod().Name("ps-0").Namespace("ns").UID("ps-0").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "2"}).Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "ps").Obj(),
+			},
+			existingPods: []*v1.Pod{
+				st.MakePod().Name("worker-0").Namespace("ns").UID("worker-0").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Node("node-a").Obj(),
+				st.MakePod().Name("worker-1").Namespace("ns").UID("worker-1").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Node("node-a").Obj(),
+				st.MakePod().Name("worker-2").Namespace("ns").UID("worker-2").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Label(v1alpha1.PodGroupLabel, "pg1").Label("role", "worker").Node("node-b").Obj(),
+				st.MakePod().Name("v1").Namespace("ns").UID("v1").Priority(lowPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "2"}).Node("node-a").Obj(),
+				st.MakePod().Name("v2").Namespace("ns").UID("v2").Priority(highPriority).Req(map[v1.ResourceName]string{v1.ResourceCPU: "3"}).Node("node-b").Obj(),
+			},
+			pgs: []*v1alpha1.PodGroup{
+				tu.MakePodGroup().Name("pg1").Namespace("ns").MinMember(2).
+					Role("ps", map[string]string{"role": "ps"}, 1, nil).
+					Role("worker", map[string]string{"role": "worker"}, 1, nil).Obj(),
+			},
+			wantResult: &framework.PostFilterResult{},
+			wantCode:   framework.Unschedulable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var objs []runtime.Object
+			for _, pod := range append(tt.existingPods, tt.pods...) {
+				objs = append(objs, pod)
+			}
+			for _, pg := range tt.pgs {
+				objs = append(objs, pg)
+			}
+			client, err := tu.NewFakeClient(objs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var podItems []v1.Pod
+			for _, pod := range append(tt.existingPods, tt.pods...) {
+				podItems = append(podItems, *pod)
+			}
+			cs := clientsetfake.NewSimpleClientset(&v1.PodList{Items: podItems})
+			informerFactory := informers.NewSharedInformerFactory(cs, 0)
+			podInformer := informerFactory.Core().V1().Pods()
+			for _, p := range append(tt.existingPods, tt.pods...) {
+				podInformer.Informer().GetStore().Add(p)
+			}
+
+			nodes := []*v1.Node{
+				st.MakeNode().Name("node-a").Capacity(capacity).Obj(),
+				st.MakeNode().Name("node-b").Capacity(capacity).Obj(),
+			}
+			registeredPlugins := []tf.RegisterPluginFunc{
+				tf.RegisterQueueSortPlugin(queuesort.Name, queuesort.New),
+				tf.RegisterBindPlugin(defaultbinder.Name, defaultbinder.New),
+				tf.RegisterPluginAsExtensions(noderesources.Name, func(ctx context.Context, plArgs runtime.Object, fh framework.Handle) (framework.Plugin, error) {
+					return noderesources.NewFit(ctx, plArgs, fh, plfeature.Features{})
+				}, "Filter", "PreFilter"),
+			}
+			f, err := tf.NewFramework(ctx, registeredPlugins, "default-scheduler",
+				fwkruntime.WithClientSet(cs),
+				fwkruntime.WithEventRecorder(&events.FakeRecorder{}),
+				fwkruntime.WithInformerFactory(informerFactory),
+				fwkruntime.WithPodNominator(tu.NewPodNominator(podInformer.Lister())),
+				fwkruntime.WithSnapshotSharedLister(tu.NewFakeSharedLister(tt.existingPods, nodes)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pl := &Coscheduling{
+				frameworkHandler: f,
+				pgMgr: core.NewPodGroupManager(
+					client,
+					tu.NewFakeSharedLister(tt.existingPods, nodes),
+					&scheduleTimeout,
+					tu.NewFakePodGroupInformer(tt.pgs...),
+					podInformer,
+					false,
+					nil,
+				),
+				scheduleTimeout:  &scheduleTimeout,
+				enablePreemption: true,
+				podLister:        podInformer.Lister(),
+				pdbLister:        informerFactory.Policy().V1().PodDisruptionBudgets().Lister(),
+			}
+
+			state := framework.NewCycleState()
+			if _, s := f.RunPreFilterPlugins(ctx, state, tt.pods[0]); !s.IsSuccess() {
+				t.Fatalf("Unexpected PreFilter status: %v", s)
+			}
+			gotResult, gotStatus := pl.PostFilter(ctx, state, tt.pods[0], nodeStatusMap)
+			if gotStatus.Code() != tt.wantCode {
+				t.Errorf("Want code %v, but got %v", tt.wantCode, gotStatus)
+			}
+			if diff := cmp.Diff(tt.wantResult, gotResult); diff != "" {
+				t.Errorf("Unexpected PostFilterResult (-want, +got):\n%s", diff)
+			}
+
+			var deleted []string
+			for _, action := range cs.Actions() {
+				if action.GetVerb() == "delete" {
+					deleted = append(deleted, action.(clienttesting.DeleteAction).GetName())
+				}
+			}
+			sort.Strings(deleted)
+			if diff := cmp.Diff(tt.wantDeleted, deleted); diff != "" {
+				t.Errorf("Unexpected deleted pods (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
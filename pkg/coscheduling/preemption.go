@@ -0,0 +1,452 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package coscheduling
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/preemption"
+	"k8s.io/kubernetes/pkg/scheduler/metrics"
+	schedutil "k8s.io/kubernetes/pkg/scheduler/util"
+
+	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	"sigs.k8s.io/scheduler-plugins/pkg/util"
+)
+
+var _ preemption.Interface = &preemptor{}
+
+// preemptionSimulationStateKey marks the CycleStates of the siblings simulated during the
+// preemption of a PodGroup, on which the PodGroup checks of PreFilter must not run again.
+const preemptionSimulationStateKey = Name + "/preemption-simulation"
+
+type preemptionSimulationState struct{}
+
+// Clone the preemptionSimulationState.
+func (s *preemptionSimulationState) Clone() framework.StateData {
+	return s
+}
+
+func isPreemptionSimulation(state *framework.CycleState) bool {
+	_, err := state.Read(preemptionSimulationStateKey)
+	return err == nil
+}
+
+// simulatedPod is a pod added to or removed from the simulated nodes during the preemption.
+type simulatedPod struct {
+	podInfo  *framework.PodInfo
+	nodeInfo *framework.NodeInfo
+	removed  bool
+}
+
+// preemptPodGroup dry-runs preemption for all the members a PodGroup is still missing to
+// reach its quorum and the minMember of its roles, each with its own CycleState. Victims are
+// only evicted if every missing member finds a node; otherwise nothing is touched. It returns
+// the node nominated for the given pod.
+func (cs *Coscheduling) preemptPodGroup(ctx context.Context, state *framework.CycleState, pod *v1.Pod,
+	pg *v1alpha1.PodGroup, assigned int, missingRoles map[string]int32, m framework.NodeToStatusMap) (string, *framework.Status) {
+	defer func() {
+		metrics.PreemptionAttempts.Inc()
+	}()
+
+	logger := klog.FromContext(ctx)
+	ev := &preemption.Evaluator{
+		PluginName: cs.Name(),
+		Handler:    cs.frameworkHandler,
+		PodLister:  cs.podLister,
+		PdbLister:  cs.pdbLister,
+		Interface: &preemptor{
+			fh:      cs.frameworkHandler,
+			pgLabel: pg.Name,
+		},
+	}
+
+	if ok, msg := ev.PodEligibleToPreemptOthers(pod, m[pod.Status.NominatedNodeName]); !ok {
+		return "", framework.NewStatus(framework.Unschedulable, msg)
+	}
+
+	members, err := cs.pendingMembers(pod, pg)
+	if err != nil {
+		return "", framework.AsStatus(err)
+	}
+	members, status := missingMembers(pg, members, assigned, missingRoles)
+	if !status.IsSuccess() {
+		return "", status
+	}
+	if members[0].UID != pod.UID {
+		// The members the PodGroup is missing are preempted for in their own cycles.
+		return "", framework.NewStatus(framework.Unschedulable,
+			fmt.Sprintf("PodGroup %v does not need pod %v to reach the quorum", klog.KObj(pg), klog.KObj(pod)))
+	}
+
+	allNodes, err := cs.frameworkHandler.SnapshotSharedLister().NodeInfos().List()
+	if err != nil {
+		return "", framework.AsStatus(err)
+	}
+	pdbs, err := cs.pdbLister.List(labels.Everything())
+	if err != nil {
+		return "", framework.AsStatus(err)
+	}
+
+	// Simulate on copies of the snapshot so that the placement of one member is visible
+	// to the dry-run of the next one.
+	nodeInfos := make(map[string]*framework.NodeInfo, len(allNodes))
+	for _, n := range allNodes {
+		nodeInfos[n.Node().Name] = n.Snapshot()
+	}
+
+	nominations := make(map[types.UID]string, len(members))
+	victims := make(map[types.UID]*v1.Pod)
+	victimNodes := make(map[types.UID]string)
+	var simulated []simulatedPod
+	for _, member := range members {
+		simState, result, status := cs.memberCycleState(ctx, state, pod, member, simulated)
+		if !status.IsSuccess() {
+			return "", framework.NewStatus(framework.Unschedulable,
+				fmt.Sprintf("PodGroup %v cannot fit pod %v: %v", klog.KObj(pg), klog.KObj(member), status.Message()))
+		}
+		ev.State = simState
+
+		var potentialNodes []*framework.NodeInfo
+		for _, n := range allNodes {
+			// Nodes that can't be helped by preemption are only known for the preemptor.
+			if member.UID == pod.UID && m[n.Node().Name].Code() == framework.UnschedulableAndUnresolvable {
+				continue
+			}
+			if !result.AllNodes() && !result.NodeNames.Has(n.Node().Name) {
+				continue
+			}
+			potentialNodes = append(potentialNodes, nodeInfos[n.Node().Name])
+		}
+
+		nodeName := ""
+		for _, nodeInfo := range potentialNodes {
+			if s := cs.frameworkHandler.RunFilterPluginsWithNominatedPods(ctx, simState, member, nodeInfo); s.IsSuccess() {
+				nodeName = nodeInfo.Node().Name
+				break
+			}
+		}
+
+		if nodeName == "" {
+			candidates, _, err := ev.DryRunPreemption(ctx, member, potentialNodes, pdbs, 0, int32(len(potentialNodes)))
+			if len(candidates) == 0 {
+				if err != nil {
+					return "", framework.AsStatus(err)
+				}
+				return "", framework.NewStatus(framework.Unschedulable,
+					fmt.Sprintf("PodGroup %v cannot fit pod %v even after preemption", klog.KObj(pg), klog.KObj(member)))
+			}
+			best := ev.SelectCandidate(ctx, candidates)
+			if best == nil || len(best.Name()) == 0 {
+				return "", framework.NewStatus(framework.Unschedulable, "no candidate node for preemption")
+			}
+			nodeName = best.Name()
+			nodeInfo := nodeInfos[nodeName]
+			for _, victim := range best.Victims().Pods {
+				if err := nodeInfo.RemovePod(logger, victim); err != nil {
+					return "", framework.AsStatus(err)
+				}
+				victimInfo, err := framework.NewPodInfo(victim)
+				if err != nil {
+					return "", framework.AsStatus(err)
+				}
+				simulated = append(simulated, simulatedPod{podInfo: victimInfo, nodeInfo: nodeInfo, removed: true})
+				victims[victim.UID] = victim
+				victimNodes[victim.UID] = nodeName
+			}
+		}
+
+		assumed := member.DeepCopy()
+		assumed.Spec.NodeName = nodeName
+		nodeInfo := nodeInfos[nodeName]
+		podInfo, err := framework.NewPodInfo(assumed)
+		if err != nil {
+			return "", framework.AsStatus(err)
+		}
+		nodeInfo.AddPodInfo(podInfo)
+		simulated = append(simulated, simulatedPod{podInfo: podInfo, nodeInfo: nodeInfo})
+		nominations[member.UID] = nodeName
+	}
+
+	if len(victims) == 0 {
+		// Nothing to preempt: the missing members fit as is, so let them go through
+		// the regular scheduling cycles.
+		return "", framework.NewStatus(framework.Unschedulable, "preemption is not needed for the PodGroup")
+	}
+
+	klog.V(3).InfoS("Preempting victims for PodGroup", "podGroup", klog.KObj(pg), "victims", len(victims), "members", len(members))
+	if status := cs.evictVictims(ctx, pod, pg, victims, victimNodes); !status.IsSuccess() {
+		return "", status
+	}
+
+	// The preemptor gets its nominated node through the PostFilterResult; its siblings
+	// are nominated explicitly so that the freed room is kept for the whole group.
+	for _, member := range members {
+		if member.UID == pod.UID || member.Status.NominatedNodeName == nominations[member.UID] {
+			continue
+		}
+		newStatus := member.Status.DeepCopy()
+		newStatus.NominatedNodeName = nominations[member.UID]
+		if err := schedutil.PatchPodStatus(ctx, cs.frameworkHandler.ClientSet(), member, newStatus); err != nil {
+			klog.ErrorS(err, "Failed to nominate node for PodGroup member", "pod", klog.KObj(member), "node", nominations[member.UID])
+		}
+	}
+	return nominations[pod.UID], framework.NewStatus(framework.Success)
+}
+
+// missingMembers picks among the pending members the ones the PodGroup is missing: first the
+// members of the roles whose minMember is not satisfied, then any member until the minMember
+// of the PodGroup is reached. The picked members keep their order, so the preemptor, placed
+// first in members, comes first if it is picked.
+func missingMembers(pg *v1alpha1.PodGroup, members []*v1.Pod, assigned int, missingRoles map[string]int32) ([]*v1.Pod, *framework.Status) {
+	picked := make([]bool, len(members))
+	count := 0
+	for i := range pg.Spec.Roles {
+		role := &pg.Spec.Roles[i]
+		missing := missingRoles[role.Name]
+		for j, member := range members {
+			if missing <= 0 {
+				break
+			}
+			if picked[j] || !util.PodMatchesRole(role, member) {
+				continue
+			}
+			picked[j] = true
+			count++
+			missing--
+		}
+		if missing > 0 {
+			return nil, framework.NewStatus(framework.Unschedulable,
+				fmt.Sprintf("PodGroup %v has not enough pending pods of role %q to reach its minMember", klog.KObj(pg), role.Name))
+		}
+	}
+
+	needed := max(int(pg.Spec.MinMember)-assigned-count, 0)
+	for j := range members {
+		if needed == 0 {
+			break
+		}
+		if picked[j] {
+			continue
+		}
+		picked[j] = true
+		count++
+		needed--
+	}
+	if needed > 0 {
+		return nil, framework.NewStatus(framework.Unschedulable,
+			fmt.Sprintf("PodGroup %v has %d pending pods, but %d are needed to reach the quorum", klog.KObj(pg), len(members), int(pg.Spec.MinMember)-assigned))
+	}
+	if count == 0 {
+		return nil, framework.NewStatus(framework.Unschedulable, fmt.Sprintf("PodGroup %v is not missing any pod", klog.KObj(pg)))
+	}
+
+	missing := make([]*v1.Pod, 0, count)
+	for j, member := range members {
+		if picked[j] {
+			missing = append(missing, member)
+		}
+	}
+	return missing, nil
+}
+
+// memberCycleState returns the CycleState to simulate the scheduling of a member of the PodGroup,
+// along with the nodes its PreFilter restricts it to. The PreFilter state is specific to each pod,
+// e.g. its resource requests, so PreFilter runs again on a fresh CycleState for every member but
+// the preemptor. The pods already added to or removed from the simulated nodes are then replayed
+// through the PreFilter extensions.
+func (cs *Coscheduling) memberCycleState(ctx context.Context, state *framework.CycleState, pod, member *v1.Pod,
+	simulated []simulatedPod) (*framework.CycleState, *framework.PreFilterResult, *framework.Status) {
+	var result *framework.PreFilterResult
+	memberState := state.Clone()
+	if member.UID != pod.UID {
+		fwk, ok := cs.frameworkHandler.(framework.Framework)
+		if !ok {
+			return nil, nil, framework.AsStatus(fmt.Errorf("cannot run the PreFilter plugins of pod %v", klog.KObj(member)))
+		}
+		memberState = framework.NewCycleState()
+		memberState.Write(preemptionSimulationStateKey, &preemptionSimulationState{})
+		var status *framework.Status
+		if result, status = fwk.RunPreFilterPlugins(ctx, memberState, member); !status.IsSuccess() {
+			return nil, nil, status
+		}
+	}
+	for _, sp := range simulated {
+		var status *framework.Status
+		if sp.removed {
+			status = cs.frameworkHandler.RunPreFilterExtensionRemovePod(ctx, memberState, member, sp.podInfo, sp.nodeInfo)
+		} else {
+			status = cs.frameworkHandler.RunPreFilterExtensionAddPod(ctx, memberState, member, sp.podInfo, sp.nodeInfo)
+		}
+		if !status.IsSuccess() {
+			return nil, nil, status
+		}
+	}
+	return memberState, result, nil
+}
+
+// pendingMembers returns the members of the PodGroup that are neither bound nor waiting
+// on Permit, with the given pod placed first.
+func (cs *Coscheduling) pendingMembers(pod *v1.Pod, pg *v1alpha1.PodGroup) ([]*v1.Pod, error) {
+	pods, err := cs.podLister.Pods(pod.Namespace).List(
+		labels.SelectorFromSet(labels.Set{v1alpha1.PodGroupLabel: pg.Name}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	members := []*v1.Pod{pod}
+	for _, p := range pods {
+		if p.UID == pod.UID || p.Spec.NodeName != "" || p.DeletionTimestamp != nil {
+			continue
+		}
+		if cs.frameworkHandler.GetWaitingPod(p.UID) != nil {
+			continue
+		}
+		members = append(members, p)
+	}
+	return members, nil
+}
+
+// evictVictims deletes the victims, or rejects them if they are waiting on Permit.
+func (cs *Coscheduling) evictVictims(ctx context.Context, pod *v1.Pod, pg *v1alpha1.PodGroup,
+	victims map[types.UID]*v1.Pod, victimNodes map[types.UID]string) *framework.Status {
+	fh := cs.frameworkHandler
+	var pods []*v1.Pod
+	for _, victim := range victims {
+		pods = append(pods, victim)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, len(pods))
+	preemptPod := func(i int) {
+		victim := pods[i]
+		if waitingPod := fh.GetWaitingPod(victim.UID); waitingPod != nil {
+			waitingPod.Reject(cs.Name(), "preempted")
+		} else if err := schedutil.DeletePod(ctx, fh.ClientSet(), victim); err != nil {
+			klog.ErrorS(err, "Failed to preempt victim", "victim", klog.KObj(victim), "podGroup", klog.KObj(pg))
+			errs[i] = err
+			cancel()
+			return
+		}
+		klog.V(2).InfoS("PodGroup preempted victim", "podGroup", klog.KObj(pg), "victim", klog.KObj(victim), "node", victimNodes[victim.UID])
+		fh.EventRecorder().Eventf(victim, pod, v1.EventTypeNormal, "Preempted", "Preempting",
+			"Preempted by PodGroup %v on node %v", util.GetPodGroupFullName(pod), victimNodes[victim.UID])
+	}
+	fh.Parallelizer().Until(ctx, len(pods), preemptPod, cs.Name())
+	for _, err := range errs {
+		if err != nil {
+			return framework.AsStatus(err)
+		}
+	}
+	metrics.PreemptionVictims.Observe(float64(len(pods)))
+	return nil
+}
+
+// preemptor selects victims for a single member of a PodGroup. Members of the same
+// PodGroup are never selected as victims.
+type preemptor struct {
+	util.PreemptorBase
+	fh      framework.Handle
+	pgLabel string
+}
+
+// PodEligibleToPreemptOthers determines whether this pod should be considered
+// for preempting other pods or not. If this pod has already preempted other
+// pods and those are in their graceful termination period, it shouldn't be
+// considered for preemption.
+func (p *preemptor) PodEligibleToPreemptOthers(pod *v1.Pod, nominatedNodeStatus *framework.Status) (bool, string) {
+	if ok, msg := util.PreemptionPolicyAllows(pod); !ok {
+		return false, msg
+	}
+
+	nomNodeName := pod.Status.NominatedNodeName
+	if len(nomNodeName) > 0 {
+		// If the pod's nominated node is considered as UnschedulableAndUnresolvable by the filters,
+		// then the pod should be considered for preempting again.
+		if nominatedNodeStatus.Code() == framework.UnschedulableAndUnresolvable {
+			return true, ""
+		}
+		if nodeInfo, _ := p.fh.SnapshotSharedLister().NodeInfos().Get(nomNodeName); nodeInfo != nil {
+			podPriority := corev1helpers.PodPriority(pod)
+			for _, pi := range nodeInfo.Pods {
+				if pi.Pod.DeletionTimestamp != nil && corev1helpers.PodPriority(pi.Pod) < podPriority {
+					// There is a terminating pod on the nominated node.
+					return false, "not eligible due to a terminating pod on the nominated node."
+				}
+			}
+		}
+	}
+	return true, ""
+}
+
+// SelectVictimsOnNode finds the minimum set of lower priority pods on the given node
+// that should be preempted to make room for the given member of the PodGroup.
+func (p *preemptor) SelectVictimsOnNode(
+	ctx context.Context,
+	state *framework.CycleState,
+	pod *v1.Pod,
+	nodeInfo *framework.NodeInfo,
+	pdbs []*policy.PodDisruptionBudget) ([]*v1.Pod, int, *framework.Status) {
+	sim := &util.VictimsSimulation{Handle: p.fh, State: state, Pod: pod, NodeInfo: nodeInfo}
+
+	podPriority := corev1helpers.PodPriority(pod)
+	var potentialVictims []*framework.PodInfo
+	for _, pi := range nodeInfo.Pods {
+		if pi.Pod.Namespace == pod.Namespace && util.GetPodGroupLabel(pi.Pod) == p.pgLabel {
+			continue
+		}
+		if corev1helpers.PodPriority(pi.Pod) < podPriority {
+			potentialVictims = append(potentialVictims, pi)
+		}
+	}
+	for _, pi := range potentialVictims {
+		if err := sim.RemovePod(ctx, pi); err != nil {
+			return nil, 0, framework.AsStatus(err)
+		}
+	}
+
+	// No potential victims are found, and so we don't need to evaluate the node again since its state didn't change.
+	if len(potentialVictims) == 0 {
+		message := fmt.Sprintf("No victims found on node %v for preemptor pod %v", nodeInfo.Node().Name, pod.Name)
+		return nil, 0, framework.NewStatus(framework.UnschedulableAndUnresolvable, message)
+	}
+
+	// If the new pod does not fit after removing all the lower priority pods,
+	// this node is not suitable for preemption.
+	if s := sim.PodFits(ctx); !s.IsSuccess() {
+		return nil, 0, s
+	}
+
+	// Try to reprieve as many pods as possible, starting from the highest priority victims.
+	sort.Slice(potentialVictims, func(i, j int) bool {
+		return schedutil.MoreImportantPod(potentialVictims[i].Pod, potentialVictims[j].Pod)
+	})
+	victims, numViolatingVictim, err := sim.ReprieveVictims(ctx, potentialVictims, pdbs, nil)
+	if err != nil {
+		return nil, 0, framework.AsStatus(err)
+	}
+	return victims, numViolatingVictim, framework.NewStatus(framework.Success)
+}
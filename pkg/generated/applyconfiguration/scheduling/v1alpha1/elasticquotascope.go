@@ -0,0 +1,52 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// ElasticQuotaScopeApplyConfiguration represents an declarative configuration of the ElasticQuotaScope type for use
+// with apply.
+type ElasticQuotaScopeApplyConfiguration struct {
+	PriorityClassName *string          `json:"priorityClassName,omitempty"`
+	Max               *v1.ResourceList `json:"max,omitempty"`
+}
+
+// ElasticQuotaScopeApplyConfiguration constructs an declarative configuration of the ElasticQuotaScope type for use with
+// apply.
+func ElasticQuotaScope() *ElasticQuotaScopeApplyConfiguration {
+	return &ElasticQuotaScopeApplyConfiguration{}
+}
+
+// WithPriorityClassName sets the PriorityClassName field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the PriorityClassName field is set to the value of the last call.
+func (b *ElasticQuotaScopeApplyConfiguration) WithPriorityClassName(value string) *ElasticQuotaScopeApplyConfiguration {
+	b.PriorityClassName = &value
+	return b
+}
+
+// WithMax sets the Max field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Max field is set to the value of the last call.
+func (b *ElasticQuotaScopeApplyConfiguration) WithMax(value v1.ResourceList) *ElasticQuotaScopeApplyConfiguration {
+	b.Max = &value
+	return b
+}
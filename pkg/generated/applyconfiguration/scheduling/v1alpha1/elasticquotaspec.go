@@ -20,13 +20,18 @@ package v1alpha1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // ElasticQuotaSpecApplyConfiguration represents an declarative configuration of the ElasticQuotaSpec type for use
 // with apply.
 type ElasticQuotaSpecApplyConfiguration struct {
-	Min *v1.ResourceList `json:"min,omitempty"`
-	Max *v1.ResourceList `json:"max,omitempty"`
+	Min               *v1.ResourceList                         `json:"min,omitempty"`
+	Max               *v1.ResourceList                         `json:"max,omitempty"`
+	Parent            *ElasticQuotaReferenceApplyConfiguration `json:"parent,omitempty"`
+	NamespaceSelector *metav1.LabelSelector                    `json:"namespaceSelector,omitempty"`
+	Weight            *int32                                   `json:"weight,omitempty"`
+	Scopes            []ElasticQuotaScopeApplyConfiguration    `json:"scopes,omitempty"`
 }
 
 // ElasticQuotaSpecApplyConfiguration constructs an declarative configuration of the ElasticQuotaSpec type for use with
@@ -50,3 +55,40 @@ func (b *ElasticQuotaSpecApplyConfiguration) WithMax(value v1.ResourceList) *Ela
 	b.Max = &value
 	return b
 }
+
+// WithParent sets the Parent field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Parent field is set to the value of the last call.
+func (b *ElasticQuotaSpecApplyConfiguration) WithParent(value *ElasticQuotaReferenceApplyConfiguration) *ElasticQuotaSpecApplyConfiguration {
+	b.Parent = value
+	return b
+}
+
+// WithNamespaceSelector sets the NamespaceSelector field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the NamespaceSelector field is set to the value of the last call.
+func (b *ElasticQuotaSpecApplyConfiguration) WithNamespaceSelector(value metav1.LabelSelector) *ElasticQuotaSpecApplyConfiguration {
+	b.NamespaceSelector = &value
+	return b
+}
+
+// WithWeight sets the Weight field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Weight field is set to the value of the last call.
+func (b *ElasticQuotaSpecApplyConfiguration) WithWeight(value int32) *ElasticQuotaSpecApplyConfiguration {
+	b.Weight = &value
+	return b
+}
+
+// WithScopes adds the given value to the Scopes field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Scopes field.
+func (b *ElasticQuotaSpecApplyConfiguration) WithScopes(values ...*ElasticQuotaScopeApplyConfiguration) *ElasticQuotaSpecApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithScopes")
+		}
+		b.Scopes = append(b.Scopes, *values[i])
+	}
+	return b
+}
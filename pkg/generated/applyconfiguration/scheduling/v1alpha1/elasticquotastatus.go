@@ -20,12 +20,19 @@ package v1alpha1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	types "k8s.io/apimachinery/pkg/types"
 )
 
 // ElasticQuotaStatusApplyConfiguration represents an declarative configuration of the ElasticQuotaStatus type for use
 // with apply.
 type ElasticQuotaStatusApplyConfiguration struct {
-	Used *v1.ResourceList `json:"used,omitempty"`
+	Used          *v1.ResourceList `json:"used,omitempty"`
+	Pods          *int32           `json:"pods,omitempty"`
+	Borrowed      *v1.ResourceList `json:"borrowed,omitempty"`
+	Lent          *v1.ResourceList `json:"lent,omitempty"`
+	Pending       *v1.ResourceList `json:"pending,omitempty"`
+	Preempted     *int32           `json:"preempted,omitempty"`
+	PreemptedPods []types.UID      `json:"preemptedPods,omitempty"`
 }
 
 // ElasticQuotaStatusApplyConfiguration constructs an declarative configuration of the ElasticQuotaStatus type for use with
@@ -41,3 +48,53 @@ func (b *ElasticQuotaStatusApplyConfiguration) WithUsed(value v1.ResourceList) *
 	b.Used = &value
 	return b
 }
+
+// WithPods sets the Pods field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Pods field is set to the value of the last call.
+func (b *ElasticQuotaStatusApplyConfiguration) WithPods(value int32) *ElasticQuotaStatusApplyConfiguration {
+	b.Pods = &value
+	return b
+}
+
+// WithBorrowed sets the Borrowed field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Borrowed field is set to the value of the last call.
+func (b *ElasticQuotaStatusApplyConfiguration) WithBorrowed(value v1.ResourceList) *ElasticQuotaStatusApplyConfiguration {
+	b.Borrowed = &value
+	return b
+}
+
+// WithLent sets the Lent field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Lent field is set to the value of the last call.
+func (b *ElasticQuotaStatusApplyConfiguration) WithLent(value v1.ResourceList) *ElasticQuotaStatusApplyConfiguration {
+	b.Lent = &value
+	return b
+}
+
+// WithPending sets the Pending field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Pending field is set to the value of the last call.
+func (b *ElasticQuotaStatusApplyConfiguration) WithPending(value v1.ResourceList) *ElasticQuotaStatusApplyConfiguration {
+	b.Pending = &value
+	return b
+}
+
+// WithPreempted sets the Preempted field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Preempted field is set to the value of the last call.
+func (b *ElasticQuotaStatusApplyConfiguration) WithPreempted(value int32) *ElasticQuotaStatusApplyConfiguration {
+	b.Preempted = &value
+	return b
+}
+
+// WithPreemptedPods adds the given value to the PreemptedPods field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the PreemptedPods field.
+func (b *ElasticQuotaStatusApplyConfiguration) WithPreemptedPods(values ...types.UID) *ElasticQuotaStatusApplyConfiguration {
+	for i := range values {
+		b.PreemptedPods = append(b.PreemptedPods, values[i])
+	}
+	return b
+}
@@ -0,0 +1,71 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodGroupRoleApplyConfiguration represents an declarative configuration of the PodGroupRole type for use
+// with apply.
+type PodGroupRoleApplyConfiguration struct {
+	Name         *string              `json:"name,omitempty"`
+	Selector     *v1.LabelSelector    `json:"selector,omitempty"`
+	MinMember    *int32               `json:"minMember,omitempty"`
+	MinResources *corev1.ResourceList `json:"minResources,omitempty"`
+}
+
+// PodGroupRoleApplyConfiguration constructs an declarative configuration of the PodGroupRole type for use with
+// apply.
+func PodGroupRole() *PodGroupRoleApplyConfiguration {
+	return &PodGroupRoleApplyConfiguration{}
+}
+
+// WithName sets the Name field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Name field is set to the value of the last call.
+func (b *PodGroupRoleApplyConfiguration) WithName(value string) *PodGroupRoleApplyConfiguration {
+	b.Name = &value
+	return b
+}
+
+// WithSelector sets the Selector field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Selector field is set to the value of the last call.
+func (b *PodGroupRoleApplyConfiguration) WithSelector(value v1.LabelSelector) *PodGroupRoleApplyConfiguration {
+	b.Selector = &value
+	return b
+}
+
+// WithMinMember sets the MinMember field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the MinMember field is set to the value of the last call.
+func (b *PodGroupRoleApplyConfiguration) WithMinMember(value int32) *PodGroupRoleApplyConfiguration {
+	b.MinMember = &value
+	return b
+}
+
+// WithMinResources sets the MinResources field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the MinResources field is set to the value of the last call.
+func (b *PodGroupRoleApplyConfiguration) WithMinResources(value corev1.ResourceList) *PodGroupRoleApplyConfiguration {
+	b.MinResources = &value
+	return b
+}
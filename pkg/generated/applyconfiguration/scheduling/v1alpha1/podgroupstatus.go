@@ -32,6 +32,9 @@ type PodGroupStatusApplyConfiguration struct {
 	Succeeded         *int32                  `json:"succeeded,omitempty"`
 	Failed            *int32                  `json:"failed,omitempty"`
 	ScheduleStartTime *v1.Time                `json:"scheduleStartTime,omitempty"`
+	BackoffCount      *int32                  `json:"backoffCount,omitempty"`
+	BackoffUntil      *v1.Time                `json:"backoffUntil,omitempty"`
+	Conditions        []v1.Condition          `json:"conditions,omitempty"`
 }
 
 // PodGroupStatusApplyConfiguration constructs an declarative configuration of the PodGroupStatus type for use with
@@ -87,3 +90,29 @@ func (b *PodGroupStatusApplyConfiguration) WithScheduleStartTime(value v1.Time)
 	b.ScheduleStartTime = &value
 	return b
 }
+
+// WithBackoffCount sets the BackoffCount field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the BackoffCount field is set to the value of the last call.
+func (b *PodGroupStatusApplyConfiguration) WithBackoffCount(value int32) *PodGroupStatusApplyConfiguration {
+	b.BackoffCount = &value
+	return b
+}
+
+// WithBackoffUntil sets the BackoffUntil field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the BackoffUntil field is set to the value of the last call.
+func (b *PodGroupStatusApplyConfiguration) WithBackoffUntil(value v1.Time) *PodGroupStatusApplyConfiguration {
+	b.BackoffUntil = &value
+	return b
+}
+
+// WithConditions adds the given value to the Conditions field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Conditions field.
+func (b *PodGroupStatusApplyConfiguration) WithConditions(values ...v1.Condition) *PodGroupStatusApplyConfiguration {
+	for i := range values {
+		b.Conditions = append(b.Conditions, values[i])
+	}
+	return b
+}
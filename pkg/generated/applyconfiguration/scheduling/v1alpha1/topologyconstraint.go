@@ -0,0 +1,52 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+)
+
+// TopologyConstraintApplyConfiguration represents an declarative configuration of the TopologyConstraint type for use
+// with apply.
+type TopologyConstraintApplyConfiguration struct {
+	TopologyKey *string                          `json:"topologyKey,omitempty"`
+	Mode        *v1alpha1.TopologyConstraintMode `json:"mode,omitempty"`
+}
+
+// TopologyConstraintApplyConfiguration constructs an declarative configuration of the TopologyConstraint type for use with
+// apply.
+func TopologyConstraint() *TopologyConstraintApplyConfiguration {
+	return &TopologyConstraintApplyConfiguration{}
+}
+
+// WithTopologyKey sets the TopologyKey field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the TopologyKey field is set to the value of the last call.
+func (b *TopologyConstraintApplyConfiguration) WithTopologyKey(value string) *TopologyConstraintApplyConfiguration {
+	b.TopologyKey = &value
+	return b
+}
+
+// WithMode sets the Mode field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Mode field is set to the value of the last call.
+func (b *TopologyConstraintApplyConfiguration) WithMode(value v1alpha1.TopologyConstraintMode) *TopologyConstraintApplyConfiguration {
+	b.Mode = &value
+	return b
+}
@@ -31,16 +31,24 @@ func ForKind(kind schema.GroupVersionKind) interface{} {
 	// Group=scheduling.x-k8s.io, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithKind("ElasticQuota"):
 		return &schedulingv1alpha1.ElasticQuotaApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("ElasticQuotaReference"):
+		return &schedulingv1alpha1.ElasticQuotaReferenceApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("ElasticQuotaScope"):
+		return &schedulingv1alpha1.ElasticQuotaScopeApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("ElasticQuotaSpec"):
 		return &schedulingv1alpha1.ElasticQuotaSpecApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("ElasticQuotaStatus"):
 		return &schedulingv1alpha1.ElasticQuotaStatusApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("PodGroup"):
 		return &schedulingv1alpha1.PodGroupApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("PodGroupRole"):
+		return &schedulingv1alpha1.PodGroupRoleApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("PodGroupSpec"):
 		return &schedulingv1alpha1.PodGroupSpecApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("PodGroupStatus"):
 		return &schedulingv1alpha1.PodGroupStatusApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("TopologyConstraint"):
+		return &schedulingv1alpha1.TopologyConstraintApplyConfiguration{}
 
 	}
 	return nil
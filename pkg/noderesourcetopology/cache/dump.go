@@ -0,0 +1,194 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
+)
+
+// Dump is a point-in-time view of the cache state, meant for debugging.
+type Dump struct {
+	Nodes map[string]NodeDump `json:"nodes"`
+}
+
+// NodeDump is a point-in-time view of the cache state of a node.
+type NodeDump struct {
+	// NUMAZones holds the resources of each NUMA zone, as reported and after deducting the assumed pods.
+	NUMAZones map[string]NUMAZoneDump `json:"numaZones,omitempty"`
+	// AssumedPods holds the pods reserved on the node after the last resync, by namespace/name.
+	AssumedPods map[string]AssumedPodDump `json:"assumedPods,omitempty"`
+	// Fingerprint is the pods fingerprint of the cached NRT data.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// MaybeOverReserved counts how many times the node was filtered out since the last resync.
+	MaybeOverReserved int `json:"maybeOverReserved,omitempty"`
+	// ForeignPods counts the foreign pods observed on the node since the last resync.
+	ForeignPods int `json:"foreignPods,omitempty"`
+	// ResyncAttempts counts the resync attempts since the last resync.
+	ResyncAttempts int `json:"resyncAttempts,omitempty"`
+	// LastResyncFailure is the reason the last resync attempt failed, e.g. a pods fingerprint mismatch.
+	LastResyncFailure string `json:"lastResyncFailure,omitempty"`
+}
+
+// NUMAZoneDump holds the available resources of a NUMA zone.
+type NUMAZoneDump struct {
+	Reported  corev1.ResourceList `json:"reported"`
+	Available corev1.ResourceList `json:"available"`
+}
+
+// AssumedPodDump holds the resources of a pod reserved on a node, and the NUMA zones they are
+// accounted on, if known; otherwise they are accounted on all the NUMA zones.
+type AssumedPodDump struct {
+	Resources corev1.ResourceList `json:"resources"`
+	Zones     []string            `json:"zones,omitempty"`
+}
+
+// Dump returns a copy of the cache state of all the known nodes. The lock is held only to copy
+// the state: the dump is built, and the fingerprints are computed, after releasing it, so the
+// Filter and Reserve calls are not stalled by the dump.
+func (ov *OverReserve) Dump() Dump {
+	nodes := ov.nodeStates()
+
+	dump := Dump{
+		Nodes: make(map[string]NodeDump, len(nodes)),
+	}
+	for nodeName, state := range nodes {
+		nodeDump := NodeDump{
+			MaybeOverReserved: state.maybeOverReserved,
+			ForeignPods:       state.foreignPods,
+			ResyncAttempts:    state.resyncAttempts,
+			LastResyncFailure: state.lastResyncFailure,
+		}
+		nodeDump.Fingerprint, _ = podFingerprintForNodeTopology(state.nrt, ov.resyncMethod)
+
+		available := state.nrt.DeepCopy()
+		if state.assumedResources != nil {
+			state.assumedResources.UpdateNRT(available)
+			nodeDump.AssumedPods = state.assumedResources.dump()
+		}
+		nodeDump.NUMAZones = make(map[string]NUMAZoneDump, len(state.nrt.Zones))
+		for zi := range state.nrt.Zones {
+			nodeDump.NUMAZones[state.nrt.Zones[zi].Name] = NUMAZoneDump{
+				Reported:  zoneAvailableResources(state.nrt.Zones[zi]),
+				Available: zoneAvailableResources(available.Zones[zi]),
+			}
+		}
+		dump.Nodes[nodeName] = nodeDump
+	}
+	return dump
+}
+
+// nodeState is the cache state of a node, as copied by nodeStates.
+type nodeState struct {
+	nrt               *topologyv1alpha2.NodeResourceTopology
+	assumedResources  *resourceStore
+	maybeOverReserved int
+	foreignPods       int
+	resyncAttempts    int
+	lastResyncFailure string
+}
+
+// nodeStates copies, under the lock, the cache state of all the known nodes. The cached NRT objects
+// and the resources of the assumed pods are replaced, never modified in place, so copying the maps
+// holding them is enough.
+func (ov *OverReserve) nodeStates() map[string]nodeState {
+	ov.lock.Lock()
+	defer ov.lock.Unlock()
+
+	nodes := make(map[string]nodeState, len(ov.nrts.data))
+	for nodeName, nrt := range ov.nrts.data {
+		state := nodeState{
+			nrt:               nrt,
+			maybeOverReserved: ov.nodesMaybeOverreserved[nodeName],
+			foreignPods:       ov.nodesWithForeignPods[nodeName],
+			resyncAttempts:    ov.resyncAttempts[nodeName],
+			lastResyncFailure: ov.resyncFailures[nodeName],
+		}
+		if nodeAssumedResources, ok := ov.assumedResources[nodeName]; ok {
+			state.assumedResources = nodeAssumedResources.shallowCopy()
+		}
+		nodes[nodeName] = state
+	}
+	return nodes
+}
+
+func zoneAvailableResources(zone topologyv1alpha2.Zone) corev1.ResourceList {
+	resources := make(corev1.ResourceList, len(zone.Resources))
+	for _, res := range zone.Resources {
+		resources[corev1.ResourceName(res.Name)] = res.Available.DeepCopy()
+	}
+	return resources
+}
+
+// shallowCopy returns a copy of the resourceStore sharing the resources and the zones of the pods,
+// which are replaced, never modified in place.
+func (rs *resourceStore) shallowCopy() *resourceStore {
+	cp := &resourceStore{
+		data:  make(map[string]corev1.ResourceList, len(rs.data)),
+		zones: make(map[string]sets.Set[string], len(rs.zones)),
+		lh:    rs.lh,
+	}
+	for key, res := range rs.data {
+		cp.data[key] = res
+	}
+	for key, zones := range rs.zones {
+		cp.zones[key] = zones
+	}
+	return cp
+}
+
+func (rs *resourceStore) dump() map[string]AssumedPodDump {
+	pods := make(map[string]AssumedPodDump, len(rs.data))
+	for key, res := range rs.data {
+		pod := AssumedPodDump{
+			Resources: res.DeepCopy(),
+		}
+		if zones, ok := rs.zones[key]; ok {
+			pod.Zones = zones.UnsortedList()
+			sort.Strings(pod.Zones)
+		}
+		pods[key] = pod
+	}
+	return pods
+}
+
+// NewDumpHandler returns the handler serving as JSON the cache state at the time of the request.
+// Only the OverReserve cache keeps a state of its own: for the other caches, the handler answers
+// that the dump is not supported.
+func NewDumpHandler(nrtCache Interface) http.Handler {
+	ov, ok := nrtCache.(*OverReserve)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !ok {
+			http.Error(w, fmt.Sprintf("the cache state dump is not supported by the %T cache, only by the overreserve cache", nrtCache), http.StatusNotImplemented)
+			return
+		}
+		data, err := json.Marshal(ov.Dump())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
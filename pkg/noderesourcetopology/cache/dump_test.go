@@ -0,0 +1,169 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
+	"github.com/k8stopologyawareschedwg/podfingerprint"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/klog/v2"
+
+	tu "sigs.k8s.io/scheduler-plugins/test/util"
+)
+
+func TestDump(t *testing.T) {
+	fakeClient, err := tu.NewFakeClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fakePodLister := &fakePodLister{}
+
+	nrtCache := mustOverReserve(t, fakeClient, fakePodLister)
+
+	nodeTopologies := makeDefaultTestTopology()
+	for _, obj := range nodeTopologies {
+		nrtCache.Store().Update(obj)
+	}
+
+	testPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "namespace1",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+			Containers: []corev1.Container{
+				{
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("8"),
+							corev1.ResourceMemory: resource.MustParse("16Gi"),
+						},
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("8"),
+							corev1.ResourceMemory: resource.MustParse("16Gi"),
+						},
+					},
+				},
+			},
+		},
+	}
+	nrtCache.ReserveNodeResources("node1", testPod, []string{"node-1"})
+	nrtCache.NodeMaybeOverReserved("node1", testPod)
+	nrtCache.NodeMaybeOverReserved("node1", testPod)
+
+	runningPod := testPod.DeepCopy()
+	runningPod.Status.Phase = corev1.PodRunning
+	fakePodLister.AddPod(runningPod)
+
+	updatedNodeTopology := nodeTopologies[0].DeepCopy()
+	updatedNodeTopology.Attributes = topologyv1alpha2.AttributeList{
+		{
+			Name:  podfingerprint.Attribute,
+			Value: "pfp0v001ffffffffffffffff",
+		},
+	}
+	nrtCache.NodeTopologyUpdated(updatedNodeTopology)
+
+	rec := httptest.NewRecorder()
+	NewDumpHandler(nrtCache).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %v: %s", rec.Code, rec.Body.String())
+	}
+	data := rec.Body.Bytes()
+	var got Dump
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	nodeDump, ok := got.Nodes["node1"]
+	if !ok {
+		t.Fatalf("missing node1 in dump: %s", string(data))
+	}
+	if !strings.Contains(nodeDump.LastResyncFailure, podfingerprint.ErrSignatureMismatch.Error()) {
+		t.Errorf("unexpected last resync failure: %q", nodeDump.LastResyncFailure)
+	}
+	nodeDump.LastResyncFailure = ""
+
+	expected := NodeDump{
+		NUMAZones: map[string]NUMAZoneDump{
+			"node-0": {
+				Reported: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("30"),
+					corev1.ResourceMemory: resource.MustParse("60Gi"),
+					nicResourceName:       resource.MustParse("16"),
+				},
+				Available: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("30"),
+					corev1.ResourceMemory: resource.MustParse("60Gi"),
+					nicResourceName:       resource.MustParse("16"),
+				},
+			},
+			"node-1": {
+				Reported: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("30"),
+					corev1.ResourceMemory: resource.MustParse("60Gi"),
+					nicResourceName:       resource.MustParse("16"),
+				},
+				Available: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("22"),
+					corev1.ResourceMemory: resource.MustParse("44Gi"),
+					nicResourceName:       resource.MustParse("16"),
+				},
+			},
+		},
+		AssumedPods: map[string]AssumedPodDump{
+			"namespace1/pod1": {
+				Resources: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("8"),
+					corev1.ResourceMemory: resource.MustParse("16Gi"),
+				},
+				Zones: []string{"node-1"},
+			},
+		},
+		MaybeOverReserved: 2,
+		ResyncAttempts:    1,
+	}
+	if diff := cmp.Diff(expected, nodeDump); diff != "" {
+		t.Errorf("unexpected dump (-want +got):\n%s", diff)
+	}
+}
+
+func TestDumpNotSupported(t *testing.T) {
+	fakeClient, err := tu.NewFakeClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	NewDumpHandler(NewPassthrough(klog.Background(), fakeClient)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusNotImplemented || !strings.Contains(rec.Body.String(), "not supported") {
+		t.Errorf("unexpected response %v: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8scache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/logging"
+)
+
+// SetupNodeTopologyResyncOnUpdate makes the cache attempt to resync the dirty nodes as soon as
+// their NRT data is added or updated, instead of waiting for the next periodic Resync, and drop
+// the metrics of the nodes whose NRT data is deleted.
+// The event handlers only queue the node names, so they never wait for a resync in progress:
+// a worker resyncs the queued nodes with their latest NRT data from the informer store, until
+// the context is done.
+func SetupNodeTopologyResyncOnUpdate(ctx context.Context, lh logr.Logger, nrtInformer k8scache.SharedInformer, ov *OverReserve) error {
+	queue := workqueue.New()
+	nodeTopologyUpdated := func(obj interface{}) {
+		nrt, ok := obj.(*topologyv1alpha2.NodeResourceTopology)
+		if !ok {
+			lh.V(3).Info("unsupported object", "kind", fmt.Sprintf("%T", obj))
+			return
+		}
+		queue.Add(nrt.Name)
+	}
+
+	_, err := nrtInformer.AddEventHandler(k8scache.ResourceEventHandlerFuncs{
+		AddFunc: nodeTopologyUpdated,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			nodeTopologyUpdated(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(k8scache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			nrt, ok := obj.(*topologyv1alpha2.NodeResourceTopology)
+			if !ok {
+				lh.V(3).Info("unsupported object", "kind", fmt.Sprintf("%T", obj))
+				return
+			}
+			ov.NodeTopologyDeleted(nrt.Name)
+		},
+	})
+	if err != nil {
+		queue.ShutDown()
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		queue.ShutDown()
+	}()
+	go wait.Until(func() {
+		for resyncNextNodeTopology(lh, queue, nrtInformer.GetStore(), ov) {
+		}
+	}, time.Second, ctx.Done())
+	return nil
+}
+
+// resyncNextNodeTopology attempts to resync the next queued node, and returns false once the queue is shut down.
+func resyncNextNodeTopology(lh logr.Logger, queue workqueue.Interface, store k8scache.Store, ov *OverReserve) bool {
+	item, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+	defer queue.Done(item)
+
+	nodeName := item.(string)
+	// the NRT objects are cluster-scoped, so their key is their name
+	obj, exists, err := store.GetByKey(nodeName)
+	if err != nil || !exists {
+		lh.V(4).Info("cannot find NodeTopology", logging.KeyNode, nodeName, "error", err)
+		return true
+	}
+	nrt, ok := obj.(*topologyv1alpha2.NodeResourceTopology)
+	if !ok {
+		lh.V(3).Info("unsupported object", "kind", fmt.Sprintf("%T", obj))
+		return true
+	}
+	ov.NodeTopologyUpdated(nrt)
+	return true
+}
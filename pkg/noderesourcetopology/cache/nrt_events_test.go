@@ -0,0 +1,216 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
+	"github.com/k8stopologyawareschedwg/podfingerprint"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8scache "k8s.io/client-go/tools/cache"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/metrics"
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/podprovider"
+	tu "sigs.k8s.io/scheduler-plugins/test/util"
+)
+
+func TestResyncNextNodeTopology(t *testing.T) {
+	fakeClient, err := tu.NewFakeClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	podIndexer := k8scache.NewIndexer(k8scache.MetaNamespaceKeyFunc, k8scache.Indexers{podprovider.NodeNameIndex: podprovider.NodeNameIndexFunc})
+	nrtCache := mustOverReserve(t, fakeClient, podprovider.NewNodePodLister(podIndexer))
+	for _, obj := range makeDefaultTestTopology() {
+		nrtCache.Store().Update(obj)
+	}
+
+	testPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "namespace1",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+			Containers: []corev1.Container{
+				{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("8"),
+							corev1.ResourceMemory: resource.MustParse("16Gi"),
+						},
+					},
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+		},
+	}
+	nrtCache.ReserveNodeResources("node1", testPod, nil)
+	nrtCache.NodeMaybeOverReserved("node1", testPod)
+
+	// the pods of the other nodes are not part of the fingerprint of node1
+	otherPod := testPod.DeepCopy()
+	otherPod.Name = "pod2"
+	otherPod.Spec.NodeName = "node2"
+	for _, pod := range []*corev1.Pod{testPod, otherPod} {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	updatedNodeTopology := &topologyv1alpha2.NodeResourceTopology{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node1",
+		},
+		TopologyPolicies: []string{string(topologyv1alpha2.SingleNUMANodeContainerLevel)},
+		Zones: topologyv1alpha2.ZoneList{
+			{
+				Name: "node-0",
+				Type: "Node",
+				Resources: topologyv1alpha2.ResourceInfoList{
+					MakeTopologyResInfo(cpu, "32", "30"),
+					MakeTopologyResInfo(memory, "64Gi", "60Gi"),
+					MakeTopologyResInfo(nicResourceName, "16", "16"),
+				},
+			},
+			{
+				Name: "node-1",
+				Type: "Node",
+				Resources: topologyv1alpha2.ResourceInfoList{
+					MakeTopologyResInfo(cpu, "32", "22"),
+					MakeTopologyResInfo(memory, "64Gi", "44Gi"),
+					MakeTopologyResInfo(nicResourceName, "16", "16"),
+				},
+			},
+		},
+		Attributes: topologyv1alpha2.AttributeList{
+			{
+				Name:  podfingerprint.Attribute,
+				Value: "pfp0v0019e0420efb37746c6",
+			},
+		},
+	}
+	nrtStore := k8scache.NewStore(k8scache.MetaNamespaceKeyFunc)
+	if err := nrtStore.Add(updatedNodeTopology); err != nil {
+		t.Fatal(err)
+	}
+
+	queue := workqueue.New()
+	// the updates of the same node are merged while queued, and the nodes without NRT data are skipped
+	queue.Add("node1")
+	queue.Add("node1")
+	queue.Add("node-without-nrt")
+	if queue.Len() != 2 {
+		t.Fatalf("queued nodes %d expected 2", queue.Len())
+	}
+	for queue.Len() > 0 {
+		if !resyncNextNodeTopology(klog.Background(), queue, nrtStore, nrtCache) {
+			t.Fatalf("queue unexpectedly shut down")
+		}
+	}
+
+	if dirtyNodes := nrtCache.NodesMaybeOverReserved(klog.Background()); len(dirtyNodes) > 0 {
+		t.Errorf("dirty nodes after the NRT update: %v", dirtyNodes)
+	}
+	nrtObj, _ := nrtCache.GetCachedNRTCopy(context.Background(), "node1", testPod)
+	if !isNRTEqual(nrtObj, updatedNodeTopology) {
+		t.Errorf("unexpected nrt from cache\ngot: %v\nupdated: %v\n", dumpNRT(nrtObj), dumpNRT(updatedNodeTopology))
+	}
+
+	queue.ShutDown()
+	if resyncNextNodeTopology(klog.Background(), queue, nrtStore, nrtCache) {
+		t.Errorf("resync went on after the queue shut down")
+	}
+}
+
+func TestNodeTopologyDeleted(t *testing.T) {
+	fakeClient, err := tu.NewFakeClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics.Register()
+	nrtCache := mustOverReserve(t, fakeClient, &fakePodLister{})
+	nodeTopologies := makeDefaultTestTopology()
+	nrtCache.FlushNodes(klog.Background(), nodeTopologies...)
+	if !hasLastSyncTimestamp(t, "node1") {
+		t.Fatalf("missing last sync timestamp of node1")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := fcache.NewFakeControllerSource()
+	for _, nrt := range nodeTopologies {
+		source.Add(nrt.DeepCopy())
+	}
+	nrtInformer := k8scache.NewSharedIndexInformer(source, &topologyv1alpha2.NodeResourceTopology{}, 0, k8scache.Indexers{})
+	if err := SetupNodeTopologyResyncOnUpdate(ctx, klog.Background(), nrtInformer, nrtCache); err != nil {
+		t.Fatal(err)
+	}
+	go nrtInformer.Run(ctx.Done())
+	if !k8scache.WaitForCacheSync(ctx.Done(), nrtInformer.HasSynced) {
+		t.Fatalf("cannot sync the informer")
+	}
+
+	source.Delete(nodeTopologies[0].DeepCopy())
+	err = wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		return !hasLastSyncTimestamp(t, nodeTopologies[0].Name), nil
+	})
+	if err != nil {
+		t.Errorf("last sync timestamp of the deleted node %s still reported: %v", nodeTopologies[0].Name, err)
+	}
+	for _, nrt := range nodeTopologies[1:] {
+		if !hasLastSyncTimestamp(t, nrt.Name) {
+			t.Errorf("missing last sync timestamp of %s", nrt.Name)
+		}
+	}
+}
+
+func hasLastSyncTimestamp(t *testing.T, nodeName string) bool {
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "noderesourcetopology_cache_last_sync_timestamp_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "node" && label.GetValue() == nodeName {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
@@ -36,24 +37,32 @@ import (
 
 	apiconfig "sigs.k8s.io/scheduler-plugins/apis/config"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/logging"
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/metrics"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/podprovider"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/resourcerequests"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/stringify"
 )
 
 type OverReserve struct {
-	lh               logr.Logger
-	client           ctrlclient.Client
-	lock             sync.Mutex
+	lh     logr.Logger
+	client ctrlclient.Client
+	lock   sync.Mutex
+	// resyncLock serializes the resync attempts, periodic or triggered by NRT updates,
+	// so they cannot flush concurrently the data of the same node.
+	resyncLock       sync.Mutex
 	nrts             *nrtStore
 	assumedResources map[string]*resourceStore // nodeName -> resourceStore
 	// nodesMaybeOverreserved counts how many times a node is filtered out. This is used as trigger condition to try
 	// to resync nodes. See The documentation of Resync() below for more details.
 	nodesMaybeOverreserved counter
 	nodesWithForeignPods   counter
-	podLister              podlisterv1.PodLister
-	resyncMethod           apiconfig.CacheResyncMethod
-	isPodRelevant          podprovider.PodFilterFunc
+	// resyncAttempts counts the resync attempts of the dirty nodes, and resyncFailures holds the reason
+	// of the last failed one, for debugging purposes. Both are reset once a node is flushed.
+	resyncAttempts counter
+	resyncFailures map[string]string
+	podLister      podlisterv1.PodLister
+	resyncMethod   apiconfig.CacheResyncMethod
+	isPodRelevant  podprovider.PodFilterFunc
 }
 
 func NewOverReserve(ctx context.Context, lh logr.Logger, cfg *apiconfig.NodeResourceTopologyCache, client ctrlclient.Client,
@@ -77,10 +86,16 @@ func NewOverReserve(ctx context.Context, lh logr.Logger, cfg *apiconfig.NodeReso
 		assumedResources:       make(map[string]*resourceStore),
 		nodesMaybeOverreserved: newCounter(),
 		nodesWithForeignPods:   newCounter(),
+		resyncAttempts:         newCounter(),
+		resyncFailures:         make(map[string]string),
 		podLister:              podLister,
 		resyncMethod:           resyncMethod,
 		isPodRelevant:          isPodRelevant,
 	}
+	now := time.Now()
+	for _, nrt := range nrtObjs.Items {
+		metrics.CacheLastSyncTimestamp.WithLabelValues(nrt.Name).Set(float64(now.Unix()))
+	}
 	return obj, nil
 }
 
@@ -114,6 +129,9 @@ func (ov *OverReserve) NodeMaybeOverReserved(nodeName string, pod *corev1.Pod) {
 	ov.lock.Lock()
 	defer ov.lock.Unlock()
 	val := ov.nodesMaybeOverreserved.Incr(nodeName)
+	if val == 1 {
+		metrics.CacheNodesMaybeOverReserved.Inc()
+	}
 	ov.lh.V(4).Info("mark discarded", logging.KeyNode, nodeName, "count", val)
 }
 
@@ -126,11 +144,14 @@ func (ov *OverReserve) NodeHasForeignPods(nodeName string, pod *corev1.Pod) {
 		return
 	}
 	val := ov.nodesWithForeignPods.Incr(nodeName)
+	if val == 1 {
+		metrics.CacheNodesWithForeignPods.Inc()
+	}
 	lh.V(2).Info("marked with foreign pods", logging.KeyNode, nodeName, "count", val)
 }
 
-func (ov *OverReserve) ReserveNodeResources(nodeName string, pod *corev1.Pod) {
-	lh := ov.lh.WithValues(logging.KeyPod, klog.KObj(pod), logging.KeyPodUID, logging.PodUID(pod), logging.KeyNode, nodeName)
+func (ov *OverReserve) ReserveNodeResources(nodeName string, pod *corev1.Pod, zones []string) {
+	lh := ov.lh.WithValues(logging.KeyPod, klog.KObj(pod), logging.KeyPodUID, logging.PodUID(pod), logging.KeyNode, nodeName, "zones", zones)
 	ov.lock.Lock()
 	defer ov.lock.Unlock()
 	nodeAssumedResources, ok := ov.assumedResources[nodeName]
@@ -139,10 +160,10 @@ func (ov *OverReserve) ReserveNodeResources(nodeName string, pod *corev1.Pod) {
 		ov.assumedResources[nodeName] = nodeAssumedResources
 	}
 
-	nodeAssumedResources.AddPod(pod)
+	nodeAssumedResources.AddPod(pod, zones)
 	lh.V(2).Info("post reserve", logging.KeyNode, nodeName, "assumedResources", nodeAssumedResources.String())
 
-	ov.nodesMaybeOverreserved.Delete(nodeName)
+	ov.clearNodeMaybeOverReserved(nodeName)
 	lh.V(6).Info("reset discard counter", logging.KeyNode, nodeName)
 }
 
@@ -205,6 +226,9 @@ func (ov *OverReserve) Resync() {
 	lh_.V(4).Info(logging.FlowBegin)
 	defer lh_.V(4).Info(logging.FlowEnd)
 
+	ov.resyncLock.Lock()
+	defer ov.resyncLock.Unlock()
+
 	nodeNames := ov.NodesMaybeOverReserved(lh_)
 	// avoid as much as we can unnecessary work and logs.
 	if len(nodeNames) == 0 {
@@ -226,10 +250,12 @@ func (ov *OverReserve) Resync() {
 		nrtCandidate := &topologyv1alpha2.NodeResourceTopology{}
 		if err := ov.client.Get(context.Background(), types.NamespacedName{Name: nodeName}, nrtCandidate); err != nil {
 			lh.V(2).Info("failed to get NodeTopology", "error", err)
+			ov.resyncAttempted(nodeName, metrics.TriggerPeriodic, metrics.ResyncError, fmt.Sprintf("failed to get NodeTopology: %v", err))
 			continue
 		}
 		if nrtCandidate == nil {
 			lh.V(2).Info("missing NodeTopology")
+			ov.resyncAttempted(nodeName, metrics.TriggerPeriodic, metrics.ResyncError, "missing NodeTopology")
 			continue
 		}
 
@@ -237,26 +263,11 @@ func (ov *OverReserve) Resync() {
 		if !ok {
 			// this really should never happen
 			lh.Info("cannot find any pod for node")
+			ov.resyncAttempted(nodeName, metrics.TriggerPeriodic, metrics.ResyncError, "cannot find any pod for node")
 			continue
 		}
 
-		pfpExpected, onlyExclRes := podFingerprintForNodeTopology(nrtCandidate, ov.resyncMethod)
-		if pfpExpected == "" {
-			lh.V(2).Info("missing NodeTopology podset fingerprint data")
-			continue
-		}
-
-		lh.V(4).Info("trying to sync NodeTopology", "fingerprint", pfpExpected, "onlyExclusiveResources", onlyExclRes)
-
-		err = checkPodFingerprintForNode(lh, objs, nodeName, pfpExpected, onlyExclRes)
-		if errors.Is(err, podfingerprint.ErrSignatureMismatch) {
-			// can happen, not critical
-			lh.V(4).Info("NodeTopology podset fingerprint mismatch")
-			continue
-		}
-		if err != nil {
-			// should never happen, let's be vocal
-			lh.Error(err, "checking NodeTopology podset fingerprint")
+		if !ov.canSyncNodeTopology(lh, metrics.TriggerPeriodic, nrtCandidate, objs) {
 			continue
 		}
 
@@ -267,17 +278,129 @@ func (ov *OverReserve) Resync() {
 	ov.FlushNodes(lh_, nrtUpdates...)
 }
 
+// NodeTopologyUpdated attempts to resync a node as soon as its NRT data is updated, if the node is dirty,
+// without waiting for the next Resync. The NRT object must not be modified by the caller afterwards.
+// Resync still runs periodically as a fallback, for example to catch updates received while the node
+// was not yet dirty, or if the events are delayed.
+func (ov *OverReserve) NodeTopologyUpdated(nrt *topologyv1alpha2.NodeResourceTopology) {
+	if nrt == nil {
+		return
+	}
+	nodeName := nrt.Name
+	if !ov.isNodeDirty(nodeName) {
+		return
+	}
+
+	// we are not working with a specific pod, so we need a unique key to track this flow
+	lh := ov.lh.WithName(logging.FlowCacheSync).WithValues(logging.KeyLogID, logging.TimeLogID(), logging.KeyNode, nodeName)
+	lh.V(4).Info(logging.FlowBegin, "trigger", "update")
+	defer lh.V(4).Info(logging.FlowEnd, "trigger", "update")
+
+	ov.resyncLock.Lock()
+	defer ov.resyncLock.Unlock()
+
+	objs, err := makePodDataForNode(lh, ov.podLister, ov.isPodRelevant, nodeName)
+	if err != nil {
+		lh.Error(err, "cannot find the running pods on node")
+		return
+	}
+	if len(objs) == 0 {
+		// this really should never happen
+		lh.Info("cannot find any pod for node")
+		ov.resyncAttempted(nodeName, metrics.TriggerUpdate, metrics.ResyncError, "cannot find any pod for node")
+		return
+	}
+
+	if !ov.canSyncNodeTopology(lh, metrics.TriggerUpdate, nrt, objs) {
+		return
+	}
+
+	lh.V(4).Info("overriding cached info")
+	ov.FlushNodes(lh, nrt)
+}
+
+// NodeTopologyDeleted drops the last sync timestamp of a node whose NRT data is deleted, so the metric
+// does not report forever a node which may be gone.
+func (ov *OverReserve) NodeTopologyDeleted(nodeName string) {
+	ov.lh.V(4).Info("NodeTopology deleted", logging.KeyNode, nodeName)
+	metrics.CacheLastSyncTimestamp.DeleteLabelValues(nodeName)
+}
+
+// canSyncNodeTopology returns true if the pods fingerprint of the given NRT object matches the pods
+// running on the node, so the cached data of the node can be replaced by the NRT object.
+// The trigger tells which flow, periodic or NRT update, attempts the resync.
+func (ov *OverReserve) canSyncNodeTopology(lh logr.Logger, trigger string, nrtCandidate *topologyv1alpha2.NodeResourceTopology, objs []podData) bool {
+	pfpExpected, onlyExclRes := podFingerprintForNodeTopology(nrtCandidate, ov.resyncMethod)
+	if pfpExpected == "" {
+		lh.V(2).Info("missing NodeTopology podset fingerprint data")
+		ov.resyncAttempted(nrtCandidate.Name, trigger, metrics.ResyncMissingFingerprint, "missing NodeTopology podset fingerprint data")
+		return false
+	}
+
+	lh.V(4).Info("trying to sync NodeTopology", "fingerprint", pfpExpected, "onlyExclusiveResources", onlyExclRes)
+
+	err := checkPodFingerprintForNode(lh, objs, nrtCandidate.Name, pfpExpected, onlyExclRes)
+	if errors.Is(err, podfingerprint.ErrSignatureMismatch) {
+		// can happen, not critical
+		lh.V(4).Info("NodeTopology podset fingerprint mismatch")
+		ov.resyncAttempted(nrtCandidate.Name, trigger, metrics.ResyncFingerprintMismatch, err.Error())
+		return false
+	}
+	if err != nil {
+		// should never happen, let's be vocal
+		lh.Error(err, "checking NodeTopology podset fingerprint")
+		ov.resyncAttempted(nrtCandidate.Name, trigger, metrics.ResyncError, err.Error())
+		return false
+	}
+	ov.resyncAttempted(nrtCandidate.Name, trigger, metrics.ResyncSuccess, "")
+	return true
+}
+
+// resyncAttempted records a resync attempt of a node, along with the reason it failed, if it did.
+func (ov *OverReserve) resyncAttempted(nodeName, trigger, result, failure string) {
+	metrics.CacheResyncAttempts.WithLabelValues(trigger, result).Inc()
+	ov.lock.Lock()
+	defer ov.lock.Unlock()
+	ov.resyncAttempts.Incr(nodeName)
+	if failure != "" {
+		ov.resyncFailures[nodeName] = failure
+	}
+}
+
+// isNodeDirty returns true if the node is a candidate for resync; see NodesMaybeOverReserved.
+func (ov *OverReserve) isNodeDirty(nodeName string) bool {
+	ov.lock.Lock()
+	defer ov.lock.Unlock()
+	return ov.nodesMaybeOverreserved.IsSet(nodeName) || ov.nodesWithForeignPods.IsSet(nodeName)
+}
+
 // FlushNodes drops all the cached information about a given node, resetting its state clean.
 func (ov *OverReserve) FlushNodes(lh logr.Logger, nrts ...*topologyv1alpha2.NodeResourceTopology) {
+	now := time.Now()
 	ov.lock.Lock()
 	defer ov.lock.Unlock()
 	for _, nrt := range nrts {
 		lh.V(2).Info("flushing", logging.KeyNode, nrt.Name)
 		ov.nrts.Update(nrt)
 		delete(ov.assumedResources, nrt.Name)
-		ov.nodesMaybeOverreserved.Delete(nrt.Name)
-		ov.nodesWithForeignPods.Delete(nrt.Name)
+		ov.clearNodeMaybeOverReserved(nrt.Name)
+		if ov.nodesWithForeignPods.IsSet(nrt.Name) {
+			ov.nodesWithForeignPods.Delete(nrt.Name)
+			metrics.CacheNodesWithForeignPods.Dec()
+		}
+		ov.resyncAttempts.Delete(nrt.Name)
+		delete(ov.resyncFailures, nrt.Name)
+		metrics.CacheLastSyncTimestamp.WithLabelValues(nrt.Name).Set(float64(now.Unix()))
+	}
+}
+
+// clearNodeMaybeOverReserved resets the discard counter of a node. Must be called with the lock held.
+func (ov *OverReserve) clearNodeMaybeOverReserved(nodeName string) {
+	if !ov.nodesMaybeOverreserved.IsSet(nodeName) {
+		return
 	}
+	ov.nodesMaybeOverreserved.Delete(nodeName)
+	metrics.CacheNodesMaybeOverReserved.Dec()
 }
 
 // to be used only in tests
@@ -306,6 +429,34 @@ func makeNodeToPodDataMap(lh logr.Logger, podLister podlisterv1.PodLister, isPod
 	return nodeToObjsMap, nil
 }
 
+func makePodDataForNode(lh logr.Logger, podLister podlisterv1.PodLister, isPodRelevant podprovider.PodFilterFunc, nodeName string) ([]podData, error) {
+	var objs []podData
+	pods, err := listPodsOnNode(podLister, nodeName)
+	if err != nil {
+		return objs, err
+	}
+	for _, pod := range pods {
+		if pod.Spec.NodeName != nodeName || !isPodRelevant(lh, pod) {
+			continue
+		}
+		objs = append(objs, podData{
+			Namespace:             pod.Namespace,
+			Name:                  pod.Name,
+			HasExclusiveResources: resourcerequests.AreExclusiveForPod(pod),
+		})
+	}
+	return objs, nil
+}
+
+// listPodsOnNode lists the pods bound to a node through the index of the lister, if it has one;
+// otherwise, it lists all the pods, and the caller must filter them.
+func listPodsOnNode(podLister podlisterv1.PodLister, nodeName string) ([]*corev1.Pod, error) {
+	if nodeLister, ok := podLister.(podprovider.NodePodLister); ok {
+		return nodeLister.ListOnNode(nodeName)
+	}
+	return podLister.List(labels.Everything())
+}
+
 func getCacheResyncMethod(lh logr.Logger, cfg *apiconfig.NodeResourceTopologyCache) apiconfig.CacheResyncMethod {
 	var resyncMethod apiconfig.CacheResyncMethod
 	if cfg != nil && cfg.ResyncMethod != nil {
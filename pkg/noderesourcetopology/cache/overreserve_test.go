@@ -31,11 +31,13 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	podlisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/component-base/metrics/testutil"
 	"k8s.io/klog/v2"
 
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	apiconfig "sigs.k8s.io/scheduler-plugins/apis/config"
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/metrics"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/podprovider"
 	tu "sigs.k8s.io/scheduler-plugins/test/util"
 )
@@ -147,7 +149,7 @@ func TestDirtyNodesMarkDiscarded(t *testing.T) {
 	}
 
 	for _, nodeName := range expectedNodes {
-		nrtCache.ReserveNodeResources(nodeName, &corev1.Pod{})
+		nrtCache.ReserveNodeResources(nodeName, &corev1.Pod{}, nil)
 	}
 
 	dirtyNodes := nrtCache.NodesMaybeOverReserved(klog.Background())
@@ -183,7 +185,7 @@ func TestDirtyNodesUnmarkedOnReserve(t *testing.T) {
 	}
 
 	for _, nodeName := range availNodes {
-		nrtCache.ReserveNodeResources(nodeName, &corev1.Pod{})
+		nrtCache.ReserveNodeResources(nodeName, &corev1.Pod{}, nil)
 	}
 
 	dirtyNodes := nrtCache.NodesMaybeOverReserved(klog.Background())
@@ -196,7 +198,7 @@ func TestDirtyNodesUnmarkedOnReserve(t *testing.T) {
 	}
 
 	// assume noe update which unblocks node-4
-	nrtCache.ReserveNodeResources("node-4", &corev1.Pod{})
+	nrtCache.ReserveNodeResources("node-4", &corev1.Pod{}, nil)
 
 	expectedNodes := []string{
 		"node-1",
@@ -268,7 +270,7 @@ func TestGetCachedNRTCopyReserve(t *testing.T) {
 			},
 		},
 	}
-	nrtCache.ReserveNodeResources("node1", testPod)
+	nrtCache.ReserveNodeResources("node1", testPod, nil)
 
 	nrtObj, _ := nrtCache.GetCachedNRTCopy(context.Background(), "node1", testPod)
 	for _, zone := range nrtObj.Zones {
@@ -361,7 +363,7 @@ func TestGetCachedNRTCopyReserveRelease(t *testing.T) {
 			},
 		},
 	}
-	nrtCache.ReserveNodeResources("node1", testPod)
+	nrtCache.ReserveNodeResources("node1", testPod, nil)
 	nrtCache.UnreserveNodeResources("node1", testPod)
 
 	nrtObj, _ := nrtCache.GetCachedNRTCopy(context.Background(), "node1", testPod)
@@ -404,7 +406,7 @@ func TestFlush(t *testing.T) {
 		},
 	}
 
-	nrtCache.ReserveNodeResources("node1", testPod)
+	nrtCache.ReserveNodeResources("node1", testPod, nil)
 	nrtCache.NodeMaybeOverReserved("node1", testPod)
 
 	expectedNodeTopology := &topologyv1alpha2.NodeResourceTopology{
@@ -484,7 +486,7 @@ func TestResyncNoPodFingerprint(t *testing.T) {
 			},
 		},
 	}
-	nrtCache.ReserveNodeResources("node1", testPod)
+	nrtCache.ReserveNodeResources("node1", testPod, nil)
 	nrtCache.NodeMaybeOverReserved("node1", testPod)
 
 	expectedNodeTopology := &topologyv1alpha2.NodeResourceTopology{
@@ -563,7 +565,7 @@ func TestResyncMatchFingerprint(t *testing.T) {
 			},
 		},
 	}
-	nrtCache.ReserveNodeResources("node1", testPod)
+	nrtCache.ReserveNodeResources("node1", testPod, nil)
 	nrtCache.NodeMaybeOverReserved("node1", testPod)
 
 	expectedNodeTopology := &topologyv1alpha2.NodeResourceTopology{
@@ -624,6 +626,171 @@ func TestResyncMatchFingerprint(t *testing.T) {
 	}
 }
 
+func TestNodeTopologyUpdated(t *testing.T) {
+	tcases := []struct {
+		description  string
+		dirty        bool
+		fingerprint  string
+		expectDirty  bool
+		expectSync   bool
+		expectResult string
+	}{
+		{
+			description:  "dirty node, matching fingerprint",
+			dirty:        true,
+			fingerprint:  "pfp0v0019e0420efb37746c6",
+			expectDirty:  false,
+			expectSync:   true,
+			expectResult: metrics.ResyncSuccess,
+		},
+		{
+			description:  "dirty node, mismatching fingerprint",
+			dirty:        true,
+			fingerprint:  "pfp0v001ffffffffffffffff",
+			expectDirty:  true,
+			expectSync:   false,
+			expectResult: metrics.ResyncFingerprintMismatch,
+		},
+		{
+			description:  "dirty node, missing fingerprint",
+			dirty:        true,
+			expectDirty:  true,
+			expectSync:   false,
+			expectResult: metrics.ResyncMissingFingerprint,
+		},
+		{
+			description: "clean node",
+			dirty:       false,
+			fingerprint: "pfp0v0019e0420efb37746c6",
+			expectDirty: false,
+			expectSync:  false,
+		},
+	}
+
+	metrics.Register()
+	resyncAttempts := func(result string) float64 {
+		val, err := testutil.GetCounterMetricValue(metrics.CacheResyncAttempts.WithLabelValues(metrics.TriggerUpdate, result))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return val
+	}
+	results := []string{metrics.ResyncSuccess, metrics.ResyncFingerprintMismatch, metrics.ResyncMissingFingerprint, metrics.ResyncError}
+
+	for _, tcase := range tcases {
+		t.Run(tcase.description, func(t *testing.T) {
+			fakeClient, err := tu.NewFakeClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fakePodLister := &fakePodLister{}
+
+			nrtCache := mustOverReserve(t, fakeClient, fakePodLister)
+
+			nodeTopologies := makeDefaultTestTopology()
+			for _, obj := range nodeTopologies {
+				nrtCache.Store().Update(obj)
+			}
+
+			attemptsBefore := make(map[string]float64)
+			for _, result := range results {
+				attemptsBefore[result] = resyncAttempts(result)
+			}
+
+			testPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod1",
+					Namespace: "namespace1",
+				},
+				Spec: corev1.PodSpec{
+					NodeName: "node1",
+					Containers: []corev1.Container{
+						{
+							Resources: corev1.ResourceRequirements{
+								Limits: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("8"),
+									corev1.ResourceMemory: resource.MustParse("16Gi"),
+								},
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("8"),
+									corev1.ResourceMemory: resource.MustParse("16Gi"),
+								},
+							},
+						},
+					},
+				},
+			}
+			nrtCache.ReserveNodeResources("node1", testPod, nil)
+			if tcase.dirty {
+				nrtCache.NodeMaybeOverReserved("node1", testPod)
+			}
+
+			runningPod := testPod.DeepCopy()
+			runningPod.Status.Phase = corev1.PodRunning
+			fakePodLister.AddPod(runningPod)
+
+			updatedNodeTopology := &topologyv1alpha2.NodeResourceTopology{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node1",
+				},
+				TopologyPolicies: []string{string(topologyv1alpha2.SingleNUMANodeContainerLevel)},
+				Zones: topologyv1alpha2.ZoneList{
+					{
+						Name: "node-0",
+						Type: "Node",
+						Resources: topologyv1alpha2.ResourceInfoList{
+							MakeTopologyResInfo(cpu, "32", "30"),
+							MakeTopologyResInfo(memory, "64Gi", "60Gi"),
+							MakeTopologyResInfo(nicResourceName, "16", "16"),
+						},
+					},
+					{
+						Name: "node-1",
+						Type: "Node",
+						Resources: topologyv1alpha2.ResourceInfoList{
+							MakeTopologyResInfo(cpu, "32", "22"),
+							MakeTopologyResInfo(memory, "64Gi", "44Gi"),
+							MakeTopologyResInfo(nicResourceName, "16", "16"),
+						},
+					},
+				},
+			}
+			if tcase.fingerprint != "" {
+				updatedNodeTopology.Attributes = topologyv1alpha2.AttributeList{
+					{
+						Name:  podfingerprint.Attribute,
+						Value: tcase.fingerprint,
+					},
+				}
+			}
+
+			nrtCache.NodeTopologyUpdated(updatedNodeTopology)
+
+			dirtyNodes := nrtCache.NodesMaybeOverReserved(klog.Background())
+			if isDirty := len(dirtyNodes) > 0; isDirty != tcase.expectDirty {
+				t.Errorf("dirty nodes after the NRT update: %v expected dirty %v", dirtyNodes, tcase.expectDirty)
+			}
+
+			nrtObj, _ := nrtCache.GetCachedNRTCopy(context.Background(), "node1", testPod)
+			if isSynced := isNRTEqual(nrtObj, updatedNodeTopology); isSynced != tcase.expectSync {
+				t.Errorf("unexpected nrt from cache, synced %v expected %v\ngot: %v\nupdated: %v\n",
+					isSynced, tcase.expectSync, dumpNRT(nrtObj), dumpNRT(updatedNodeTopology))
+			}
+
+			for _, result := range results {
+				expected := attemptsBefore[result]
+				if result == tcase.expectResult {
+					expected++
+				}
+				if got := resyncAttempts(result); got != expected {
+					t.Errorf("resync attempts with result %q: %v expected %v", result, got, expected)
+				}
+			}
+		})
+	}
+}
+
 func isNRTEqual(a, b *topologyv1alpha2.NodeResourceTopology) bool {
 	return equality.Semantic.DeepDerivative(a.Zones, b.Zones) &&
 		equality.Semantic.DeepDerivative(a.TopologyPolicies, b.TopologyPolicies) &&
@@ -50,8 +50,8 @@ func (pt Passthrough) GetCachedNRTCopy(ctx context.Context, nodeName string, _ *
 	return nrt, true
 }
 
-func (pt Passthrough) NodeMaybeOverReserved(nodeName string, pod *corev1.Pod)  {}
-func (pt Passthrough) NodeHasForeignPods(nodeName string, pod *corev1.Pod)     {}
-func (pt Passthrough) ReserveNodeResources(nodeName string, pod *corev1.Pod)   {}
-func (pt Passthrough) UnreserveNodeResources(nodeName string, pod *corev1.Pod) {}
-func (pt Passthrough) PostBind(nodeName string, pod *corev1.Pod)               {}
+func (pt Passthrough) NodeMaybeOverReserved(nodeName string, pod *corev1.Pod)                {}
+func (pt Passthrough) NodeHasForeignPods(nodeName string, pod *corev1.Pod)                   {}
+func (pt Passthrough) ReserveNodeResources(nodeName string, pod *corev1.Pod, zones []string) {}
+func (pt Passthrough) UnreserveNodeResources(nodeName string, pod *corev1.Pod)               {}
+func (pt Passthrough) PostBind(nodeName string, pod *corev1.Pod)                             {}
@@ -21,6 +21,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/go-logr/logr"
 	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
@@ -79,13 +80,17 @@ func (nrs *nrtStore) Update(nrt *topologyv1alpha2.NodeResourceTopology) {
 type resourceStore struct {
 	// key: namespace + "/" name
 	data map[string]corev1.ResourceList
-	lh   logr.Logger
+	// zones holds the names of the NUMA zones each pod is expected to be aligned on, if known.
+	// key: namespace + "/" name
+	zones map[string]sets.Set[string]
+	lh    logr.Logger
 }
 
 func newResourceStore(lh logr.Logger) *resourceStore {
 	return &resourceStore{
-		data: make(map[string]corev1.ResourceList),
-		lh:   lh,
+		data:  make(map[string]corev1.ResourceList),
+		zones: make(map[string]sets.Set[string]),
+		lh:    lh,
 	}
 }
 
@@ -97,8 +102,9 @@ func (rs *resourceStore) String() string {
 	return sb.String()
 }
 
-// AddPod returns true if updating existing pod, false if adding for the first time
-func (rs *resourceStore) AddPod(pod *corev1.Pod) bool {
+// AddPod returns true if updating existing pod, false if adding for the first time.
+// If zones is not empty, the resources of the pod are accounted only on the NUMA zones named there.
+func (rs *resourceStore) AddPod(pod *corev1.Pod, zones []string) bool {
 	key := pod.Namespace + "/" + pod.Name
 	_, ok := rs.data[key]
 	if ok {
@@ -108,6 +114,11 @@ func (rs *resourceStore) AddPod(pod *corev1.Pod) bool {
 	resData := util.GetPodEffectiveRequest(pod)
 	rs.lh.V(5).Info("resourcestore ADD", stringify.ResourceListToLoggable(resData)...)
 	rs.data[key] = resData
+	if len(zones) > 0 {
+		rs.zones[key] = sets.New[string](zones...)
+	} else {
+		delete(rs.zones, key)
+	}
 	return ok
 }
 
@@ -121,20 +132,26 @@ func (rs *resourceStore) DeletePod(pod *corev1.Pod) bool {
 	}
 	rs.lh.V(5).Info("resourcestore DEL", stringify.ResourceListToLoggable(rs.data[key])...)
 	delete(rs.data, key)
+	delete(rs.zones, key)
 	return ok
 }
 
 // UpdateNRT updates the provided Node Resource Topology object with the resources tracked in this store,
-// performing pessimistic overallocation across all the NUMA zones.
+// performing pessimistic overallocation across all the NUMA zones, or across the NUMA zones the pods
+// are expected to be aligned on, when known.
 func (rs *resourceStore) UpdateNRT(nrt *topologyv1alpha2.NodeResourceTopology, logKeysAndValues ...any) {
 	for key, res := range rs.data {
-		// We cannot predict on which Zone the workload will be placed.
-		// And we should totally not guess. So the only safe (and conservative)
+		// Unless the plugin told us on which Zone the workload is expected to be placed,
+		// we cannot predict it. And we should totally not guess. So the only safe (and conservative)
 		// choice is to decrement the available resources from *all* the zones.
 		// This can cause false negatives, but will never cause false positives,
 		// which are much worse.
+		zones := rs.zones[key]
 		for zi := 0; zi < len(nrt.Zones); zi++ {
 			zone := &nrt.Zones[zi] // shortcut
+			if zones.Len() > 0 && !zones.Has(zone.Name) {
+				continue
+			}
 			for ri := 0; ri < len(zone.Resources); ri++ {
 				zr := &zone.Resources[ri] // shortcut
 				qty, ok := res[corev1.ResourceName(zr.Name)]
@@ -411,11 +411,11 @@ func TestResourceStoreAddPod(t *testing.T) {
 	}
 
 	rs := newResourceStore(klog.Background())
-	existed := rs.AddPod(&pod)
+	existed := rs.AddPod(&pod, nil)
 	if existed {
 		t.Fatalf("replaced a pod into a empty resourceStore")
 	}
-	existed = rs.AddPod(&pod)
+	existed = rs.AddPod(&pod, nil)
 	if !existed {
 		t.Fatalf("added pod twice")
 	}
@@ -447,7 +447,7 @@ func TestResourceStoreDeletePod(t *testing.T) {
 	if existed {
 		t.Fatalf("deleted a pod into a empty resourceStore")
 	}
-	rs.AddPod(&pod)
+	rs.AddPod(&pod, nil)
 	existed = rs.DeletePod(&pod)
 	if !existed {
 		t.Fatalf("deleted a pod which was not supposed to be present")
@@ -510,7 +510,7 @@ func TestResourceStoreUpdate(t *testing.T) {
 	}
 
 	rs := newResourceStore(klog.Background())
-	existed := rs.AddPod(&pod)
+	existed := rs.AddPod(&pod, nil)
 	if existed {
 		t.Fatalf("replacing a pod into a empty resourceStore")
 	}
@@ -565,6 +565,92 @@ func TestResourceStoreUpdate(t *testing.T) {
 	}
 }
 
+func TestResourceStoreUpdateWithZones(t *testing.T) {
+	nrt := &topologyv1alpha2.NodeResourceTopology{
+		ObjectMeta:       metav1.ObjectMeta{Name: "node"},
+		TopologyPolicies: []string{string(topologyv1alpha2.SingleNUMANodePodLevel)},
+		Zones: topologyv1alpha2.ZoneList{
+			{
+				Name: "node-0",
+				Type: "Node",
+				Resources: topologyv1alpha2.ResourceInfoList{
+					MakeTopologyResInfo(cpu, "20", "20"),
+					MakeTopologyResInfo(memory, "32Gi", "32Gi"),
+				},
+			},
+			{
+				Name: "node-1",
+				Type: "Node",
+				Resources: topologyv1alpha2.ResourceInfoList{
+					MakeTopologyResInfo(cpu, "20", "20"),
+					MakeTopologyResInfo(memory, "32Gi", "32Gi"),
+				},
+			},
+		},
+	}
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns-0",
+			Name:      "pod-0",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "cnt-0",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("16"),
+							corev1.ResourceMemory: resource.MustParse("4Gi"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	rs := newResourceStore(klog.Background())
+	rs.AddPod(&pod, []string{"node-1"})
+
+	logID := "testResourceStoreUpdateWithZones"
+	rs.UpdateNRT(nrt, "logID", logID)
+
+	expected := []struct {
+		cpu    string
+		memory string
+	}{
+		{cpu: "20", memory: "32Gi"},
+		{cpu: "4", memory: "28Gi"},
+	}
+	for zi, exp := range expected {
+		cpuInfo := findResourceInfo(nrt.Zones[zi].Resources, cpu)
+		if cpuInfo.Available.Cmp(resource.MustParse(exp.cpu)) != 0 {
+			t.Errorf("bad availability for resource %q on zone %d: expected %v got %v", cpu, zi, exp.cpu, cpuInfo.Available)
+		}
+		memInfo := findResourceInfo(nrt.Zones[zi].Resources, memory)
+		if memInfo.Available.Cmp(resource.MustParse(exp.memory)) != 0 {
+			t.Errorf("bad availability for resource %q on zone %d: expected %v got %v", memory, zi, exp.memory, memInfo.Available)
+		}
+	}
+
+	// reserving again without zones falls back to all the zones
+	rs.AddPod(&pod, nil)
+	nrt.Zones[1].Resources = topologyv1alpha2.ResourceInfoList{
+		MakeTopologyResInfo(cpu, "20", "20"),
+		MakeTopologyResInfo(memory, "32Gi", "32Gi"),
+	}
+	rs.UpdateNRT(nrt, "logID", logID)
+
+	cpuInfo0 := findResourceInfo(nrt.Zones[0].Resources, cpu)
+	if cpuInfo0.Available.Cmp(resource.MustParse("4")) != 0 {
+		t.Errorf("bad availability for resource %q on zone %d: expected %v got %v", cpu, 0, "4", cpuInfo0.Available)
+	}
+	cpuInfo1 := findResourceInfo(nrt.Zones[1].Resources, cpu)
+	if cpuInfo1.Available.Cmp(resource.MustParse("4")) != 0 {
+		t.Errorf("bad availability for resource %q on zone %d: expected %v got %v", cpu, 1, "4", cpuInfo1.Available)
+	}
+}
+
 func TestCheckPodFingerprintForNode(t *testing.T) {
 	tcases := []struct {
 		description string
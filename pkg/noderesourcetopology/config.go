@@ -17,6 +17,8 @@ limitations under the License.
 package noderesourcetopology
 
 import (
+	"strconv"
+
 	kubeletconfig "k8s.io/kubernetes/pkg/kubelet/apis/config"
 
 	"github.com/go-logr/logr"
@@ -26,10 +28,12 @@ import (
 const (
 	AttributeScope  = "topologyManagerScope"
 	AttributePolicy = "topologyManagerPolicy"
+	// AttributeOptionPreferClosestNUMANodes reports the prefer-closest-numa-nodes topology manager
+	// policy option, making the kubelet prefer, among the NUMA affinities of the same size,
+	// the one with the shortest average distance between its NUMA nodes.
+	AttributeOptionPreferClosestNUMANodes = "topologyManagerOptionPreferClosestNumaNodes"
 )
 
-// TODO: handle topologyManagerPolicyOptions added in k8s 1.26
-
 func IsValidScope(scope string) bool {
 	if scope == kubeletconfig.ContainerTopologyManagerScope || scope == kubeletconfig.PodTopologyManagerScope {
 		return true
@@ -46,8 +50,9 @@ func IsValidPolicy(policy string) bool {
 }
 
 type TopologyManagerConfig struct {
-	Scope  string
-	Policy string
+	Scope             string
+	Policy            string
+	PreferClosestNUMA bool
 }
 
 func makeTopologyManagerConfigDefaults() TopologyManagerConfig {
@@ -76,7 +81,12 @@ func updateTopologyManagerConfigFromAttributes(conf *TopologyManagerConfig, attr
 			conf.Policy = attr.Value
 			continue
 		}
-		// TODO: handle topologyManagerPolicyOptions added in k8s 1.26
+		if attr.Name == AttributeOptionPreferClosestNUMANodes {
+			if preferClosestNUMA, err := strconv.ParseBool(attr.Value); err == nil {
+				conf.PreferClosestNUMA = preferClosestNUMA
+			}
+			continue
+		}
 	}
 }
 
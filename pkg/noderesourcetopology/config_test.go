@@ -194,6 +194,33 @@ func TestConfigFromAttributes(t *testing.T) {
 				Scope:  kubeletconfig.PodTopologyManagerScope,
 			},
 		},
+		{
+			name: "prefer-closest-numa-nodes",
+			attrs: topologyv1alpha2.AttributeList{
+				{
+					Name:  "topologyManagerPolicy",
+					Value: "restricted",
+				},
+				{
+					Name:  "topologyManagerOptionPreferClosestNumaNodes",
+					Value: "true",
+				},
+			},
+			expected: TopologyManagerConfig{
+				Policy:            kubeletconfig.RestrictedTopologyManagerPolicy,
+				PreferClosestNUMA: true,
+			},
+		},
+		{
+			name: "prefer-closest-numa-nodes-invalid",
+			attrs: topologyv1alpha2.AttributeList{
+				{
+					Name:  "topologyManagerOptionPreferClosestNumaNodes",
+					Value: "yes",
+				},
+			},
+			expected: TopologyManagerConfig{},
+		},
 		{
 			name: "error-case-1",
 			attrs: topologyv1alpha2.AttributeList{
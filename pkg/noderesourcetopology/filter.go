@@ -29,7 +29,9 @@ import (
 
 	"github.com/go-logr/logr"
 	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
+	nrtcache "sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/cache"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/logging"
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/metrics"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/resourcerequests"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/stringify"
 	"sigs.k8s.io/scheduler-plugins/pkg/util"
@@ -41,7 +43,7 @@ const highestNUMAID = 8
 
 type PolicyHandler func(pod *v1.Pod, zoneMap topologyv1alpha2.ZoneList) *framework.Status
 
-func singleNUMAContainerLevelHandler(lh logr.Logger, pod *v1.Pod, zones topologyv1alpha2.ZoneList, nodeInfo *framework.NodeInfo) *framework.Status {
+func singleNUMAContainerLevelHandler(lh logr.Logger, pod *v1.Pod, zones topologyv1alpha2.ZoneList, nodeInfo *framework.NodeInfo) (bm.BitMask, bool, *framework.Status) {
 	lh.V(5).Info("container level single NUMA node handler")
 
 	// prepare NUMANodes list from zoneMap
@@ -51,18 +53,21 @@ func singleNUMAContainerLevelHandler(lh logr.Logger, pod *v1.Pod, zones topology
 	// Node() != nil already verified in Filter(), which is the only public entry point
 	logNumaNodes(lh, "container handler NUMA resources", nodeInfo.Node().Name, nodes)
 
+	affinity := bm.NewEmptyBitMask()
+
 	// the init containers are running SERIALLY and BEFORE the normal containers.
 	// https://kubernetes.io/docs/concepts/workloads/pods/init-containers/#understanding-init-containers
 	// therefore, we don't need to accumulate their resources together
 	for _, initContainer := range pod.Spec.InitContainers {
 		lh.V(6).Info("init container desired resources", stringify.ResourceListToLoggable(initContainer.Resources.Requests)...)
 
-		_, match := resourcesAvailableInAnyNUMANodes(lh, nodes, initContainer.Resources.Requests, qos, nodeInfo)
+		numaID, match := resourcesAvailableInAnyNUMANodes(lh, nodes, initContainer.Resources.Requests, qos, nodeInfo)
 		if !match {
 			// we can't align init container, so definitely we can't align a pod
 			lh.V(2).Info("cannot align container", "name", initContainer.Name, "kind", "init")
-			return framework.NewStatus(framework.Unschedulable, "cannot align init container")
+			return nil, false, framework.NewStatus(framework.Unschedulable, "cannot align init container")
 		}
+		_ = affinity.Add(numaID)
 	}
 
 	for _, container := range pod.Spec.Containers {
@@ -73,15 +78,16 @@ func singleNUMAContainerLevelHandler(lh logr.Logger, pod *v1.Pod, zones topology
 		if !match {
 			// we can't align container, so definitely we can't align a pod
 			lh.V(2).Info("cannot align container", "name", container.Name, "kind", "app")
-			return framework.NewStatus(framework.Unschedulable, "cannot align container")
+			return nil, false, framework.NewStatus(framework.Unschedulable, "cannot align container")
 		}
+		_ = affinity.Add(numaID)
 
 		// subtract the resources requested by the container from the given NUMA.
 		// this is necessary, so we won't allocate the same resources for the upcoming containers
 		subtractFromNUMA(lh, nodes, numaID, container)
 	}
-	lh.V(2).Info("can align all containers")
-	return nil
+	lh.V(2).Info("can align all containers", "affinity", affinity)
+	return affinity, true, nil
 }
 
 // resourcesAvailableInAnyNUMANodes checks for sufficient resource and return the NUMAID that would be selected by Kubelet.
@@ -161,7 +167,7 @@ func isResourceSetSuitable(qos v1.PodQOSClass, resource v1.ResourceName, quantit
 	return numaQuantity.Cmp(quantity) >= 0
 }
 
-func singleNUMAPodLevelHandler(lh logr.Logger, pod *v1.Pod, zones topologyv1alpha2.ZoneList, nodeInfo *framework.NodeInfo) *framework.Status {
+func singleNUMAPodLevelHandler(lh logr.Logger, pod *v1.Pod, zones topologyv1alpha2.ZoneList, nodeInfo *framework.NodeInfo) (bm.BitMask, bool, *framework.Status) {
 	lh.V(5).Info("pod level single NUMA node handler")
 
 	resources := util.GetPodEffectiveRequest(pod)
@@ -172,15 +178,28 @@ func singleNUMAPodLevelHandler(lh logr.Logger, pod *v1.Pod, zones topologyv1alph
 	logNumaNodes(lh, "pod handler NUMA resources", nodeInfo.Node().Name, nodes)
 	lh.V(6).Info("pod desired resources", stringify.ResourceListToLoggable(resources)...)
 
-	if _, match := resourcesAvailableInAnyNUMANodes(lh, createNUMANodeList(lh, zones), resources, v1qos.GetPodQOS(pod), nodeInfo); !match {
+	numaID, match := resourcesAvailableInAnyNUMANodes(lh, createNUMANodeList(lh, zones), resources, v1qos.GetPodQOS(pod), nodeInfo)
+	if !match {
 		lh.V(2).Info("cannot align pod", "name", pod.Name)
-		return framework.NewStatus(framework.Unschedulable, "cannot align pod")
+		return nil, false, framework.NewStatus(framework.Unschedulable, "cannot align pod")
 	}
-	lh.V(2).Info("can align pod")
-	return nil
+	lh.V(2).Info("can align pod", "numaCell", numaID)
+	affinity, _ := bm.NewBitMask(numaID)
+	return affinity, true, nil
 }
 
-// Filter Now only single-numa-node supported
+// staleNRTResult returns the filter result of a node whose data the cache reports as stale: the
+// discardreserved cache discards the nodes with reservations in flight, while the overreserve cache
+// discards the nodes running foreign pods until they are resynced.
+func staleNRTResult(nrtCache nrtcache.Interface) string {
+	if _, ok := nrtCache.(*nrtcache.DiscardReserved); ok {
+		return metrics.FilterPendingReservations
+	}
+	return metrics.FilterForeignPods
+}
+
+// Filter checks the pod can be aligned on the NUMA nodes of the node as the kubelet's topology manager
+// would, with the single-numa-node, restricted or best-effort policy.
 func (tm *TopologyMatch) Filter(ctx context.Context, cycleState *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
 	if nodeInfo.Node() == nil {
 		return framework.NewStatus(framework.Error, "node not found")
@@ -198,10 +217,13 @@ func (tm *TopologyMatch) Filter(ctx context.Context, cycleState *framework.Cycle
 
 	nodeTopology, ok := tm.nrtCache.GetCachedNRTCopy(ctx, nodeName, pod)
 	if !ok {
-		lh.V(2).Info("invalid topology data")
+		result := staleNRTResult(tm.nrtCache)
+		lh.V(2).Info("invalid topology data", "reason", result)
+		metrics.FilterResults.WithLabelValues(result).Inc()
 		return framework.NewStatus(framework.Unschedulable, "invalid node topology data")
 	}
 	if nodeTopology == nil {
+		metrics.FilterResults.WithLabelValues(metrics.FilterMissingNRT).Inc()
 		return nil
 	}
 
@@ -209,13 +231,18 @@ func (tm *TopologyMatch) Filter(ctx context.Context, cycleState *framework.Cycle
 
 	handler := filterHandlerFromTopologyManagerConfig(topologyManagerConfigFromNodeResourceTopology(lh, nodeTopology))
 	if handler == nil {
+		metrics.FilterResults.WithLabelValues(metrics.FilterAdmitted).Inc()
 		return nil
 	}
-	status := handler(lh, pod, nodeTopology.Zones, nodeInfo)
+	affinity, preferred, status := handler(lh, pod, nodeTopology.Zones, nodeInfo)
 	if status != nil {
+		metrics.FilterResults.WithLabelValues(metrics.FilterInsufficientNUMAResources).Inc()
 		tm.nrtCache.NodeMaybeOverReserved(nodeName, pod)
+		return status
 	}
-	return status
+	metrics.FilterResults.WithLabelValues(metrics.FilterAdmitted).Inc()
+	recordNUMAZones(lh, cycleState, pod, nodeName, nodeTopology.Zones, affinity, preferred)
+	return nil
 }
 
 // subtractFromNUMA finds the correct NUMA ID's resources and subtract them from `nodes`.
@@ -241,14 +268,25 @@ func subtractFromNUMA(lh logr.Logger, nodes NUMANodeList, numaID int, container
 }
 
 func filterHandlerFromTopologyManagerConfig(conf TopologyManagerConfig) filterFn {
-	if conf.Policy != kubeletconfig.SingleNumaNodeTopologyManagerPolicy {
-		return nil
-	}
-	if conf.Scope == kubeletconfig.PodTopologyManagerScope {
-		return singleNUMAPodLevelHandler
-	}
-	if conf.Scope == kubeletconfig.ContainerTopologyManagerScope {
-		return singleNUMAContainerLevelHandler
+	switch conf.Policy {
+	case kubeletconfig.SingleNumaNodeTopologyManagerPolicy:
+		if conf.Scope == kubeletconfig.PodTopologyManagerScope {
+			return singleNUMAPodLevelHandler
+		}
+		if conf.Scope == kubeletconfig.ContainerTopologyManagerScope {
+			return singleNUMAContainerLevelHandler
+		}
+	case kubeletconfig.RestrictedTopologyManagerPolicy, kubeletconfig.BestEffortTopologyManagerPolicy:
+		if conf.Scope == kubeletconfig.PodTopologyManagerScope {
+			return func(lh logr.Logger, pod *v1.Pod, zones topologyv1alpha2.ZoneList, nodeInfo *framework.NodeInfo) (bm.BitMask, bool, *framework.Status) {
+				return multiNUMAPodLevelHandler(lh, pod, zones, nodeInfo, conf)
+			}
+		}
+		if conf.Scope == kubeletconfig.ContainerTopologyManagerScope {
+			return func(lh logr.Logger, pod *v1.Pod, zones topologyv1alpha2.ZoneList, nodeInfo *framework.NodeInfo) (bm.BitMask, bool, *framework.Status) {
+				return multiNUMAContainerLevelHandler(lh, pod, zones, nodeInfo, conf)
+			}
+		}
 	}
-	return nil // cannot happen
+	return nil
 }
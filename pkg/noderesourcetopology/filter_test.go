@@ -27,10 +27,12 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/component-base/metrics/testutil"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 
 	nrtcache "sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/cache"
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/metrics"
 	tu "sigs.k8s.io/scheduler-plugins/test/util"
 )
 
@@ -1179,6 +1181,119 @@ func TestNodeResourceTopologyMultiContainerContainerScope(t *testing.T) {
 	}
 }
 
+// foreignPodsCache reports the data of all the nodes as stale, like the cache does for the nodes running foreign pods.
+type foreignPodsCache struct {
+	nrtcache.Interface
+}
+
+func (fc foreignPodsCache) GetCachedNRTCopy(ctx context.Context, nodeName string, pod *v1.Pod) (*topologyv1alpha2.NodeResourceTopology, bool) {
+	return nil, false
+}
+
+func TestFilterResults(t *testing.T) {
+	nrt := &topologyv1alpha2.NodeResourceTopology{
+		ObjectMeta:       metav1.ObjectMeta{Name: "node"},
+		TopologyPolicies: []string{string(topologyv1alpha2.SingleNUMANodePodLevel)},
+		Zones: topologyv1alpha2.ZoneList{
+			{
+				Name: "node-0",
+				Type: "Node",
+				Resources: topologyv1alpha2.ResourceInfoList{
+					MakeTopologyResInfo(cpu, "4", "2"),
+					MakeTopologyResInfo(memory, "8Gi", "8Gi"),
+				},
+			},
+		},
+	}
+	guaranteed := func(cpus int64) *v1.Pod {
+		return makePod("pod", withMultiContainers([]v1.ResourceList{{
+			v1.ResourceCPU:    *resource.NewQuantity(cpus, resource.DecimalSI),
+			v1.ResourceMemory: resource.MustParse("1Gi"),
+		}}))
+	}
+
+	fakeClient, err := tu.NewFakeClient()
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+	if err := fakeClient.Create(context.Background(), nrt.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	passthrough := nrtcache.NewPassthrough(klog.Background(), fakeClient)
+	discardReserved := nrtcache.NewDiscardReserved(klog.Background(), fakeClient)
+	discardReserved.ReserveNodeResources(nrt.Name, guaranteed(1), nil)
+
+	tests := []struct {
+		name     string
+		pod      *v1.Pod
+		node     *v1.Node
+		nrtCache nrtcache.Interface
+		result   string
+	}{
+		{
+			name:     "admitted",
+			pod:      guaranteed(2),
+			node:     makeNodeFromNodeResourceTopology(nrt),
+			nrtCache: passthrough,
+			result:   metrics.FilterAdmitted,
+		},
+		{
+			name:     "insufficient NUMA resources",
+			pod:      guaranteed(3),
+			node:     makeNodeFromNodeResourceTopology(nrt),
+			nrtCache: passthrough,
+			result:   metrics.FilterInsufficientNUMAResources,
+		},
+		{
+			name:     "missing NRT",
+			pod:      guaranteed(2),
+			node:     &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "other-node"}},
+			nrtCache: passthrough,
+			result:   metrics.FilterMissingNRT,
+		},
+		{
+			name:     "foreign pods",
+			pod:      guaranteed(2),
+			node:     makeNodeFromNodeResourceTopology(nrt),
+			nrtCache: foreignPodsCache{Interface: passthrough},
+			result:   metrics.FilterForeignPods,
+		},
+		{
+			name:     "pending reservations",
+			pod:      guaranteed(2),
+			node:     makeNodeFromNodeResourceTopology(nrt),
+			nrtCache: discardReserved,
+			result:   metrics.FilterPendingReservations,
+		},
+	}
+
+	metrics.Register()
+	filterResults := func(result string) float64 {
+		val, err := testutil.GetCounterMetricValue(metrics.FilterResults.WithLabelValues(result))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return val
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := TopologyMatch{
+				nrtCache: tt.nrtCache,
+			}
+			before := filterResults(tt.result)
+
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(tt.node)
+			tm.Filter(context.Background(), framework.NewCycleState(), tt.pod, nodeInfo)
+
+			if got := filterResults(tt.result); got != before+1 {
+				t.Errorf("filter results %q: %v expected %v", tt.result, got, before+1)
+			}
+		})
+	}
+}
+
 func makeNodeFromNodeResourceTopology(nrt *topologyv1alpha2.NodeResourceTopology) *v1.Node {
 	res := makeResourceListFromZones(nrt.Zones)
 	return &v1.Node{
@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	schedmetrics "k8s.io/kubernetes/pkg/scheduler/metrics"
+)
+
+const subsystem = "noderesourcetopology"
+
+// values of the trigger label of the resync attempts
+const (
+	TriggerPeriodic string = "periodic"
+	TriggerUpdate   string = "update"
+)
+
+// values of the result label of the resync attempts
+const (
+	ResyncSuccess             string = "success"
+	ResyncFingerprintMismatch string = "fingerprint_mismatch"
+	ResyncMissingFingerprint  string = "missing_fingerprint"
+	ResyncError               string = "error"
+)
+
+// values of the result label of the filter evaluations
+const (
+	FilterAdmitted                  string = "admitted"
+	FilterMissingNRT                string = "missing_nrt"
+	FilterForeignPods               string = "foreign_pods"
+	FilterPendingReservations       string = "pending_reservations"
+	FilterInsufficientNUMAResources string = "insufficient_numa_resources"
+)
+
+var (
+	CacheResyncAttempts = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      subsystem,
+			Name:           "cache_resync_attempts_total",
+			Help:           "Number of attempts to resync the cached data of a dirty node, by trigger and result.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"trigger", "result"})
+
+	CacheNodesMaybeOverReserved = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      subsystem,
+			Name:           "cache_nodes_maybe_overreserved",
+			Help:           "Number of nodes which may be overreserved and are waiting to be resynced.",
+			StabilityLevel: metrics.ALPHA,
+		})
+
+	CacheNodesWithForeignPods = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      subsystem,
+			Name:           "cache_nodes_with_foreign_pods",
+			Help:           "Number of nodes running pods not scheduled by this scheduler and waiting to be resynced.",
+			StabilityLevel: metrics.ALPHA,
+		})
+
+	CacheLastSyncTimestamp = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      subsystem,
+			Name:           "cache_last_sync_timestamp_seconds",
+			Help:           "Unix time the cached NRT data of a node was last refreshed from the API server.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"node"})
+
+	FilterResults = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      subsystem,
+			Name:           "filter_results_total",
+			Help:           "Number of nodes evaluated by the filter, by result. All results but admitted and missing_nrt reject the node.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"result"})
+
+	ScoreDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Subsystem:      subsystem,
+			Name:           "score_duration_seconds",
+			Help:           "Latency of the scoring of a node, by scoring strategy.",
+			Buckets:        metrics.ExponentialBuckets(0.00001, 2, 15),
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"strategy"})
+
+	metricsList = []metrics.Registerable{
+		CacheResyncAttempts,
+		CacheNodesMaybeOverReserved,
+		CacheNodesWithForeignPods,
+		CacheLastSyncTimestamp,
+		FilterResults,
+		ScoreDuration,
+	}
+)
+
+var registerMetrics sync.Once
+
+// Register registers the metrics along with the scheduler ones. It is safe to call it more than once,
+// as done by each profile enabling the plugin.
+func Register() {
+	registerMetrics.Do(func() {
+		schedmetrics.RegisterMetrics(metricsList...)
+	})
+}
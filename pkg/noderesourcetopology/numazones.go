@@ -0,0 +1,99 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesourcetopology
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	v1qos "k8s.io/kubernetes/pkg/apis/core/v1/helper/qos"
+	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager/bitmask"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+
+	"github.com/go-logr/logr"
+	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
+	"github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2/helper/numanode"
+)
+
+const (
+	// AnnotationKeyExpectedNUMAZones is the pod annotation holding the comma-separated names of the
+	// NUMA zones the pod is expected to be aligned on by the kubelet, so the node agents can cross-check
+	// the scheduler expectations against the actual allocation.
+	AnnotationKeyExpectedNUMAZones = "noderesourcetopology.scheduling.x-k8s.io/expected-numa-zones"
+
+	numaZonesStateKeyPrefix = Name + "/numazones/"
+)
+
+// numaZonesState holds the names of the NUMA zones of a node a pod is expected to be aligned on.
+type numaZonesState struct {
+	zones []string
+}
+
+// Clone the numaZonesState. The state is never modified once written, so it can be shared.
+func (s *numaZonesState) Clone() framework.StateData {
+	return s
+}
+
+// numaZonesStateKey returns the key of the numaZonesState of a node. The Filter of different nodes runs
+// concurrently, so each node has its own key, instead of sharing a map which would need a lock.
+func numaZonesStateKey(nodeName string) framework.StateKey {
+	return framework.StateKey(numaZonesStateKeyPrefix + nodeName)
+}
+
+// recordNUMAZones records in the cycle state the NUMA zones of the node the pod is expected to be aligned on.
+// Only the resources of the guaranteed pods are all aligned by the kubelet, and only on a preferred affinity:
+// with the best-effort policy, the resources of a non-preferred one may be allocated from any NUMA zone.
+// Nothing is recorded otherwise, and the resources keep being pessimistically accounted on all the NUMA zones.
+func recordNUMAZones(lh logr.Logger, cycleState *framework.CycleState, pod *v1.Pod, nodeName string, zones topologyv1alpha2.ZoneList, affinity bitmask.BitMask, preferred bool) {
+	if affinity == nil || affinity.IsEmpty() || !preferred || v1qos.GetPodQOS(pod) != v1.PodQOSGuaranteed {
+		return
+	}
+	var names []string
+	for _, zone := range zones {
+		if zone.Type != "Node" {
+			continue
+		}
+		numaID, err := numanode.NameToID(zone.Name)
+		if err != nil || !affinity.IsSet(numaID) {
+			continue
+		}
+		names = append(names, zone.Name)
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+	lh.V(4).Info("expected NUMA zones", "zones", names)
+	cycleState.Write(numaZonesStateKey(nodeName), &numaZonesState{zones: names})
+}
+
+// numaZonesFromCycleState returns the NUMA zones of the node the pod is expected to be aligned on,
+// or nil if they are not known.
+func numaZonesFromCycleState(cycleState *framework.CycleState, nodeName string) []string {
+	if cycleState == nil {
+		return nil
+	}
+	c, err := cycleState.Read(numaZonesStateKey(nodeName))
+	if err != nil {
+		return nil
+	}
+	s, ok := c.(*numaZonesState)
+	if !ok {
+		return nil
+	}
+	return s.zones
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesourcetopology
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+
+	nrtcache "sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/cache"
+	tu "sigs.k8s.io/scheduler-plugins/test/util"
+)
+
+type reservedZonesCache struct {
+	nrtcache.Interface
+	zones map[string][]string
+}
+
+func (rc *reservedZonesCache) ReserveNodeResources(nodeName string, pod *v1.Pod, zones []string) {
+	rc.zones[nodeName] = zones
+}
+
+func TestNUMAZonesReserve(t *testing.T) {
+	// node-0 has 1 CPU available, node-1 and node-2 have 2 CPUs available each
+	makeNRT := func(name string, policy topologyv1alpha2.TopologyManagerPolicy) *topologyv1alpha2.NodeResourceTopology {
+		return &topologyv1alpha2.NodeResourceTopology{
+			ObjectMeta:       metav1.ObjectMeta{Name: name},
+			TopologyPolicies: []string{string(policy)},
+			Zones: topologyv1alpha2.ZoneList{
+				{
+					Name: "node-0",
+					Type: "Node",
+					Resources: topologyv1alpha2.ResourceInfoList{
+						MakeTopologyResInfo(cpu, "2", "1"),
+						MakeTopologyResInfo(memory, "8Gi", "8Gi"),
+					},
+				},
+				{
+					Name: "node-1",
+					Type: "Node",
+					Resources: topologyv1alpha2.ResourceInfoList{
+						MakeTopologyResInfo(cpu, "2", "2"),
+						MakeTopologyResInfo(memory, "8Gi", "8Gi"),
+					},
+				},
+				{
+					Name: "node-2",
+					Type: "Node",
+					Resources: topologyv1alpha2.ResourceInfoList{
+						MakeTopologyResInfo(cpu, "2", "2"),
+						MakeTopologyResInfo(memory, "8Gi", "8Gi"),
+					},
+				},
+			},
+		}
+	}
+	nrts := []*topologyv1alpha2.NodeResourceTopology{
+		makeNRT("single-numa-pod", topologyv1alpha2.SingleNUMANodePodLevel),
+		makeNRT("single-numa-container", topologyv1alpha2.SingleNUMANodeContainerLevel),
+		makeNRT("restricted-pod", topologyv1alpha2.RestrictedPodLevel),
+		makeNRT("none", topologyv1alpha2.None),
+		// the CPUs of the pod below fit on node-0 and node-1 only, while its memory fits on node-1 alone:
+		// the merged hint is not preferred, so the kubelet may allocate the resources from any NUMA zone.
+		{
+			ObjectMeta:       metav1.ObjectMeta{Name: "best-effort-pod"},
+			TopologyPolicies: []string{string(topologyv1alpha2.BestEffortPodLevel)},
+			Zones: topologyv1alpha2.ZoneList{
+				{
+					Name: "node-0",
+					Type: "Node",
+					Resources: topologyv1alpha2.ResourceInfoList{
+						MakeTopologyResInfo(cpu, "2", "2"),
+						MakeTopologyResInfo(memory, "4Gi", "1Gi"),
+					},
+				},
+				{
+					Name: "node-1",
+					Type: "Node",
+					Resources: topologyv1alpha2.ResourceInfoList{
+						MakeTopologyResInfo(cpu, "2", "1"),
+						MakeTopologyResInfo(memory, "8Gi", "8Gi"),
+					},
+				},
+				{
+					Name: "node-2",
+					Type: "Node",
+					Resources: topologyv1alpha2.ResourceInfoList{
+						MakeTopologyResInfo(cpu, "2", "0"),
+						MakeTopologyResInfo(memory, "4Gi", "0"),
+					},
+				},
+			},
+		},
+	}
+
+	guaranteed := func(cpus int64) v1.ResourceList {
+		return v1.ResourceList{
+			v1.ResourceCPU:    *resource.NewQuantity(cpus, resource.DecimalSI),
+			v1.ResourceMemory: resource.MustParse("1Gi"),
+		}
+	}
+
+	tests := []struct {
+		name  string
+		pod   *v1.Pod
+		node  string
+		zones []string
+	}{
+		{
+			name:  "single-numa-node pod scope",
+			pod:   makePod("pod", withMultiContainers([]v1.ResourceList{guaranteed(2)})),
+			node:  "single-numa-pod",
+			zones: []string{"node-1"},
+		},
+		{
+			name:  "single-numa-node container scope",
+			pod:   makePod("pod", withMultiContainers([]v1.ResourceList{guaranteed(2), guaranteed(2)})),
+			node:  "single-numa-container",
+			zones: []string{"node-1", "node-2"},
+		},
+		{
+			name: "restricted pod scope",
+			pod: makePod("pod", withMultiContainers([]v1.ResourceList{{
+				v1.ResourceCPU:    resource.MustParse("3"),
+				v1.ResourceMemory: resource.MustParse("9Gi"),
+			}})),
+			node:  "restricted-pod",
+			zones: []string{"node-0", "node-1"},
+		},
+		{
+			name: "best-effort pod scope with a non-preferred alignment",
+			pod: makePod("pod", withMultiContainers([]v1.ResourceList{{
+				v1.ResourceCPU:    resource.MustParse("3"),
+				v1.ResourceMemory: resource.MustParse("6Gi"),
+			}})),
+			node: "best-effort-pod",
+		},
+		{
+			name: "Burstable QoS",
+			pod: makePodWithReqByResourceList(&v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("2"),
+				v1.ResourceMemory: resource.MustParse("1Gi"),
+			}),
+			node: "single-numa-pod",
+		},
+		{
+			name: "none policy",
+			pod:  makePod("pod", withMultiContainers([]v1.ResourceList{guaranteed(2)})),
+			node: "none",
+		},
+	}
+
+	fakeClient, err := tu.NewFakeClient()
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+	nodes := make(map[string]*v1.Node)
+	for _, nrt := range nrts {
+		if err := fakeClient.Create(context.Background(), nrt.DeepCopy()); err != nil {
+			t.Fatal(err)
+		}
+		nodes[nrt.Name] = makeNodeFromNodeResourceTopology(nrt)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &reservedZonesCache{
+				Interface: nrtcache.NewPassthrough(klog.Background(), fakeClient),
+				zones:     make(map[string][]string),
+			}
+			tm := TopologyMatch{
+				nrtCache: rc,
+			}
+
+			state := framework.NewCycleState()
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(nodes[tt.node])
+			if status := tm.Filter(context.Background(), state, tt.pod, nodeInfo); status != nil {
+				t.Fatalf("unexpected filter status: %v", status)
+			}
+
+			tm.Reserve(context.Background(), state, tt.pod, tt.node)
+			if got := rc.zones[tt.node]; !reflect.DeepEqual(got, tt.zones) {
+				t.Errorf("reserved zones %v, expected %v", got, tt.zones)
+			}
+		})
+	}
+}
+
+func TestNUMAZonesPreBind(t *testing.T) {
+	tests := []struct {
+		name       string
+		zones      []string
+		annotation string
+	}{
+		{
+			name:       "expected NUMA zones",
+			zones:      []string{"node-0", "node-1"},
+			annotation: "node-0,node-1",
+		},
+		{
+			name: "unknown NUMA zones",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pod"},
+			}
+			clientSet := fake.NewSimpleClientset(pod)
+			tm := TopologyMatch{
+				clientSet: clientSet,
+			}
+
+			state := framework.NewCycleState()
+			if tt.zones != nil {
+				state.Write(numaZonesStateKey("node"), &numaZonesState{zones: tt.zones})
+			}
+			if status := tm.PreBind(context.Background(), state, pod, "node"); status != nil {
+				t.Fatalf("unexpected prebind status: %v", status)
+			}
+
+			got, err := clientSet.CoreV1().Pods(pod.Namespace).Get(context.Background(), pod.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if annotation := got.Annotations[AnnotationKeyExpectedNUMAZones]; annotation != tt.annotation {
+				t.Errorf("annotation %q, expected %q", annotation, tt.annotation)
+			}
+		})
+	}
+}
@@ -24,13 +24,16 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager/bitmask"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 
 	apiconfig "sigs.k8s.io/scheduler-plugins/apis/config"
 	"sigs.k8s.io/scheduler-plugins/apis/config/validation"
 	nrtcache "sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/cache"
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/metrics"
 
 	"github.com/go-logr/logr"
 	topologyapi "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology"
@@ -52,7 +55,9 @@ func init() {
 type NUMANode struct {
 	NUMAID    int
 	Resources v1.ResourceList
-	Costs     map[int]int
+	// Capacity is the total of the resources of the NUMA node, available or not.
+	Capacity v1.ResourceList
+	Costs    map[int]int
 }
 
 func (n *NUMANode) WithCosts(costs map[int]int) *NUMANode {
@@ -95,7 +100,10 @@ func subtractFromNUMAs(resources v1.ResourceList, numaNodes NUMANodeList, nodes
 	}
 }
 
-type filterFn func(lh logr.Logger, pod *v1.Pod, zones topologyv1alpha2.ZoneList, nodeInfo *framework.NodeInfo) *framework.Status
+// filterFn checks the pod can be aligned on the NUMA zones of the node, returning the NUMA nodes
+// the kubelet is expected to align it on, and whether the alignment is preferred, i.e. the kubelet
+// allocates the resources of the pod from these NUMA nodes only.
+type filterFn func(lh logr.Logger, pod *v1.Pod, zones topologyv1alpha2.ZoneList, nodeInfo *framework.NodeInfo) (bitmask.BitMask, bool, *framework.Status)
 type scoringFn func(logr.Logger, *v1.Pod, topologyv1alpha2.ZoneList) (int64, *framework.Status)
 
 // TopologyMatch plugin which run simplified version of TopologyManager's admit handler
@@ -104,12 +112,14 @@ type TopologyMatch struct {
 	nrtCache            nrtcache.Interface
 	scoreStrategyFunc   scoreStrategyFn
 	scoreStrategyType   apiconfig.ScoringStrategyType
+	clientSet           kubernetes.Interface
 }
 
 var _ framework.FilterPlugin = &TopologyMatch{}
 var _ framework.ReservePlugin = &TopologyMatch{}
 var _ framework.ScorePlugin = &TopologyMatch{}
 var _ framework.EnqueueExtensions = &TopologyMatch{}
+var _ framework.PreBindPlugin = &TopologyMatch{}
 var _ framework.PostBindPlugin = &TopologyMatch{}
 
 // Name returns name of the plugin. It is used in logs, etc.
@@ -131,11 +141,14 @@ func New(ctx context.Context, args runtime.Object, handle framework.Handle) (fra
 		return nil, err
 	}
 
+	metrics.Register()
+
 	nrtCache, err := initNodeTopologyInformer(ctx, lh, tcfg, handle)
 	if err != nil {
 		lh.Error(err, "cannot create clientset for NodeTopologyResource", "kubeConfig", handle.KubeConfig())
 		return nil, err
 	}
+	initNodeTopologyCacheDump(lh, handle, nrtCache)
 
 	resToWeightMap := make(resourceToWeightMap)
 	for _, resource := range tcfg.ScoringStrategy.Resources {
@@ -157,6 +170,7 @@ func New(ctx context.Context, args runtime.Object, handle framework.Handle) (fra
 		nrtCache:            nrtCache,
 		scoreStrategyFunc:   strategy,
 		scoreStrategyType:   tcfg.ScoringStrategy.Type,
+		clientSet:           handle.ClientSet(),
 	}
 
 	return topologyMatch, nil
@@ -18,6 +18,8 @@ package noderesourcetopology
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -28,6 +30,8 @@ import (
 	"github.com/go-logr/logr"
 	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
 	"github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2/helper/numanode"
+	topologyclientset "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/generated/clientset/versioned"
+	topologyinformers "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/generated/informers/externalversions"
 
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -36,10 +40,14 @@ import (
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/logging"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/podprovider"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/stringify"
+	"sigs.k8s.io/scheduler-plugins/pkg/util"
 )
 
 const (
 	maxNUMAId = 64
+
+	// cacheDumpPath is the debug path the cache state is served under, followed by the profile name.
+	cacheDumpPath = "/debug/noderesourcetopology/cache"
 )
 
 func initNodeTopologyInformer(ctx context.Context, lh logr.Logger,
@@ -67,6 +75,11 @@ func initNodeTopologyInformer(ctx context.Context, lh logr.Logger,
 
 	initNodeTopologyForeignPodsDetection(lh, tcfg.Cache, handle, podSharedInformer, nrtCache)
 
+	if err := initNodeTopologyResyncOnUpdate(ctx, lh, handle, nrtCache); err != nil {
+		return nil, err
+	}
+
+	// the resync on NRT updates covers the common case; the periodic resync is the fallback for the rest.
 	resyncPeriod := time.Duration(tcfg.CacheResyncPeriodSeconds) * time.Second
 	go wait.Forever(nrtCache.Resync, resyncPeriod)
 
@@ -75,6 +88,57 @@ func initNodeTopologyInformer(ctx context.Context, lh logr.Logger,
 	return nrtCache, nil
 }
 
+func initNodeTopologyResyncOnUpdate(ctx context.Context, lh logr.Logger, handle framework.Handle, nrtCache *nrtcache.OverReserve) error {
+	nrtInformer, err := getNodeTopologyInformer(ctx, lh, handle)
+	if err != nil {
+		return err
+	}
+	return nrtcache.SetupNodeTopologyResyncOnUpdate(ctx, lh.WithName(logging.SubsystemNRTCache), nrtInformer, nrtCache)
+}
+
+var (
+	nrtInformerLock sync.Mutex
+	// nrtInformerFactory is shared by the plugin instances of all the profiles, so the NRT objects are watched once.
+	nrtInformerFactory topologyinformers.SharedInformerFactory
+)
+
+// getNodeTopologyInformer returns the NRT informer shared by the plugin instances of all the profiles,
+// starting it and waiting for its cache to sync the first time.
+func getNodeTopologyInformer(ctx context.Context, lh logr.Logger, handle framework.Handle) (k8scache.SharedIndexInformer, error) {
+	nrtInformerLock.Lock()
+	defer nrtInformerLock.Unlock()
+
+	if nrtInformerFactory == nil {
+		topoClient, err := topologyclientset.NewForConfig(handle.KubeConfig())
+		if err != nil {
+			lh.Error(err, "cannot create clientset for NodeTopologyResource", "kubeConfig", handle.KubeConfig())
+			return nil, err
+		}
+		nrtInformerFactory = topologyinformers.NewSharedInformerFactory(topoClient, 0)
+	}
+	nrtInformer := nrtInformerFactory.Topology().V1alpha2().NodeResourceTopologies().Informer()
+
+	lh.V(5).Info("start NodeTopology informer")
+	nrtInformerFactory.Start(ctx.Done())
+	if !k8scache.WaitForCacheSync(ctx.Done(), nrtInformer.HasSynced) {
+		return nil, fmt.Errorf("cannot sync the NodeTopology informer")
+	}
+	lh.V(5).Info("synced NodeTopology informer")
+	return nrtInformer, nil
+}
+
+// initNodeTopologyCacheDump exposes the cache state, read-only, under a debug path of its own, per profile.
+// The path is registered for every cache, but only the overreserve cache supports the dump: the others
+// answer with 501 Not Implemented.
+func initNodeTopologyCacheDump(lh logr.Logger, handle framework.Handle, nrtCache nrtcache.Interface) {
+	path := cacheDumpPath
+	if fwk, ok := handle.(framework.Framework); ok {
+		path += "/" + fwk.ProfileName()
+	}
+	util.RegisterDebugHandler(path, nrtcache.NewDumpHandler(nrtCache))
+	lh.V(3).Info("exposing the NodeTopology cache state", "path", path)
+}
+
 func initNodeTopologyForeignPodsDetection(lh logr.Logger, cfg *apiconfig.NodeResourceTopologyCache, handle framework.Handle, podSharedInformer k8scache.SharedInformer, nrtCache *nrtcache.OverReserve) {
 	foreignPodsDetect := getForeignPodsDetectMode(lh, cfg)
 
@@ -120,7 +184,7 @@ func createNUMANodeList(lh logr.Logger, zones topologyv1alpha2.ZoneList) NUMANod
 		resources := extractResources(zone)
 		numaItems := []interface{}{"numaCell", numaID}
 		lh.V(6).Info("extracted NUMA resources", stringify.ResourceListToLoggableWithValues(numaItems, resources)...)
-		nodes = append(nodes, NUMANode{NUMAID: numaID, Resources: resources})
+		nodes = append(nodes, NUMANode{NUMAID: numaID, Resources: resources, Capacity: extractCapacity(zone)})
 	}
 
 	// iterate over nodes and fill them with Costs
@@ -158,6 +222,14 @@ func extractResources(zone topologyv1alpha2.Zone) corev1.ResourceList {
 	return res
 }
 
+func extractCapacity(zone topologyv1alpha2.Zone) corev1.ResourceList {
+	res := make(corev1.ResourceList)
+	for _, resInfo := range zone.Resources {
+		res[corev1.ResourceName(resInfo.Name)] = resInfo.Capacity.DeepCopy()
+	}
+	return res
+}
+
 func onlyNonNUMAResources(numaNodes NUMANodeList, resources corev1.ResourceList) bool {
 	for resourceName := range resources {
 		for _, node := range numaNodes {
@@ -32,17 +32,34 @@ import (
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/logging"
 )
 
+// NodeNameIndex is the name of the index of the pods by the node they are bound to.
+const NodeNameIndex = "spec.nodeName"
+
 type PodFilterFunc func(lh logr.Logger, pod *corev1.Pod) bool
 
+// NodePodLister is implemented by the pod listers which can list the pods bound to a node
+// without listing all the pods, like the ones returned by NewFromHandle.
+type NodePodLister interface {
+	ListOnNode(nodeName string) ([]*corev1.Pod, error)
+}
+
 func NewFromHandle(lh logr.Logger, handle framework.Handle, cacheConf *apiconfig.NodeResourceTopologyCache) (k8scache.SharedIndexInformer, podlisterv1.PodLister, PodFilterFunc) {
 	dedicated := wantsDedicatedInformer(cacheConf)
 	if !dedicated {
 		podHandle := handle.SharedInformerFactory().Core().V1().Pods() // shortcut
-		return podHandle.Informer(), podHandle.Lister(), IsPodRelevantShared
+		podInformer := podHandle.Informer()
+		// the informer is shared by the plugin instances of all the profiles, and only the first one adds the index
+		if _, ok := podInformer.GetIndexer().GetIndexers()[NodeNameIndex]; !ok {
+			if err := podInformer.AddIndexers(cache.Indexers{NodeNameIndex: NodeNameIndexFunc}); err != nil {
+				lh.Info("cannot index the pods by node name, all the pods will be listed", "error", err)
+				return podInformer, podHandle.Lister(), IsPodRelevantShared
+			}
+		}
+		return podInformer, NewNodePodLister(podInformer.GetIndexer()), IsPodRelevantShared
 	}
 
-	podInformer := coreinformers.NewFilteredPodInformer(handle.ClientSet(), metav1.NamespaceAll, 0, cache.Indexers{}, nil)
-	podLister := podlisterv1.NewPodLister(podInformer.GetIndexer())
+	podInformer := coreinformers.NewFilteredPodInformer(handle.ClientSet(), metav1.NamespaceAll, 0, cache.Indexers{NodeNameIndex: NodeNameIndexFunc}, nil)
+	podLister := NewNodePodLister(podInformer.GetIndexer())
 
 	lh.V(5).Info("start custom pod informer")
 	ctx := context.Background()
@@ -55,6 +72,45 @@ func NewFromHandle(lh logr.Logger, handle framework.Handle, cacheConf *apiconfig
 	return podInformer, podLister, IsPodRelevantDedicated
 }
 
+// NodeNameIndexFunc indexes the pods by the node they are bound to.
+func NodeNameIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return []string{}, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
+
+// nodePodLister is a PodLister which can also list the pods bound to a node through the NodeNameIndex.
+type nodePodLister struct {
+	podlisterv1.PodLister
+	indexer cache.Indexer
+}
+
+// NewNodePodLister returns a PodLister implementing NodePodLister, backed by an indexer with the NodeNameIndex.
+func NewNodePodLister(indexer cache.Indexer) podlisterv1.PodLister {
+	return nodePodLister{
+		PodLister: podlisterv1.NewPodLister(indexer),
+		indexer:   indexer,
+	}
+}
+
+func (npl nodePodLister) ListOnNode(nodeName string) ([]*corev1.Pod, error) {
+	objs, err := npl.indexer.ByIndex(NodeNameIndex, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*corev1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
 // IsPodRelevantAlways is meant to be used in test only
 func IsPodRelevantAlways(lh logr.Logger, pod *corev1.Pod) bool {
 	return true
@@ -0,0 +1,62 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesourcetopology
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/logging"
+)
+
+// PreBind annotates the pod with the NUMA zones it is expected to be aligned on, if known.
+// The annotation is informational, so failing to set it doesn't prevent the pod from being bound.
+func (tm *TopologyMatch) PreBind(ctx context.Context, state *framework.CycleState, pod *corev1.Pod, nodeName string) *framework.Status {
+	lh := klog.FromContext(ctx).WithValues(logging.KeyPod, klog.KObj(pod), logging.KeyPodUID, logging.PodUID(pod), logging.KeyNode, nodeName)
+	lh.V(4).Info(logging.FlowBegin)
+	defer lh.V(4).Info(logging.FlowEnd)
+
+	zones := numaZonesFromCycleState(state, nodeName)
+	if len(zones) == 0 || tm.clientSet == nil {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{
+				AnnotationKeyExpectedNUMAZones: strings.Join(zones, ","),
+			},
+		},
+	})
+	if err != nil {
+		lh.Error(err, "cannot build the expected NUMA zones annotation", "zones", zones)
+		return nil
+	}
+	_, err = tm.clientSet.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		lh.Error(err, "cannot annotate the expected NUMA zones", "zones", zones)
+		return nil
+	}
+	lh.V(2).Info("annotated the expected NUMA zones", "zones", zones)
+	return nil
+}
@@ -31,7 +31,7 @@ func (tm *TopologyMatch) Reserve(ctx context.Context, state *framework.CycleStat
 	lh.V(4).Info(logging.FlowBegin)
 	defer lh.V(4).Info(logging.FlowEnd)
 
-	tm.nrtCache.ReserveNodeResources(nodeName, pod)
+	tm.nrtCache.ReserveNodeResources(nodeName, pod, numaZonesFromCycleState(state, nodeName))
 	// can't fail
 	return framework.NewStatus(framework.Success, "")
 }
@@ -19,6 +19,7 @@ package noderesourcetopology
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"gonum.org/v1/gonum/stat"
 
@@ -32,6 +33,7 @@ import (
 	"github.com/go-logr/logr"
 	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/logging"
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/metrics"
 	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/stringify"
 	"sigs.k8s.io/scheduler-plugins/pkg/util"
 )
@@ -89,6 +91,10 @@ func (tm *TopologyMatch) Score(ctx context.Context, state *framework.CycleState,
 	if handler == nil {
 		return 0, nil
 	}
+	startTime := time.Now()
+	defer func() {
+		metrics.ScoreDuration.WithLabelValues(string(tm.scoreStrategyType)).Observe(time.Since(startTime).Seconds())
+	}()
 	return handler(lh, pod, nodeTopology.Zones)
 }
 
@@ -0,0 +1,401 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesourcetopology
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1qos "k8s.io/kubernetes/pkg/apis/core/v1/helper/qos"
+	kubeletconfig "k8s.io/kubernetes/pkg/kubelet/apis/config"
+	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager/bitmask"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+
+	"github.com/go-logr/logr"
+	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
+
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/stringify"
+	"sigs.k8s.io/scheduler-plugins/pkg/util"
+)
+
+// topologyHint mirrors the kubelet's TopologyHint: a set of NUMA nodes a resource can be allocated
+// from, preferred if it is among the narrowest sets the resource could ever be allocated from.
+// https://github.com/kubernetes/kubernetes/blob/v1.29.0/pkg/kubelet/cm/topologymanager/topology_manager.go#L83
+type topologyHint struct {
+	affinity  bitmask.BitMask
+	preferred bool
+}
+
+// multiNUMAContainerLevelHandler emulates the restricted and best-effort policies of the kubelet's
+// topology manager with the container scope: the hints of each container are merged, and the
+// container is aligned on the NUMA nodes of the best hint. The alignment of the pod is preferred
+// if the one of each container is.
+func multiNUMAContainerLevelHandler(lh logr.Logger, pod *v1.Pod, zones topologyv1alpha2.ZoneList, nodeInfo *framework.NodeInfo, conf TopologyManagerConfig) (bitmask.BitMask, bool, *framework.Status) {
+	lh.V(5).Info("container level multi NUMA node handler", "policy", conf.Policy)
+
+	nodes := createNUMANodeList(lh, zones)
+	qos := v1qos.GetPodQOS(pod)
+
+	// Node() != nil already verified in Filter(), which is the only public entry point
+	logNumaNodes(lh, "container handler NUMA resources", nodeInfo.Node().Name, nodes)
+
+	affinity := bitmask.NewEmptyBitMask()
+	preferred := true
+
+	// the init containers are running SERIALLY and BEFORE the normal containers,
+	// and the resources they are allocated are reused by the normal containers.
+	for _, initContainer := range pod.Spec.InitContainers {
+		lh.V(6).Info("init container desired resources", stringify.ResourceListToLoggable(initContainer.Resources.Requests)...)
+
+		aligned, ok := alignOnNUMANodes(lh, nodes, initContainer.Resources.Requests, qos, conf)
+		if !ok {
+			lh.V(2).Info("cannot align container", "name", initContainer.Name, "kind", "init")
+			return nil, false, framework.NewStatus(framework.Unschedulable, "cannot align init container")
+		}
+		affinity.Or(aligned.affinity)
+		preferred = preferred && aligned.preferred
+	}
+
+	for _, container := range pod.Spec.Containers {
+		lh.V(6).Info("app container resources", stringify.ResourceListToLoggable(container.Resources.Requests)...)
+
+		aligned, ok := alignOnNUMANodes(lh, nodes, container.Resources.Requests, qos, conf)
+		if !ok {
+			lh.V(2).Info("cannot align container", "name", container.Name, "kind", "app")
+			return nil, false, framework.NewStatus(framework.Unschedulable, "cannot align container")
+		}
+		affinity.Or(aligned.affinity)
+		preferred = preferred && aligned.preferred
+
+		// subtract the aligned resources from the NUMA nodes they are allocated from,
+		// so we won't allocate the same resources for the upcoming containers
+		subtractFromNUMAs(aligned.resources, nodes, numaNodeIndexes(nodes, aligned.affinity)...)
+	}
+	lh.V(2).Info("can align all containers", "affinity", affinity, "preferred", preferred)
+	return affinity, preferred, nil
+}
+
+// multiNUMAPodLevelHandler emulates the restricted and best-effort policies of the kubelet's
+// topology manager with the pod scope: the hints of the whole pod are merged at once.
+func multiNUMAPodLevelHandler(lh logr.Logger, pod *v1.Pod, zones topologyv1alpha2.ZoneList, nodeInfo *framework.NodeInfo, conf TopologyManagerConfig) (bitmask.BitMask, bool, *framework.Status) {
+	lh.V(5).Info("pod level multi NUMA node handler", "policy", conf.Policy)
+
+	resources := util.GetPodEffectiveRequest(pod)
+
+	nodes := createNUMANodeList(lh, zones)
+
+	// Node() != nil already verified in Filter(), which is the only public entry point
+	logNumaNodes(lh, "pod handler NUMA resources", nodeInfo.Node().Name, nodes)
+	lh.V(6).Info("pod desired resources", stringify.ResourceListToLoggable(resources)...)
+
+	aligned, ok := alignOnNUMANodes(lh, nodes, resources, v1qos.GetPodQOS(pod), conf)
+	if !ok {
+		lh.V(2).Info("cannot align pod", "name", pod.Name)
+		return nil, false, framework.NewStatus(framework.Unschedulable, "cannot align pod")
+	}
+	lh.V(2).Info("can align pod", "affinity", aligned.affinity, "preferred", aligned.preferred)
+	return aligned.affinity, aligned.preferred, nil
+}
+
+// numaAlignment is the NUMA affinity the kubelet would pick for a set of resources, whether it is
+// preferred, along with the resources aligned on it.
+type numaAlignment struct {
+	affinity  bitmask.BitMask
+	preferred bool
+	resources v1.ResourceList
+}
+
+// alignOnNUMANodes returns the NUMA affinity the kubelet would pick for the given resources, and
+// whether it would admit them. The restricted policy only admits the preferred affinities, while
+// the best-effort policy admits any, provided each resource can be allocated at all.
+func alignOnNUMANodes(lh logr.Logger, nodes NUMANodeList, resources v1.ResourceList, qos v1.PodQOSClass, conf TopologyManagerConfig) (numaAlignment, bool) {
+	hints, aligned := topologyHintsFor(lh, nodes, resources, qos)
+	best := mergeTopologyHints(nodes, hints, conf.PreferClosestNUMA)
+	lh.V(4).Info("merged topology hints", "affinity", best.affinity, "preferred", best.preferred)
+
+	alignment := numaAlignment{affinity: best.affinity, preferred: best.preferred, resources: aligned}
+	if conf.Policy == kubeletconfig.RestrictedTopologyManagerPolicy {
+		return alignment, best.preferred
+	}
+	for _, resourceHints := range hints {
+		if len(resourceHints) == 0 {
+			return alignment, false
+		}
+	}
+	return alignment, true
+}
+
+// topologyHintsFor returns the hints of each resource the kubelet would align on NUMA nodes, along
+// with those resources. Like the kubelet's hint providers, the CPU and memory managers only align
+// the exclusive resources of the guaranteed pods, i.e. the CPUs of the integral CPU requests, and
+// the resources no NUMA node reports have no NUMA preference. A resource no set of NUMA nodes can
+// currently satisfy has no hints.
+func topologyHintsFor(lh logr.Logger, nodes NUMANodeList, resources v1.ResourceList, qos v1.PodQOSClass) ([][]topologyHint, v1.ResourceList) {
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	var hints [][]topologyHint
+	aligned := v1.ResourceList{}
+	for _, name := range names {
+		resourceName := v1.ResourceName(name)
+		quantity := resources[resourceName]
+		if quantity.IsZero() {
+			continue
+		}
+		if isNUMAAffineResource(resourceName) {
+			if qos != v1.PodQOSGuaranteed {
+				continue
+			}
+			if resourceName == v1.ResourceCPU && quantity.MilliValue()%1000 != 0 {
+				continue
+			}
+		}
+		if !hasNUMAAffinity(nodes, resourceName) {
+			continue
+		}
+		resourceHints := resourceTopologyHints(nodes, resourceName, quantity)
+		lh.V(6).Info("topology hints", "resource", resourceName, "count", len(resourceHints))
+		hints = append(hints, resourceHints)
+		aligned[resourceName] = quantity
+	}
+	return hints, aligned
+}
+
+func hasNUMAAffinity(nodes NUMANodeList, resourceName v1.ResourceName) bool {
+	for _, node := range nodes {
+		if _, ok := node.Resources[resourceName]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceTopologyHints returns a hint for each set of NUMA nodes with enough of the given resource
+// available. The preferred hints are the narrowest among the sets of NUMA nodes whose capacity could
+// satisfy the request, whatever is available, as the kubelet's hint providers do.
+func resourceTopologyHints(nodes NUMANodeList, resourceName v1.ResourceName, quantity resource.Quantity) []topologyHint {
+	numaIDs := make([]int, 0, len(nodes))
+	for _, node := range nodes {
+		numaIDs = append(numaIDs, node.NUMAID)
+	}
+
+	minAffinitySize := len(nodes)
+	var hints []topologyHint
+	bitmask.IterateBitMasks(numaIDs, func(mask bitmask.BitMask) {
+		var capacity, available resource.Quantity
+		for _, idx := range numaNodeIndexes(nodes, mask) {
+			nodeAvailable, ok := nodes[idx].Resources[resourceName]
+			if !ok {
+				continue
+			}
+			available.Add(nodeAvailable)
+			// fall back to the available resources if the capacity is not reported
+			if nodeCapacity, ok := nodes[idx].Capacity[resourceName]; ok && !nodeCapacity.IsZero() {
+				capacity.Add(nodeCapacity)
+			} else {
+				capacity.Add(nodeAvailable)
+			}
+		}
+		if capacity.Cmp(quantity) >= 0 && mask.Count() < minAffinitySize {
+			minAffinitySize = mask.Count()
+		}
+		if available.Cmp(quantity) < 0 {
+			return
+		}
+		hints = append(hints, topologyHint{affinity: mask})
+	})
+
+	for i := range hints {
+		hints[i].preferred = hints[i].affinity.Count() == minAffinitySize
+	}
+	return hints
+}
+
+// mergeTopologyHints returns the hint the kubelet's topology manager would pick given the hints
+// of each resource. The kubelet merges every permutation of the hints, one per resource, by ANDing
+// their affinities; a merged hint is preferred if all its hints are preferred and have the same
+// affinity. Since there are at most 255 distinct affinities with 8 NUMA nodes, the distinct merged
+// hints are accumulated resource by resource instead of iterating over all the permutations.
+// https://github.com/kubernetes/kubernetes/blob/v1.29.0/pkg/kubelet/cm/topologymanager/policy.go#L331
+func mergeTopologyHints(nodes NUMANodeList, hints [][]topologyHint, preferClosestNUMA bool) topologyHint {
+	numaIDs := make([]int, 0, len(nodes))
+	for _, node := range nodes {
+		numaIDs = append(numaIDs, node.NUMAID)
+	}
+	defaultAffinity, _ := bitmask.NewBitMask(numaIDs...)
+
+	// the merged hints of the permutations of the hints of the resources seen so far, by affinity
+	// and preferredness. The merged hints with an empty affinity are dropped, since they are never
+	// picked and ANDing them with other affinities keeps them empty.
+	merged := map[string]topologyHint{}
+	addMerged := func(hints map[string]topologyHint, hint topologyHint) {
+		if hint.affinity.IsEmpty() {
+			return
+		}
+		key := hint.affinity.String()
+		if hint.preferred {
+			key += "/preferred"
+		}
+		hints[key] = hint
+	}
+	addMerged(merged, topologyHint{affinity: defaultAffinity, preferred: true})
+	// whether the merged hints already include the affinity of a hint, which the affinities of
+	// the hints of the next resources must be equal to for the merged hints to stay preferred
+	hasAffinity := false
+	bestNonPreferredAffinityCount := 0
+	for _, resourceHints := range hints {
+		next := map[string]topologyHint{}
+		if len(resourceHints) == 0 {
+			// the resource can't be allocated: the kubelet merges a non-preferred hint without affinity
+			for _, hint := range merged {
+				addMerged(next, topologyHint{affinity: hint.affinity})
+			}
+			merged = next
+			continue
+		}
+
+		narrowest := resourceHints[0].affinity
+		for _, resourceHint := range resourceHints {
+			if resourceHint.affinity.IsNarrowerThan(narrowest) {
+				narrowest = resourceHint.affinity
+			}
+			for _, hint := range merged {
+				addMerged(next, topologyHint{
+					affinity:  bitmask.And(hint.affinity, resourceHint.affinity),
+					preferred: hint.preferred && resourceHint.preferred && (!hasAffinity || resourceHint.affinity.IsEqual(hint.affinity)),
+				})
+			}
+		}
+		bestNonPreferredAffinityCount = max(bestNonPreferredAffinityCount, narrowest.Count())
+		hasAffinity = true
+		merged = next
+	}
+
+	candidates := make([]topologyHint, 0, len(merged))
+	for _, hint := range merged {
+		candidates = append(candidates, hint)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].affinity.IsEqual(candidates[j].affinity) {
+			return candidates[i].preferred
+		}
+		return candidates[i].affinity.IsLessThan(candidates[j].affinity)
+	})
+
+	compareAffinities := func(current, candidate *topologyHint) *topologyHint {
+		if candidate.affinity.IsEqual(current.affinity) {
+			return current
+		}
+		if preferClosestNUMA && current.affinity.Count() == candidate.affinity.Count() {
+			currentDistance := numaNodesAvgDistance(nodes, current.affinity)
+			candidateDistance := numaNodesAvgDistance(nodes, candidate.affinity)
+			if currentDistance != candidateDistance {
+				if candidateDistance < currentDistance {
+					return candidate
+				}
+				return current
+			}
+		}
+		if candidate.affinity.IsNarrowerThan(current.affinity) {
+			return candidate
+		}
+		return current
+	}
+
+	var best *topologyHint
+	for i := range candidates {
+		best = compareTopologyHints(best, &candidates[i], bestNonPreferredAffinityCount, compareAffinities)
+	}
+	if best == nil {
+		return topologyHint{affinity: defaultAffinity}
+	}
+	return *best
+}
+
+// compareTopologyHints returns the best of the current and candidate hints, as the kubelet does: the
+// preferred hints come first, then the fittest affinities among the preferred hints, or the ones
+// closest to bestNonPreferredAffinityCount among the non-preferred hints.
+// https://github.com/kubernetes/kubernetes/blob/v1.29.0/pkg/kubelet/cm/topologymanager/policy.go#L172
+func compareTopologyHints(current, candidate *topologyHint, bestNonPreferredAffinityCount int, compareAffinities func(current, candidate *topologyHint) *topologyHint) *topologyHint {
+	if current == nil {
+		return candidate
+	}
+	if !current.preferred && candidate.preferred {
+		return candidate
+	}
+	if current.preferred && !candidate.preferred {
+		return current
+	}
+	if current.preferred && candidate.preferred {
+		return compareAffinities(current, candidate)
+	}
+
+	currentCount, candidateCount := current.affinity.Count(), candidate.affinity.Count()
+	switch {
+	case currentCount > bestNonPreferredAffinityCount:
+		return compareAffinities(current, candidate)
+	case currentCount == bestNonPreferredAffinityCount:
+		if candidateCount != bestNonPreferredAffinityCount {
+			return current
+		}
+		return compareAffinities(current, candidate)
+	case candidateCount > bestNonPreferredAffinityCount:
+		return current
+	case candidateCount == bestNonPreferredAffinityCount:
+		return candidate
+	case candidateCount > currentCount:
+		return candidate
+	case candidateCount < currentCount:
+		return current
+	}
+	return compareAffinities(current, candidate)
+}
+
+// numaNodesAvgDistance returns the average distance between the NUMA nodes of the given affinity,
+// according to their costs.
+func numaNodesAvgDistance(nodes NUMANodeList, affinity bitmask.BitMask) float64 {
+	indexes := numaNodeIndexes(nodes, affinity)
+	if len(indexes) == 0 {
+		return maxDistanceValue
+	}
+	sum := 0
+	for _, idx1 := range indexes {
+		for _, idx2 := range indexes {
+			cost, ok := nodes[idx1].Costs[nodes[idx2].NUMAID]
+			if !ok {
+				cost = maxDistanceValue
+			}
+			sum += cost
+		}
+	}
+	return float64(sum) / float64(len(indexes)*len(indexes))
+}
+
+// numaNodeIndexes returns the indexes in the given list of the NUMA nodes of the given affinity.
+func numaNodeIndexes(nodes NUMANodeList, affinity bitmask.BitMask) []int {
+	var indexes []int
+	for idx, node := range nodes {
+		if affinity.IsSet(node.NUMAID) {
+			indexes = append(indexes, idx)
+		}
+	}
+	return indexes
+}
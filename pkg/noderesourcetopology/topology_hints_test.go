@@ -0,0 +1,300 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesourcetopology
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager/bitmask"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+
+	nrtcache "sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology/cache"
+	tu "sigs.k8s.io/scheduler-plugins/test/util"
+)
+
+func TestMergeTopologyHints(t *testing.T) {
+	mask := func(bits ...int) bitmask.BitMask {
+		m, _ := bitmask.NewBitMask(bits...)
+		return m
+	}
+	// node-0 and node-2 are closer to each other than node-0 and node-1
+	nodes := NUMANodeList{
+		{NUMAID: 0, Costs: map[int]int{0: 10, 1: 21, 2: 12, 3: 21}},
+		{NUMAID: 1, Costs: map[int]int{0: 21, 1: 10, 2: 21, 3: 12}},
+		{NUMAID: 2, Costs: map[int]int{0: 12, 1: 21, 2: 10, 3: 21}},
+		{NUMAID: 3, Costs: map[int]int{0: 21, 1: 12, 2: 21, 3: 10}},
+	}
+
+	tests := []struct {
+		name              string
+		hints             [][]topologyHint
+		preferClosestNUMA bool
+		expected          topologyHint
+	}{
+		{
+			name:     "no hints",
+			expected: topologyHint{affinity: mask(0, 1, 2, 3), preferred: true},
+		},
+		{
+			name: "narrowest preferred hint",
+			hints: [][]topologyHint{
+				{
+					{affinity: mask(0, 1), preferred: false},
+					{affinity: mask(1), preferred: true},
+					{affinity: mask(2), preferred: true},
+				},
+			},
+			expected: topologyHint{affinity: mask(1), preferred: true},
+		},
+		{
+			name: "preferred hints with the same affinity",
+			hints: [][]topologyHint{
+				{
+					{affinity: mask(0), preferred: true},
+					{affinity: mask(1), preferred: true},
+				},
+				{
+					{affinity: mask(1), preferred: true},
+					{affinity: mask(0, 1), preferred: false},
+				},
+			},
+			expected: topologyHint{affinity: mask(1), preferred: true},
+		},
+		{
+			name: "preferred hints with different affinities",
+			hints: [][]topologyHint{
+				{
+					{affinity: mask(0), preferred: true},
+					{affinity: mask(0, 1), preferred: false},
+				},
+				{
+					{affinity: mask(1), preferred: true},
+					{affinity: mask(0, 1), preferred: false},
+				},
+			},
+			expected: topologyHint{affinity: mask(0), preferred: false},
+		},
+		{
+			name: "resource without hints",
+			hints: [][]topologyHint{
+				{
+					{affinity: mask(0), preferred: true},
+				},
+				{},
+			},
+			expected: topologyHint{affinity: mask(0), preferred: false},
+		},
+		{
+			name: "narrowest affinity with the lowest NUMA nodes",
+			hints: [][]topologyHint{
+				{
+					{affinity: mask(0, 1), preferred: true},
+					{affinity: mask(0, 2), preferred: true},
+				},
+			},
+			expected: topologyHint{affinity: mask(0, 1), preferred: true},
+		},
+		{
+			name: "closest affinity",
+			hints: [][]topologyHint{
+				{
+					{affinity: mask(0, 1), preferred: true},
+					{affinity: mask(0, 2), preferred: true},
+				},
+			},
+			preferClosestNUMA: true,
+			expected:          topologyHint{affinity: mask(0, 2), preferred: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeTopologyHints(nodes, tt.hints, tt.preferClosestNUMA)
+			if !got.affinity.IsEqual(tt.expected.affinity) || got.preferred != tt.expected.preferred {
+				t.Errorf("got affinity %v preferred %v, expected affinity %v preferred %v", got.affinity, got.preferred, tt.expected.affinity, tt.expected.preferred)
+			}
+		})
+	}
+}
+
+func TestNodeResourceTopologyMultiNUMA(t *testing.T) {
+	// each NUMA node has 4 CPUs and 2 NICs, only 2 CPUs and 1 NIC of which are available
+	makeNRT := func(name string, policy topologyv1alpha2.TopologyManagerPolicy) *topologyv1alpha2.NodeResourceTopology {
+		return &topologyv1alpha2.NodeResourceTopology{
+			ObjectMeta:       metav1.ObjectMeta{Name: name},
+			TopologyPolicies: []string{string(policy)},
+			Zones: topologyv1alpha2.ZoneList{
+				{
+					Name: "node-0",
+					Type: "Node",
+					Resources: topologyv1alpha2.ResourceInfoList{
+						MakeTopologyResInfo(cpu, "4", "2"),
+						MakeTopologyResInfo(memory, "8Gi", "4Gi"),
+						MakeTopologyResInfo(nicResourceName, "2", "1"),
+					},
+				},
+				{
+					Name: "node-1",
+					Type: "Node",
+					Resources: topologyv1alpha2.ResourceInfoList{
+						MakeTopologyResInfo(cpu, "4", "2"),
+						MakeTopologyResInfo(memory, "8Gi", "4Gi"),
+						MakeTopologyResInfo(nicResourceName, "2", "1"),
+					},
+				},
+			},
+		}
+	}
+	nrts := []*topologyv1alpha2.NodeResourceTopology{
+		makeNRT("restricted-pod", topologyv1alpha2.RestrictedPodLevel),
+		makeNRT("restricted-container", topologyv1alpha2.RestrictedContainerLevel),
+		makeNRT("best-effort-pod", topologyv1alpha2.BestEffortPodLevel),
+	}
+
+	guaranteed := func(cpus, nics int64) v1.ResourceList {
+		return v1.ResourceList{
+			v1.ResourceCPU:    *resource.NewQuantity(cpus, resource.DecimalSI),
+			v1.ResourceMemory: resource.MustParse("1Gi"),
+			nicResourceName:   *resource.NewQuantity(nics, resource.DecimalSI),
+		}
+	}
+	burstable := func(nics int64) *v1.Pod {
+		pod := makePodWithReqByResourceList(&v1.ResourceList{
+			v1.ResourceCPU:  resource.MustParse("100m"),
+			nicResourceName: *resource.NewQuantity(nics, resource.DecimalSI),
+		})
+		pod.Spec.Containers[0].Name = containerName
+		return pod
+	}
+
+	tests := []struct {
+		name       string
+		pod        *v1.Pod
+		node       string
+		wantStatus *framework.Status
+	}{
+		{
+			name:       "Guaranteed QoS, restricted, fits in one NUMA node",
+			pod:        makePod("pod", withMultiContainers([]v1.ResourceList{guaranteed(2, 1)})),
+			node:       "restricted-pod",
+			wantStatus: nil,
+		},
+		{
+			name:       "Guaranteed QoS, restricted, CPUs only available on two NUMA nodes while one could hold them",
+			pod:        makePod("pod", withMultiContainers([]v1.ResourceList{guaranteed(3, 0)})),
+			node:       "restricted-pod",
+			wantStatus: framework.NewStatus(framework.Unschedulable, "cannot align pod"),
+		},
+		{
+			name:       "Guaranteed QoS, best-effort, CPUs only available on two NUMA nodes while one could hold them",
+			pod:        makePod("pod", withMultiContainers([]v1.ResourceList{guaranteed(3, 0)})),
+			node:       "best-effort-pod",
+			wantStatus: nil,
+		},
+		{
+			name:       "Guaranteed QoS, best-effort, not enough CPUs",
+			pod:        makePod("pod", withMultiContainers([]v1.ResourceList{guaranteed(5, 0)})),
+			node:       "best-effort-pod",
+			wantStatus: framework.NewStatus(framework.Unschedulable, "cannot align pod"),
+		},
+		{
+			name:       "Guaranteed QoS, restricted, fractional CPUs are not aligned",
+			pod:        makePod("pod", withMultiContainers([]v1.ResourceList{{v1.ResourceCPU: resource.MustParse("3500m"), v1.ResourceMemory: resource.MustParse("1Gi")}})),
+			node:       "restricted-pod",
+			wantStatus: nil,
+		},
+		{
+			name:       "Guaranteed QoS, restricted, CPUs and NICs on different NUMA nodes",
+			pod:        makePod("pod", withMultiContainers([]v1.ResourceList{guaranteed(2, 2)})),
+			node:       "restricted-pod",
+			wantStatus: framework.NewStatus(framework.Unschedulable, "cannot align pod"),
+		},
+		{
+			name:       "Burstable QoS, restricted, NICs only available on two NUMA nodes while one could hold them",
+			pod:        burstable(2),
+			node:       "restricted-pod",
+			wantStatus: framework.NewStatus(framework.Unschedulable, "cannot align pod"),
+		},
+		{
+			name:       "Burstable QoS, restricted, NICs requiring two NUMA nodes anyway",
+			pod:        burstable(3),
+			node:       "restricted-pod",
+			wantStatus: framework.NewStatus(framework.Unschedulable, "cannot align pod"),
+		},
+		{
+			name:       "Burstable QoS, restricted, NICs fit in one NUMA node",
+			pod:        burstable(1),
+			node:       "restricted-pod",
+			wantStatus: nil,
+		},
+		{
+			name:       "Guaranteed QoS, restricted container scope, each container fits in its own NUMA node",
+			pod:        makePod("pod", withMultiContainers([]v1.ResourceList{guaranteed(2, 1), guaranteed(2, 1)})),
+			node:       "restricted-container",
+			wantStatus: nil,
+		},
+		{
+			name:       "Guaranteed QoS, restricted container scope, the last container doesn't fit",
+			pod:        makePod("pod", withMultiContainers([]v1.ResourceList{guaranteed(2, 0), guaranteed(2, 0), guaranteed(1, 0)})),
+			node:       "restricted-container",
+			wantStatus: framework.NewStatus(framework.Unschedulable, "cannot align container"),
+		},
+		{
+			name: "Guaranteed QoS, restricted container scope, init container resources are reused",
+			pod: makePod("pod",
+				withMultiInitContainers([]v1.ResourceList{guaranteed(2, 1)}),
+				withMultiContainers([]v1.ResourceList{guaranteed(2, 1), guaranteed(2, 1)})),
+			node:       "restricted-container",
+			wantStatus: nil,
+		},
+	}
+
+	fakeClient, err := tu.NewFakeClient()
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+	nodes := make(map[string]*v1.Node)
+	for _, nrt := range nrts {
+		if err := fakeClient.Create(context.Background(), nrt.DeepCopy()); err != nil {
+			t.Fatal(err)
+		}
+		nodes[nrt.Name] = makeNodeFromNodeResourceTopology(nrt)
+	}
+
+	tm := TopologyMatch{
+		nrtCache: nrtcache.NewPassthrough(klog.Background(), fakeClient),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(nodes[tt.node])
+			gotStatus := tm.Filter(context.Background(), framework.NewCycleState(), tt.pod, nodeInfo)
+
+			if !reflect.DeepEqual(gotStatus, tt.wantStatus) {
+				t.Errorf("status does not match: %v, want: %v", gotStatus, tt.wantStatus)
+			}
+		})
+	}
+}
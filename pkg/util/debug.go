@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// debugHandlers holds the debug handlers registered by the plugins, by path. The secure port of
+// the scheduler only serves a fixed set of handlers, so they are served by DebugHandler on an
+// address of their own.
+var debugHandlers = struct {
+	sync.RWMutex
+	handlers map[string]http.Handler
+}{handlers: make(map[string]http.Handler)}
+
+// RegisterDebugHandler registers the handler serving the given path, which should start with
+// /debug/. A handler registered again for the same path, e.g. by a plugin instantiated again,
+// replaces the previous one.
+func RegisterDebugHandler(path string, handler http.Handler) {
+	debugHandlers.Lock()
+	defer debugHandlers.Unlock()
+	debugHandlers.handlers[path] = handler
+}
+
+// DebugHandler serves the registered debug handlers, and lists their paths under /debug/.
+func DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		debugHandlers.RLock()
+		handler, ok := debugHandlers.handlers[req.URL.Path]
+		var paths []string
+		if !ok && req.URL.Path == "/debug/" {
+			for path := range debugHandlers.handlers {
+				paths = append(paths, path)
+			}
+		}
+		debugHandlers.RUnlock()
+
+		if ok {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		if paths == nil {
+			http.NotFound(w, req)
+			return
+		}
+		sort.Strings(paths)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(strings.Join(paths, "\n") + "\n"))
+	})
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	serve := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(body))
+		})
+	}
+	RegisterDebugHandler("/debug/test/a", serve("first"))
+	RegisterDebugHandler("/debug/test/a", serve("a"))
+	RegisterDebugHandler("/debug/test/b", serve("b"))
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{path: "/debug/test/a", wantCode: http.StatusOK, wantBody: "a"},
+		{path: "/debug/test/b", wantCode: http.StatusOK, wantBody: "b"},
+		{path: "/debug/", wantCode: http.StatusOK, wantBody: "/debug/test/a\n/debug/test/b\n"},
+		{path: "/debug/test/c", wantCode: http.StatusNotFound, wantBody: "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantCode || rec.Body.String() != tt.wantBody {
+				t.Errorf("want %v %q, got %v %q", tt.wantCode, tt.wantBody, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
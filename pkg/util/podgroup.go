@@ -22,6 +22,8 @@ import (
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
@@ -74,3 +76,86 @@ func GetWaitTimeDuration(pg *v1alpha1.PodGroup, scheduleTimeout *time.Duration)
 	}
 	return DefaultWaitTime
 }
+
+// PodMatchesRole returns true if the pod is selected by the given role of a pod group.
+// A role with an invalid or empty selector selects no pod.
+func PodMatchesRole(role *v1alpha1.PodGroupRole, pod *v1.Pod) bool {
+	if role.Selector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(role.Selector)
+	if err != nil || selector.Empty() {
+		return false
+	}
+	return selector.Matches(labels.Set(pod.Labels))
+}
+
+// GetUnsatisfiedRoles returns the names of the roles of the given pg that are
+// selecting less pods than their minMember among the given pods.
+func GetUnsatisfiedRoles(pg *v1alpha1.PodGroup, pods []*v1.Pod) []string {
+	missing := GetMissingRoleMembers(pg, pods)
+	var unsatisfied []string
+	for _, role := range pg.Spec.Roles {
+		if _, ok := missing[role.Name]; ok {
+			unsatisfied = append(unsatisfied, role.Name)
+		}
+	}
+	return unsatisfied
+}
+
+// GetMissingRoleMembers returns, for each role of the given pg selecting less pods than
+// its minMember among the given pods, how many pods it is missing.
+func GetMissingRoleMembers(pg *v1alpha1.PodGroup, pods []*v1.Pod) map[string]int32 {
+	var missing map[string]int32
+	for i := range pg.Spec.Roles {
+		role := &pg.Spec.Roles[i]
+		var count int32
+		for _, pod := range pods {
+			if PodMatchesRole(role, pod) {
+				count++
+			}
+		}
+		if count < role.MinMember {
+			if missing == nil {
+				missing = make(map[string]int32)
+			}
+			missing[role.Name] = role.MinMember - count
+		}
+	}
+	return missing
+}
+
+// GetMinResources returns the minimal resources to run the given pg: for each resource,
+// the larger of spec.minResources and the sum of the minResources of its roles.
+// It returns nil if neither the pg nor its roles specify minResources.
+func GetMinResources(pg *v1alpha1.PodGroup) v1.ResourceList {
+	var roleResources v1.ResourceList
+	for _, role := range pg.Spec.Roles {
+		for name, quant := range role.MinResources {
+			if roleResources == nil {
+				roleResources = v1.ResourceList{}
+			}
+			sum := roleResources[name]
+			sum.Add(quant)
+			roleResources[name] = sum
+		}
+	}
+	if roleResources == nil {
+		return pg.Spec.MinResources.DeepCopy()
+	}
+	for name, quant := range pg.Spec.MinResources {
+		if sum, ok := roleResources[name]; !ok || quant.Cmp(sum) > 0 {
+			roleResources[name] = quant.DeepCopy()
+		}
+	}
+	return roleResources
+}
+
+// GetMaxMember returns the maximal number of members of the given pg if it is elastic,
+// which is at least its minMember; otherwise returns 0.
+func GetMaxMember(pg *v1alpha1.PodGroup) int32 {
+	if pg.Spec.MaxMember == nil {
+		return 0
+	}
+	return max(*pg.Spec.MaxMember, pg.Spec.MinMember)
+}
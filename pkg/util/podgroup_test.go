@@ -17,9 +17,15 @@ limitations under the License.
 package util
 
 import (
+	"reflect"
 	"testing"
 
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/kubernetes/pkg/apis/core"
+	st "k8s.io/kubernetes/pkg/scheduler/testing"
+
+	tu "sigs.k8s.io/scheduler-plugins/test/util"
 )
 
 func TestCreateMergePatch(t *testing.T) {
@@ -70,3 +76,116 @@ func TestCreateMergePatch(t *testing.T) {
 		}
 	}
 }
+
+func TestGetUnsatisfiedRoles(t *testing.T) {
+	pg := tu.MakePodGroup().Name("pg").Namespace("ns").MinMember(3).
+		Role("ps", map[string]string{"role": "ps"}, 1, nil).
+		Role("worker", map[string]string{"role": "worker"}, 2, nil).Obj()
+
+	tests := []struct {
+		name string
+		pods []*v1.Pod
+		want []string
+	}{
+		{
+			name: "no pods",
+			want: []string{"ps", "worker"},
+		},
+		{
+			name: "workers are missing",
+			pods: []*v1.Pod{
+				st.MakePod().Name("ps-0").Label("role", "ps").Obj(),
+				st.MakePod().Name("worker-0").Label("role", "worker").Obj(),
+				st.MakePod().Name("other").Obj(),
+			},
+			want: []string{"worker"},
+		},
+		{
+			name: "all roles satisfied",
+			pods: []*v1.Pod{
+				st.MakePod().Name("ps-0").Label("role", "ps").Obj(),
+				st.MakePod().Name("worker-0").Label("role", "worker").Obj(),
+				st.MakePod().Name("worker-1").Label("role", "worker").Obj(),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetUnsatisfiedRoles(pg, tt.pods); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v get %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetMinResources(t *testing.T) {
+	tests := []struct {
+		name string
+		pg   *tu.PodGroupWrapper
+		want v1.ResourceList
+	}{
+		{
+			name: "no minResources",
+			pg:   tu.MakePodGroup().Role("ps", map[string]string{"role": "ps"}, 1, nil),
+		},
+		{
+			name: "minResources of the pod group only",
+			pg:   tu.MakePodGroup().MinResources(map[v1.ResourceName]string{v1.ResourceCPU: "2"}),
+			want: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")},
+		},
+		{
+			name: "minResources of the roles are added up",
+			pg: tu.MakePodGroup().MinResources(map[v1.ResourceName]string{v1.ResourceCPU: "2", v1.ResourceMemory: "8Gi"}).
+				Role("ps", map[string]string{"role": "ps"}, 1, map[v1.ResourceName]string{v1.ResourceCPU: "1"}).
+				Role("worker", map[string]string{"role": "worker"}, 2, map[v1.ResourceName]string{v1.ResourceCPU: "4"}),
+			want: v1.ResourceList{v1.ResourceCPU: resource.MustParse("5"), v1.ResourceMemory: resource.MustParse("8Gi")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetMinResources(tt.pg.Obj())
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v get %v", tt.want, got)
+			}
+			for name, quant := range tt.want {
+				if q := got[name]; q.Cmp(quant) != 0 {
+					t.Errorf("expected %v get %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGetMaxMember(t *testing.T) {
+	tests := []struct {
+		name string
+		pg   *tu.PodGroupWrapper
+		want int32
+	}{
+		{
+			name: "pod group is not elastic",
+			pg:   tu.MakePodGroup().MinMember(2),
+			want: 0,
+		},
+		{
+			name: "maxMember of an elastic pod group",
+			pg:   tu.MakePodGroup().MinMember(2).MaxMember(4),
+			want: 4,
+		},
+		{
+			name: "maxMember less than minMember",
+			pg:   tu.MakePodGroup().MinMember(2).MaxMember(1),
+			want: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetMaxMember(tt.pg.Obj()); got != tt.want {
+				t.Errorf("expected %v get %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+	extenderv1 "k8s.io/kube-scheduler/extender/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/preemption"
+)
+
+// PreemptorBase implements the methods of preemption.Interface the preemptors of the plugins
+// share: all the nodes are evaluated as candidates, and the candidates are only ordered by the
+// default criteria of the preemption.Evaluator.
+type PreemptorBase struct{}
+
+func (PreemptorBase) GetOffsetAndNumCandidates(n int32) (int32, int32) {
+	return 0, n
+}
+
+func (PreemptorBase) CandidatesToVictimsMap(candidates []preemption.Candidate) map[string]*extenderv1.Victims {
+	m := make(map[string]*extenderv1.Victims)
+	for _, c := range candidates {
+		m[c.Name()] = c.Victims()
+	}
+	return m
+}
+
+func (PreemptorBase) OrderedScoreFuncs(ctx context.Context, nodesToVictims map[string]*extenderv1.Victims) []func(node string) int64 {
+	return nil
+}
+
+// PreemptionPolicyAllows returns false, along with the reason, if the preemption policy of the pod
+// keeps it from preempting other pods.
+func PreemptionPolicyAllows(pod *v1.Pod) (bool, string) {
+	if pod.Spec.PreemptionPolicy != nil && *pod.Spec.PreemptionPolicy == v1.PreemptNever {
+		return false, "not eligible due to preemptionPolicy=Never."
+	}
+	return true, ""
+}
+
+// VictimsSimulation simulates the preemption of pods for the given pod on a copy of a node,
+// keeping the state of the PreFilter extensions of the plugins in sync.
+type VictimsSimulation struct {
+	Handle   framework.Handle
+	State    *framework.CycleState
+	Pod      *v1.Pod
+	NodeInfo *framework.NodeInfo
+}
+
+// RemovePod removes a pod from the node.
+func (s *VictimsSimulation) RemovePod(ctx context.Context, pi *framework.PodInfo) error {
+	if err := s.NodeInfo.RemovePod(klog.FromContext(ctx), pi.Pod); err != nil {
+		return err
+	}
+	if status := s.Handle.RunPreFilterExtensionRemovePod(ctx, s.State, s.Pod, pi, s.NodeInfo); !status.IsSuccess() {
+		return status.AsError()
+	}
+	return nil
+}
+
+// AddPod adds a pod back to the node.
+func (s *VictimsSimulation) AddPod(ctx context.Context, pi *framework.PodInfo) error {
+	s.NodeInfo.AddPodInfo(pi)
+	if status := s.Handle.RunPreFilterExtensionAddPod(ctx, s.State, s.Pod, pi, s.NodeInfo); !status.IsSuccess() {
+		return status.AsError()
+	}
+	return nil
+}
+
+// PodFits runs the filter plugins for the pod on the node.
+func (s *VictimsSimulation) PodFits(ctx context.Context) *framework.Status {
+	return s.Handle.RunFilterPluginsWithNominatedPods(ctx, s.State, s.Pod, s.NodeInfo)
+}
+
+// ReprieveVictims adds back to the node as many of the given potential victims, already removed
+// from it, as possible, while the pod still fits. The victims whose PDBs would be violated are
+// reprieved first, and then the others, each in the given order. A victim the pod fits with is
+// preempted anyway if <mustPreempt>, when not nil, returns true once it's added back.
+// It returns the victims left removed and how many of them violate their PDBs.
+func (s *VictimsSimulation) ReprieveVictims(ctx context.Context, potentialVictims []*framework.PodInfo,
+	pdbs []*policy.PodDisruptionBudget, mustPreempt func() bool) ([]*v1.Pod, int, error) {
+	var victims []*v1.Pod
+	reprievePod := func(pi *framework.PodInfo) (bool, error) {
+		if err := s.AddPod(ctx, pi); err != nil {
+			return false, err
+		}
+		if s.PodFits(ctx).IsSuccess() && (mustPreempt == nil || !mustPreempt()) {
+			return true, nil
+		}
+		if err := s.RemovePod(ctx, pi); err != nil {
+			return false, err
+		}
+		victims = append(victims, pi.Pod)
+		klog.V(5).InfoS("Found a potential preemption victim on node", "pod", klog.KObj(pi.Pod), "node", klog.KObj(s.NodeInfo.Node()))
+		return false, nil
+	}
+
+	numViolatingVictim := 0
+	violatingVictims, nonViolatingVictims := FilterPodsWithPDBViolation(potentialVictims, pdbs)
+	for _, pi := range violatingVictims {
+		if reprieved, err := reprievePod(pi); err != nil {
+			return nil, 0, err
+		} else if !reprieved {
+			numViolatingVictim++
+		}
+	}
+	for _, pi := range nonViolatingVictims {
+		if _, err := reprievePod(pi); err != nil {
+			return nil, 0, err
+		}
+	}
+	return victims, numViolatingVictim, nil
+}
+
+// FilterPodsWithPDBViolation groups the given "pods" into two groups of "violatingPods"
+// and "nonViolatingPods" based on whether their PDBs will be violated if they are
+// preempted.
+// This function is stable and does not change the order of received pods. So, if it
+// receives a sorted list, grouping will preserve the order of the input list.
+func FilterPodsWithPDBViolation(podInfos []*framework.PodInfo, pdbs []*policy.PodDisruptionBudget) (violatingPods, nonViolatingPods []*framework.PodInfo) {
+	pdbsAllowed := make([]int32, len(pdbs))
+	for i, pdb := range pdbs {
+		pdbsAllowed[i] = pdb.Status.DisruptionsAllowed
+	}
+
+	for _, podInfo := range podInfos {
+		pod := podInfo.Pod
+		pdbForPodIsViolated := false
+		// A pod with no labels will not match any PDB. So, no need to check.
+		if len(pod.Labels) != 0 {
+			for i, pdb := range pdbs {
+				if pdb.Namespace != pod.Namespace {
+					continue
+				}
+				selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+				if err != nil {
+					continue
+				}
+				// A PDB with a nil or empty selector matches nothing.
+				if selector.Empty() || !selector.Matches(labels.Set(pod.Labels)) {
+					continue
+				}
+
+				// Existing in DisruptedPods means it has been processed in API server,
+				// we don't treat it as a violating case.
+				if _, exist := pdb.Status.DisruptedPods[pod.Name]; exist {
+					continue
+				}
+				// Only decrement the matched pdb when it's not in its <DisruptedPods>;
+				// otherwise we may over-decrement the budget number.
+				pdbsAllowed[i]--
+				// We have found a matching PDB.
+				if pdbsAllowed[i] < 0 {
+					pdbForPodIsViolated = true
+				}
+			}
+		}
+		if pdbForPodIsViolated {
+			violatingPods = append(violatingPods, podInfo)
+		} else {
+			nonViolatingPods = append(nonViolatingPods, podInfo)
+		}
+	}
+	return violatingPods, nonViolatingPods
+}
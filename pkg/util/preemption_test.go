@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+func TestFilterPodsWithPDBViolation(t *testing.T) {
+	newPodInfo := func(name, namespace string, labels map[string]string) *framework.PodInfo {
+		return &framework.PodInfo{Pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}}
+	}
+	newPDB := func(namespace string, selector *metav1.LabelSelector, allowed int32, disrupted ...string) *policy.PodDisruptionBudget {
+		pdb := &policy.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "pdb", Namespace: namespace},
+			Spec:       policy.PodDisruptionBudgetSpec{Selector: selector},
+			Status:     policy.PodDisruptionBudgetStatus{DisruptionsAllowed: allowed},
+		}
+		if len(disrupted) > 0 {
+			pdb.Status.DisruptedPods = make(map[string]metav1.Time)
+			for _, name := range disrupted {
+				pdb.Status.DisruptedPods[name] = metav1.Now()
+			}
+		}
+		return pdb
+	}
+	appSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "a"}}
+	appLabels := map[string]string{"app": "a"}
+
+	tests := []struct {
+		name             string
+		pods             []*framework.PodInfo
+		pdbs             []*policy.PodDisruptionBudget
+		wantViolating    []string
+		wantNonViolating []string
+	}{
+		{
+			name: "pods beyond the allowed disruptions violate the PDB, in order",
+			pods: []*framework.PodInfo{
+				newPodInfo("p1", "ns", appLabels),
+				newPodInfo("p2", "ns", appLabels),
+				newPodInfo("p3", "ns", appLabels),
+			},
+			pdbs:             []*policy.PodDisruptionBudget{newPDB("ns", appSelector, 1)},
+			wantViolating:    []string{"p2", "p3"},
+			wantNonViolating: []string{"p1"},
+		},
+		{
+			name: "pods of other namespaces, without labels or already disrupted don't count",
+			pods: []*framework.PodInfo{
+				newPodInfo("p1", "other", appLabels),
+				newPodInfo("p2", "ns", nil),
+				newPodInfo("p3", "ns", appLabels),
+				newPodInfo("p4", "ns", appLabels),
+			},
+			pdbs:             []*policy.PodDisruptionBudget{newPDB("ns", appSelector, 0, "p3")},
+			wantViolating:    []string{"p4"},
+			wantNonViolating: []string{"p1", "p2", "p3"},
+		},
+		{
+			name: "a PDB with an empty selector matches nothing",
+			pods: []*framework.PodInfo{
+				newPodInfo("p1", "ns", appLabels),
+			},
+			pdbs:             []*policy.PodDisruptionBudget{newPDB("ns", &metav1.LabelSelector{}, 0)},
+			wantNonViolating: []string{"p1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violating, nonViolating := FilterPodsWithPDBViolation(tt.pods, tt.pdbs)
+			names := func(podInfos []*framework.PodInfo) []string {
+				var names []string
+				for _, pi := range podInfos {
+					names = append(names, pi.Pod.Name)
+				}
+				return names
+			}
+			if got := names(violating); !reflect.DeepEqual(got, tt.wantViolating) {
+				t.Errorf("violating pods: got %v, want %v", got, tt.wantViolating)
+			}
+			if got := names(nonViolating); !reflect.DeepEqual(got, tt.wantNonViolating) {
+				t.Errorf("non violating pods: got %v, want %v", got, tt.wantNonViolating)
+			}
+		})
+	}
+}
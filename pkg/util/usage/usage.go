@@ -0,0 +1,115 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package usage computes the usage of ElasticQuotas, so that the ElasticQuota controller
+// reports the same usage the CapacityScheduling plugin enforces.
+package usage
+
+import (
+	v1 "k8s.io/api/core/v1"
+	quota "k8s.io/apiserver/pkg/quota/v1"
+)
+
+// IsPodCounted checks whether a pod counts towards the usage of its ElasticQuota: it is
+// bound to a node, it is in a non-terminal phase and it is not terminating.
+func IsPodCounted(pod *v1.Pod) bool {
+	if pod.Spec.NodeName == "" || pod.DeletionTimestamp != nil {
+		return false
+	}
+	return pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed
+}
+
+// IsPodUnschedulable checks whether a pod is pending because the scheduler found no node for it.
+func IsPodUnschedulable(pod *v1.Pod) bool {
+	if pod.Spec.NodeName != "" || pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodPending {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled {
+			return condition.Status == v1.ConditionFalse && condition.Reason == v1.PodReasonUnschedulable
+		}
+	}
+	return false
+}
+
+// IsPodPreempted checks whether a pod is being terminated because the scheduler preempted it.
+func IsPodPreempted(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.DisruptionTarget {
+			return condition.Status == v1.ConditionTrue && condition.Reason == v1.PodReasonPreemptionByScheduler
+		}
+	}
+	return false
+}
+
+// PodRequest returns a v1.ResourceList that covers the largest
+// width in each resource dimension. Because init-containers run sequentially, we collect
+// the max in each dimension iteratively. In contrast, we sum the resource vectors for
+// regular containers since they run simultaneously.
+//
+// If Pod Overhead is specified, the resources defined for Overhead
+// are added to the calculated Resource request sum
+//
+// Example:
+//
+// Pod:
+//
+//	InitContainers
+//	  IC1:
+//	    CPU: 2
+//	    Memory: 1G
+//	  IC2:
+//	    CPU: 2
+//	    Memory: 3G
+//	Containers
+//	  C1:
+//	    CPU: 2
+//	    Memory: 1G
+//	  C2:
+//	    CPU: 1
+//	    Memory: 1G
+//
+// Result: CPU: 3, Memory: 3G
+func PodRequest(pod *v1.Pod) v1.ResourceList {
+	result := v1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		result = quota.Add(result, container.Resources.Requests)
+	}
+	// take max_resource for init_containers and containers
+	for _, container := range pod.Spec.InitContainers {
+		result = quota.Max(result, container.Resources.Requests)
+	}
+	// If Overhead is being utilized, add to the total requests for the pod
+	if pod.Spec.Overhead != nil {
+		result = quota.Add(result, pod.Spec.Overhead)
+	}
+	return result
+}
+
+// Compute returns the sum of the requests of the given pods that count towards the usage
+// of an ElasticQuota, along with the number of these pods.
+func Compute(pods []v1.Pod) (v1.ResourceList, int32) {
+	used := v1.ResourceList{}
+	var count int32
+	for i := range pods {
+		if !IsPodCounted(&pods[i]) {
+			continue
+		}
+		used = quota.Add(used, PodRequest(&pods[i]))
+		count++
+	}
+	return used, count
+}
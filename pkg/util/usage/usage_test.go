@@ -0,0 +1,127 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usage
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	quota "k8s.io/apiserver/pkg/quota/v1"
+)
+
+func makePod(name, nodeName string, phase v1.PodPhase, cpu string) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Spec: v1.PodSpec{
+			NodeName: nodeName,
+			Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)},
+				},
+			}},
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func TestCompute(t *testing.T) {
+	terminating := makePod("terminating", "node", v1.PodRunning, "8")
+	terminating.DeletionTimestamp = &metav1.Time{}
+	pods := []v1.Pod{
+		makePod("running", "node", v1.PodRunning, "1"),
+		makePod("pending-bound", "node", v1.PodPending, "2"),
+		makePod("pending-unbound", "", v1.PodPending, "4"),
+		makePod("succeeded", "node", v1.PodSucceeded, "8"),
+		makePod("failed", "node", v1.PodFailed, "8"),
+		makePod("unknown", "node", v1.PodUnknown, "16"),
+		terminating,
+	}
+
+	used, count := Compute(pods)
+	if want := (v1.ResourceList{v1.ResourceCPU: resource.MustParse("19")}); !quota.Equals(used, want) {
+		t.Errorf("expected used %v, got %v", want, used)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 pods, got %v", count)
+	}
+}
+
+func TestPodRequest(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{
+				{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2"), v1.ResourceMemory: resource.MustParse("1G")}}},
+				{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2"), v1.ResourceMemory: resource.MustParse("3G")}}},
+			},
+			Containers: []v1.Container{
+				{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2"), v1.ResourceMemory: resource.MustParse("1G")}}},
+				{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"), v1.ResourceMemory: resource.MustParse("1G")}}},
+			},
+			Overhead: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")},
+		},
+	}
+	want := v1.ResourceList{v1.ResourceCPU: resource.MustParse("3100m"), v1.ResourceMemory: resource.MustParse("3G")}
+	if got := PodRequest(pod); !quota.Equals(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestIsPodUnschedulable(t *testing.T) {
+	unschedulable := makePod("unschedulable", "", v1.PodPending, "1")
+	unschedulable.Status.Conditions = []v1.PodCondition{{
+		Type:   v1.PodScheduled,
+		Status: v1.ConditionFalse,
+		Reason: v1.PodReasonUnschedulable,
+	}}
+	bound := unschedulable.DeepCopy()
+	bound.Spec.NodeName = "node"
+	for _, tt := range []struct {
+		pod  v1.Pod
+		want bool
+	}{
+		{pod: unschedulable, want: true},
+		{pod: makePod("pending", "", v1.PodPending, "1"), want: false},
+		{pod: *bound, want: false},
+	} {
+		if got := IsPodUnschedulable(&tt.pod); got != tt.want {
+			t.Errorf("%v: expected %v, got %v", tt.pod.Name, tt.want, got)
+		}
+	}
+}
+
+func TestIsPodPreempted(t *testing.T) {
+	preempted := makePod("preempted", "node", v1.PodRunning, "1")
+	preempted.Status.Conditions = []v1.PodCondition{{
+		Type:   v1.DisruptionTarget,
+		Status: v1.ConditionTrue,
+		Reason: v1.PodReasonPreemptionByScheduler,
+	}}
+	evicted := makePod("evicted", "node", v1.PodRunning, "1")
+	evicted.Status.Conditions = []v1.PodCondition{{
+		Type:   v1.DisruptionTarget,
+		Status: v1.ConditionTrue,
+		Reason: "EvictionByEvictionAPI",
+	}}
+	if !IsPodPreempted(&preempted) {
+		t.Errorf("expected preempted pod to be preempted")
+	}
+	if IsPodPreempted(&evicted) {
+		t.Errorf("expected evicted pod not to be preempted")
+	}
+}
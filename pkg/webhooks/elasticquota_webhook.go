@@ -0,0 +1,334 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	quota "k8s.io/apiserver/pkg/quota/v1"
+	"k8s.io/utils/pointer"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"sigs.k8s.io/scheduler-plugins/apis/scheduling"
+	schedv1alpha1 "sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+)
+
+// MinCapacityPolicy defines how the ElasticQuota webhook handles an ElasticQuota that brings
+// the sum of the Min of the root ElasticQuotas above the capacity of the cluster.
+type MinCapacityPolicy string
+
+const (
+	// MinCapacityIgnore admits the ElasticQuota without checking the capacity of the cluster.
+	MinCapacityIgnore MinCapacityPolicy = "Ignore"
+	// MinCapacityWarn admits the ElasticQuota with a warning.
+	MinCapacityWarn MinCapacityPolicy = "Warn"
+	// MinCapacityDeny rejects the ElasticQuota.
+	MinCapacityDeny MinCapacityPolicy = "Deny"
+)
+
+var elasticQuotaKind = schema.GroupKind{Group: scheduling.GroupName, Kind: "ElasticQuota"}
+
+// ElasticQuotaWebhook defaults and validates ElasticQuotas.
+type ElasticQuotaWebhook struct {
+	client.Reader
+	// MinCapacityPolicy defines how an ElasticQuota whose Min can't be guaranteed by the
+	// capacity of the cluster is handled. Defaults to Warn.
+	MinCapacityPolicy MinCapacityPolicy
+}
+
+var _ admission.CustomDefaulter = &ElasticQuotaWebhook{}
+var _ admission.CustomValidator = &ElasticQuotaWebhook{}
+
+// +kubebuilder:webhook:path=/mutate-scheduling-x-k8s-io-v1alpha1-elasticquota,mutating=true,failurePolicy=fail,sideEffects=None,groups=scheduling.x-k8s.io,resources=elasticquotas,verbs=create;update,versions=v1alpha1,name=melasticquota.scheduling.x-k8s.io,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-scheduling-x-k8s-io-v1alpha1-elasticquota,mutating=false,failurePolicy=fail,sideEffects=None,groups=scheduling.x-k8s.io,resources=elasticquotas,verbs=create;update,versions=v1alpha1,name=velasticquota.scheduling.x-k8s.io,admissionReviewVersions=v1
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// SetupWithManager registers the webhook with the Manager.
+func (w *ElasticQuotaWebhook) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&schedv1alpha1.ElasticQuota{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+// Default sets the weight of the ElasticQuota to 1 and the namespace of its parent to its own
+// namespace, if unset.
+func (w *ElasticQuotaWebhook) Default(_ context.Context, obj runtime.Object) error {
+	eq, ok := obj.(*schedv1alpha1.ElasticQuota)
+	if !ok {
+		return fmt.Errorf("expected an ElasticQuota but got a %T", obj)
+	}
+	if eq.Spec.Weight == nil {
+		eq.Spec.Weight = pointer.Int32(1)
+	}
+	if eq.Spec.Parent != nil && eq.Spec.Parent.Namespace == "" {
+		eq.Spec.Parent.Namespace = eq.Namespace
+	}
+	return nil
+}
+
+// ValidateCreate validates the ElasticQuota, checks that it is the only ElasticQuota of its namespace,
+// and that it is consistent with the other ElasticQuotas.
+func (w *ElasticQuotaWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	eq, ok := obj.(*schedv1alpha1.ElasticQuota)
+	if !ok {
+		return nil, fmt.Errorf("expected an ElasticQuota but got a %T", obj)
+	}
+	allErrs := validateElasticQuotaSpec(eq)
+	eqList := &schedv1alpha1.ElasticQuotaList{}
+	if err := w.List(ctx, eqList, client.InNamespace(eq.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range eqList.Items {
+		if eqList.Items[i].Name != eq.Name {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("metadata", "namespace"),
+				fmt.Sprintf("namespace %s already has ElasticQuota %s, only one ElasticQuota is supported in each namespace", eq.Namespace, eqList.Items[i].Name)))
+			break
+		}
+	}
+	errs, err := w.validateAgainstOtherElasticQuotas(ctx, eq, allErrs)
+	if err != nil {
+		return nil, err
+	}
+	allErrs = append(allErrs, errs...)
+	if len(allErrs) > 0 {
+		return nil, apierrors.NewInvalid(elasticQuotaKind, eq.Name, allErrs)
+	}
+	return w.checkMinCapacity(ctx, eq)
+}
+
+// ValidateUpdate validates the updated ElasticQuota, and checks that it is consistent with the
+// other ElasticQuotas.
+func (w *ElasticQuotaWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	eq, ok := newObj.(*schedv1alpha1.ElasticQuota)
+	if !ok {
+		return nil, fmt.Errorf("expected an ElasticQuota but got a %T", newObj)
+	}
+	allErrs := validateElasticQuotaSpec(eq)
+	errs, err := w.validateAgainstOtherElasticQuotas(ctx, eq, allErrs)
+	if err != nil {
+		return nil, err
+	}
+	allErrs = append(allErrs, errs...)
+	if len(allErrs) > 0 {
+		return nil, apierrors.NewInvalid(elasticQuotaKind, eq.Name, allErrs)
+	}
+	return w.checkMinCapacity(ctx, eq)
+}
+
+// ValidateDelete allows the deletion of any ElasticQuota.
+func (w *ElasticQuotaWebhook) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateElasticQuotaSpec checks that the quantities of the ElasticQuota are not negative,
+// that its Min is not greater than its Max, and that its parent and namespace selector are valid.
+func validateElasticQuotaSpec(eq *schedv1alpha1.ElasticQuota) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	allErrs = append(allErrs, validateNonNegative(eq.Spec.Min, specPath.Child("min"))...)
+	allErrs = append(allErrs, validateNonNegative(eq.Spec.Max, specPath.Child("max"))...)
+	for name, min := range eq.Spec.Min {
+		if max, ok := eq.Spec.Max[name]; ok && min.Cmp(max) > 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("min").Key(string(name)), min.String(),
+				fmt.Sprintf("must be less than or equal to max %s", max.String())))
+		}
+	}
+	if parent := eq.Spec.Parent; parent != nil {
+		if parent.Name == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("parent", "name"), ""))
+		}
+		if parent.Name == eq.Name && (parent.Namespace == "" || parent.Namespace == eq.Namespace) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("parent"), parent.Name, "must not reference the ElasticQuota itself"))
+		}
+	}
+	if eq.Spec.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(eq.Spec.NamespaceSelector); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("namespaceSelector"), eq.Spec.NamespaceSelector, err.Error()))
+		}
+	}
+	if eq.Spec.Weight != nil && *eq.Spec.Weight < 1 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("weight"), *eq.Spec.Weight, "must be greater than or equal to 1"))
+	}
+	priorityClassNames := sets.New[string]()
+	for i, scope := range eq.Spec.Scopes {
+		scopePath := specPath.Child("scopes").Index(i)
+		if scope.PriorityClassName == "" {
+			allErrs = append(allErrs, field.Required(scopePath.Child("priorityClassName"), ""))
+		} else if priorityClassNames.Has(scope.PriorityClassName) {
+			allErrs = append(allErrs, field.Duplicate(scopePath.Child("priorityClassName"), scope.PriorityClassName))
+		}
+		priorityClassNames.Insert(scope.PriorityClassName)
+		allErrs = append(allErrs, validateNonNegative(scope.Max, scopePath.Child("max"))...)
+	}
+	return allErrs
+}
+
+// validateAgainstOtherElasticQuotas checks that the chain of parents of the ElasticQuota doesn't loop
+// back to it, and that it doesn't apply to a namespace another ElasticQuota applies to. The checks
+// are skipped if the spec of the ElasticQuota is already invalid, as given by specErrs.
+func (w *ElasticQuotaWebhook) validateAgainstOtherElasticQuotas(ctx context.Context, eq *schedv1alpha1.ElasticQuota, specErrs field.ErrorList) (field.ErrorList, error) {
+	if len(specErrs) > 0 {
+		return nil, nil
+	}
+	eqList := &schedv1alpha1.ElasticQuotaList{}
+	if err := w.List(ctx, eqList); err != nil {
+		return nil, err
+	}
+	key := client.ObjectKeyFromObject(eq)
+	byKey := make(map[client.ObjectKey]*schedv1alpha1.ElasticQuota, len(eqList.Items)+1)
+	for i := range eqList.Items {
+		byKey[client.ObjectKeyFromObject(&eqList.Items[i])] = &eqList.Items[i]
+	}
+	byKey[key] = eq
+
+	var allErrs field.ErrorList
+	visited := sets.New(key)
+	for parent := getParentKey(eq); parent != nil; {
+		if *parent == key {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "parent"), eq.Spec.Parent.Name, "must not reference a descendant of the ElasticQuota"))
+			break
+		}
+		next, ok := byKey[*parent]
+		if !ok || visited.Has(*parent) {
+			break
+		}
+		visited.Insert(*parent)
+		parent = getParentKey(next)
+	}
+
+	nsList := &v1.NamespaceList{}
+	if err := w.List(ctx, nsList); err != nil {
+		return nil, err
+	}
+	namespaces := getNamespaces(eq, nsList.Items)
+	for otherKey, other := range byKey {
+		if otherKey == key || other.Namespace == eq.Namespace {
+			continue
+		}
+		if overlap := namespaces.Intersection(getNamespaces(other, nsList.Items)); overlap.Len() > 0 {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "namespaceSelector"),
+				fmt.Sprintf("namespaces %v are already subject to ElasticQuota %s", sets.List(overlap), otherKey)))
+		}
+	}
+	return allErrs, nil
+}
+
+// getParentKey returns the key of the parent of the given ElasticQuota, if any.
+func getParentKey(eq *schedv1alpha1.ElasticQuota) *client.ObjectKey {
+	if eq.Spec.Parent == nil {
+		return nil
+	}
+	namespace := eq.Spec.Parent.Namespace
+	if namespace == "" {
+		namespace = eq.Namespace
+	}
+	return &client.ObjectKey{Namespace: namespace, Name: eq.Spec.Parent.Name}
+}
+
+// getNamespaces returns the namespaces of the given ones the given ElasticQuota applies to: the
+// namespaces selected by its namespace selector, or its own namespace if it has none.
+func getNamespaces(eq *schedv1alpha1.ElasticQuota, namespaces []v1.Namespace) sets.Set[string] {
+	if eq.Spec.NamespaceSelector == nil {
+		return sets.New(eq.Namespace)
+	}
+	selected := sets.New[string]()
+	selector, err := metav1.LabelSelectorAsSelector(eq.Spec.NamespaceSelector)
+	if err != nil {
+		return selected
+	}
+	for i := range namespaces {
+		if selector.Matches(labels.Set(namespaces[i].Labels)) {
+			selected.Insert(namespaces[i].Name)
+		}
+	}
+	return selected
+}
+
+// checkMinCapacity checks, according to the MinCapacityPolicy, that the sum of the Min of the root
+// ElasticQuotas doesn't exceed the allocatable resources of the nodes of the cluster, as the Min
+// couldn't be guaranteed otherwise. The Min of the descendants is bound by the Min of the roots.
+func (w *ElasticQuotaWebhook) checkMinCapacity(ctx context.Context, eq *schedv1alpha1.ElasticQuota) (admission.Warnings, error) {
+	if w.MinCapacityPolicy == MinCapacityIgnore || eq.Spec.Parent != nil {
+		return nil, nil
+	}
+	eqList := &schedv1alpha1.ElasticQuotaList{}
+	if err := w.List(ctx, eqList); err != nil {
+		return nil, err
+	}
+	totalMin := eq.Spec.Min.DeepCopy()
+	for i := range eqList.Items {
+		other := &eqList.Items[i]
+		if other.Spec.Parent == nil && client.ObjectKeyFromObject(other) != client.ObjectKeyFromObject(eq) {
+			totalMin = quota.Add(totalMin, other.Spec.Min)
+		}
+	}
+	nodeList := &v1.NodeList{}
+	if err := w.List(ctx, nodeList); err != nil {
+		return nil, err
+	}
+	capacity := v1.ResourceList{}
+	for i := range nodeList.Items {
+		capacity = quota.Add(capacity, nodeList.Items[i].Status.Allocatable)
+	}
+
+	var allErrs field.ErrorList
+	for _, name := range quota.ResourceNames(eq.Spec.Min) {
+		total := totalMin[name]
+		if allocatable := capacity[name]; total.Cmp(allocatable) > 0 {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "min").Key(string(name)), eq.Spec.Min[name],
+				fmt.Sprintf("the sum of the min of the root ElasticQuotas %s exceeds the allocatable resources of the cluster %s", total.String(), allocatable.String())))
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil, nil
+	}
+	if w.MinCapacityPolicy == MinCapacityDeny {
+		return nil, apierrors.NewInvalid(elasticQuotaKind, eq.Name, allErrs)
+	}
+	warnings := make(admission.Warnings, 0, len(allErrs))
+	for _, err := range allErrs {
+		warnings = append(warnings, err.Error())
+	}
+	return warnings, nil
+}
+
+// validateNonNegative checks that the given quantities are not negative.
+func validateNonNegative(resources v1.ResourceList, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for name, quantity := range resources {
+		if quantity.Cmp(resource.Quantity{}) < 0 {
+			allErrs = append(allErrs, field.Invalid(path.Key(string(name)), quantity.String(), "must be greater than or equal to 0"))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	schedv1alpha1 "sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+)
+
+func TestElasticQuotaDefault(t *testing.T) {
+	eq := makeEQ("ns1", "eq1", makeResourceList("1", "1Gi"), makeResourceList("2", "2Gi"))
+	eq.Spec.Parent = &schedv1alpha1.ElasticQuotaReference{Name: "parent"}
+	if err := (&ElasticQuotaWebhook{}).Default(context.TODO(), eq); err != nil {
+		t.Fatal(err)
+	}
+	if eq.Spec.Weight == nil || *eq.Spec.Weight != 1 {
+		t.Errorf("want weight 1, got %v", eq.Spec.Weight)
+	}
+	if eq.Spec.Parent.Namespace != "ns1" {
+		t.Errorf("want parent namespace ns1, got %q", eq.Spec.Parent.Namespace)
+	}
+}
+
+func TestElasticQuotaValidate(t *testing.T) {
+	ctx := context.TODO()
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+		Status:     v1.NodeStatus{Allocatable: makeResourceList("4", "8Gi")},
+	}
+	tests := []struct {
+		name         string
+		eq           *schedv1alpha1.ElasticQuota
+		existing     []runtime.Object
+		policy       MinCapacityPolicy
+		update       bool
+		wantErr      bool
+		wantWarnings int
+	}{
+		{
+			name: "valid elastic quota",
+			eq:   makeEQ("ns1", "eq1", makeResourceList("1", "1Gi"), makeResourceList("2", "2Gi")),
+		},
+		{
+			name:    "min greater than max",
+			eq:      makeEQ("ns1", "eq1", makeResourceList("3", "1Gi"), makeResourceList("2", "2Gi")),
+			wantErr: true,
+		},
+		{
+			name:    "negative quantity",
+			eq:      makeEQ("ns1", "eq1", makeResourceList("-1", "1Gi"), makeResourceList("2", "2Gi")),
+			wantErr: true,
+		},
+		{
+			name:     "second elastic quota in the namespace",
+			eq:       makeEQ("ns1", "eq2", makeResourceList("1", "1Gi"), makeResourceList("2", "2Gi")),
+			existing: []runtime.Object{makeEQ("ns1", "eq1", nil, nil)},
+			wantErr:  true,
+		},
+		{
+			name:     "second elastic quota in the namespace is not checked on update",
+			eq:       makeEQ("ns1", "eq2", makeResourceList("1", "1Gi"), makeResourceList("2", "2Gi")),
+			existing: []runtime.Object{makeEQ("ns1", "eq1", nil, nil)},
+			update:   true,
+		},
+		{
+			name: "parent referencing the elastic quota itself",
+			eq: func() *schedv1alpha1.ElasticQuota {
+				eq := makeEQ("ns1", "eq1", nil, nil)
+				eq.Spec.Parent = &schedv1alpha1.ElasticQuotaReference{Name: "eq1", Namespace: "ns1"}
+				return eq
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "zero weight",
+			eq: func() *schedv1alpha1.ElasticQuota {
+				eq := makeEQ("ns1", "eq1", nil, nil)
+				eq.Spec.Weight = pointer.Int32(0)
+				return eq
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "scopes of distinct priority classes",
+			eq: func() *schedv1alpha1.ElasticQuota {
+				eq := makeEQ("ns1", "eq1", nil, nil)
+				eq.Spec.Scopes = []schedv1alpha1.ElasticQuotaScope{
+					{PriorityClassName: "preemptible", Max: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("2")}},
+					{PriorityClassName: "production", Max: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("4")}},
+				}
+				return eq
+			}(),
+		},
+		{
+			name: "scopes of the same priority class",
+			eq: func() *schedv1alpha1.ElasticQuota {
+				eq := makeEQ("ns1", "eq1", nil, nil)
+				eq.Spec.Scopes = []schedv1alpha1.ElasticQuotaScope{
+					{PriorityClassName: "preemptible", Max: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("2")}},
+					{PriorityClassName: "preemptible", Max: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("4")}},
+				}
+				return eq
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "scope without priority class",
+			eq: func() *schedv1alpha1.ElasticQuota {
+				eq := makeEQ("ns1", "eq1", nil, nil)
+				eq.Spec.Scopes = []schedv1alpha1.ElasticQuotaScope{
+					{Max: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("2")}},
+				}
+				return eq
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "negative scoped max",
+			eq: func() *schedv1alpha1.ElasticQuota {
+				eq := makeEQ("ns1", "eq1", nil, nil)
+				eq.Spec.Scopes = []schedv1alpha1.ElasticQuotaScope{
+					{PriorityClassName: "preemptible", Max: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("-1")}},
+				}
+				return eq
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "parent chain looping back to the elastic quota",
+			eq:   withParent(makeEQ("ns1", "eq1", nil, nil), "ns3", "eq3"),
+			existing: []runtime.Object{
+				withParent(makeEQ("ns2", "eq2", nil, nil), "ns1", "eq1"),
+				withParent(makeEQ("ns3", "eq3", nil, nil), "ns2", "eq2"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "parent chain looping back to the elastic quota on update",
+			eq:   withParent(makeEQ("ns1", "eq1", nil, nil), "ns2", "eq2"),
+			existing: []runtime.Object{
+				makeEQ("ns1", "eq1", nil, nil),
+				withParent(makeEQ("ns2", "eq2", nil, nil), "ns1", "eq1"),
+			},
+			update:  true,
+			wantErr: true,
+		},
+		{
+			name: "parent chain of a loop the elastic quota is not part of",
+			eq:   withParent(makeEQ("ns1", "eq1", nil, nil), "ns2", "eq2"),
+			existing: []runtime.Object{
+				withParent(makeEQ("ns2", "eq2", nil, nil), "ns3", "eq3"),
+				withParent(makeEQ("ns3", "eq3", nil, nil), "ns2", "eq2"),
+			},
+		},
+		{
+			name: "namespace selector selecting the namespace of another elastic quota",
+			eq:   withSelector(makeEQ("team1", "eq1", nil, nil), "team1"),
+			existing: []runtime.Object{
+				makeNamespace("ns1", "team1"), makeNamespace("ns2", "team2"),
+				makeEQ("ns1", "eq1", nil, nil),
+			},
+			wantErr: true,
+		},
+		{
+			name: "namespace selector overlapping the selector of another elastic quota on update",
+			eq:   withSelector(makeEQ("team1", "eq1", nil, nil), "team2"),
+			existing: []runtime.Object{
+				makeNamespace("ns1", "team1"), makeNamespace("ns2", "team2"),
+				withSelector(makeEQ("team1", "eq1", nil, nil), "team1"),
+				withSelector(makeEQ("team2", "eq2", nil, nil), "team2"),
+			},
+			update:  true,
+			wantErr: true,
+		},
+		{
+			name: "namespace selectors selecting distinct namespaces",
+			eq:   withSelector(makeEQ("team1", "eq1", nil, nil), "team1"),
+			existing: []runtime.Object{
+				makeNamespace("ns1", "team1"), makeNamespace("ns2", "team2"),
+				withSelector(makeEQ("team2", "eq2", nil, nil), "team2"),
+				makeEQ("ns3", "eq3", nil, nil),
+			},
+		},
+		{
+			name:         "sum of min exceeds the capacity with the Warn policy",
+			eq:           makeEQ("ns1", "eq1", makeResourceList("3", "1Gi"), nil),
+			existing:     []runtime.Object{makeEQ("ns2", "eq2", makeResourceList("2", "1Gi"), nil)},
+			policy:       MinCapacityWarn,
+			wantWarnings: 1,
+		},
+		{
+			name:     "sum of min exceeds the capacity with the Deny policy",
+			eq:       makeEQ("ns1", "eq1", makeResourceList("3", "1Gi"), nil),
+			existing: []runtime.Object{makeEQ("ns2", "eq2", makeResourceList("2", "1Gi"), nil)},
+			policy:   MinCapacityDeny,
+			wantErr:  true,
+		},
+		{
+			name:     "sum of min exceeds the capacity with the Ignore policy",
+			eq:       makeEQ("ns1", "eq1", makeResourceList("3", "1Gi"), nil),
+			existing: []runtime.Object{makeEQ("ns2", "eq2", makeResourceList("2", "1Gi"), nil)},
+			policy:   MinCapacityIgnore,
+		},
+		{
+			name:     "updated min replaces the previous one in the sum",
+			eq:       makeEQ("ns1", "eq1", makeResourceList("4", "1Gi"), nil),
+			existing: []runtime.Object{makeEQ("ns1", "eq1", makeResourceList("3", "1Gi"), nil)},
+			policy:   MinCapacityDeny,
+			update:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(s)
+			_ = schedv1alpha1.AddToScheme(s)
+			objs := append([]runtime.Object{node}, tt.existing...)
+			w := &ElasticQuotaWebhook{
+				Reader:            fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build(),
+				MinCapacityPolicy: tt.policy,
+			}
+
+			var err error
+			var warnings []string
+			if tt.update {
+				warnings, err = w.ValidateUpdate(ctx, tt.eq, tt.eq)
+			} else {
+				warnings, err = w.ValidateCreate(ctx, tt.eq)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("want %d warnings, got %v", tt.wantWarnings, warnings)
+			}
+		})
+	}
+}
+
+func makeEQ(namespace, name string, min, max v1.ResourceList) *schedv1alpha1.ElasticQuota {
+	return &schedv1alpha1.ElasticQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       schedv1alpha1.ElasticQuotaSpec{Min: min, Max: max},
+	}
+}
+
+func withParent(eq *schedv1alpha1.ElasticQuota, namespace, name string) *schedv1alpha1.ElasticQuota {
+	eq.Spec.Parent = &schedv1alpha1.ElasticQuotaReference{Namespace: namespace, Name: name}
+	return eq
+}
+
+func withSelector(eq *schedv1alpha1.ElasticQuota, team string) *schedv1alpha1.ElasticQuota {
+	eq.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": team}}
+	return eq
+}
+
+func makeNamespace(name, team string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": team}}}
+}
+
+func makeResourceList(cpu, mem string) v1.ResourceList {
+	return v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(mem),
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"sigs.k8s.io/scheduler-plugins/apis/scheduling"
+	schedv1alpha1 "sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+)
+
+var podGroupKind = schema.GroupKind{Group: scheduling.GroupName, Kind: "PodGroup"}
+
+// PodGroupWebhook defaults and validates PodGroups.
+type PodGroupWebhook struct{}
+
+var _ admission.CustomDefaulter = &PodGroupWebhook{}
+var _ admission.CustomValidator = &PodGroupWebhook{}
+
+// +kubebuilder:webhook:path=/mutate-scheduling-x-k8s-io-v1alpha1-podgroup,mutating=true,failurePolicy=fail,sideEffects=None,groups=scheduling.x-k8s.io,resources=podgroups,verbs=create;update,versions=v1alpha1,name=mpodgroup.scheduling.x-k8s.io,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-scheduling-x-k8s-io-v1alpha1-podgroup,mutating=false,failurePolicy=fail,sideEffects=None,groups=scheduling.x-k8s.io,resources=podgroups,verbs=create;update,versions=v1alpha1,name=vpodgroup.scheduling.x-k8s.io,admissionReviewVersions=v1
+
+// SetupWithManager registers the webhook with the Manager.
+func (w *PodGroupWebhook) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&schedv1alpha1.PodGroup{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+// Default sets the mode of the topology constraint of the PodGroup to Required, if unset.
+func (w *PodGroupWebhook) Default(_ context.Context, obj runtime.Object) error {
+	pg, ok := obj.(*schedv1alpha1.PodGroup)
+	if !ok {
+		return fmt.Errorf("expected a PodGroup but got a %T", obj)
+	}
+	if pg.Spec.TopologyConstraint != nil && pg.Spec.TopologyConstraint.Mode == "" {
+		pg.Spec.TopologyConstraint.Mode = schedv1alpha1.TopologyConstraintRequired
+	}
+	return nil
+}
+
+// ValidateCreate validates the PodGroup.
+func (w *PodGroupWebhook) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, validatePodGroup(obj)
+}
+
+// ValidateUpdate validates the updated PodGroup.
+func (w *PodGroupWebhook) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, validatePodGroup(newObj)
+}
+
+// ValidateDelete allows the deletion of any PodGroup.
+func (w *PodGroupWebhook) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validatePodGroup checks that the PodGroup requires at least one member, unless it's controlled by
+// a workload, that its maxMember is not lower than its minMember, that its roles don't require more
+// members than its minMember, and that its quantities, timeout, topology constraint and roles are valid.
+func validatePodGroup(obj runtime.Object) error {
+	pg, ok := obj.(*schedv1alpha1.PodGroup)
+	if !ok {
+		return fmt.Errorf("expected a PodGroup but got a %T", obj)
+	}
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	// The PodGroups of workloads scaled to zero are kept with no member by the controller.
+	if pg.Spec.MinMember < 0 || (pg.Spec.MinMember == 0 && metav1.GetControllerOf(pg) == nil) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("minMember"), pg.Spec.MinMember, "must be greater than or equal to 1"))
+	}
+	if pg.Spec.MaxMember != nil && *pg.Spec.MaxMember < pg.Spec.MinMember {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("maxMember"), *pg.Spec.MaxMember,
+			fmt.Sprintf("must be greater than or equal to minMember %d", pg.Spec.MinMember)))
+	}
+	allErrs = append(allErrs, validateNonNegative(pg.Spec.MinResources, specPath.Child("minResources"))...)
+	if pg.Spec.ScheduleTimeoutSeconds != nil && *pg.Spec.ScheduleTimeoutSeconds <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("scheduleTimeoutSeconds"), *pg.Spec.ScheduleTimeoutSeconds, "must be greater than 0"))
+	}
+	if tc := pg.Spec.TopologyConstraint; tc != nil {
+		tcPath := specPath.Child("topologyConstraint")
+		if tc.TopologyKey == "" {
+			allErrs = append(allErrs, field.Required(tcPath.Child("topologyKey"), ""))
+		}
+		if tc.Mode != "" && tc.Mode != schedv1alpha1.TopologyConstraintRequired && tc.Mode != schedv1alpha1.TopologyConstraintPreferred {
+			allErrs = append(allErrs, field.NotSupported(tcPath.Child("mode"), tc.Mode,
+				[]string{string(schedv1alpha1.TopologyConstraintRequired), string(schedv1alpha1.TopologyConstraintPreferred)}))
+		}
+	}
+	names := make(map[string]bool, len(pg.Spec.Roles))
+	var rolesMinMember int32
+	for i, role := range pg.Spec.Roles {
+		rolePath := specPath.Child("roles").Index(i)
+		if role.Name == "" {
+			allErrs = append(allErrs, field.Required(rolePath.Child("name"), ""))
+		} else if names[role.Name] {
+			allErrs = append(allErrs, field.Duplicate(rolePath.Child("name"), role.Name))
+		}
+		names[role.Name] = true
+		if role.MinMember < 0 {
+			allErrs = append(allErrs, field.Invalid(rolePath.Child("minMember"), role.MinMember, "must be greater than or equal to 0"))
+		} else {
+			rolesMinMember += role.MinMember
+		}
+		if role.Selector == nil {
+			allErrs = append(allErrs, field.Required(rolePath.Child("selector"), ""))
+		} else if _, err := metav1.LabelSelectorAsSelector(role.Selector); err != nil {
+			allErrs = append(allErrs, field.Invalid(rolePath.Child("selector"), role.Selector, err.Error()))
+		}
+		allErrs = append(allErrs, validateNonNegative(role.MinResources, rolePath.Child("minResources"))...)
+	}
+	// The members of the roles are members of the PodGroup, so the roles can't require more than minMember.
+	if rolesMinMember > pg.Spec.MinMember {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("roles"), rolesMinMember,
+			fmt.Sprintf("the sum of the minMember of the roles must be lower than or equal to minMember %d", pg.Spec.MinMember)))
+	}
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(podGroupKind, pg.Name, allErrs)
+	}
+	return nil
+}
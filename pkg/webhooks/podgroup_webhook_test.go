@@ -0,0 +1,164 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	schedv1alpha1 "sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+)
+
+func TestPodGroupDefault(t *testing.T) {
+	pg := makePG(2)
+	pg.Spec.TopologyConstraint = &schedv1alpha1.TopologyConstraint{TopologyKey: "topology.kubernetes.io/zone"}
+	if err := (&PodGroupWebhook{}).Default(context.TODO(), pg); err != nil {
+		t.Fatal(err)
+	}
+	if pg.Spec.TopologyConstraint.Mode != schedv1alpha1.TopologyConstraintRequired {
+		t.Errorf("want mode Required, got %q", pg.Spec.TopologyConstraint.Mode)
+	}
+}
+
+func TestPodGroupValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		pg      *schedv1alpha1.PodGroup
+		wantErr bool
+	}{
+		{
+			name: "valid pod group",
+			pg:   makePG(2),
+		},
+		{
+			name:    "zero minMember",
+			pg:      makePG(0),
+			wantErr: true,
+		},
+		{
+			name: "zero minMember of a workload scaled to zero",
+			pg: func() *schedv1alpha1.PodGroup {
+				pg := makePG(0)
+				pg.OwnerReferences = []metav1.OwnerReference{{
+					APIVersion: "apps/v1", Kind: "StatefulSet", Name: "sts", UID: "sts", Controller: pointer.Bool(true),
+				}}
+				return pg
+			}(),
+		},
+		{
+			name: "maxMember lower than minMember",
+			pg: func() *schedv1alpha1.PodGroup {
+				pg := makePG(3)
+				pg.Spec.MaxMember = pointer.Int32(2)
+				return pg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "negative minResources",
+			pg: func() *schedv1alpha1.PodGroup {
+				pg := makePG(2)
+				pg.Spec.MinResources = makeResourceList("-1", "1Gi")
+				return pg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "zero scheduleTimeoutSeconds",
+			pg: func() *schedv1alpha1.PodGroup {
+				pg := makePG(2)
+				pg.Spec.ScheduleTimeoutSeconds = pointer.Int32(0)
+				return pg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "topology constraint without key",
+			pg: func() *schedv1alpha1.PodGroup {
+				pg := makePG(2)
+				pg.Spec.TopologyConstraint = &schedv1alpha1.TopologyConstraint{Mode: schedv1alpha1.TopologyConstraintPreferred}
+				return pg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "duplicate role names",
+			pg: func() *schedv1alpha1.PodGroup {
+				pg := makePG(2)
+				selector := &metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}}
+				pg.Spec.Roles = []schedv1alpha1.PodGroupRole{
+					{Name: "worker", Selector: selector, MinMember: 1},
+					{Name: "worker", Selector: selector, MinMember: 1},
+				}
+				return pg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "roles within minMember",
+			pg: func() *schedv1alpha1.PodGroup {
+				pg := makePG(3)
+				pg.Spec.Roles = []schedv1alpha1.PodGroupRole{
+					{Name: "ps", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "ps"}}, MinMember: 1},
+					{Name: "worker", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}}, MinMember: 2},
+				}
+				return pg
+			}(),
+		},
+		{
+			name: "roles requiring more than minMember",
+			pg: func() *schedv1alpha1.PodGroup {
+				pg := makePG(2)
+				pg.Spec.Roles = []schedv1alpha1.PodGroupRole{
+					{Name: "ps", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "ps"}}, MinMember: 1},
+					{Name: "worker", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}}, MinMember: 2},
+				}
+				return pg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "negative role minMember",
+			pg: func() *schedv1alpha1.PodGroup {
+				pg := makePG(2)
+				pg.Spec.Roles = []schedv1alpha1.PodGroupRole{
+					{Name: "worker", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}}, MinMember: -1},
+				}
+				return pg
+			}(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&PodGroupWebhook{}).ValidateCreate(context.TODO(), tt.pg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func makePG(minMember int32) *schedv1alpha1.PodGroup {
+	return &schedv1alpha1.PodGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: "pg", Namespace: "ns1"},
+		Spec:       schedv1alpha1.PodGroupSpec{MinMember: minMember},
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package util
 
 import (
+	"slices"
+	"sort"
 	"sync"
 
 	v1 "k8s.io/api/core/v1"
@@ -30,6 +32,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
+	fakepgclientset "sigs.k8s.io/scheduler-plugins/pkg/generated/clientset/versioned/fake"
+	pgformers "sigs.k8s.io/scheduler-plugins/pkg/generated/informers/externalversions"
+	pginformer "sigs.k8s.io/scheduler-plugins/pkg/generated/informers/externalversions/scheduling/v1alpha1"
 
 	topologyv1alpha2 "github.com/k8stopologyawareschedwg/noderesourcetopology-api/pkg/apis/topology/v1alpha2"
 )
@@ -45,10 +50,26 @@ type fakeSharedLister struct {
 
 func NewFakeSharedLister(pods []*v1.Pod, nodes []*v1.Node) framework.SharedLister {
 	nodeInfoMap := createNodeInfoMap(pods, nodes)
+	// The nodes are listed in the given order, followed by the nodes of the pods only, sorted by
+	// name, so that the tests don't depend on the iteration order of the map.
+	names := make([]string, 0, len(nodeInfoMap))
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	var podNodeNames []string
+	for name := range nodeInfoMap {
+		if !slices.Contains(names, name) {
+			podNodeNames = append(podNodeNames, name)
+		}
+	}
+	sort.Strings(podNodeNames)
+	names = append(names, podNodeNames...)
+
 	nodeInfos := make([]*framework.NodeInfo, 0, len(nodeInfoMap))
 	havePodsWithAffinityNodeInfoList := make([]*framework.NodeInfo, 0, len(nodeInfoMap))
 	havePodsWithRequiredAntiAffinityNodeInfoList := make([]*framework.NodeInfo, 0, len(nodeInfoMap))
-	for _, v := range nodeInfoMap {
+	for _, name := range names {
+		v := nodeInfoMap[name]
 		nodeInfos = append(nodeInfos, v)
 		if len(v.PodsWithAffinity) > 0 {
 			havePodsWithAffinityNodeInfoList = append(havePodsWithAffinityNodeInfoList, v)
@@ -285,7 +306,18 @@ func NewFakeClient(objs ...runtime.Object) (client.Client, error) {
 	if err := topologyv1alpha2.AddToScheme(scheme); err != nil {
 		return nil, err
 	}
-	return fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build(), nil
+	return fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&v1alpha1.PodGroup{}).WithRuntimeObjects(objs...).Build(), nil
+}
+
+// NewFakePodGroupInformer returns a PodGroup informer, whose cache holds all given `pgs`.
+// This function is used by unit tests.
+func NewFakePodGroupInformer(pgs ...*v1alpha1.PodGroup) pginformer.PodGroupInformer {
+	pgInformerFactory := pgformers.NewSharedInformerFactory(fakepgclientset.NewSimpleClientset(), 0)
+	pgInformer := pgInformerFactory.Scheduling().V1alpha1().PodGroups()
+	for _, pg := range pgs {
+		pgInformer.Informer().GetStore().Add(pg)
+	}
+	return pgInformer
 }
 
 // NewClientOrDie returns a generic controller-runtime client or panic upon any error.
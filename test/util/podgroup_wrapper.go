@@ -21,6 +21,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"sigs.k8s.io/scheduler-plugins/apis/scheduling/v1alpha1"
 )
@@ -45,16 +46,35 @@ func (p *PodGroupWrapper) Namespace(s string) *PodGroupWrapper {
 	return p
 }
 
+func (p *PodGroupWrapper) Label(k, v string) *PodGroupWrapper {
+	if p.Labels == nil {
+		p.Labels = map[string]string{}
+	}
+	p.Labels[k] = v
+	return p
+}
+
 func (p *PodGroupWrapper) MinMember(i int32) *PodGroupWrapper {
 	p.Spec.MinMember = i
 	return p
 }
 
+func (p *PodGroupWrapper) MaxMember(i int32) *PodGroupWrapper {
+	p.Spec.MaxMember = &i
+	return p
+}
+
 func (p *PodGroupWrapper) Time(t time.Time) *PodGroupWrapper {
 	p.CreationTimestamp.Time = t
 	return p
 }
 
+func (p *PodGroupWrapper) Backoff(count int32, until time.Time) *PodGroupWrapper {
+	p.Status.BackoffCount = count
+	p.Status.BackoffUntil = &metav1.Time{Time: until}
+	return p
+}
+
 func (p *PodGroupWrapper) MinResources(resources map[v1.ResourceName]string) *PodGroupWrapper {
 	res := make(v1.ResourceList)
 	for name, value := range resources {
@@ -68,3 +88,24 @@ func (p *PodGroupWrapper) Phase(phase v1alpha1.PodGroupPhase) *PodGroupWrapper {
 	p.Status.Phase = phase
 	return p
 }
+
+func (p *PodGroupWrapper) TopologyConstraint(key string, mode v1alpha1.TopologyConstraintMode) *PodGroupWrapper {
+	p.Spec.TopologyConstraint = &v1alpha1.TopologyConstraint{TopologyKey: key, Mode: mode}
+	return p
+}
+
+func (p *PodGroupWrapper) Role(name string, matchLabels map[string]string, minMember int32, resources map[v1.ResourceName]string) *PodGroupWrapper {
+	role := v1alpha1.PodGroupRole{
+		Name:      name,
+		Selector:  &metav1.LabelSelector{MatchLabels: matchLabels},
+		MinMember: minMember,
+	}
+	if resources != nil {
+		role.MinResources = make(v1.ResourceList)
+		for name, value := range resources {
+			role.MinResources[name] = resource.MustParse(value)
+		}
+	}
+	p.Spec.Roles = append(p.Spec.Roles, role)
+	return p
+}